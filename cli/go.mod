@@ -3,6 +3,7 @@ module github.com/Infisical/infisical-merge
 go 1.21
 
 require (
+	filippo.io/age v1.0.0
 	github.com/bradleyjkemp/cupaloy/v2 v2.8.0
 	github.com/charmbracelet/lipgloss v0.5.0
 	github.com/creack/pty v1.1.21
@@ -25,6 +26,7 @@ require (
 	github.com/spf13/viper v1.8.1
 	github.com/stretchr/testify v1.9.0
 	golang.org/x/crypto v0.23.0
+	golang.org/x/sys v0.20.0
 	golang.org/x/term v0.20.0
 	gopkg.in/yaml.v2 v2.4.0
 )
@@ -94,7 +96,6 @@ require (
 	golang.org/x/net v0.25.0 // indirect
 	golang.org/x/oauth2 v0.21.0 // indirect
 	golang.org/x/sync v0.7.0 // indirect
-	golang.org/x/sys v0.20.0 // indirect
 	golang.org/x/text v0.15.0 // indirect
 	golang.org/x/time v0.5.0 // indirect
 	google.golang.org/api v0.183.0 // indirect
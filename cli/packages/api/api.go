@@ -1,6 +1,7 @@
 package api
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
@@ -370,6 +371,27 @@ func CallDeleteFolderV1(httpClient *resty.Client, request DeleteFolderV1Request)
 	return folderResponse, nil
 }
 
+func CallUpdateFolderV1(httpClient *resty.Client, request UpdateFolderV1Request) (UpdateFolderV1Response, error) {
+	var folderResponse UpdateFolderV1Response
+
+	httpRequest := httpClient.
+		R().
+		SetResult(&folderResponse).
+		SetHeader("User-Agent", USER_AGENT).
+		SetBody(request)
+
+	response, err := httpRequest.Patch(fmt.Sprintf("%v/v1/folders/%v", config.INFISICAL_URL, request.FolderId))
+	if err != nil {
+		return UpdateFolderV1Response{}, fmt.Errorf("CallUpdateFolderV1: Unable to complete api request [err=%s]", err)
+	}
+
+	if response.IsError() {
+		return UpdateFolderV1Response{}, fmt.Errorf("CallUpdateFolderV1: Unsuccessful [response=%s]", response.String())
+	}
+
+	return folderResponse, nil
+}
+
 func CallCreateSecretsV3(httpClient *resty.Client, request CreateSecretV3Request) error {
 	var secretsResponse GetEncryptedSecretsV3Response
 	response, err := httpClient.
@@ -568,6 +590,63 @@ func CallCreateDynamicSecretLeaseV1(httpClient *resty.Client, request CreateDyna
 	return createDynamicSecretLeaseResponse, nil
 }
 
+func CallRenewDynamicSecretLeaseV1(httpClient *resty.Client, request RenewDynamicSecretLeaseV1Request) (RenewDynamicSecretLeaseV1Response, error) {
+	var renewDynamicSecretLeaseResponse RenewDynamicSecretLeaseV1Response
+	response, err := httpClient.
+		R().
+		SetResult(&renewDynamicSecretLeaseResponse).
+		SetHeader("User-Agent", USER_AGENT).
+		SetBody(request).
+		Post(fmt.Sprintf("%v/v1/dynamic-secrets/leases/%s/renew", config.INFISICAL_URL, request.LeaseId))
+
+	if err != nil {
+		return RenewDynamicSecretLeaseV1Response{}, fmt.Errorf("CallRenewDynamicSecretLeaseV1: Unable to complete api request [err=%w]", err)
+	}
+
+	if response.IsError() {
+		return RenewDynamicSecretLeaseV1Response{}, fmt.Errorf("CallRenewDynamicSecretLeaseV1: Unsuccessful response [%v %v] [status-code=%v] [response=%v]", response.Request.Method, response.Request.URL, response.StatusCode(), response.String())
+	}
+
+	return renewDynamicSecretLeaseResponse, nil
+}
+
+func CallRevokeDynamicSecretLeaseV1(httpClient *resty.Client, request RevokeDynamicSecretLeaseV1Request) error {
+	response, err := httpClient.
+		R().
+		SetHeader("User-Agent", USER_AGENT).
+		SetBody(request).
+		Delete(fmt.Sprintf("%v/v1/dynamic-secrets/leases/%s", config.INFISICAL_URL, request.LeaseId))
+
+	if err != nil {
+		return fmt.Errorf("CallRevokeDynamicSecretLeaseV1: Unable to complete api request [err=%w]", err)
+	}
+
+	if response.IsError() {
+		return fmt.Errorf("CallRevokeDynamicSecretLeaseV1: Unsuccessful response [%v %v] [status-code=%v] [response=%v]", response.Request.Method, response.Request.URL, response.StatusCode(), response.String())
+	}
+
+	return nil
+}
+
+func CallGetProjectByIdV1(httpClient *resty.Client, request GetProjectByIdV1Request) (GetProjectByIdV1Response, error) {
+	var projectResponse GetProjectByIdV1Response
+	response, err := httpClient.
+		R().
+		SetResult(&projectResponse).
+		SetHeader("User-Agent", USER_AGENT).
+		Get(fmt.Sprintf("%v/v1/workspace/%s", config.INFISICAL_URL, request.ProjectId))
+
+	if err != nil {
+		return GetProjectByIdV1Response{}, fmt.Errorf("CallGetProjectByIdV1: Unable to complete api request [err=%w]", err)
+	}
+
+	if response.IsError() {
+		return GetProjectByIdV1Response{}, fmt.Errorf("CallGetProjectByIdV1: Unsuccessful response [%v %v] [status-code=%v] [response=%v]", response.Request.Method, response.Request.URL, response.StatusCode(), response.String())
+	}
+
+	return projectResponse, nil
+}
+
 func CallCreateRawSecretsV3(httpClient *resty.Client, request CreateRawSecretV3Request) error {
 	response, err := httpClient.
 		R().
@@ -603,3 +682,283 @@ func CallUpdateRawSecretsV3(httpClient *resty.Client, request UpdateRawSecretByN
 
 	return nil
 }
+
+func CallDeleteRawSecretsV3(httpClient *resty.Client, request DeleteRawSecretV3Request) error {
+	response, err := httpClient.
+		R().
+		SetHeader("User-Agent", USER_AGENT).
+		SetBody(request).
+		Delete(fmt.Sprintf("%v/v3/secrets/raw/%s", config.INFISICAL_URL, request.SecretName))
+
+	if err != nil {
+		return fmt.Errorf("CallDeleteRawSecretsV3: Unable to complete api request [err=%w]", err)
+	}
+
+	if response.IsError() {
+		return fmt.Errorf("CallDeleteRawSecretsV3: Unsuccessful response [%v %v] [status-code=%v] [response=%v]", response.Request.Method, response.Request.URL, response.StatusCode(), response.String())
+	}
+
+	return nil
+}
+
+// ErrBatchNotSupported is returned by the batch calls when the Infisical instance does not have the batch endpoints yet
+var ErrBatchNotSupported = errors.New("the batch endpoints are not supported by this Infisical instance")
+
+func CallCreateRawSecretsBatchV3(httpClient *resty.Client, request BatchRawSecretsV3Request) error {
+	return callRawSecretsBatchV3(httpClient, http.MethodPost, request, "CallCreateRawSecretsBatchV3")
+}
+
+func CallUpdateRawSecretsBatchV3(httpClient *resty.Client, request BatchRawSecretsV3Request) error {
+	return callRawSecretsBatchV3(httpClient, http.MethodPatch, request, "CallUpdateRawSecretsBatchV3")
+}
+
+func CallDeleteRawSecretsBatchV3(httpClient *resty.Client, request BatchRawSecretsV3Request) error {
+	return callRawSecretsBatchV3(httpClient, http.MethodDelete, request, "CallDeleteRawSecretsBatchV3")
+}
+
+func callRawSecretsBatchV3(httpClient *resty.Client, method string, request BatchRawSecretsV3Request, caller string) error {
+	response, err := httpClient.
+		R().
+		SetHeader("User-Agent", USER_AGENT).
+		SetBody(request).
+		Execute(method, fmt.Sprintf("%v/v3/secrets/batch/raw", config.INFISICAL_URL))
+
+	if err != nil {
+		return fmt.Errorf("%s: Unable to complete api request [err=%w]", caller, err)
+	}
+
+	// instances without the batch endpoints answer with the route not found error of the router
+	if response.StatusCode() == http.StatusNotFound && strings.Contains(response.String(), "Route "+method) {
+		return ErrBatchNotSupported
+	}
+
+	if response.IsError() {
+		return fmt.Errorf("%s: Unsuccessful response [%v %v] [status-code=%v] [response=%v]", caller, response.Request.Method, response.Request.URL, response.StatusCode(), response.String())
+	}
+
+	return nil
+}
+
+func CallAttachSecretTagsV3(httpClient *resty.Client, request SecretTagsV3Request) error {
+	response, err := httpClient.
+		R().
+		SetHeader("User-Agent", USER_AGENT).
+		SetBody(request).
+		Post(fmt.Sprintf("%v/v3/secrets/tags/%s", config.INFISICAL_URL, request.SecretName))
+
+	if err != nil {
+		return fmt.Errorf("CallAttachSecretTagsV3: Unable to complete api request [err=%w]", err)
+	}
+
+	if response.IsError() {
+		return fmt.Errorf("CallAttachSecretTagsV3: Unsuccessful response [%v %v] [status-code=%v] [response=%v]", response.Request.Method, response.Request.URL, response.StatusCode(), response.String())
+	}
+
+	return nil
+}
+
+func CallDetachSecretTagsV3(httpClient *resty.Client, request SecretTagsV3Request) error {
+	response, err := httpClient.
+		R().
+		SetHeader("User-Agent", USER_AGENT).
+		SetBody(request).
+		Delete(fmt.Sprintf("%v/v3/secrets/tags/%s", config.INFISICAL_URL, request.SecretName))
+
+	if err != nil {
+		return fmt.Errorf("CallDetachSecretTagsV3: Unable to complete api request [err=%w]", err)
+	}
+
+	if response.IsError() {
+		return fmt.Errorf("CallDetachSecretTagsV3: Unsuccessful response [%v %v] [status-code=%v] [response=%v]", response.Request.Method, response.Request.URL, response.StatusCode(), response.String())
+	}
+
+	return nil
+}
+
+func CallGetRawSecretByNameV3(httpClient *resty.Client, request GetRawSecretByNameV3Request) (GetRawSecretByNameV3Response, error) {
+	var secretResponse GetRawSecretByNameV3Response
+	req := httpClient.
+		R().
+		SetResult(&secretResponse).
+		SetHeader("User-Agent", USER_AGENT).
+		SetQueryParam("environment", request.Environment).
+		SetQueryParam("secretPath", request.SecretPath)
+
+	if request.WorkspaceId != "" {
+		req.SetQueryParam("workspaceId", request.WorkspaceId)
+	}
+
+	if request.Type != "" {
+		req.SetQueryParam("type", request.Type)
+	}
+
+	response, err := req.Get(fmt.Sprintf("%v/v3/secrets/raw/%s", config.INFISICAL_URL, request.SecretName))
+
+	if err != nil {
+		return GetRawSecretByNameV3Response{}, fmt.Errorf("CallGetRawSecretByNameV3: Unable to complete api request [err=%w]", err)
+	}
+
+	if response.IsError() {
+		return GetRawSecretByNameV3Response{}, fmt.Errorf("CallGetRawSecretByNameV3: Unsuccessful response [%v %v] [status-code=%v] [response=%v]", response.Request.Method, response.Request.URL, response.StatusCode(), response.String())
+	}
+
+	return secretResponse, nil
+}
+
+func CallGetSecretVersionsV1(httpClient *resty.Client, request GetSecretVersionsV1Request) (GetSecretVersionsV1Response, error) {
+	var secretVersionsResponse GetSecretVersionsV1Response
+	response, err := httpClient.
+		R().
+		SetResult(&secretVersionsResponse).
+		SetHeader("User-Agent", USER_AGENT).
+		SetQueryParam("offset", fmt.Sprintf("%d", request.Offset)).
+		SetQueryParam("limit", fmt.Sprintf("%d", request.Limit)).
+		Get(fmt.Sprintf("%v/v1/secret/%s/secret-versions", config.INFISICAL_URL, request.SecretId))
+
+	if err != nil {
+		return GetSecretVersionsV1Response{}, fmt.Errorf("CallGetSecretVersionsV1: Unable to complete api request [err=%w]", err)
+	}
+
+	if response.IsError() {
+		return GetSecretVersionsV1Response{}, fmt.Errorf("CallGetSecretVersionsV1: Unsuccessful response [%v %v] [status-code=%v] [response=%v]", response.Request.Method, response.Request.URL, response.StatusCode(), response.String())
+	}
+
+	return secretVersionsResponse, nil
+}
+
+func CallGetProjectUsersV1(httpClient *resty.Client, projectId string) (GetProjectUsersV1Response, error) {
+	var projectUsersResponse GetProjectUsersV1Response
+	response, err := httpClient.
+		R().
+		SetResult(&projectUsersResponse).
+		SetHeader("User-Agent", USER_AGENT).
+		Get(fmt.Sprintf("%v/v1/workspace/%s/users", config.INFISICAL_URL, projectId))
+
+	if err != nil {
+		return GetProjectUsersV1Response{}, fmt.Errorf("CallGetProjectUsersV1: Unable to complete api request [err=%w]", err)
+	}
+
+	if response.IsError() {
+		return GetProjectUsersV1Response{}, fmt.Errorf("CallGetProjectUsersV1: Unsuccessful response [%v %v] [status-code=%v] [response=%v]", response.Request.Method, response.Request.URL, response.StatusCode(), response.String())
+	}
+
+	return projectUsersResponse, nil
+}
+
+func CallGetSecretSnapshotsV1(httpClient *resty.Client, request GetSecretSnapshotsV1Request) (GetSecretSnapshotsV1Response, error) {
+	var secretSnapshotsResponse GetSecretSnapshotsV1Response
+	response, err := httpClient.
+		R().
+		SetResult(&secretSnapshotsResponse).
+		SetHeader("User-Agent", USER_AGENT).
+		SetQueryParam("environment", request.Environment).
+		SetQueryParam("path", request.Path).
+		SetQueryParam("offset", fmt.Sprintf("%d", request.Offset)).
+		SetQueryParam("limit", fmt.Sprintf("%d", request.Limit)).
+		Get(fmt.Sprintf("%v/v1/workspace/%s/secret-snapshots", config.INFISICAL_URL, request.WorkspaceId))
+
+	if err != nil {
+		return GetSecretSnapshotsV1Response{}, fmt.Errorf("CallGetSecretSnapshotsV1: Unable to complete api request [err=%w]", err)
+	}
+
+	if response.IsError() {
+		return GetSecretSnapshotsV1Response{}, fmt.Errorf("CallGetSecretSnapshotsV1: Unsuccessful response [%v %v] [status-code=%v] [response=%v]", response.Request.Method, response.Request.URL, response.StatusCode(), response.String())
+	}
+
+	return secretSnapshotsResponse, nil
+}
+
+func CallRollbackSecretSnapshotV1(httpClient *resty.Client, snapshotId string) (RollbackSecretSnapshotV1Response, error) {
+	var rollbackResponse RollbackSecretSnapshotV1Response
+	response, err := httpClient.
+		R().
+		SetResult(&rollbackResponse).
+		SetHeader("User-Agent", USER_AGENT).
+		Post(fmt.Sprintf("%v/v1/secret-snapshot/%s/rollback", config.INFISICAL_URL, snapshotId))
+
+	if err != nil {
+		return RollbackSecretSnapshotV1Response{}, fmt.Errorf("CallRollbackSecretSnapshotV1: Unable to complete api request [err=%w]", err)
+	}
+
+	if response.IsError() {
+		return RollbackSecretSnapshotV1Response{}, fmt.Errorf("CallRollbackSecretSnapshotV1: Unsuccessful response [%v %v] [status-code=%v] [response=%v]", response.Request.Method, response.Request.URL, response.StatusCode(), response.String())
+	}
+
+	return rollbackResponse, nil
+}
+
+func CallGetSecretImportsV1(httpClient *resty.Client, request GetSecretImportsV1Request) (GetSecretImportsV1Response, error) {
+	var importsResponse GetSecretImportsV1Response
+	response, err := httpClient.
+		R().
+		SetResult(&importsResponse).
+		SetHeader("User-Agent", USER_AGENT).
+		SetQueryParam("workspaceId", request.WorkspaceId).
+		SetQueryParam("environment", request.Environment).
+		SetQueryParam("path", request.Path).
+		Get(fmt.Sprintf("%v/v1/secret-imports", config.INFISICAL_URL))
+
+	if err != nil {
+		return GetSecretImportsV1Response{}, fmt.Errorf("CallGetSecretImportsV1: Unable to complete api request [err=%v]", err)
+	}
+
+	if response.IsError() {
+		return GetSecretImportsV1Response{}, fmt.Errorf("CallGetSecretImportsV1: Unsuccessful [response=%s]", response)
+	}
+
+	return importsResponse, nil
+}
+
+func CallCreateSecretImportV1(httpClient *resty.Client, request CreateSecretImportV1Request) error {
+	response, err := httpClient.
+		R().
+		SetHeader("User-Agent", USER_AGENT).
+		SetBody(request).
+		Post(fmt.Sprintf("%v/v1/secret-imports", config.INFISICAL_URL))
+
+	if err != nil {
+		return fmt.Errorf("CallCreateSecretImportV1: Unable to complete api request [err=%v]", err)
+	}
+
+	if response.IsError() {
+		return fmt.Errorf("CallCreateSecretImportV1: Unsuccessful [response=%s]", response)
+	}
+
+	return nil
+}
+
+func CallUpdateSecretImportV1(httpClient *resty.Client, request UpdateSecretImportV1Request) error {
+	response, err := httpClient.
+		R().
+		SetHeader("User-Agent", USER_AGENT).
+		SetBody(request).
+		Patch(fmt.Sprintf("%v/v1/secret-imports/%v", config.INFISICAL_URL, request.SecretImportId))
+
+	if err != nil {
+		return fmt.Errorf("CallUpdateSecretImportV1: Unable to complete api request [err=%v]", err)
+	}
+
+	if response.IsError() {
+		return fmt.Errorf("CallUpdateSecretImportV1: Unsuccessful [response=%s]", response)
+	}
+
+	return nil
+}
+
+func CallDeleteSecretImportV1(httpClient *resty.Client, request DeleteSecretImportV1Request) error {
+	response, err := httpClient.
+		R().
+		SetHeader("User-Agent", USER_AGENT).
+		SetBody(request).
+		Delete(fmt.Sprintf("%v/v1/secret-imports/%v", config.INFISICAL_URL, request.SecretImportId))
+
+	if err != nil {
+		return fmt.Errorf("CallDeleteSecretImportV1: Unable to complete api request [err=%v]", err)
+	}
+
+	if response.IsError() {
+		return fmt.Errorf("CallDeleteSecretImportV1: Unsuccessful [response=%s]", response)
+	}
+
+	return nil
+}
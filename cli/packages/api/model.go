@@ -1,6 +1,10 @@
 package api
 
-import "time"
+import (
+	"time"
+
+	"github.com/Infisical/infisical-merge/packages/models"
+)
 
 // Stores info for login one
 type LoginOneRequest struct {
@@ -344,33 +348,45 @@ type DeleteFolderV1Response struct {
 	} `json:"folders"`
 }
 
+type UpdateFolderV1Request struct {
+	FolderId    string `json:"-"`
+	Name        string `json:"name"`
+	WorkspaceId string `json:"workspaceId"`
+	Environment string `json:"environment"`
+	// the path of the parent folder
+	Path string `json:"path"`
+}
+
+type UpdateFolderV1Response struct {
+	Folder struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"folder"`
+}
+
 type EncryptedSecretV3 struct {
-	ID        string `json:"_id"`
-	Version   int    `json:"version"`
-	Workspace string `json:"workspace"`
-	Type      string `json:"type"`
-	Tags      []struct {
-		ID        string `json:"_id"`
-		Name      string `json:"name"`
-		Slug      string `json:"slug"`
-		Workspace string `json:"workspace"`
-	} `json:"tags"`
-	Environment             string    `json:"environment"`
-	SecretKeyCiphertext     string    `json:"secretKeyCiphertext"`
-	SecretKeyIV             string    `json:"secretKeyIV"`
-	SecretKeyTag            string    `json:"secretKeyTag"`
-	SecretValueCiphertext   string    `json:"secretValueCiphertext"`
-	SecretValueIV           string    `json:"secretValueIV"`
-	SecretValueTag          string    `json:"secretValueTag"`
-	SecretCommentCiphertext string    `json:"secretCommentCiphertext"`
-	SecretCommentIV         string    `json:"secretCommentIV"`
-	SecretCommentTag        string    `json:"secretCommentTag"`
-	Algorithm               string    `json:"algorithm"`
-	KeyEncoding             string    `json:"keyEncoding"`
-	Folder                  string    `json:"folder"`
-	V                       int       `json:"__v"`
-	CreatedAt               time.Time `json:"createdAt"`
-	UpdatedAt               time.Time `json:"updatedAt"`
+	ID                      string             `json:"_id"`
+	Version                 int                `json:"version"`
+	Workspace               string             `json:"workspace"`
+	Type                    string             `json:"type"`
+	Tags                    []models.SecretTag `json:"tags"`
+	Environment             string             `json:"environment"`
+	SecretKeyCiphertext     string             `json:"secretKeyCiphertext"`
+	SecretKeyIV             string             `json:"secretKeyIV"`
+	SecretKeyTag            string             `json:"secretKeyTag"`
+	SecretValueCiphertext   string             `json:"secretValueCiphertext"`
+	SecretValueIV           string             `json:"secretValueIV"`
+	SecretValueTag          string             `json:"secretValueTag"`
+	SecretCommentCiphertext string             `json:"secretCommentCiphertext"`
+	SecretCommentIV         string             `json:"secretCommentIV"`
+	SecretCommentTag        string             `json:"secretCommentTag"`
+	Algorithm               string             `json:"algorithm"`
+	KeyEncoding             string             `json:"keyEncoding"`
+	Folder                  string             `json:"folder"`
+	SecretPath              string             `json:"secretPath"`
+	V                       int                `json:"__v"`
+	CreatedAt               time.Time          `json:"createdAt"`
+	UpdatedAt               time.Time          `json:"updatedAt"`
 }
 
 type ImportedSecretV3 struct {
@@ -438,13 +454,18 @@ type DeleteSecretV3Request struct {
 }
 
 type UpdateSecretByNameV3Request struct {
-	WorkspaceID           string `json:"workspaceId"`
-	Environment           string `json:"environment"`
-	Type                  string `json:"type"`
-	SecretPath            string `json:"secretPath"`
-	SecretValueCiphertext string `json:"secretValueCiphertext"`
-	SecretValueIV         string `json:"secretValueIV"`
-	SecretValueTag        string `json:"secretValueTag"`
+	WorkspaceID             string `json:"workspaceId"`
+	Environment             string `json:"environment"`
+	Type                    string `json:"type"`
+	SecretPath              string `json:"secretPath"`
+	SecretValueCiphertext   string `json:"secretValueCiphertext"`
+	SecretValueIV           string `json:"secretValueIV"`
+	SecretValueTag          string `json:"secretValueTag"`
+	SecretCommentCiphertext string `json:"secretCommentCiphertext,omitempty"`
+	SecretCommentIV         string `json:"secretCommentIV,omitempty"`
+	SecretCommentTag        string `json:"secretCommentTag,omitempty"`
+	// the IDs of all tags of the secret, nil keeps the current tags
+	Tags *[]string `json:"tags,omitempty"`
 }
 
 type UpdateRawSecretByNameV3Request struct {
@@ -456,6 +477,27 @@ type UpdateRawSecretByNameV3Request struct {
 	Type        string `json:"type,omitempty"`
 }
 
+type DeleteRawSecretV3Request struct {
+	SecretName  string `json:"-"`
+	WorkspaceID string `json:"workspaceId,omitempty"`
+	Environment string `json:"environment"`
+	SecretPath  string `json:"secretPath,omitempty"`
+	Type        string `json:"type,omitempty"`
+}
+
+type BatchRawSecretV3 struct {
+	SecretKey     string `json:"secretKey"`
+	SecretValue   string `json:"secretValue"`
+	SecretComment string `json:"secretComment,omitempty"`
+}
+
+type BatchRawSecretsV3Request struct {
+	ProjectSlug string             `json:"projectSlug"`
+	Environment string             `json:"environment"`
+	SecretPath  string             `json:"secretPath,omitempty"`
+	Secrets     []BatchRawSecretV3 `json:"secrets"`
+}
+
 type GetSingleSecretByNameV3Request struct {
 	SecretName  string `json:"secretName"`
 	WorkspaceId string `json:"workspaceId"`
@@ -547,10 +589,10 @@ type UniversalAuthRefreshResponse struct {
 }
 
 type CreateDynamicSecretLeaseV1Request struct {
-	Environment string `json:"environment"`
+	Environment string `json:"environmentSlug"`
 	ProjectSlug string `json:"projectSlug"`
-	SecretPath  string `json:"secretPath,omitempty"`
-	Slug        string `json:"slug"`
+	SecretPath  string `json:"path,omitempty"`
+	Slug        string `json:"dynamicSecretName"`
 	TTL         string `json:"ttl,omitempty"`
 }
 
@@ -568,6 +610,40 @@ type CreateDynamicSecretLeaseV1Response struct {
 	Data map[string]interface{} `json:"data"`
 }
 
+type RenewDynamicSecretLeaseV1Request struct {
+	LeaseId     string `json:"-"`
+	Environment string `json:"environmentSlug"`
+	ProjectSlug string `json:"projectSlug"`
+	SecretPath  string `json:"path,omitempty"`
+	TTL         string `json:"ttl,omitempty"`
+}
+
+type RenewDynamicSecretLeaseV1Response struct {
+	Lease struct {
+		Id       string    `json:"id"`
+		ExpireAt time.Time `json:"expireAt"`
+	} `json:"lease"`
+}
+
+type RevokeDynamicSecretLeaseV1Request struct {
+	LeaseId     string `json:"-"`
+	Environment string `json:"environmentSlug"`
+	ProjectSlug string `json:"projectSlug"`
+	SecretPath  string `json:"path,omitempty"`
+}
+
+type GetProjectByIdV1Request struct {
+	ProjectId string `json:"projectId"`
+}
+
+type GetProjectByIdV1Response struct {
+	Workspace struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+		Slug string `json:"slug"`
+	} `json:"workspace"`
+}
+
 type GetRawSecretsV3Request struct {
 	Environment   string `json:"environment"`
 	WorkspaceId   string `json:"workspaceId"`
@@ -586,7 +662,158 @@ type GetRawSecretsV3Response struct {
 		SecretKey     string `json:"secretKey"`
 		SecretValue   string `json:"secretValue"`
 		SecretComment string `json:"secretComment"`
+		SecretPath    string `json:"secretPath"`
 	} `json:"secrets"`
 	Imports []ImportedRawSecretV3 `json:"imports"`
 	ETag    string
 }
+
+type SecretTagsV3Request struct {
+	SecretName  string   `json:"-"`
+	ProjectSlug string   `json:"projectSlug"`
+	Environment string   `json:"environment"`
+	SecretPath  string   `json:"secretPath,omitempty"`
+	Type        string   `json:"type,omitempty"`
+	TagSlugs    []string `json:"tagSlugs"`
+}
+
+type GetRawSecretByNameV3Request struct {
+	SecretName  string
+	WorkspaceId string
+	Environment string
+	SecretPath  string
+	Type        string
+}
+
+type GetRawSecretByNameV3Response struct {
+	Secret struct {
+		ID            string `json:"id"`
+		Version       int    `json:"version"`
+		Workspace     string `json:"workspace"`
+		Type          string `json:"type"`
+		Environment   string `json:"environment"`
+		SecretKey     string `json:"secretKey"`
+		SecretValue   string `json:"secretValue"`
+		SecretComment string `json:"secretComment"`
+		Tags          []struct {
+			ID        string `json:"id"`
+			Name      string `json:"name"`
+			Slug      string `json:"slug"`
+			Workspace string `json:"workspace"`
+		} `json:"tags"`
+	} `json:"secret"`
+}
+
+type GetSecretVersionsV1Request struct {
+	SecretId string `json:"secretId"`
+	Offset   int    `json:"offset"`
+	Limit    int    `json:"limit"`
+}
+
+type SecretVersionV1 struct {
+	ID                      string    `json:"id"`
+	Version                 int       `json:"version"`
+	Type                    string    `json:"type"`
+	SecretKeyCiphertext     string    `json:"secretKeyCiphertext"`
+	SecretKeyIV             string    `json:"secretKeyIV"`
+	SecretKeyTag            string    `json:"secretKeyTag"`
+	SecretValueCiphertext   string    `json:"secretValueCiphertext"`
+	SecretValueIV           string    `json:"secretValueIV"`
+	SecretValueTag          string    `json:"secretValueTag"`
+	SecretCommentCiphertext string    `json:"secretCommentCiphertext"`
+	SecretCommentIV         string    `json:"secretCommentIV"`
+	SecretCommentTag        string    `json:"secretCommentTag"`
+	SecretId                string    `json:"secretId"`
+	UserId                  string    `json:"userId"`
+	CreatedAt               time.Time `json:"createdAt"`
+}
+
+type GetSecretVersionsV1Response struct {
+	SecretVersions []SecretVersionV1 `json:"secretVersions"`
+}
+
+type GetProjectUsersV1Response struct {
+	Users []struct {
+		User struct {
+			ID        string `json:"id"`
+			Email     string `json:"email"`
+			Username  string `json:"username"`
+			FirstName string `json:"firstName"`
+			LastName  string `json:"lastName"`
+		} `json:"user"`
+	} `json:"users"`
+}
+
+type GetSecretSnapshotsV1Request struct {
+	WorkspaceId string `json:"workspaceId"`
+	Environment string `json:"environment"`
+	Path        string `json:"path"`
+	Offset      int    `json:"offset"`
+	Limit       int    `json:"limit"`
+}
+
+type SecretSnapshotV1 struct {
+	ID        string    `json:"id"`
+	EnvId     string    `json:"envId"`
+	FolderId  string    `json:"folderId"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type GetSecretSnapshotsV1Response struct {
+	SecretSnapshots []SecretSnapshotV1 `json:"secretSnapshots"`
+}
+
+type RollbackSecretSnapshotV1Response struct {
+	SecretSnapshot SecretSnapshotV1 `json:"secretSnapshot"`
+}
+
+type SecretImportV1 struct {
+	ID            string `json:"id"`
+	ImportPath    string `json:"importPath"`
+	Position      int    `json:"position"`
+	IsReplication bool   `json:"isReplication"`
+	IsReserved    bool   `json:"isReserved"`
+	ImportEnv     struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+		Slug string `json:"slug"`
+	} `json:"importEnv"`
+}
+
+type GetSecretImportsV1Request struct {
+	WorkspaceId string
+	Environment string
+	Path        string
+}
+
+type GetSecretImportsV1Response struct {
+	SecretImports []SecretImportV1 `json:"secretImports"`
+}
+
+type SecretImportSourceV1 struct {
+	Environment string `json:"environment,omitempty"`
+	Path        string `json:"path,omitempty"`
+	Position    int    `json:"position,omitempty"`
+}
+
+type CreateSecretImportV1Request struct {
+	WorkspaceId string               `json:"workspaceId"`
+	Environment string               `json:"environment"`
+	Path        string               `json:"path"`
+	Import      SecretImportSourceV1 `json:"import"`
+}
+
+type UpdateSecretImportV1Request struct {
+	SecretImportId string               `json:"-"`
+	WorkspaceId    string               `json:"workspaceId"`
+	Environment    string               `json:"environment"`
+	Path           string               `json:"path"`
+	Import         SecretImportSourceV1 `json:"import"`
+}
+
+type DeleteSecretImportV1Request struct {
+	SecretImportId string `json:"-"`
+	WorkspaceId    string `json:"workspaceId"`
+	Environment    string `json:"environment"`
+	Path           string `json:"path"`
+}
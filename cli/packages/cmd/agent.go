@@ -321,7 +321,10 @@ func secretTemplateFunction(accessToken string, existingEtag string, currentEtag
 			*currentEtag = res.Etag
 		}
 
-		expandedSecrets := util.ExpandSecrets(res.Secrets, models.ExpandSecretsAuthentication{UniversalAuthAccessToken: accessToken}, "")
+		expandedSecrets, err := util.ExpandSecretReferences(res.Secrets, models.ExpandSecretsAuthentication{UniversalAuthAccessToken: accessToken}, "", false)
+		if err != nil {
+			return nil, err
+		}
 
 		return expandedSecrets, nil
 	}
@@ -4,16 +4,25 @@ Copyright (c) 2023 Infisical Inc.
 package cmd
 
 import (
+	"encoding/base64"
 	"encoding/csv"
 	"encoding/json"
 	"fmt"
 	"os"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
+	"unicode/utf16"
 
+	"filippo.io/age"
+	"github.com/Infisical/infisical-merge/packages/crypto"
 	"github.com/Infisical/infisical-merge/packages/models"
 	"github.com/Infisical/infisical-merge/packages/util"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
 )
 
 const (
@@ -22,8 +31,51 @@ const (
 	FormatCSV          string = "csv"
 	FormatYaml         string = "yaml"
 	FormatDotEnvExport string = "dotenv-export"
+	FormatK8sSecret    string = "k8s-secret"
+	FormatDockerEnv    string = "docker-env"
+	FormatTfvars       string = "tfvars"
+	FormatProperties   string = "properties"
+	FormatSystemdEnv   string = "systemd-env"
+	FormatPowerShell   string = "powershell"
 )
 
+// exports that are a list, such as the json format, are stored under this key when encrypted into a sops document
+const EXPORT_ENCRYPTED_SECRETS_KEY = "secrets"
+
+var exportFormats = []string{FormatDotenv, FormatDotEnvExport, FormatJson, FormatCSV, FormatYaml, FormatK8sSecret, FormatDockerEnv, FormatTfvars, FormatProperties, FormatSystemdEnv, FormatPowerShell}
+
+// exportFormatOptions holds the settings of formats that need more than the secrets
+type exportFormatOptions struct {
+	K8sName      string
+	K8sNamespace string
+	K8sLabels    map[string]string
+	// WithMetadata exports exportSecretMetadata records instead of the plain format, OmitValues leaves their values out
+	WithMetadata bool
+	OmitValues   bool
+	// the exported folder, Nested and KeyTemplate describe where secrets are below it
+	BasePath    string
+	Nested      bool
+	KeyTemplate string
+}
+
+// exportSecretMetadata is the documented schema of every secret exported with --with-metadata.
+// Fields may be added to it but are never renamed or removed, so inventories stay comparable between versions.
+type exportSecretMetadata struct {
+	Key string `json:"key" yaml:"key"`
+	// nil when values are left out with --values=false
+	Value *string `json:"value,omitempty" yaml:"value,omitempty"`
+	Type  string  `json:"type" yaml:"type"`
+	// the folder the secret is stored in, for imported secrets the folder it is imported from
+	Environment string   `json:"environment" yaml:"environment"`
+	Path        string   `json:"path" yaml:"path"`
+	Imported    bool     `json:"imported" yaml:"imported"`
+	Comment     string   `json:"comment" yaml:"comment"`
+	Tags        []string `json:"tags" yaml:"tags"`
+}
+
+// the formats that support --with-metadata
+var exportMetadataFormats = []string{FormatJson, FormatYaml, FormatCSV}
+
 // exportCmd represents the export command
 var exportCmd = &cobra.Command{
 	Use:                   "export",
@@ -45,6 +97,11 @@ var exportCmd = &cobra.Command{
 			util.HandleError(err)
 		}
 
+		allowMissingReferences, err := cmd.Flags().GetBool("allow-missing-references")
+		if err != nil {
+			util.HandleError(err)
+		}
+
 		includeImports, err := cmd.Flags().GetBool("include-imports")
 		if err != nil {
 			util.HandleError(err)
@@ -85,12 +142,75 @@ var exportCmd = &cobra.Command{
 			util.HandleError(err, "Unable to parse flag")
 		}
 
+		k8sName, err := cmd.Flags().GetString("k8s-name")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		k8sNamespace, err := cmd.Flags().GetString("k8s-namespace")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		k8sLabels, err := cmd.Flags().GetStringToString("k8s-labels")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		outputPath, err := cmd.Flags().GetString("output")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		encryptTargets, err := cmd.Flags().GetStringArray("encrypt")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		withMetadata, err := cmd.Flags().GetBool("with-metadata")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		includeValues, err := cmd.Flags().GetBool("values")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		recursive, err := cmd.Flags().GetBool("recursive")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		nested, err := cmd.Flags().GetBool("nested")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		keyTemplate, err := cmd.Flags().GetString("key-template")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		formatOptions := exportFormatOptions{
+			K8sName:      k8sName,
+			K8sNamespace: k8sNamespace,
+			K8sLabels:    k8sLabels,
+			// an export without values is only useful as an inventory
+			WithMetadata: withMetadata || !includeValues,
+			OmitValues:   !includeValues,
+			BasePath:     secretsPath,
+			Nested:       nested,
+			KeyTemplate:  keyTemplate,
+		}
+
 		request := models.GetAllSecretsParameters{
 			Environment:   environmentName,
 			TagSlugs:      tagSlugs,
 			WorkspaceId:   projectId,
 			SecretsPath:   secretsPath,
 			IncludeImport: includeImports,
+			Recursive:     recursive,
 		}
 
 		if token != nil && token.Type == util.SERVICE_TOKEN_IDENTIFIER {
@@ -120,7 +240,8 @@ var exportCmd = &cobra.Command{
 			if err != nil {
 				util.HandleError(err)
 			}
-			fmt.Print(processedTemplate.String())
+			// rendered templates have no format of their own, so they are always encrypted as a whole
+			writeExportOutput(processedTemplate.String(), "", encryptTargets, outputPath)
 			return
 		}
 
@@ -129,10 +250,16 @@ var exportCmd = &cobra.Command{
 			util.HandleError(err, "Unable to fetch secrets")
 		}
 
+		preferredSecretType := util.SECRET_TYPE_SHARED
 		if secretOverriding {
-			secrets = util.OverrideSecrets(secrets, util.SECRET_TYPE_PERSONAL)
+			preferredSecretType = util.SECRET_TYPE_PERSONAL
+		}
+
+		// secrets of different folders can share a key, so with --recursive personal secrets only override shared secrets of their own folder
+		if recursive {
+			secrets = overrideSecretsPerFolder(secrets, preferredSecretType, secretsPath)
 		} else {
-			secrets = util.OverrideSecrets(secrets, util.SECRET_TYPE_SHARED)
+			secrets = util.OverrideSecrets(secrets, preferredSecretType)
 		}
 
 		var output string
@@ -146,17 +273,22 @@ var exportCmd = &cobra.Command{
 				authParams.UniversalAuthAccessToken = token.Token
 			}
 
-			secrets = util.ExpandSecrets(secrets, authParams, "")
+			secrets = expandSecretReferences(secrets, authParams, "", allowMissingReferences)
 		}
 		secrets = util.FilterSecretsByTag(secrets, tagSlugs)
 		secrets = util.SortSecretsByKeys(secrets)
 
-		output, err = formatEnvs(secrets, format)
+		// formats without a place for the folder of a secret can only hold one secret per key
+		if recursive && !nested && keyTemplate == "" && !formatOptions.WithMetadata {
+			secrets = dropShadowedSecrets(secrets, secretsPath)
+		}
+
+		output, err = formatEnvs(secrets, format, formatOptions)
 		if err != nil {
 			util.HandleError(err)
 		}
 
-		fmt.Print(output)
+		writeExportOutput(output, format, encryptTargets, outputPath)
 
 		// Telemetry.CaptureEvent("cli-command:export", posthog.NewProperties().Set("secretsCount", len(secrets)).Set("version", util.CLI_VERSION))
 	},
@@ -166,18 +298,138 @@ func init() {
 	rootCmd.AddCommand(exportCmd)
 	exportCmd.Flags().StringP("env", "e", "dev", "Set the environment (dev, prod, etc.) from which your secrets should be pulled from")
 	exportCmd.Flags().Bool("expand", true, "Parse shell parameter expansions in your secrets")
-	exportCmd.Flags().StringP("format", "f", "dotenv", "Set the format of the output file (dotenv, json, csv)")
+	exportCmd.Flags().Bool("allow-missing-references", false, "Expand references to secrets that do not exist to an empty value with a warning, instead of failing")
+	exportCmd.Flags().StringP("format", "f", "dotenv", "Set the format of the output file (dotenv, dotenv-export, json, csv, yaml, k8s-secret, docker-env, tfvars, properties, systemd-env, powershell)")
 	exportCmd.Flags().Bool("secret-overriding", true, "Prioritizes personal secrets, if any, with the same name over shared secrets")
 	exportCmd.Flags().Bool("include-imports", true, "Imported linked secrets")
 	exportCmd.Flags().String("token", "", "Fetch secrets using service token or machine identity access token")
 	exportCmd.Flags().StringP("tags", "t", "", "filter secrets by tag slugs")
 	exportCmd.Flags().String("projectId", "", "manually set the projectId to export secrets from")
 	exportCmd.Flags().String("path", "/", "get secrets within a folder path")
+	exportCmd.Flags().Bool("recursive", false, "Fetch secrets from all sub-folders")
+	exportCmd.Flags().Bool("nested", false, "Export an object tree that mirrors the folders, e.g. {\"db\": {\"HOST\": ...}} (json and yaml formats)")
+	exportCmd.Flags().String("key-template", "", "Rename exported keys, e.g. {{path}}_{{key}} exports HOST in the db folder as db_HOST")
 	exportCmd.Flags().String("template", "", "The path to the template file used to render secrets")
+	exportCmd.Flags().String("k8s-name", "infisical-secrets", "The name of the Kubernetes secret when using the k8s-secret format")
+	exportCmd.Flags().String("k8s-namespace", "", "The namespace of the Kubernetes secret when using the k8s-secret format")
+	exportCmd.Flags().StringToString("k8s-labels", map[string]string{}, "Labels of the Kubernetes secret when using the k8s-secret format (e.g. app=api,team=core)")
+	exportCmd.Flags().Bool("with-metadata", false, "Export the comment, tags, type, folder and import origin of every secret (json, yaml and csv formats)")
+	exportCmd.Flags().Bool("values", true, "Include secret values, --values=false exports an inventory of the secrets with their metadata but without values")
+	exportCmd.Flags().String("output", "", "Write the export to this file, readable only by you, instead of printing it")
+	exportCmd.Flags().StringArray("encrypt", []string{}, "Encrypt the export to an age recipient (age:<recipient>). The json and yaml formats are encrypted like sops does, all others as a whole")
+}
+
+// writeExportOutput encrypts the output when encryption targets are given and prints it, or writes it to outputPath
+func writeExportOutput(output string, format string, encryptTargets []string, outputPath string) {
+	if len(encryptTargets) > 0 {
+		encrypted, err := encryptExportOutput(output, format, encryptTargets)
+		if err != nil {
+			util.HandleError(err, "Unable to encrypt the export")
+		}
+		output = encrypted
+	}
+
+	if outputPath == "" {
+		fmt.Print(output)
+		return
+	}
+
+	if err := util.WritePrivateFile(outputPath, []byte(output)); err != nil {
+		util.HandleError(err, "Unable to write the export")
+	}
+
+	util.PrintSuccessMessage(fmt.Sprintf("Export written to %s", outputPath))
+}
+
+// encryptExportOutput encrypts the json and yaml formats into sops documents, so single values stay diffable and `sops -d` can read them.
+// Every other format is encrypted as a whole into an ASCII armored age file.
+func encryptExportOutput(output string, format string, encryptTargets []string) (string, error) {
+	recipients := []*age.X25519Recipient{}
+	for _, target := range encryptTargets {
+		scheme, value, _ := strings.Cut(target, ":")
+		if scheme != "age" {
+			return "", fmt.Errorf("unsupported encryption [%s], only age:<recipient> is supported", target)
+		}
+
+		parsed, err := crypto.ParseAgeRecipients(value)
+		if err != nil {
+			return "", err
+		}
+		recipients = append(recipients, parsed...)
+	}
+
+	format = strings.ToLower(format)
+	if format != FormatYaml && format != FormatJson {
+		encrypted, err := crypto.AgeEncrypt([]byte(output), recipients...)
+		if err != nil {
+			return "", err
+		}
+		return string(encrypted), nil
+	}
+
+	var parsed interface{}
+	var err error
+	if format == FormatYaml {
+		parsed, err = unmarshalYamlDocument([]byte(output))
+	} else {
+		parsed, err = util.UnmarshalOrderedJSON([]byte(output))
+	}
+	if err != nil {
+		return "", err
+	}
+
+	// sops documents need a mapping at the top level to hold their metadata
+	document, ok := parsed.(yaml.MapSlice)
+	if !ok {
+		document = yaml.MapSlice{{Key: EXPORT_ENCRYPTED_SECRETS_KEY, Value: parsed}}
+	}
+
+	encrypted, err := crypto.SopsEncrypt(document, recipients, time.Now())
+	if err != nil {
+		return "", err
+	}
+
+	if format == FormatYaml {
+		encryptedOutput, err := yaml.Marshal(encrypted)
+		if err != nil {
+			return "", fmt.Errorf("unable to marshal the encrypted export to YAML [err=%v]", err)
+		}
+		return string(encryptedOutput), nil
+	}
+
+	encryptedOutput, err := util.MarshalOrderedJSON(encrypted)
+	if err != nil {
+		return "", fmt.Errorf("unable to marshal the encrypted export to JSON [err=%v]", err)
+	}
+	return string(encryptedOutput) + "\n", nil
 }
 
 // Format according to the format flag
-func formatEnvs(envs []models.SingleEnvironmentVariable, format string) (string, error) {
+func formatEnvs(envs []models.SingleEnvironmentVariable, format string, options exportFormatOptions) (string, error) {
+	if options.KeyTemplate != "" {
+		if options.Nested {
+			return "", fmt.Errorf("--key-template cannot be combined with --nested")
+		}
+
+		renamed, err := applyExportKeyTemplate(envs, options.KeyTemplate, options.BasePath)
+		if err != nil {
+			return "", err
+		}
+		envs = renamed
+	}
+
+	if options.Nested {
+		if options.WithMetadata {
+			return "", fmt.Errorf("--nested cannot be combined with --with-metadata or --values=false")
+		}
+
+		return formatAsNested(envs, format, options.BasePath)
+	}
+
+	if options.WithMetadata {
+		return formatWithMetadata(envs, format, options)
+	}
+
 	switch strings.ToLower(format) {
 	case FormatDotenv:
 		return formatAsDotEnv(envs), nil
@@ -188,12 +440,95 @@ func formatEnvs(envs []models.SingleEnvironmentVariable, format string) (string,
 	case FormatCSV:
 		return formatAsCSV(envs), nil
 	case FormatYaml:
-		return formatAsYaml(envs), nil
+		return formatAsYaml(envs)
+	case FormatK8sSecret:
+		return formatAsK8sSecret(envs, options)
+	case FormatDockerEnv:
+		return formatAsDockerEnv(envs)
+	case FormatTfvars:
+		return formatAsTfvars(envs)
+	case FormatProperties:
+		return formatAsProperties(envs), nil
+	case FormatSystemdEnv:
+		return formatAsSystemdEnv(envs), nil
+	case FormatPowerShell:
+		return formatAsPowerShell(envs), nil
 	default:
-		return "", fmt.Errorf("invalid format type: %s. Available format types are [%s]", format, []string{FormatDotenv, FormatJson, FormatCSV, FormatYaml, FormatDotEnvExport})
+		return "", fmt.Errorf("invalid format type: %s. Available format types are %s", format, exportFormats)
 	}
 }
 
+// Format environment variables as exportSecretMetadata records in the json, yaml or csv format
+func formatWithMetadata(envs []models.SingleEnvironmentVariable, format string, options exportFormatOptions) (string, error) {
+	records := make([]exportSecretMetadata, 0, len(envs))
+	for _, env := range envs {
+		records = append(records, newExportSecretMetadata(env, options.OmitValues))
+	}
+
+	switch strings.ToLower(format) {
+	case FormatJson:
+		output, err := json.Marshal(records)
+		if err != nil {
+			return "", fmt.Errorf("unable to marshal secrets to JSON [err=%v]", err)
+		}
+		return string(output), nil
+	case FormatYaml:
+		output, err := yaml.Marshal(records)
+		if err != nil {
+			return "", fmt.Errorf("unable to marshal secrets to YAML [err=%v]", err)
+		}
+		return string(output), nil
+	case FormatCSV:
+		header := []string{"Key", "Value", "Type", "Environment", "Path", "Imported", "Comment", "Tags"}
+		if options.OmitValues {
+			header = append(header[:1], header[2:]...)
+		}
+
+		csvString := &strings.Builder{}
+		writer := csv.NewWriter(csvString)
+		writer.Write(header)
+		for _, record := range records {
+			row := []string{record.Key}
+			if record.Value != nil {
+				row = append(row, *record.Value)
+			}
+			row = append(row, record.Type, record.Environment, record.Path, strconv.FormatBool(record.Imported), record.Comment, strings.Join(record.Tags, ","))
+			writer.Write(row)
+		}
+		writer.Flush()
+		return csvString.String(), nil
+	default:
+		return "", fmt.Errorf("metadata can only be exported in the %s formats, not %s", strings.Join(exportMetadataFormats, ", "), format)
+	}
+}
+
+func newExportSecretMetadata(env models.SingleEnvironmentVariable, omitValue bool) exportSecretMetadata {
+	record := exportSecretMetadata{
+		Key:         env.Key,
+		Type:        env.Type,
+		Environment: env.Environment,
+		Path:        env.SecretPath,
+		Imported:    env.IsImported,
+		Comment:     env.Comment,
+		Tags:        []string{},
+	}
+
+	if record.Type == "" {
+		record.Type = util.SECRET_TYPE_SHARED
+	}
+
+	if !omitValue {
+		value := env.Value
+		record.Value = &value
+	}
+
+	for _, tag := range env.Tags {
+		record.Tags = append(record.Tags, tag.Slug)
+	}
+
+	return record
+}
+
 // Format environment variables as a CSV file
 func formatAsCSV(envs []models.SingleEnvironmentVariable) string {
 	csvString := &strings.Builder{}
@@ -208,28 +543,35 @@ func formatAsCSV(envs []models.SingleEnvironmentVariable) string {
 
 // Format environment variables as a dotenv file
 func formatAsDotEnv(envs []models.SingleEnvironmentVariable) string {
-	var dotenv string
+	var dotenv strings.Builder
 	for _, env := range envs {
-		dotenv += fmt.Sprintf("%s='%s'\n", env.Key, env.Value)
+		dotenv.WriteString(fmt.Sprintf("%s=%s\n", env.Key, util.QuoteDotEnvValue(env.Value)))
 	}
-	return dotenv
+	return dotenv.String()
 }
 
-// Format environment variables as a dotenv file with export at the beginning
+// Format environment variables as a dotenv file with export at the beginning, the output can be sourced by POSIX shells
 func formatAsDotEnvExport(envs []models.SingleEnvironmentVariable) string {
-	var dotenv string
+	var dotenv strings.Builder
 	for _, env := range envs {
-		dotenv += fmt.Sprintf("export %s='%s'\n", env.Key, env.Value)
+		dotenv.WriteString(fmt.Sprintf("export %s=%s\n", env.Key, util.QuoteShellValue(env.Value)))
 	}
-	return dotenv
+	return dotenv.String()
 }
 
-func formatAsYaml(envs []models.SingleEnvironmentVariable) string {
-	var dotenv string
+// Format environment variables as a YAML mapping, keeping the order of the secrets
+func formatAsYaml(envs []models.SingleEnvironmentVariable) (string, error) {
+	mapping := yaml.MapSlice{}
 	for _, env := range envs {
-		dotenv += fmt.Sprintf("%s: %s\n", env.Key, env.Value)
+		mapping = append(mapping, yaml.MapItem{Key: env.Key, Value: env.Value})
+	}
+
+	output, err := yaml.Marshal(mapping)
+	if err != nil {
+		return "", fmt.Errorf("unable to marshal environment variables to YAML [err=%v]", err)
 	}
-	return dotenv
+
+	return string(output), nil
 }
 
 // Format environment variables as a JSON file
@@ -242,3 +584,175 @@ func formatAsJson(envs []models.SingleEnvironmentVariable) string {
 	}
 	return string(json)
 }
+
+// Format environment variables as a Kubernetes Secret manifest, values are stored base64 encoded under data
+func formatAsK8sSecret(envs []models.SingleEnvironmentVariable, options exportFormatOptions) (string, error) {
+	if options.K8sName == "" {
+		return "", fmt.Errorf("a name is required for the k8s-secret format, set it with --k8s-name")
+	}
+
+	metadata := yaml.MapSlice{{Key: "name", Value: options.K8sName}}
+	if options.K8sNamespace != "" {
+		metadata = append(metadata, yaml.MapItem{Key: "namespace", Value: options.K8sNamespace})
+	}
+
+	if len(options.K8sLabels) > 0 {
+		labelKeys := make([]string, 0, len(options.K8sLabels))
+		for key := range options.K8sLabels {
+			labelKeys = append(labelKeys, key)
+		}
+		sort.Strings(labelKeys)
+
+		labels := yaml.MapSlice{}
+		for _, key := range labelKeys {
+			labels = append(labels, yaml.MapItem{Key: key, Value: options.K8sLabels[key]})
+		}
+		metadata = append(metadata, yaml.MapItem{Key: "labels", Value: labels})
+	}
+
+	data := yaml.MapSlice{}
+	for _, env := range envs {
+		data = append(data, yaml.MapItem{Key: env.Key, Value: base64.StdEncoding.EncodeToString([]byte(env.Value))})
+	}
+
+	manifest := yaml.MapSlice{
+		{Key: "apiVersion", Value: "v1"},
+		{Key: "kind", Value: "Secret"},
+		{Key: "metadata", Value: metadata},
+		{Key: "type", Value: "Opaque"},
+		{Key: "data", Value: data},
+	}
+
+	output, err := yaml.Marshal(manifest)
+	if err != nil {
+		return "", fmt.Errorf("unable to marshal Kubernetes secret [err=%v]", err)
+	}
+
+	return string(output), nil
+}
+
+// Format environment variables for docker run --env-file. Docker reads everything after the first = literally
+// and has no way to escape line breaks, so multi-line values are rejected.
+func formatAsDockerEnv(envs []models.SingleEnvironmentVariable) (string, error) {
+	var dockerEnv strings.Builder
+	for _, env := range envs {
+		if strings.ContainsAny(env.Value, "\n\r") {
+			return "", fmt.Errorf("secret %s contains a line break, which is not supported by the docker-env format", env.Key)
+		}
+		dockerEnv.WriteString(fmt.Sprintf("%s=%s\n", env.Key, env.Value))
+	}
+	return dockerEnv.String(), nil
+}
+
+var tfvarsIdentifierRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+
+// Format environment variables as a Terraform .tfvars file, values are HCL strings with template sequences escaped
+func formatAsTfvars(envs []models.SingleEnvironmentVariable) (string, error) {
+	var tfvars strings.Builder
+	for _, env := range envs {
+		if !tfvarsIdentifierRegex.MatchString(env.Key) {
+			return "", fmt.Errorf("secret %s is not a valid Terraform variable name", env.Key)
+		}
+		tfvars.WriteString(fmt.Sprintf("%s = %s\n", env.Key, quoteHCLString(env.Value)))
+	}
+	return tfvars.String(), nil
+}
+
+func quoteHCLString(value string) string {
+	var quoted strings.Builder
+	quoted.WriteByte('"')
+	for i, char := range value {
+		switch {
+		case char == '\\':
+			quoted.WriteString(`\\`)
+		case char == '"':
+			quoted.WriteString(`\"`)
+		case char == '\n':
+			quoted.WriteString(`\n`)
+		case char == '\r':
+			quoted.WriteString(`\r`)
+		case char == '\t':
+			quoted.WriteString(`\t`)
+		case (char == '$' || char == '%') && strings.HasPrefix(value[i+1:], "{"):
+			// ${ and %{ start template sequences, doubling the first character escapes them
+			quoted.WriteRune(char)
+			quoted.WriteRune(char)
+		case char < 0x20 || char == 0x7f:
+			quoted.WriteString(fmt.Sprintf(`\u%04x`, char))
+		default:
+			quoted.WriteRune(char)
+		}
+	}
+	quoted.WriteByte('"')
+	return quoted.String()
+}
+
+// Format environment variables as a Java .properties file, escaped the same way as java.util.Properties.store
+func formatAsProperties(envs []models.SingleEnvironmentVariable) string {
+	var properties strings.Builder
+	for _, env := range envs {
+		properties.WriteString(fmt.Sprintf("%s=%s\n", escapeProperty(env.Key, true), escapeProperty(env.Value, false)))
+	}
+	return properties.String()
+}
+
+func escapeProperty(value string, isKey bool) string {
+	var escaped strings.Builder
+	for i, char := range value {
+		switch {
+		case char == '\\':
+			escaped.WriteString(`\\`)
+		case char == '\n':
+			escaped.WriteString(`\n`)
+		case char == '\r':
+			escaped.WriteString(`\r`)
+		case char == '\t':
+			escaped.WriteString(`\t`)
+		case char == '\f':
+			escaped.WriteString(`\f`)
+		case char == ' ' && (isKey || i == 0):
+			// spaces separate keys from values and leading spaces of values are trimmed
+			escaped.WriteString(`\ `)
+		case char == '=' || char == ':' || char == '#' || char == '!':
+			escaped.WriteRune('\\')
+			escaped.WriteRune(char)
+		case char < 0x20 || char > 0x7e:
+			for _, unit := range utf16.Encode([]rune{char}) {
+				escaped.WriteString(fmt.Sprintf(`\u%04X`, unit))
+			}
+		default:
+			escaped.WriteRune(char)
+		}
+	}
+	return escaped.String()
+}
+
+// Format environment variables as a systemd EnvironmentFile. Values are double quoted, inside which systemd
+// only unescapes \", \\, \` and \$, and line breaks are kept as they are.
+func formatAsSystemdEnv(envs []models.SingleEnvironmentVariable) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "`", "\\`", "$", `\$`)
+
+	var systemdEnv strings.Builder
+	for _, env := range envs {
+		systemdEnv.WriteString(fmt.Sprintf("%s=\"%s\"\n", env.Key, replacer.Replace(env.Value)))
+	}
+	return systemdEnv.String()
+}
+
+var powerShellVariableRegex = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+// Format environment variables as a PowerShell script that sets them for the current session.
+// Values are single quoted, PowerShell treats the typographic single quotes as quotes too so they are doubled as well.
+func formatAsPowerShell(envs []models.SingleEnvironmentVariable) string {
+	replacer := strings.NewReplacer("'", "''", "\u2018", "\u2018\u2018", "\u2019", "\u2019\u2019", "\u201a", "\u201a\u201a", "\u201b", "\u201b\u201b")
+
+	var powerShell strings.Builder
+	for _, env := range envs {
+		variable := "$env:" + env.Key
+		if !powerShellVariableRegex.MatchString(env.Key) {
+			variable = "${env:" + strings.NewReplacer("`", "``", "}", "`}").Replace(env.Key) + "}"
+		}
+		powerShell.WriteString(fmt.Sprintf("%s = '%s'\n", variable, replacer.Replace(env.Value)))
+	}
+	return powerShell.String()
+}
@@ -0,0 +1,233 @@
+/*
+Copyright (c) 2023 Infisical Inc.
+*/
+package cmd
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/Infisical/infisical-merge/packages/models"
+	"github.com/Infisical/infisical-merge/packages/util"
+	"gopkg.in/yaml.v2"
+)
+
+// the placeholders of --key-template
+const (
+	EXPORT_KEY_TEMPLATE_KEY        = "{{key}}"
+	EXPORT_KEY_TEMPLATE_PATH       = "{{path}}"
+	EXPORT_KEY_TEMPLATE_PATH_UPPER = "{{PATH}}"
+)
+
+// exportFolderOf returns the folders between the exported path and the folder of a secret, e.g. [db primary] for
+// /db/primary when exporting /. Imported secrets belong to the exported folder since that is the folder importing them.
+func exportFolderOf(secret models.SingleEnvironmentVariable, basePath string) []string {
+	if secret.IsImported || secret.SecretPath == "" {
+		return nil
+	}
+
+	secretPath := path.Clean("/" + secret.SecretPath)
+	basePath = path.Clean("/" + basePath)
+
+	relativePath := secretPath
+	if basePath == "/" || strings.HasPrefix(secretPath, basePath+"/") {
+		relativePath = strings.TrimPrefix(secretPath, basePath)
+	} else if secretPath == basePath {
+		return nil
+	}
+
+	folders := []string{}
+	for _, folder := range strings.Split(relativePath, "/") {
+		if folder != "" {
+			folders = append(folders, folder)
+		}
+	}
+
+	return folders
+}
+
+// overrideSecretsPerFolder applies util.OverrideSecrets to the secrets of every folder on its own, so secrets
+// with the same key in different folders are all kept
+func overrideSecretsPerFolder(secrets []models.SingleEnvironmentVariable, secretType string, basePath string) []models.SingleEnvironmentVariable {
+	secretsByFolder := map[string][]models.SingleEnvironmentVariable{}
+	for _, secret := range secrets {
+		folder := strings.Join(exportFolderOf(secret, basePath), "/")
+		secretsByFolder[folder] = append(secretsByFolder[folder], secret)
+	}
+
+	overridden := []models.SingleEnvironmentVariable{}
+	for _, folderSecrets := range secretsByFolder {
+		overridden = append(overridden, util.OverrideSecrets(folderSecrets, secretType)...)
+	}
+
+	return overridden
+}
+
+// dropShadowedSecrets keeps a single secret per key for flat exports of several folders, the one closest to the exported path wins
+func dropShadowedSecrets(secrets []models.SingleEnvironmentVariable, basePath string) []models.SingleEnvironmentVariable {
+	closest := map[string]models.SingleEnvironmentVariable{}
+	shadowed := []string{}
+	for _, secret := range secrets {
+		existing, ok := closest[secret.Key]
+		if !ok {
+			closest[secret.Key] = secret
+			continue
+		}
+
+		if !slices.Contains(shadowed, secret.Key) {
+			shadowed = append(shadowed, secret.Key)
+		}
+
+		depth, existingDepth := len(exportFolderOf(secret, basePath)), len(exportFolderOf(existing, basePath))
+		if depth < existingDepth || (depth == existingDepth && secret.SecretPath < existing.SecretPath) {
+			closest[secret.Key] = secret
+		}
+	}
+
+	if len(shadowed) > 0 {
+		sort.Strings(shadowed)
+		util.PrintWarning(fmt.Sprintf("%s exist in more than one folder and only the one closest to %s is exported, use --key-template or --nested to export all of them", strings.Join(shadowed, ", "), basePath))
+	}
+
+	result := make([]models.SingleEnvironmentVariable, 0, len(closest))
+	for _, secret := range closest {
+		result = append(result, secret)
+	}
+
+	return util.SortSecretsByKeys(result)
+}
+
+// applyExportKeyTemplate renames every secret with the key template, e.g. {{path}}_{{key}} turns HOST in /db into db_HOST.
+// {{path}} joins the folders below the exported path with underscores and {{PATH}} does the same in upper case.
+// For secrets of the exported folder itself the path is empty and the separator next to it is dropped.
+func applyExportKeyTemplate(secrets []models.SingleEnvironmentVariable, keyTemplate string, basePath string) ([]models.SingleEnvironmentVariable, error) {
+	if !strings.Contains(keyTemplate, EXPORT_KEY_TEMPLATE_KEY) {
+		return nil, fmt.Errorf("the key template must contain %s, otherwise secrets of the same folder get the same key", EXPORT_KEY_TEMPLATE_KEY)
+	}
+
+	renamed := make([]models.SingleEnvironmentVariable, 0, len(secrets))
+	sources := map[string]models.SingleEnvironmentVariable{}
+	for _, secret := range secrets {
+		folderPath := strings.Join(exportFolderOf(secret, basePath), "_")
+
+		key := keyTemplate
+		if folderPath == "" {
+			key = removeKeyTemplatePlaceholder(key, EXPORT_KEY_TEMPLATE_PATH)
+			key = removeKeyTemplatePlaceholder(key, EXPORT_KEY_TEMPLATE_PATH_UPPER)
+		}
+		key = strings.ReplaceAll(key, EXPORT_KEY_TEMPLATE_PATH, folderPath)
+		key = strings.ReplaceAll(key, EXPORT_KEY_TEMPLATE_PATH_UPPER, strings.ToUpper(folderPath))
+		key = strings.ReplaceAll(key, EXPORT_KEY_TEMPLATE_KEY, secret.Key)
+
+		if existing, ok := sources[key]; ok {
+			return nil, fmt.Errorf("the key template gives %s in %s and %s in %s the same key %s", existing.Key, existing.SecretPath, secret.Key, secret.SecretPath, key)
+		}
+		sources[key] = secret
+
+		secret.Key = key
+		renamed = append(renamed, secret)
+	}
+
+	return util.SortSecretsByKeys(renamed), nil
+}
+
+// removeKeyTemplatePlaceholder removes a placeholder together with one separator after it, or otherwise before it
+func removeKeyTemplatePlaceholder(keyTemplate string, placeholder string) string {
+	const separators = "_-./:"
+
+	for {
+		index := strings.Index(keyTemplate, placeholder)
+		if index == -1 {
+			return keyTemplate
+		}
+
+		start, end := index, index+len(placeholder)
+		if end < len(keyTemplate) && strings.ContainsRune(separators, rune(keyTemplate[end])) {
+			end++
+		} else if start > 0 && strings.ContainsRune(separators, rune(keyTemplate[start-1])) {
+			start--
+		}
+
+		keyTemplate = keyTemplate[:start] + keyTemplate[end:]
+	}
+}
+
+// formatAsNested formats the secrets as a json or yaml object tree that mirrors the folders below the exported path
+func formatAsNested(envs []models.SingleEnvironmentVariable, format string, basePath string) (string, error) {
+	sorted := append([]models.SingleEnvironmentVariable{}, envs...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		folderI := strings.Join(exportFolderOf(sorted[i], basePath), "/")
+		folderJ := strings.Join(exportFolderOf(sorted[j], basePath), "/")
+		if folderI != folderJ {
+			return folderI < folderJ
+		}
+		return sorted[i].Key < sorted[j].Key
+	})
+
+	tree := yaml.MapSlice{}
+	for _, env := range sorted {
+		folders := exportFolderOf(env, basePath)
+
+		var err error
+		tree, err = insertIntoExportTree(tree, folders, env.Key, env.Value)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	switch strings.ToLower(format) {
+	case FormatJson:
+		output, err := util.MarshalOrderedJSON(tree)
+		if err != nil {
+			return "", fmt.Errorf("unable to marshal secrets to JSON [err=%v]", err)
+		}
+		return string(output), nil
+	case FormatYaml:
+		output, err := yaml.Marshal(tree)
+		if err != nil {
+			return "", fmt.Errorf("unable to marshal secrets to YAML [err=%v]", err)
+		}
+		return string(output), nil
+	default:
+		return "", fmt.Errorf("nested output is only supported by the %s and %s formats, not %s", FormatJson, FormatYaml, format)
+	}
+}
+
+func insertIntoExportTree(tree yaml.MapSlice, folders []string, key string, value string) (yaml.MapSlice, error) {
+	if len(folders) == 0 {
+		for _, item := range tree {
+			if item.Key == key {
+				return nil, fmt.Errorf("%s is both the name of a secret and of a folder, it cannot be exported as a tree", key)
+			}
+		}
+		return append(tree, yaml.MapItem{Key: key, Value: value}), nil
+	}
+
+	for index, item := range tree {
+		if item.Key != folders[0] {
+			continue
+		}
+
+		subtree, ok := item.Value.(yaml.MapSlice)
+		if !ok {
+			return nil, fmt.Errorf("%s is both the name of a secret and of a folder, it cannot be exported as a tree", folders[0])
+		}
+
+		subtree, err := insertIntoExportTree(subtree, folders[1:], key, value)
+		if err != nil {
+			return nil, err
+		}
+		tree[index].Value = subtree
+		return tree, nil
+	}
+
+	subtree, err := insertIntoExportTree(yaml.MapSlice{}, folders[1:], key, value)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(tree, yaml.MapItem{Key: folders[0], Value: subtree}), nil
+}
@@ -0,0 +1,143 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Infisical/infisical-merge/packages/models"
+	"github.com/Infisical/infisical-merge/packages/util"
+)
+
+var exportFolderTestSecrets = []models.SingleEnvironmentVariable{
+	{Key: "HOST", Value: "root-host", SecretPath: "/"},
+	{Key: "HOST", Value: "db-host", SecretPath: "/db"},
+	{Key: "PORT", Value: "5432", SecretPath: "/db"},
+	{Key: "HOST", Value: "replica-host", SecretPath: "/db/replica"},
+	{Key: "SHARED", Value: "imported", SecretPath: "/common", Environment: "staging", IsImported: true},
+}
+
+func TestExportFolderOf(t *testing.T) {
+	tests := []struct {
+		secretPath string
+		basePath   string
+		expected   string
+	}{
+		{"/", "/", ""},
+		{"/db/replica", "/", "db/replica"},
+		{"/api/db", "/api", "db"},
+		{"/api", "/api/", ""},
+		{"/apiv2/db", "/api", "apiv2/db"},
+	}
+
+	for _, test := range tests {
+		folders := exportFolderOf(models.SingleEnvironmentVariable{SecretPath: test.secretPath}, test.basePath)
+		if strings.Join(folders, "/") != test.expected {
+			t.Errorf("Expected %q for %s below %s, got %v", test.expected, test.secretPath, test.basePath, folders)
+		}
+	}
+}
+
+func TestFormatAsNested(t *testing.T) {
+	output, err := formatEnvs(exportFolderTestSecrets, FormatJson, exportFormatOptions{BasePath: "/", Nested: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `{
+  "HOST": "root-host",
+  "SHARED": "imported",
+  "db": {
+    "HOST": "db-host",
+    "PORT": "5432",
+    "replica": {
+      "HOST": "replica-host"
+    }
+  }
+}`
+	if output != expected {
+		t.Errorf("Expected\n%s\ngot\n%s", expected, output)
+	}
+
+	conflicting := append([]models.SingleEnvironmentVariable{{Key: "db", Value: "x", SecretPath: "/"}}, exportFolderTestSecrets...)
+	if _, err := formatEnvs(conflicting, FormatJson, exportFormatOptions{BasePath: "/", Nested: true}); err == nil {
+		t.Error("Expected an error for a secret with the name of a folder")
+	}
+
+	if _, err := formatEnvs(exportFolderTestSecrets, FormatDotenv, exportFormatOptions{BasePath: "/", Nested: true}); err == nil {
+		t.Error("Expected an error for nested dotenv output")
+	}
+}
+
+func TestExportKeyTemplate(t *testing.T) {
+	output, err := formatEnvs(exportFolderTestSecrets, FormatDotenv, exportFormatOptions{BasePath: "/", KeyTemplate: "{{PATH}}_{{key}}"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "DB_HOST='db-host'\nDB_PORT='5432'\nDB_REPLICA_HOST='replica-host'\nHOST='root-host'\nSHARED='imported'\n"
+	if output != expected {
+		t.Errorf("Expected\n%s\ngot\n%s", expected, output)
+	}
+
+	if _, err := formatEnvs(exportFolderTestSecrets, FormatDotenv, exportFormatOptions{BasePath: "/", KeyTemplate: "{{path}}"}); err == nil {
+		t.Error("Expected an error for a template without {{key}}")
+	}
+
+	colliding := []models.SingleEnvironmentVariable{
+		{Key: "db_HOST", Value: "a", SecretPath: "/"},
+		{Key: "HOST", Value: "b", SecretPath: "/db"},
+	}
+	if _, err := formatEnvs(colliding, FormatDotenv, exportFormatOptions{BasePath: "/", KeyTemplate: "{{path}}_{{key}}"}); err == nil {
+		t.Error("Expected an error for keys that collide after applying the template")
+	}
+}
+
+func TestRemoveKeyTemplatePlaceholder(t *testing.T) {
+	tests := map[string]string{
+		"{{path}}_{{key}}":      "{{key}}",
+		"{{key}}.{{path}}":      "{{key}}",
+		"APP_{{path}}__{{key}}": "APP__{{key}}",
+		"{{path}}{{key}}":       "{{key}}",
+	}
+
+	for template, expected := range tests {
+		if result := removeKeyTemplatePlaceholder(template, EXPORT_KEY_TEMPLATE_PATH); result != expected {
+			t.Errorf("Expected %s for %s, got %s", expected, template, result)
+		}
+	}
+}
+
+func TestDropShadowedSecrets(t *testing.T) {
+	secrets := dropShadowedSecrets(exportFolderTestSecrets, "/")
+
+	values := []string{}
+	for _, secret := range secrets {
+		values = append(values, secret.Key+"="+secret.Value)
+	}
+
+	if strings.Join(values, ",") != "HOST=root-host,PORT=5432,SHARED=imported" {
+		t.Errorf("Expected the secrets closest to the exported path, got %v", values)
+	}
+}
+
+func TestOverrideSecretsPerFolder(t *testing.T) {
+	secrets := []models.SingleEnvironmentVariable{
+		{Key: "HOST", Value: "shared-root", Type: util.SECRET_TYPE_SHARED, SecretPath: "/"},
+		{Key: "HOST", Value: "personal-root", Type: util.SECRET_TYPE_PERSONAL, SecretPath: "/"},
+		{Key: "HOST", Value: "shared-db", Type: util.SECRET_TYPE_SHARED, SecretPath: "/db"},
+	}
+
+	overridden := util.SortSecretsByKeys(overrideSecretsPerFolder(secrets, util.SECRET_TYPE_PERSONAL, "/"))
+	if len(overridden) != 2 {
+		t.Fatalf("Expected one secret per folder, got %v", overridden)
+	}
+
+	valuesByPath := map[string]string{}
+	for _, secret := range overridden {
+		valuesByPath[secret.SecretPath] = secret.Value
+	}
+
+	if valuesByPath["/"] != "personal-root" || valuesByPath["/db"] != "shared-db" {
+		t.Errorf("Unexpected values %v", valuesByPath)
+	}
+}
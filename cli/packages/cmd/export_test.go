@@ -0,0 +1,366 @@
+package cmd
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Infisical/infisical-merge/packages/models"
+	"github.com/Infisical/infisical-merge/packages/util"
+	"gopkg.in/yaml.v2"
+)
+
+var exportTestSecrets = []models.SingleEnvironmentVariable{
+	{Key: "PLAIN", Value: "value"},
+	{Key: "EMPTY", Value: ""},
+	{Key: "SINGLE_QUOTE", Value: "it's"},
+	{Key: "DOUBLE_QUOTE", Value: `say "hi"`},
+	{Key: "MULTI_LINE", Value: "-----BEGIN KEY-----\nabc\n-----END KEY-----\n"},
+	{Key: "CARRIAGE_RETURN", Value: "a\r\nb"},
+	{Key: "COLON", Value: "key: value"},
+	{Key: "HASH", Value: "value # not a comment"},
+	{Key: "LEADING_HASH", Value: "#value"},
+	{Key: "DOLLAR", Value: "$HOME and ${PATH}"},
+	{Key: "BACKSLASH", Value: `C:\path\n\"x`},
+	{Key: "MIXED", Value: "it's \"$(rm -rf /)\"\n`id`"},
+	{Key: "SPACES", Value: "  padded  "},
+	{Key: "YAML_BOOL", Value: "true"},
+	{Key: "YAML_NULL", Value: "null"},
+	{Key: "YAML_NUMBER", Value: "0123"},
+	{Key: "YAML_INDICATORS", Value: "- [a, {b: c}] & *d !e | > % @ `"},
+	{Key: "UNICODE", Value: "päss wörd ✓"},
+}
+
+func TestFormatAsDotEnvRoundTrip(t *testing.T) {
+	entries, err := util.ParseDotEnv(formatAsDotEnv(exportTestSecrets))
+	if err != nil {
+		t.Fatalf("Unable to parse dotenv output: %v", err)
+	}
+
+	if len(entries) != len(exportTestSecrets) {
+		t.Fatalf("Expected %d entries, got %d", len(exportTestSecrets), len(entries))
+	}
+
+	for index, secret := range exportTestSecrets {
+		if entries[index].Key != secret.Key || entries[index].Value != secret.Value {
+			t.Errorf("Expected %s=%q, got %s=%q", secret.Key, secret.Value, entries[index].Key, entries[index].Value)
+		}
+	}
+}
+
+func TestFormatAsDotEnvExportRoundTrip(t *testing.T) {
+	shell, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh is not available")
+	}
+
+	file := filepath.Join(t.TempDir(), "secrets.sh")
+	if err := os.WriteFile(file, []byte(formatAsDotEnvExport(exportTestSecrets)), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// source the file and print every variable separated by a null byte
+	script := fmt.Sprintf(". %s && printf '%%s\\0'", util.QuoteShellValue(file))
+	for _, secret := range exportTestSecrets {
+		script += fmt.Sprintf(` "$%s"`, secret.Key)
+	}
+
+	output, err := exec.Command(shell, "-c", script).Output()
+	if err != nil {
+		t.Fatalf("Unable to source dotenv-export output: %v", err)
+	}
+
+	values := strings.Split(string(output), "\x00")
+	for index, secret := range exportTestSecrets {
+		if values[index] != secret.Value {
+			t.Errorf("Expected %s to be %q, got %q", secret.Key, secret.Value, values[index])
+		}
+	}
+}
+
+func TestFormatAsYamlRoundTrip(t *testing.T) {
+	output, err := formatAsYaml(exportTestSecrets)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed := yaml.MapSlice{}
+	if err := yaml.Unmarshal([]byte(output), &parsed); err != nil {
+		t.Fatalf("Unable to parse YAML output: %v", err)
+	}
+
+	if len(parsed) != len(exportTestSecrets) {
+		t.Fatalf("Expected %d keys, got %d", len(exportTestSecrets), len(parsed))
+	}
+
+	for index, secret := range exportTestSecrets {
+		value, ok := parsed[index].Value.(string)
+		if parsed[index].Key != secret.Key || !ok || value != secret.Value {
+			t.Errorf("Expected %s: %q, got %v: %#v", secret.Key, secret.Value, parsed[index].Key, parsed[index].Value)
+		}
+	}
+}
+
+func TestParseDotEnv(t *testing.T) {
+	entries, err := util.ParseDotEnv(`
+# database settings
+# used by the api
+export DB_HOST=localhost # inline comment
+DB_PASSWORD='p@ss#word'
+DB_CERT="line 1
+line 2"
+
+UNQUOTED = spaced value
+EMPTY=
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []util.DotEnvEntry{
+		{Key: "DB_HOST", Value: "localhost", Comment: "database settings\nused by the api"},
+		{Key: "DB_PASSWORD", Value: "p@ss#word"},
+		{Key: "DB_CERT", Value: "line 1\nline 2"},
+		{Key: "UNQUOTED", Value: "spaced value"},
+		{Key: "EMPTY", Value: ""},
+	}
+
+	if len(entries) != len(expected) {
+		t.Fatalf("Expected %d entries, got %+v", len(expected), entries)
+	}
+
+	for index := range expected {
+		if entries[index] != expected[index] {
+			t.Errorf("Expected %+v, got %+v", expected[index], entries[index])
+		}
+	}
+
+	for _, invalid := range []string{"NO_EQUALS", "1KEY=value", `KEY="unterminated`, `KEY='value' trailing`} {
+		if _, err := util.ParseDotEnv(invalid); err == nil {
+			t.Errorf("Expected %q to be rejected", invalid)
+		}
+	}
+}
+
+func TestFormatAsK8sSecret(t *testing.T) {
+	output, err := formatAsK8sSecret(exportTestSecrets, exportFormatOptions{
+		K8sName:      "api-secrets",
+		K8sNamespace: "production",
+		K8sLabels:    map[string]string{"team": "core", "app": "api"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var manifest struct {
+		ApiVersion string `yaml:"apiVersion"`
+		Kind       string `yaml:"kind"`
+		Type       string `yaml:"type"`
+		Metadata   struct {
+			Name      string            `yaml:"name"`
+			Namespace string            `yaml:"namespace"`
+			Labels    map[string]string `yaml:"labels"`
+		} `yaml:"metadata"`
+		Data map[string]string `yaml:"data"`
+	}
+	if err := yaml.UnmarshalStrict([]byte(output), &manifest); err != nil {
+		t.Fatalf("Unable to parse manifest: %v", err)
+	}
+
+	if manifest.ApiVersion != "v1" || manifest.Kind != "Secret" || manifest.Type != "Opaque" {
+		t.Errorf("Unexpected manifest header: %+v", manifest)
+	}
+
+	if manifest.Metadata.Name != "api-secrets" || manifest.Metadata.Namespace != "production" || manifest.Metadata.Labels["app"] != "api" || manifest.Metadata.Labels["team"] != "core" {
+		t.Errorf("Unexpected metadata: %+v", manifest.Metadata)
+	}
+
+	for _, secret := range exportTestSecrets {
+		decoded, err := base64.StdEncoding.DecodeString(manifest.Data[secret.Key])
+		if err != nil || string(decoded) != secret.Value {
+			t.Errorf("Expected %s to decode to %q, got %q", secret.Key, secret.Value, decoded)
+		}
+	}
+
+	if _, err := formatAsK8sSecret(exportTestSecrets, exportFormatOptions{}); err == nil {
+		t.Errorf("Expected a missing name to be rejected")
+	}
+}
+
+func TestFormatAsDockerEnv(t *testing.T) {
+	output, err := formatAsDockerEnv([]models.SingleEnvironmentVariable{
+		{Key: "QUOTED", Value: `"kept as is"`},
+		{Key: "SPACES", Value: " a b "},
+		{Key: "HASH", Value: "a#b"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "QUOTED=\"kept as is\"\nSPACES= a b \nHASH=a#b\n"
+	if output != expected {
+		t.Errorf("Expected %q, got %q", expected, output)
+	}
+
+	if _, err := formatAsDockerEnv([]models.SingleEnvironmentVariable{{Key: "CERT", Value: "a\nb"}}); err == nil {
+		t.Errorf("Expected multi-line values to be rejected")
+	}
+}
+
+func TestFormatAsTfvars(t *testing.T) {
+	output, err := formatAsTfvars([]models.SingleEnvironmentVariable{
+		{Key: "db_password", Value: `p"a\ss`},
+		{Key: "template", Value: "${var.x} %{if} $$ 100%"},
+		{Key: "cert", Value: "a\nb\tc\x01"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `db_password = "p\"a\\ss"` + "\n" +
+		`template = "$${var.x} %%{if} $$ 100%"` + "\n" +
+		`cert = "a\nb\tc\u0001"` + "\n"
+	if output != expected {
+		t.Errorf("Expected %q, got %q", expected, output)
+	}
+
+	if _, err := formatAsTfvars([]models.SingleEnvironmentVariable{{Key: "invalid.name", Value: "x"}}); err == nil {
+		t.Errorf("Expected invalid variable names to be rejected")
+	}
+}
+
+func TestFormatAsProperties(t *testing.T) {
+	output := formatAsProperties([]models.SingleEnvironmentVariable{
+		{Key: "db url", Value: "jdbc:postgresql://host:5432/db?a=b"},
+		{Key: "PADDED", Value: "  two spaces"},
+		{Key: "MULTI_LINE", Value: "a\nb\\c"},
+		{Key: "UNICODE", Value: "päss😀"},
+		{Key: "COMMENT", Value: "#!"},
+	})
+
+	expected := `db\ url=jdbc\:postgresql\://host\:5432/db?a\=b` + "\n" +
+		`PADDED=\  two spaces` + "\n" +
+		`MULTI_LINE=a\nb\\c` + "\n" +
+		`UNICODE=p\u00E4ss\uD83D\uDE00` + "\n" +
+		`COMMENT=\#\!` + "\n"
+	if output != expected {
+		t.Errorf("Expected %q, got %q", expected, output)
+	}
+}
+
+func TestFormatAsSystemdEnv(t *testing.T) {
+	output := formatAsSystemdEnv([]models.SingleEnvironmentVariable{
+		{Key: "QUOTES", Value: `say "hi" it's`},
+		{Key: "SHELL", Value: "$HOME `id` \\"},
+		{Key: "MULTI_LINE", Value: "a\nb"},
+	})
+
+	expected := `QUOTES="say \"hi\" it's"` + "\n" +
+		"SHELL=\"\\$HOME \\`id\\` \\\\\"\n" +
+		"MULTI_LINE=\"a\nb\"\n"
+	if output != expected {
+		t.Errorf("Expected %q, got %q", expected, output)
+	}
+}
+
+func TestFormatAsPowerShell(t *testing.T) {
+	output := formatAsPowerShell([]models.SingleEnvironmentVariable{
+		{Key: "QUOTE", Value: "it's $HOME `n"},
+		{Key: "SMART_QUOTE", Value: "it’s"},
+		{Key: "my-key", Value: "a\nb"},
+	})
+
+	expected := "$env:QUOTE = 'it''s $HOME `n'\n" +
+		"$env:SMART_QUOTE = 'it’’s'\n" +
+		"${env:my-key} = 'a\nb'\n"
+	if output != expected {
+		t.Errorf("Expected %q, got %q", expected, output)
+	}
+}
+
+var exportMetadataTestSecrets = []models.SingleEnvironmentVariable{
+	{Key: "DB_URL", Value: "postgres://db", Type: util.SECRET_TYPE_SHARED, Comment: "primary, read/write", Environment: "dev", SecretPath: "/api"},
+	{Key: "TOKEN", Value: "personal-token", Type: util.SECRET_TYPE_PERSONAL, Environment: "staging", SecretPath: "/common", IsImported: true},
+}
+
+func init() {
+	exportMetadataTestSecrets[0].Tags = append(exportMetadataTestSecrets[0].Tags, struct {
+		ID        string `json:"_id"`
+		Name      string `json:"name"`
+		Slug      string `json:"slug"`
+		Workspace string `json:"workspace"`
+	}{Name: "Backend", Slug: "backend"})
+}
+
+func TestFormatAsJsonKeepsSecretShape(t *testing.T) {
+	output := formatAsJson(exportMetadataTestSecrets[:1])
+
+	expected := `[{"key":"DB_URL","workspace":"","value":"postgres://db","type":"shared","_id":"","tags":[{"_id":"","name":"Backend","slug":"backend","workspace":""}],"comment":"primary, read/write"}]`
+	if output != expected {
+		t.Errorf("Expected\n%s\ngot\n%s", expected, output)
+	}
+}
+
+func TestFormatWithMetadataJson(t *testing.T) {
+	output, err := formatEnvs(exportMetadataTestSecrets, FormatJson, exportFormatOptions{WithMetadata: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `[{"key":"DB_URL","value":"postgres://db","type":"shared","environment":"dev","path":"/api","imported":false,"comment":"primary, read/write","tags":["backend"]},` +
+		`{"key":"TOKEN","value":"personal-token","type":"personal","environment":"staging","path":"/common","imported":true,"comment":"","tags":[]}]`
+	if output != expected {
+		t.Errorf("Expected\n%s\ngot\n%s", expected, output)
+	}
+}
+
+func TestFormatWithMetadataWithoutValues(t *testing.T) {
+	options := exportFormatOptions{WithMetadata: true, OmitValues: true}
+
+	for _, format := range exportMetadataFormats {
+		output, err := formatEnvs(exportMetadataTestSecrets, format, options)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if strings.Contains(output, "postgres://db") || strings.Contains(output, "personal-token") || strings.Contains(strings.ToLower(output), "value") {
+			t.Errorf("Expected no values in the %s inventory, got\n%s", format, output)
+		}
+	}
+
+	csvOutput, _ := formatEnvs(exportMetadataTestSecrets, FormatCSV, options)
+	expected := "Key,Type,Environment,Path,Imported,Comment,Tags\nDB_URL,shared,dev,/api,false,\"primary, read/write\",backend\nTOKEN,personal,staging,/common,true,,\n"
+	if csvOutput != expected {
+		t.Errorf("Expected\n%s\ngot\n%s", expected, csvOutput)
+	}
+
+	if _, err := formatEnvs(exportMetadataTestSecrets, FormatDotenv, options); err == nil {
+		t.Error("Expected an error for metadata in the dotenv format")
+	}
+}
+
+func TestFormatWithMetadataYamlRoundTrip(t *testing.T) {
+	output, err := formatEnvs(exportMetadataTestSecrets, FormatYaml, exportFormatOptions{WithMetadata: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records := []exportSecretMetadata{}
+	if err := yaml.UnmarshalStrict([]byte(output), &records); err != nil {
+		t.Fatalf("Unable to parse YAML output: %v", err)
+	}
+
+	if len(records) != 2 || *records[0].Value != "postgres://db" || records[0].Tags[0] != "backend" || !records[1].Imported || records[1].Type != util.SECRET_TYPE_PERSONAL {
+		t.Errorf("Unexpected records %+v", records)
+	}
+
+	secrets, err := parseImportedSecrets([]byte(output), FormatYaml, nil)
+	if err != nil {
+		t.Fatalf("Unable to import the YAML inventory: %v", err)
+	}
+	assertImportedSecrets(t, secrets, exportMetadataTestSecrets)
+}
@@ -1,8 +1,11 @@
 package cmd
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"path"
+	"strings"
 
 	"github.com/Infisical/infisical-merge/packages/models"
 	"github.com/Infisical/infisical-merge/packages/util"
@@ -13,7 +16,7 @@ import (
 
 var folderCmd = &cobra.Command{
 	Use:                   "folders",
-	Short:                 "Create, delete, and list folders",
+	Short:                 "Create, delete, rename, and list folders",
 	DisableFlagsInUseLine: true,
 	Run: func(cmd *cobra.Command, args []string) {
 		cmd.Help()
@@ -47,6 +50,20 @@ var getCmd = &cobra.Command{
 			util.HandleError(err, "Unable to parse flag")
 		}
 
+		recursive, err := cmd.Flags().GetBool("recursive")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		outputFormat, err := cmd.Flags().GetString("output")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		if outputFormat != "table" && outputFormat != "json" {
+			util.PrintErrorMessageAndExit(fmt.Sprintf("invalid output format: %s. Available formats are [table json]", outputFormat))
+		}
+
 		request := models.GetAllFoldersParameters{
 			Environment: environmentName,
 			WorkspaceId: projectId,
@@ -59,12 +76,36 @@ var getCmd = &cobra.Command{
 			request.UniversalAuthAccessToken = token.Token
 		}
 
+		if recursive {
+			tree, err := util.GetFolderTree(request)
+			if err != nil {
+				util.HandleError(err, "Unable to get folders")
+			}
+
+			if outputFormat == "json" {
+				printFoldersJson(tree)
+			} else {
+				fmt.Print(formatFolderTree(tree))
+			}
+
+			Telemetry.CaptureEvent("cli-command:folders get", posthog.NewProperties().Set("folderCount", countFolderTreeFolders(tree)).Set("recursive", true).Set("version", util.CLI_VERSION))
+			return
+		}
+
 		folders, err := util.GetAllFolders(request)
 		if err != nil {
 			util.HandleError(err, "Unable to get folders")
 		}
 
-		visualize.PrintAllFoldersDetails(folders, foldersPath)
+		if outputFormat == "json" {
+			records := []folderRecord{}
+			for _, folder := range folders {
+				records = append(records, folderRecord{ID: folder.ID, Name: folder.Name, Path: path.Join("/", foldersPath, folder.Name)})
+			}
+			printFoldersJson(records)
+		} else {
+			visualize.PrintAllFoldersDetails(folders, foldersPath)
+		}
 		Telemetry.CaptureEvent("cli-command:folders get", posthog.NewProperties().Set("folderCount", len(folders)).Set("version", util.CLI_VERSION))
 	},
 }
@@ -177,13 +218,47 @@ var deleteCmd = &cobra.Command{
 			util.HandleError(errors.New("invalid folder name, folder name cannot be empty"))
 		}
 
-		if projectId == "" {
-			workspaceFile, err := util.GetWorkSpaceFromFile()
-			if err != nil {
-				util.HandleError(err, "Unable to get workspace file")
-			}
+		recursive, err := cmd.Flags().GetBool("recursive")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
 
-			projectId = workspaceFile.WorkspaceId
+		skipConfirmation, err := cmd.Flags().GetBool("yes")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		projectId = getFolderProjectId(token, projectId)
+
+		treeRequest := models.GetAllFoldersParameters{
+			Environment: environmentName,
+			WorkspaceId: projectId,
+			FoldersPath: path.Join("/", folderPath, folderName),
+		}
+
+		if token != nil && token.Type == util.SERVICE_TOKEN_IDENTIFIER {
+			treeRequest.InfisicalToken = token.Token
+		} else if token != nil && token.Type == util.UNIVERSAL_AUTH_TOKEN_IDENTIFIER {
+			treeRequest.UniversalAuthAccessToken = token.Token
+		}
+
+		tree, err := util.GetFolderTree(treeRequest)
+		if err != nil {
+			util.HandleError(err, "Unable to get the contents of the folder")
+		}
+
+		secretCount := countFolderTreeSecrets(tree)
+		folderCount := countFolderTreeFolders(tree)
+		if secretCount > 0 || folderCount > 0 {
+			if recursive {
+				fmt.Print(formatFolderTreeContents(tree))
+				if !skipConfirmation && !confirmChange(fmt.Sprintf("Delete %s with its %d secrets and %d folders?", tree.Path, secretCount, folderCount)) {
+					return
+				}
+			} else {
+				// deleting a folder with contents still works without --recursive for one release, so scripts can move to it
+				util.PrintWarning(fmt.Sprintf("the folder %s contains %d secrets and %d folders and is deleted with all of its contents. Deleting a folder that is not empty without --recursive is deprecated and will fail in the next release, add --recursive to list the contents and confirm", tree.Path, secretCount, folderCount))
+			}
 		}
 
 		params := models.DeleteFolderParameters{
@@ -204,6 +279,175 @@ var deleteCmd = &cobra.Command{
 
 		util.PrintSuccessMessage(fmt.Sprintf("folder named `%s` deleted in path %s", folderName, folderPath))
 
-		Telemetry.CaptureEvent("cli-command:folders delete", posthog.NewProperties().Set("version", util.CLI_VERSION))
+		Telemetry.CaptureEvent("cli-command:folders delete", posthog.NewProperties().Set("recursive", recursive).Set("version", util.CLI_VERSION))
+	},
+}
+
+var renameCmd = &cobra.Command{
+	Example: `folders rename --path /apps --name api --new-name backend`,
+	Use:     "rename",
+	Short:   "Rename a folder",
+	Args:    cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		environmentName, _ := cmd.Flags().GetString("env")
+		if !cmd.Flags().Changed("env") {
+			environmentFromWorkspace := util.GetEnvFromWorkspaceFile()
+			if environmentFromWorkspace != "" {
+				environmentName = environmentFromWorkspace
+			}
+		}
+
+		token, err := util.GetInfisicalToken(cmd)
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		projectId, err := cmd.Flags().GetString("projectId")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		folderPath, err := cmd.Flags().GetString("path")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		folderName, err := cmd.Flags().GetString("name")
+		if err != nil {
+			util.HandleError(err, "Unable to parse name flag")
+		}
+
+		newFolderName, err := cmd.Flags().GetString("new-name")
+		if err != nil {
+			util.HandleError(err, "Unable to parse new-name flag")
+		}
+
+		if folderName == "" || newFolderName == "" {
+			util.HandleError(errors.New("invalid folder name, folder name cannot be empty"))
+		}
+
+		if strings.Contains(newFolderName, "/") {
+			util.HandleError(errors.New("invalid folder name, use --path to choose the parent folder instead of a name with slashes"))
+		}
+
+		params := models.RenameFolderParameters{
+			FolderName:    folderName,
+			NewFolderName: newFolderName,
+			WorkspaceId:   getFolderProjectId(token, projectId),
+			Environment:   environmentName,
+			FolderPath:    folderPath,
+		}
+
+		if token != nil && (token.Type == util.SERVICE_TOKEN_IDENTIFIER || token.Type == util.UNIVERSAL_AUTH_TOKEN_IDENTIFIER) {
+			params.InfisicalToken = token.Token
+		}
+
+		_, err = util.RenameFolder(params)
+		if err != nil {
+			util.HandleError(err, "Unable to rename folder")
+		}
+
+		util.PrintSuccessMessage(fmt.Sprintf("folder `%s` renamed to `%s` in path %s", folderName, newFolderName, folderPath))
+
+		Telemetry.CaptureEvent("cli-command:folders rename", posthog.NewProperties().Set("version", util.CLI_VERSION))
 	},
 }
+
+// getFolderProjectId returns the project of the --projectId flag, of the service token, or of the workspace file
+func getFolderProjectId(token *models.TokenDetails, projectId string) string {
+	if projectId != "" {
+		return projectId
+	}
+
+	if token != nil && token.Type == util.SERVICE_TOKEN_IDENTIFIER {
+		projectId, err := util.GetServiceTokenProjectId(token.Token)
+		if err != nil {
+			util.HandleError(err, "Unable to get the project of the service token")
+		}
+		return projectId
+	}
+
+	workspaceFile, err := util.GetWorkSpaceFromFile()
+	if err != nil {
+		util.HandleError(err, "Unable to get workspace file")
+	}
+
+	return workspaceFile.WorkspaceId
+}
+
+type folderRecord struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Path string `json:"path"`
+}
+
+func printFoldersJson(value interface{}) {
+	output, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		util.HandleError(err, "Unable to marshal folders to JSON")
+	}
+
+	fmt.Println(string(output))
+}
+
+// formatFolderTree draws the folder and its sub-folders as a tree, with the number of secrets of each folder
+func formatFolderTree(tree models.FolderTree) string {
+	var output strings.Builder
+	output.WriteString(fmt.Sprintf("%s (%s)\n", tree.Path, pluralizeSecrets(tree.SecretCount)))
+	writeFolderTreeChildren(&output, tree, "")
+	return output.String()
+}
+
+func writeFolderTreeChildren(output *strings.Builder, tree models.FolderTree, prefix string) {
+	for i, folder := range tree.Folders {
+		branch, childPrefix := "├── ", "│   "
+		if i == len(tree.Folders)-1 {
+			branch, childPrefix = "└── ", "    "
+		}
+
+		output.WriteString(fmt.Sprintf("%s%s%s (%s)\n", prefix, branch, folder.Name, pluralizeSecrets(folder.SecretCount)))
+		writeFolderTreeChildren(output, folder, prefix+childPrefix)
+	}
+}
+
+// formatFolderTreeContents lists every folder and secret of the tree, for the confirmation of a recursive delete
+func formatFolderTreeContents(tree models.FolderTree) string {
+	var output strings.Builder
+	for _, secret := range tree.Secrets {
+		secretType := secret.Type
+		if secretType == "" {
+			secretType = util.SECRET_TYPE_SHARED
+		}
+		output.WriteString(fmt.Sprintf("  secret  %s (%s)\n", path.Join(tree.Path, secret.Key), secretType))
+	}
+
+	for _, folder := range tree.Folders {
+		output.WriteString(fmt.Sprintf("  folder  %s\n", folder.Path))
+		output.WriteString(formatFolderTreeContents(folder))
+	}
+
+	return output.String()
+}
+
+func countFolderTreeSecrets(tree models.FolderTree) int {
+	count := tree.SecretCount
+	for _, folder := range tree.Folders {
+		count += countFolderTreeSecrets(folder)
+	}
+	return count
+}
+
+func countFolderTreeFolders(tree models.FolderTree) int {
+	count := len(tree.Folders)
+	for _, folder := range tree.Folders {
+		count += countFolderTreeFolders(folder)
+	}
+	return count
+}
+
+func pluralizeSecrets(count int) string {
+	if count == 1 {
+		return "1 secret"
+	}
+	return fmt.Sprintf("%d secrets", count)
+}
@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Infisical/infisical-merge/packages/models"
+)
+
+func TestFormatFolderTree(t *testing.T) {
+	tree := models.FolderTree{
+		Name:        "/",
+		Path:        "/",
+		SecretCount: 3,
+		Secrets:     []models.SingleEnvironmentVariable{{Key: "A"}, {Key: "B"}, {Key: "C", Type: "personal"}},
+		Folders: []models.FolderTree{
+			{Name: "api", Path: "/api", SecretCount: 1, Secrets: []models.SingleEnvironmentVariable{{Key: "DB"}}, Folders: []models.FolderTree{
+				{Name: "db", Path: "/api/db"},
+			}},
+			{Name: "web", Path: "/web"},
+		},
+	}
+
+	expected := strings.Join([]string{
+		"/ (3 secrets)",
+		"├── api (1 secret)",
+		"│   └── db (0 secrets)",
+		"└── web (0 secrets)",
+		"",
+	}, "\n")
+	if output := formatFolderTree(tree); output != expected {
+		t.Errorf("formatFolderTree() =\n%s\nexpected:\n%s", output, expected)
+	}
+
+	if countFolderTreeSecrets(tree) != 4 || countFolderTreeFolders(tree) != 3 {
+		t.Errorf("expected 4 secrets and 3 folders, got %d and %d", countFolderTreeSecrets(tree), countFolderTreeFolders(tree))
+	}
+
+	contents := formatFolderTreeContents(tree)
+	for _, line := range []string{"secret  /C (personal)", "folder  /api/db", "secret  /api/DB (shared)"} {
+		if !strings.Contains(contents, line) {
+			t.Errorf("expected %q in the contents:\n%s", line, contents)
+		}
+	}
+}
@@ -0,0 +1,422 @@
+/*
+Copyright (c) 2023 Infisical Inc.
+*/
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"filippo.io/age"
+	"github.com/Infisical/infisical-merge/packages/crypto"
+	"github.com/Infisical/infisical-merge/packages/models"
+	"github.com/Infisical/infisical-merge/packages/util"
+	"github.com/Infisical/infisical-merge/packages/visualize"
+	"github.com/manifoldco/promptui"
+	"github.com/posthog/posthog-go"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+	"gopkg.in/yaml.v2"
+)
+
+var importCmd = &cobra.Command{
+	Use:                   "import [file]",
+	Short:                 "Used to set secrets from a file created by infisical export, optionally encrypted with age or sops",
+	DisableFlagsInUseLine: true,
+	Example:               "infisical import --env=prod --decrypt secrets.enc.yaml",
+	Args:                  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		runImport(cmd, args[0], "cli-command:import")
+	},
+}
+
+var secretsImportCmd = &cobra.Command{
+	Use:                   "import",
+	Short:                 "Used to set secrets from a dotenv, JSON or YAML file after previewing the changes",
+	DisableFlagsInUseLine: true,
+	Example:               "infisical secrets import --env=dev --file=.env",
+	Args:                  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		filePath, err := cmd.Flags().GetString("file")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		runImport(cmd, filePath, "cli-command:secrets import")
+	},
+}
+
+func init() {
+	importCmd.Flags().StringP("env", "e", "dev", "Set the environment (dev, prod, etc.) to import the secrets into")
+	addImportFlags(importCmd)
+	rootCmd.AddCommand(importCmd)
+
+	secretsImportCmd.Flags().String("file", "", "The file to import, - reads from stdin")
+	secretsImportCmd.MarkFlagRequired("file")
+	addImportFlags(secretsImportCmd)
+	secretsCmd.AddCommand(secretsImportCmd)
+}
+
+func addImportFlags(cmd *cobra.Command) {
+	cmd.Flags().String("token", "", "Set secrets using service token or machine identity access token")
+	cmd.Flags().String("projectId", "", "manually set the project ID to import secrets into when using machine identity based auth")
+	cmd.Flags().String("path", "/", "import secrets into a folder path")
+	cmd.Flags().String("type", util.SECRET_TYPE_SHARED, "the type of secret to create: personal or shared")
+	cmd.Flags().StringP("format", "f", "", "The format of the file (dotenv, dotenv-export, json, yaml), detected from the file extension by default")
+	cmd.Flags().Bool("decrypt", false, "Decrypt a file encrypted with age or sops, e.g. by infisical export --encrypt")
+	cmd.Flags().String("identity", "", "The age identity file used to decrypt, defaults to SOPS_AGE_KEY, SOPS_AGE_KEY_FILE or the sops keys.txt")
+	cmd.Flags().BoolP("yes", "y", false, "Apply the changes without asking for confirmation, required when not running in a terminal or reading the file from stdin")
+}
+
+// runImport reads the secrets of a file, previews the secrets that are created and modified and applies them once confirmed
+func runImport(cmd *cobra.Command, filePath string, telemetryEvent string) {
+	environmentName, _ := cmd.Flags().GetString("env")
+	if !cmd.Flags().Changed("env") {
+		environmentFromWorkspace := util.GetEnvFromWorkspaceFile()
+		if environmentFromWorkspace != "" {
+			environmentName = environmentFromWorkspace
+		}
+	}
+
+	token, err := util.GetInfisicalToken(cmd)
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+
+	projectId, err := cmd.Flags().GetString("projectId")
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+
+	secretsPath, err := cmd.Flags().GetString("path")
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+
+	secretType, err := cmd.Flags().GetString("type")
+	if err != nil || (secretType != util.SECRET_TYPE_SHARED && secretType != util.SECRET_TYPE_PERSONAL) {
+		util.HandleError(err, "Unable to parse secret type")
+	}
+
+	format, err := cmd.Flags().GetString("format")
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+
+	shouldDecrypt, err := cmd.Flags().GetBool("decrypt")
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+
+	identityFilePath, err := cmd.Flags().GetString("identity")
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+
+	skipConfirmation, err := cmd.Flags().GetBool("yes")
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+
+	data, err := readImportFile(filePath)
+	if err != nil {
+		util.HandleError(err, "Unable to read the file to import")
+	}
+
+	if format == "" {
+		format = importFormatFromFileName(filePath)
+	}
+
+	var identities []age.Identity
+	if shouldDecrypt {
+		identities, err = crypto.ReadAgeIdentities(identityFilePath)
+		if err != nil {
+			util.HandleError(err)
+		}
+	}
+
+	secrets, err := parseImportedSecrets(data, format, identities)
+	if err != nil {
+		util.HandleError(err, "Unable to parse the file to import")
+	}
+
+	secrets = dropEmptyImportedSecrets(secrets)
+	if len(secrets) == 0 {
+		util.PrintErrorMessageAndExit("The file does not contain any secrets to import")
+	}
+
+	if err := util.ValidateSecretsToSet(secrets); err != nil {
+		util.HandleError(err, "Unable to import secrets")
+	}
+
+	// stdin holds the file when it is read from -, so the confirmation cannot be read from it
+	canPrompt := filePath != "-" && term.IsTerminal(int(os.Stdin.Fd()))
+	confirmed := false
+	confirm := func(secretOperations []models.SecretSetOperation) bool {
+		printSecretOperations(secretOperations)
+
+		if !hasSecretChanges(secretOperations) {
+			return false
+		}
+
+		if !skipConfirmation && !canPrompt {
+			util.PrintErrorMessageAndExit("Unable to ask for confirmation when not running in a terminal or reading the file from stdin, use --yes to apply the changes")
+		}
+
+		if !skipConfirmation {
+			shouldApply, err := shouldApplyImportPrompt()
+			if err != nil {
+				util.HandleError(err, "Unable to read your answer")
+			}
+			if !shouldApply {
+				return false
+			}
+		}
+
+		confirmed = true
+		return true
+	}
+
+	if token != nil && (token.Type == util.SERVICE_TOKEN_IDENTIFIER || token.Type == util.UNIVERSAL_AUTH_TOKEN_IDENTIFIER) {
+		_, err = util.SetRawSecretsFromList(secrets, secretType, environmentName, secretsPath, projectId, token, confirm)
+	} else {
+		util.RequireLogin()
+		util.RequireLocalWorkspaceFile()
+
+		_, err = util.SetEncryptedSecretsFromList(secrets, secretType, environmentName, secretsPath, confirm)
+	}
+
+	if err != nil {
+		util.HandleError(err, "Unable to import secrets")
+	}
+
+	if confirmed {
+		util.PrintSuccessMessage("The secrets have been imported")
+	} else {
+		fmt.Println("No secrets were changed")
+	}
+
+	Telemetry.CaptureEvent(telemetryEvent, posthog.NewProperties().Set("secretsCount", len(secrets)).Set("isEncrypted", shouldDecrypt).Set("version", util.CLI_VERSION))
+}
+
+// dropEmptyImportedSecrets skips secrets without a value since those cannot be set through the CLI
+func dropEmptyImportedSecrets(secrets []models.SingleEnvironmentVariable) []models.SingleEnvironmentVariable {
+	nonEmpty := []models.SingleEnvironmentVariable{}
+	for _, secret := range secrets {
+		if secret.Value == "" {
+			util.PrintWarning(fmt.Sprintf("skipping %s, it has an empty value", secret.Key))
+			continue
+		}
+		nonEmpty = append(nonEmpty, secret)
+	}
+
+	return nonEmpty
+}
+
+func hasSecretChanges(secretOperations []models.SecretSetOperation) bool {
+	for _, secretOperation := range secretOperations {
+		if secretOperation.SecretOperation != "SECRET VALUE UNCHANGED" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// printSecretOperations previews the changes of an import without the values, which would end up in terminal and CI logs
+func printSecretOperations(secretOperations []models.SecretSetOperation) {
+	headers := [...]string{"SECRET NAME", "CHANGE", "STATUS"}
+	rows := [][3]string{}
+	for _, secretOperation := range secretOperations {
+		rows = append(rows, [...]string{secretOperation.SecretKey, secretOperationChange(secretOperation), secretOperation.SecretOperation})
+	}
+
+	visualize.Table(headers, rows)
+}
+
+// secretOperationChange returns add, update or unchanged for an operation of SetRawSecretsFromList or SetEncryptedSecretsFromList
+func secretOperationChange(secretOperation models.SecretSetOperation) string {
+	switch secretOperation.SecretOperation {
+	case "SECRET CREATED":
+		return "add"
+	case "SECRET VALUE UNCHANGED":
+		return "unchanged"
+	default:
+		return "update"
+	}
+}
+
+func shouldApplyImportPrompt() (bool, error) {
+	prompt := promptui.Select{
+		Label: "Would you like to apply these changes? Select[Yes/No]",
+		Items: []string{"No", "Yes"},
+	}
+	_, result, err := prompt.Run()
+	if err != nil {
+		return false, err
+	}
+	return result == "Yes", nil
+}
+
+// readImportFile reads the file to import, - reads from stdin
+func readImportFile(filePath string) ([]byte, error) {
+	if filePath == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+
+	return os.ReadFile(filePath)
+}
+
+// importFormatFromFileName detects the format from the extension, ignoring the .age extension of encrypted files (e.g. secrets.env.age)
+func importFormatFromFileName(filePath string) string {
+	extension := strings.ToLower(filepath.Ext(filePath))
+	if extension == ".age" {
+		extension = strings.ToLower(filepath.Ext(strings.TrimSuffix(filePath, filepath.Ext(filePath))))
+	}
+
+	switch extension {
+	case ".json":
+		return FormatJson
+	case ".yaml", ".yml":
+		return FormatYaml
+	default:
+		return FormatDotenv
+	}
+}
+
+// parseImportedSecrets reads the secrets of a file written by infisical export in the dotenv, json or yaml format.
+// Files encrypted with age or sops can only be read when identities are given.
+func parseImportedSecrets(data []byte, format string, identities []age.Identity) ([]models.SingleEnvironmentVariable, error) {
+	if crypto.IsAgeEncrypted(data) {
+		if identities == nil {
+			return nil, fmt.Errorf("the file is encrypted with age, use --decrypt to decrypt it")
+		}
+
+		decrypted, err := crypto.AgeDecrypt(data, identities)
+		if err != nil {
+			return nil, fmt.Errorf("unable to decrypt the file [err=%v]", err)
+		}
+		data = decrypted
+	}
+
+	var document interface{}
+	switch strings.ToLower(format) {
+	case FormatDotenv, FormatDotEnvExport:
+		entries, err := util.ParseDotEnv(string(data))
+		if err != nil {
+			return nil, err
+		}
+
+		secrets := []models.SingleEnvironmentVariable{}
+		for _, entry := range entries {
+			secrets = append(secrets, models.SingleEnvironmentVariable{Key: entry.Key, Value: entry.Value, Comment: entry.Comment})
+		}
+		return secrets, nil
+	case FormatJson:
+		parsed, err := util.UnmarshalOrderedJSON(data)
+		if err != nil {
+			return nil, err
+		}
+		document = parsed
+	case FormatYaml:
+		parsed, err := unmarshalYamlDocument(data)
+		if err != nil {
+			return nil, err
+		}
+		document = parsed
+	default:
+		return nil, fmt.Errorf("invalid format type: %s. Available format types are [%s %s %s %s]", format, FormatDotenv, FormatDotEnvExport, FormatJson, FormatYaml)
+	}
+
+	if mapping, ok := document.(yaml.MapSlice); ok && crypto.IsSopsDocument(mapping) {
+		if identities == nil {
+			return nil, fmt.Errorf("the file is encrypted with sops, use --decrypt to decrypt it")
+		}
+
+		decrypted, err := crypto.SopsDecrypt(mapping, identities)
+		if err != nil {
+			return nil, fmt.Errorf("unable to decrypt the file [err=%v]", err)
+		}
+		document = decrypted
+	}
+
+	return secretsFromDocument(document)
+}
+
+// unmarshalYamlDocument decodes a YAML mapping, or a list of mappings, keeping the order of the keys
+func unmarshalYamlDocument(data []byte) (interface{}, error) {
+	mapping := yaml.MapSlice{}
+	if err := yaml.Unmarshal(data, &mapping); err == nil {
+		return mapping, nil
+	}
+
+	list := []yaml.MapSlice{}
+	if err := yaml.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("unable to parse YAML, expected a mapping or a list of mappings [err=%v]", err)
+	}
+
+	document := make([]interface{}, 0, len(list))
+	for _, item := range list {
+		document = append(document, item)
+	}
+
+	return document, nil
+}
+
+// secretsFromDocument reads a KEY: value mapping, or the list of secret objects written by the json format.
+// Encrypted exports hold that list under EXPORT_ENCRYPTED_SECRETS_KEY.
+func secretsFromDocument(document interface{}) ([]models.SingleEnvironmentVariable, error) {
+	if mapping, ok := document.(yaml.MapSlice); ok && len(mapping) == 1 && mapping[0].Key == EXPORT_ENCRYPTED_SECRETS_KEY {
+		if list, ok := mapping[0].Value.([]interface{}); ok {
+			document = list
+		}
+	}
+
+	secrets := []models.SingleEnvironmentVariable{}
+	switch typedDocument := document.(type) {
+	case yaml.MapSlice:
+		for _, item := range typedDocument {
+			key := fmt.Sprint(item.Key)
+			switch item.Value.(type) {
+			case yaml.MapSlice, []interface{}:
+				return nil, fmt.Errorf("the value of %s is not a string, nested values cannot be imported", key)
+			case nil:
+				secrets = append(secrets, models.SingleEnvironmentVariable{Key: key})
+			default:
+				secrets = append(secrets, models.SingleEnvironmentVariable{Key: key, Value: fmt.Sprint(item.Value)})
+			}
+		}
+	case []interface{}:
+		for index, item := range typedDocument {
+			object, ok := item.(yaml.MapSlice)
+			if !ok {
+				return nil, fmt.Errorf("item %d of the list is not a secret object", index)
+			}
+
+			secret := models.SingleEnvironmentVariable{}
+			for _, field := range object {
+				value, _ := field.Value.(string)
+				switch field.Key {
+				case "key":
+					secret.Key = value
+				case "value":
+					secret.Value = value
+				case "comment":
+					secret.Comment = value
+				}
+			}
+
+			if secret.Key == "" {
+				return nil, fmt.Errorf("item %d of the list does not have a key", index)
+			}
+			secrets = append(secrets, secret)
+		}
+	default:
+		return nil, fmt.Errorf("expected a mapping of keys to values or a list of secrets")
+	}
+
+	return secrets, nil
+}
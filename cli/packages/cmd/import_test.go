@@ -0,0 +1,197 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+	"github.com/Infisical/infisical-merge/packages/models"
+	"github.com/Infisical/infisical-merge/packages/util"
+	"gopkg.in/yaml.v2"
+)
+
+func TestEncryptedExportRoundTrip(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	recipient := "age:" + identity.Recipient().String()
+
+	for _, format := range []string{FormatDotenv, FormatYaml, FormatJson} {
+		t.Run(format, func(t *testing.T) {
+			output, err := formatEnvs(exportTestSecrets, format, exportFormatOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			encrypted, err := encryptExportOutput(output, format, []string{recipient})
+			if err != nil {
+				t.Fatalf("Unable to encrypt: %v", err)
+			}
+
+			if strings.Contains(encrypted, "päss wörd") {
+				t.Fatalf("Expected values to be encrypted, got %s", encrypted)
+			}
+
+			if _, err := parseImportedSecrets([]byte(encrypted), format, nil); err == nil || !strings.Contains(err.Error(), "--decrypt") {
+				t.Fatalf("Expected encrypted files to require --decrypt, got %v", err)
+			}
+
+			secrets, err := parseImportedSecrets([]byte(encrypted), format, []age.Identity{identity})
+			if err != nil {
+				t.Fatalf("Unable to decrypt: %v", err)
+			}
+
+			assertImportedSecrets(t, secrets, exportTestSecrets)
+		})
+	}
+}
+
+func TestSopsExportFormat(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encrypted, err := encryptExportOutput("A: one\nEMPTY: \"\"\nPORT_unencrypted: \"8080\"\n", FormatYaml, []string{"age:" + identity.Recipient().String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	document := yaml.MapSlice{}
+	if err := yaml.Unmarshal([]byte(encrypted), &document); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(document[0].Value.(string), "ENC[AES256_GCM,data:") || !strings.HasSuffix(document[0].Value.(string), ",type:str]") {
+		t.Errorf("Expected a sops encrypted value, got %v", document[0].Value)
+	}
+	if document[1].Value != "" || document[2].Value != "8080" {
+		t.Errorf("Expected empty values and keys with the unencrypted suffix to be kept, got %v", document)
+	}
+	if document[3].Key != "sops" {
+		t.Fatalf("Expected sops metadata at the end of the document, got %v", document[3].Key)
+	}
+
+	// moving an encrypted value to another key must break its authentication
+	tampered := strings.Replace(encrypted, "A: ", "B: ", 1)
+	if _, err := parseImportedSecrets([]byte(tampered), FormatYaml, []age.Identity{identity}); err == nil {
+		t.Error("Expected a value moved to another key to fail decryption")
+	}
+
+	// and so must changing a value that is not encrypted
+	tampered = strings.Replace(encrypted, `"8080"`, `"9090"`, 1)
+	if _, err := parseImportedSecrets([]byte(tampered), FormatYaml, []age.Identity{identity}); err == nil || !strings.Contains(err.Error(), "MAC") {
+		t.Errorf("Expected a MAC mismatch, got %v", err)
+	}
+
+	other, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parseImportedSecrets([]byte(encrypted), FormatYaml, []age.Identity{other}); err == nil {
+		t.Error("Expected decryption with another identity to fail")
+	}
+}
+
+func TestEncryptExportOutputRejectsOtherSchemes(t *testing.T) {
+	if _, err := encryptExportOutput("A=1\n", FormatDotenv, []string{"pgp:ABCDEF"}); err == nil {
+		t.Error("Expected an error for an unsupported encryption scheme")
+	}
+}
+
+func TestImportFormatFromFileName(t *testing.T) {
+	tests := map[string]string{
+		"secrets.json":     FormatJson,
+		"secrets.enc.yaml": FormatYaml,
+		"secrets.yml":      FormatYaml,
+		"secrets.env.age":  FormatDotenv,
+		"secrets.json.age": FormatJson,
+		".env":             FormatDotenv,
+	}
+
+	for fileName, expected := range tests {
+		if format := importFormatFromFileName(fileName); format != expected {
+			t.Errorf("Expected %s for %s, got %s", expected, fileName, format)
+		}
+	}
+}
+
+func assertImportedSecrets(t *testing.T, actual []models.SingleEnvironmentVariable, expected []models.SingleEnvironmentVariable) {
+	t.Helper()
+
+	if len(actual) != len(expected) {
+		t.Fatalf("Expected %d secrets, got %d", len(expected), len(actual))
+	}
+
+	for index, secret := range expected {
+		if actual[index].Key != secret.Key || actual[index].Value != secret.Value {
+			t.Errorf("Expected %s=%q, got %s=%q", secret.Key, secret.Value, actual[index].Key, actual[index].Value)
+		}
+	}
+}
+
+func TestEncryptedMetadataExportRoundTrip(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output, err := formatEnvs(exportMetadataTestSecrets, FormatYaml, exportFormatOptions{WithMetadata: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encrypted, err := encryptExportOutput(output, FormatYaml, []string{"age:" + identity.Recipient().String()})
+	if err != nil {
+		t.Fatalf("Unable to encrypt a YAML list: %v", err)
+	}
+
+	secrets, err := parseImportedSecrets([]byte(encrypted), FormatYaml, []age.Identity{identity})
+	if err != nil {
+		t.Fatalf("Unable to decrypt: %v", err)
+	}
+	assertImportedSecrets(t, secrets, exportMetadataTestSecrets)
+}
+
+func TestImportDotenvComments(t *testing.T) {
+	secrets, err := parseImportedSecrets([]byte("# the database host\nDB_HOST=localhost\nEMPTY=\nDB_PORT=5432\n"), FormatDotenv, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	secrets = dropEmptyImportedSecrets(secrets)
+	if len(secrets) != 2 || secrets[0].Comment != "the database host" || secrets[1].Comment != "" {
+		t.Fatalf("Expected the comment above a secret to become its comment, got %v", secrets)
+	}
+
+	if err := util.ValidateSecretsToSet(append(secrets, secrets[0])); err == nil {
+		t.Error("Expected an error for a secret given twice")
+	}
+}
+
+func TestHasSecretChanges(t *testing.T) {
+	unchanged := []models.SecretSetOperation{{SecretKey: "A", SecretOperation: "SECRET VALUE UNCHANGED"}}
+	if hasSecretChanges(unchanged) {
+		t.Error("Expected no changes")
+	}
+
+	if !hasSecretChanges(append(unchanged, models.SecretSetOperation{SecretKey: "B", SecretOperation: "SECRET CREATED"})) {
+		t.Error("Expected a created secret to be a change")
+	}
+}
+
+func TestSecretOperationChange(t *testing.T) {
+	expected := map[string]string{
+		"SECRET CREATED":          "add",
+		"SECRET VALUE MODIFIED":   "update",
+		"SECRET COMMENT MODIFIED": "update",
+		"SECRET VALUE UNCHANGED":  "unchanged",
+	}
+
+	for operation, change := range expected {
+		if result := secretOperationChange(models.SecretSetOperation{SecretKey: "A", SecretValue: "hidden", SecretOperation: operation}); result != change {
+			t.Errorf("expected %s for %s, got %s", change, operation, result)
+		}
+	}
+}
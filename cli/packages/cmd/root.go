@@ -29,8 +29,11 @@ var rootCmd = &cobra.Command{
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
-	err := rootCmd.Execute()
+	cmd, err := rootCmd.ExecuteC()
 	if err != nil {
+		if cmd == secretsDiffCmd {
+			os.Exit(SECRETS_DIFF_ERROR_EXIT_CODE)
+		}
 		os.Exit(1)
 	}
 }
@@ -4,13 +4,14 @@ Copyright (c) 2023 Infisical Inc.
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"os/signal"
 	"runtime"
 	"strings"
-	"syscall"
+	"time"
 
 	"github.com/Infisical/infisical-merge/packages/models"
 	"github.com/Infisical/infisical-merge/packages/util"
@@ -25,6 +26,8 @@ var runCmd = &cobra.Command{
 	Example: `
 	infisical run --env=dev -- npm run dev
 	infisical run --command "first-command && second-command; more-commands..."
+	infisical run --procfile infisical-procs.yaml
+	infisical run --explain --env=prod
 	`,
 	Use:                   "run [any infisical run command flags] -- [your application start command]",
 	Short:                 "Used to inject environments variables into your application process",
@@ -33,6 +36,20 @@ var runCmd = &cobra.Command{
 		// Check if the --command flag has been set
 		commandFlagSet := cmd.Flags().Changed("command")
 
+		// Nothing is executed when explaining, so the command is optional
+		explain, _ := cmd.Flags().GetBool("explain")
+		if explain {
+			return nil
+		}
+
+		// The processes to start are read from the procfile, so neither a command nor arguments can be given
+		if cmd.Flags().Changed("procfile") {
+			if commandFlagSet || len(args) > 0 {
+				return fmt.Errorf("you cannot set a command or any arguments together with the --procfile flag")
+			}
+			return nil
+		}
+
 		// If the --command flag has been set, check if a value was provided
 		if commandFlagSet {
 			command := cmd.Flag("command").Value.String()
@@ -87,6 +104,11 @@ var runCmd = &cobra.Command{
 			util.HandleError(err, "Unable to parse flag")
 		}
 
+		allowMissingReferences, err := cmd.Flags().GetBool("allow-missing-references")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
 		tagSlugs, err := cmd.Flags().GetString("tags")
 		if err != nil {
 			util.HandleError(err, "Unable to parse flag")
@@ -107,77 +129,84 @@ var runCmd = &cobra.Command{
 			util.HandleError(err, "Unable to parse flag")
 		}
 
-		request := models.GetAllSecretsParameters{
-			Environment:   environmentName,
-			WorkspaceId:   projectId,
-			TagSlugs:      tagSlugs,
-			SecretsPath:   secretsPath,
-			IncludeImport: includeImports,
-			Recursive:     recursive,
+		killTimeout, err := cmd.Flags().GetDuration("kill-timeout")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
 		}
 
-		if token != nil && token.Type == util.SERVICE_TOKEN_IDENTIFIER {
-			request.InfisicalToken = token.Token
-		} else if token != nil && token.Type == util.UNIVERSAL_AUTH_TOKEN_IDENTIFIER {
-			request.UniversalAuthAccessToken = token.Token
+		dynamicSecrets, err := cmd.Flags().GetStringArray("dynamic-secret")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
 		}
 
-		secrets, err := util.GetAllEnvironmentVariables(request, projectConfigDir)
+		procfilePath, err := cmd.Flags().GetString("procfile")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
 
+		maskOutput, err := cmd.Flags().GetBool("mask-output")
 		if err != nil {
-			util.HandleError(err, "Could not fetch secrets", "If you are using a service token to fetch secrets, please ensure it is valid")
+			util.HandleError(err, "Unable to parse flag")
 		}
 
-		if secretOverriding {
-			secrets = util.OverrideSecrets(secrets, util.SECRET_TYPE_PERSONAL)
-		} else {
-			secrets = util.OverrideSecrets(secrets, util.SECRET_TYPE_SHARED)
+		options := runSecretsOptions{
+			Environment:            environmentName,
+			SecretsPath:            secretsPath,
+			TagSlugs:               tagSlugs,
+			IncludeImports:         includeImports,
+			Recursive:              recursive,
+			SecretOverriding:       secretOverriding,
+			ShouldExpandSecrets:    shouldExpandSecrets,
+			AllowMissingReferences: allowMissingReferences,
 		}
 
-		if shouldExpandSecrets {
+		explain, err := cmd.Flags().GetBool("explain")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
 
-			authParams := models.ExpandSecretsAuthentication{}
+		showValues, err := cmd.Flags().GetBool("show-values")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
 
-			if token != nil && token.Type == util.SERVICE_TOKEN_IDENTIFIER {
-				authParams.InfisicalToken = token.Token
-			} else if token != nil && token.Type == util.UNIVERSAL_AUTH_TOKEN_IDENTIFIER {
-				authParams.UniversalAuthAccessToken = token.Token
+		if explain {
+			if procfilePath != "" {
+				explainProcfile(os.Stdout, procfilePath, token, projectId, projectConfigDir, options, dynamicSecrets, showValues)
+			} else {
+				explainRun(os.Stdout, token, projectId, projectConfigDir, options, dynamicSecrets, showValues)
 			}
-
-			secrets = util.ExpandSecrets(secrets, authParams, projectConfigDir)
+			return
 		}
 
-		secretsByKey := getSecretsByKeys(secrets)
-		environmentVariables := make(map[string]string)
-
-		// add all existing environment vars
-		for _, s := range os.Environ() {
-			kv := strings.SplitN(s, "=", 2)
-			key := kv[0]
-			value := kv[1]
-			environmentVariables[key] = value
+		if procfilePath != "" {
+			exitCode := runProcfile(procfilePath, token, projectId, projectConfigDir, options, dynamicSecrets, killTimeout, maskOutput)
+			os.Exit(exitCode)
 		}
 
-		// check to see if there are any reserved key words in secrets to inject
-		filterReservedEnvVars(secretsByKey)
+		secretsByKey := fetchSecretsForRun(token, projectId, projectConfigDir, options)
 
-		// now add infisical secrets
-		for k, v := range secretsByKey {
-			environmentVariables[k] = v.Value
+		// dynamic secret leases take precedence over static secrets with the same name
+		var dynamicSecretLeases *RunDynamicSecretLeases
+		if len(dynamicSecrets) > 0 {
+			dynamicSecretLeases = createDynamicSecretLeasesForRun(token, projectId, projectConfigDir, environmentName, secretsPath, dynamicSecrets)
+			for _, secret := range dynamicSecretLeases.Secrets() {
+				secretsByKey[secret.Key] = secret
+			}
 		}
 
-		// turn it back into a list of envs
-		var env []string
-		for key, value := range environmentVariables {
-			s := key + "=" + value
-			env = append(env, s)
+		env := buildEnvironmentForRun(secretsByKey)
+
+		var maskPatterns [][]byte
+		if maskOutput {
+			maskPatterns = secretMaskPatterns(secretsByKey)
 		}
 
 		log.Debug().Msgf("injecting the following environment variables into shell: %v", env)
 
 		Telemetry.CaptureEvent("cli-command:run",
 			posthog.NewProperties().
-				Set("secretsCount", len(secrets)).
+				Set("secretsCount", len(secretsByKey)).
 				Set("environment", environmentName).
 				Set("isUsingServiceToken", token != nil && token.Type == util.SERVICE_TOKEN_IDENTIFIER).
 				Set("isUsingUniversalAuthToken", token != nil && token.Type == util.UNIVERSAL_AUTH_TOKEN_IDENTIFIER).
@@ -185,25 +214,141 @@ var runCmd = &cobra.Command{
 				Set("multi-command", cmd.Flag("command").Value.String()).
 				Set("version", util.CLI_VERSION))
 
+		if dynamicSecretLeases != nil {
+			dynamicSecretLeases.StartRenewal()
+		}
+
+		var processState *os.ProcessState
 		if cmd.Flags().Changed("command") {
 			command := cmd.Flag("command").Value.String()
+			processState, err = executeMultipleCommandWithEnvs(command, len(secretsByKey), env, killTimeout, maskPatterns)
+		} else {
+			processState, err = executeSingleCommandWithEnvs(args, len(secretsByKey), env, killTimeout, maskPatterns)
+		}
 
-			err = executeMultipleCommandWithEnvs(command, len(secretsByKey), env)
-			if err != nil {
-				fmt.Println(err)
-				os.Exit(1)
-			}
+		if dynamicSecretLeases != nil {
+			dynamicSecretLeases.RevokeAll()
+		}
 
-		} else {
-			err = executeSingleCommandWithEnvs(args, len(secretsByKey), env)
-			if err != nil {
-				fmt.Println(err)
-				os.Exit(1)
+		if err != nil {
+			fmt.Println(err)
+			if processState == nil {
+				os.Exit(exitCodeFromStartError(err))
 			}
 		}
+
+		exitWithProcessState(processState)
 	},
 }
 
+// runSecretsOptions holds the flags that decide which secrets are injected into a process
+type runSecretsOptions struct {
+	Environment         string
+	SecretsPath         string
+	TagSlugs            string
+	IncludeImports      bool
+	Recursive           bool
+	SecretOverriding    bool
+	ShouldExpandSecrets bool
+	// expand references to secrets that do not exist to an empty value instead of failing
+	AllowMissingReferences bool
+}
+
+// fetchSecretsForRun fetches, overrides and expands the secrets for the given scope and returns them by key
+func fetchSecretsForRun(token *models.TokenDetails, projectId string, projectConfigDir string, options runSecretsOptions) map[string]models.SingleEnvironmentVariable {
+	secrets := getAllSecretsForRun(token, projectId, projectConfigDir, options)
+
+	if options.SecretOverriding {
+		secrets = util.OverrideSecrets(secrets, util.SECRET_TYPE_PERSONAL)
+	} else {
+		secrets = util.OverrideSecrets(secrets, util.SECRET_TYPE_SHARED)
+	}
+
+	if options.ShouldExpandSecrets {
+		secrets = expandSecretReferences(secrets, expandSecretsAuthentication(token), projectConfigDir, options.AllowMissingReferences)
+	}
+
+	return getSecretsByKeys(secrets)
+}
+
+func expandSecretsAuthentication(token *models.TokenDetails) models.ExpandSecretsAuthentication {
+	authParams := models.ExpandSecretsAuthentication{}
+
+	if token != nil && token.Type == util.SERVICE_TOKEN_IDENTIFIER {
+		authParams.InfisicalToken = token.Token
+	} else if token != nil && token.Type == util.UNIVERSAL_AUTH_TOKEN_IDENTIFIER {
+		authParams.UniversalAuthAccessToken = token.Token
+	}
+
+	return authParams
+}
+
+// expandSecretReferences expands the references of the secrets and exits when one of them can not be expanded
+func expandSecretReferences(secrets []models.SingleEnvironmentVariable, auth models.ExpandSecretsAuthentication, projectConfigDir string, allowMissing bool) []models.SingleEnvironmentVariable {
+	expandedSecrets, err := util.ExpandSecretReferences(secrets, auth, projectConfigDir, allowMissing)
+	if err != nil {
+		util.HandleError(err, "Run [infisical secrets lint] to list the broken references, or add --allow-missing-references to expand references to secrets that do not exist to an empty value")
+	}
+
+	return expandedSecrets
+}
+
+// getAllSecretsForRun fetches the secrets of the given scope as returned by the API, before any overriding or expansion
+func getAllSecretsForRun(token *models.TokenDetails, projectId string, projectConfigDir string, options runSecretsOptions) []models.SingleEnvironmentVariable {
+	request := models.GetAllSecretsParameters{
+		Environment:   options.Environment,
+		WorkspaceId:   projectId,
+		TagSlugs:      options.TagSlugs,
+		SecretsPath:   options.SecretsPath,
+		IncludeImport: options.IncludeImports,
+		Recursive:     options.Recursive,
+	}
+
+	if token != nil && token.Type == util.SERVICE_TOKEN_IDENTIFIER {
+		request.InfisicalToken = token.Token
+	} else if token != nil && token.Type == util.UNIVERSAL_AUTH_TOKEN_IDENTIFIER {
+		request.UniversalAuthAccessToken = token.Token
+	}
+
+	secrets, err := util.GetAllEnvironmentVariables(request, projectConfigDir)
+
+	if err != nil {
+		util.HandleError(err, "Could not fetch secrets", "If you are using a service token to fetch secrets, please ensure it is valid")
+	}
+
+	return secrets
+}
+
+// buildEnvironmentForRun merges the secrets on top of the current environment, skipping reserved names
+func buildEnvironmentForRun(secretsByKey map[string]models.SingleEnvironmentVariable) []string {
+	environmentVariables := make(map[string]string)
+
+	// add all existing environment vars
+	for _, s := range os.Environ() {
+		kv := strings.SplitN(s, "=", 2)
+		key := kv[0]
+		value := kv[1]
+		environmentVariables[key] = value
+	}
+
+	// check to see if there are any reserved key words in secrets to inject
+	filterReservedEnvVars(secretsByKey)
+
+	// now add infisical secrets
+	for k, v := range secretsByKey {
+		environmentVariables[k] = v.Value
+	}
+
+	// turn it back into a list of envs
+	var env []string
+	for key, value := range environmentVariables {
+		s := key + "=" + value
+		env = append(env, s)
+	}
+
+	return env
+}
+
 var (
 	reservedEnvVars = []string{
 		"HOME", "PATH", "PS1", "PS2",
@@ -218,21 +363,29 @@ var (
 )
 
 func filterReservedEnvVars(env map[string]models.SingleEnvironmentVariable) {
+	for envName := range env {
+		if reason := reservedEnvVarReason(envName); reason != "" {
+			delete(env, envName)
+			util.PrintWarning(fmt.Sprintf("Infisical secret named [%v] has been removed because it %s", envName, reason))
+		}
+	}
+}
+
+// reservedEnvVarReason explains why a secret cannot be injected under the given name, or returns an empty string if it can
+func reservedEnvVarReason(envName string) string {
 	for _, reservedEnvName := range reservedEnvVars {
-		if _, ok := env[reservedEnvName]; ok {
-			delete(env, reservedEnvName)
-			util.PrintWarning(fmt.Sprintf("Infisical secret named [%v] has been removed because it is a reserved secret name", reservedEnvName))
+		if envName == reservedEnvName {
+			return "is a reserved secret name"
 		}
 	}
 
 	for _, reservedEnvPrefix := range reservedEnvVarPrefixes {
-		for envName := range env {
-			if strings.HasPrefix(envName, reservedEnvPrefix) {
-				delete(env, envName)
-				util.PrintWarning(fmt.Sprintf("Infisical secret named [%v] has been removed because it contains a reserved prefix", envName))
-			}
+		if strings.HasPrefix(envName, reservedEnvPrefix) {
+			return "contains a reserved prefix"
 		}
 	}
+
+	return ""
 }
 
 func init() {
@@ -241,6 +394,7 @@ func init() {
 	runCmd.Flags().String("projectId", "", "manually set the project ID to fetch secrets from when using machine identity based auth")
 	runCmd.Flags().StringP("env", "e", "dev", "Set the environment (dev, prod, etc.) from which your secrets should be pulled from")
 	runCmd.Flags().Bool("expand", true, "Parse shell parameter expansions in your secrets")
+	runCmd.Flags().Bool("allow-missing-references", false, "Expand references to secrets that do not exist to an empty value with a warning, instead of failing")
 	runCmd.Flags().Bool("include-imports", true, "Import linked secrets ")
 	runCmd.Flags().Bool("recursive", false, "Fetch secrets from all sub-folders")
 	runCmd.Flags().Bool("secret-overriding", true, "Prioritizes personal secrets, if any, with the same name over shared secrets")
@@ -248,25 +402,51 @@ func init() {
 	runCmd.Flags().StringP("tags", "t", "", "filter secrets by tag slugs ")
 	runCmd.Flags().String("path", "/", "get secrets within a folder path")
 	runCmd.Flags().String("project-config-dir", "", "explicitly set the directory where the .infisical.json resides")
+	runCmd.Flags().StringArray("dynamic-secret", []string{}, "lease a dynamic secret for the lifetime of the process and inject its data, in the format slug[:ttl][=PREFIX] (can be repeated)")
+	runCmd.Flags().String("procfile", "", "start every process defined in the given procfile (e.g. infisical-procs.yaml), each with its own secrets scope")
+	runCmd.Flags().Bool("mask-output", false, "replace secret values, including their base64 and URL-encoded forms, with *** in the output of the process")
+	runCmd.Flags().Bool("explain", false, "print where each secret comes from and whether it would be injected, without executing the command")
+	runCmd.Flags().Bool("show-values", false, "show secret values in the output of --explain")
+	runCmd.Flags().Duration("kill-timeout", 0, "time to wait after forwarding SIGTERM/SIGINT before sending SIGKILL to the process group (e.g. 10s). Disabled when 0")
 }
 
 // Will execute a single command and pass in the given secrets into the process
-func executeSingleCommandWithEnvs(args []string, secretsCount int, env []string) error {
+func executeSingleCommandWithEnvs(args []string, secretsCount int, env []string, killTimeout time.Duration, maskPatterns [][]byte) (*os.ProcessState, error) {
 	command := args[0]
 	argsForCommand := args[1:]
 
 	log.Info().Msgf(color.GreenString("Injecting %v Infisical secrets into your application process", secretsCount))
 
+	stdout, stderr, closeOutput := outputWriters(maskPatterns)
+	defer closeOutput()
+
 	cmd := exec.Command(command, argsForCommand...)
 	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
 	cmd.Env = env
 
-	return execCmd(cmd)
+	return execCmd(cmd, killTimeout)
 }
 
-func executeMultipleCommandWithEnvs(fullCommand string, secretsCount int, env []string) error {
+func executeMultipleCommandWithEnvs(fullCommand string, secretsCount int, env []string, killTimeout time.Duration, maskPatterns [][]byte) (*os.ProcessState, error) {
+	stdout, stderr, closeOutput := outputWriters(maskPatterns)
+	defer closeOutput()
+
+	cmd := newShellCommand(fullCommand)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	cmd.Env = env
+
+	log.Info().Msgf(color.GreenString("Injecting %v Infisical secrets into your application process", secretsCount))
+	log.Debug().Msgf("executing command: %s \n", strings.Join(cmd.Args, " "))
+
+	return execCmd(cmd, killTimeout)
+}
+
+// newShellCommand runs the full command through the user's shell, or cmd on windows
+func newShellCommand(fullCommand string) *exec.Cmd {
 	shell := [2]string{"sh", "-c"}
 	if runtime.GOOS == "windows" {
 		shell = [2]string{"cmd", "/C"}
@@ -277,40 +457,82 @@ func executeMultipleCommandWithEnvs(fullCommand string, secretsCount int, env []
 		}
 	}
 
-	cmd := exec.Command(shell[0], shell[1], fullCommand)
-	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	cmd.Env = env
-
-	log.Info().Msgf(color.GreenString("Injecting %v Infisical secrets into your application process", secretsCount))
-	log.Debug().Msgf("executing command: %s %s %s \n", shell[0], shell[1], fullCommand)
-
-	return execCmd(cmd)
+	return exec.Command(shell[0], shell[1], fullCommand)
 }
 
 // Credit: inspired by AWS Valut
-func execCmd(cmd *exec.Cmd) error {
+// execCmd starts the command in its own process group and forwards every signal we receive to that group,
+// so that children spawned by a shell in --command mode are signalled too. The returned process state
+// carries the child's exit code or terminating signal.
+func execCmd(cmd *exec.Cmd, killTimeout time.Duration) (*os.ProcessState, error) {
 	sigChannel := make(chan os.Signal, 1)
 	signal.Notify(sigChannel)
+	defer signal.Stop(sigChannel)
+
+	configureProcessGroup(cmd)
 
 	if err := cmd.Start(); err != nil {
-		return err
+		return nil, err
 	}
 
+	return waitForCommand(cmd, sigChannel, killTimeout)
+}
+
+// waitForCommand forwards signals from sigChannel to the process group of an already started command until it exits.
+// When killTimeout is set, a forwarded termination signal is followed by SIGKILL if the group is still alive after the timeout.
+func waitForCommand(cmd *exec.Cmd, sigChannel chan os.Signal, killTimeout time.Duration) (*os.ProcessState, error) {
+	done := make(chan struct{})
+
 	go func() {
+		var escalate <-chan time.Time
 		for {
-			sig := <-sigChannel
-			_ = cmd.Process.Signal(sig) // process all sigs
+			select {
+			case sig := <-sigChannel:
+				if !isForwardableSignal(sig) {
+					continue
+				}
+
+				_ = signalProcessGroup(cmd, sig)
+
+				if killTimeout > 0 && escalate == nil && isTerminationSignal(sig) {
+					escalate = time.After(killTimeout)
+				}
+			case <-escalate:
+				log.Debug().Msgf("process did not exit within %s, sending SIGKILL", killTimeout)
+				_ = signalProcessGroup(cmd, os.Kill)
+				escalate = nil
+			case <-done:
+				return
+			}
 		}
 	}()
 
-	if err := cmd.Wait(); err != nil {
-		_ = cmd.Process.Signal(os.Kill)
-		return fmt.Errorf("failed to wait for command termination: %v", err)
+	err := cmd.Wait()
+	close(done)
+	restoreTerminalForeground(cmd)
+
+	// a non-zero exit is reported through the process state, not as an error
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		err = nil
+	}
+
+	if err != nil {
+		return cmd.ProcessState, fmt.Errorf("failed to wait for command termination: %v", err)
+	}
+
+	return cmd.ProcessState, nil
+}
+
+// exitCodeFromStartError maps a failure to start the command to the exit codes used by POSIX shells
+func exitCodeFromStartError(err error) int {
+	if errors.Is(err, exec.ErrNotFound) || errors.Is(err, os.ErrNotExist) {
+		return 127
+	}
+
+	if errors.Is(err, os.ErrPermission) {
+		return 126
 	}
 
-	waitStatus := cmd.ProcessState.Sys().(syscall.WaitStatus)
-	os.Exit(waitStatus.ExitStatus())
-	return nil
+	return 1
 }
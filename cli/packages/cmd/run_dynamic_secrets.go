@@ -0,0 +1,271 @@
+/*
+Copyright (c) 2023 Infisical Inc.
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Infisical/infisical-merge/packages/models"
+	"github.com/Infisical/infisical-merge/packages/util"
+	"github.com/rs/zerolog/log"
+)
+
+// minimum time left on a lease when it gets renewed
+const RUN_DYNAMIC_SECRET_RENEW_BUFFER = 15 * time.Second
+
+// dynamicSecretSpec is the parsed value of a --dynamic-secret flag: slug[:ttl][=PREFIX]
+type dynamicSecretSpec struct {
+	Slug   string
+	TTL    string
+	Prefix string
+}
+
+func parseDynamicSecretSpec(value string) (dynamicSecretSpec, error) {
+	spec := dynamicSecretSpec{}
+
+	slugAndTTL, prefix, hasPrefix := strings.Cut(value, "=")
+	if hasPrefix {
+		if prefix == "" {
+			return dynamicSecretSpec{}, fmt.Errorf("invalid dynamic secret [%s], the prefix after '=' cannot be empty", value)
+		}
+		spec.Prefix = prefix
+	}
+
+	slug, ttl, hasTTL := strings.Cut(slugAndTTL, ":")
+	if hasTTL && ttl == "" {
+		return dynamicSecretSpec{}, fmt.Errorf("invalid dynamic secret [%s], the ttl after ':' cannot be empty", value)
+	}
+
+	if slug == "" {
+		return dynamicSecretSpec{}, fmt.Errorf("invalid dynamic secret [%s], expected format slug[:ttl][=PREFIX]", value)
+	}
+
+	spec.Slug = slug
+	spec.TTL = ttl
+
+	return spec, nil
+}
+
+type runDynamicSecretLease struct {
+	DynamicSecretLease
+	TTL    string
+	Prefix string
+}
+
+// RunDynamicSecretLeases holds the leases created for a single `infisical run` invocation.
+// Leases are renewed while the child process is alive and revoked once it exits.
+type RunDynamicSecretLeases struct {
+	accessToken string
+	leases      []*runDynamicSecretLease
+	mutex       sync.Mutex
+	stop        chan struct{}
+	wg          sync.WaitGroup
+}
+
+// CreateRunDynamicSecretLeases creates one lease per spec. If any of them fails, the ones already created are revoked.
+func CreateRunDynamicSecretLeases(accessToken, projectSlug, environment, secretPath string, specs []dynamicSecretSpec) (*RunDynamicSecretLeases, error) {
+	manager := &RunDynamicSecretLeases{
+		accessToken: accessToken,
+		stop:        make(chan struct{}),
+	}
+
+	for _, spec := range specs {
+		res, err := util.CreateDynamicSecretLease(accessToken, projectSlug, environment, secretPath, spec.Slug, spec.TTL)
+		if err != nil {
+			manager.RevokeAll()
+			return nil, fmt.Errorf("unable to create lease for dynamic secret [%s] [err=%v]", spec.Slug, err)
+		}
+
+		log.Debug().Msgf("created lease %s for dynamic secret %s, expires at %s", res.Lease.Id, spec.Slug, res.Lease.ExpireAt)
+
+		manager.leases = append(manager.leases, &runDynamicSecretLease{
+			DynamicSecretLease: DynamicSecretLease{
+				LeaseID:     res.Lease.Id,
+				ExpireAt:    res.Lease.ExpireAt,
+				Environment: environment,
+				SecretPath:  secretPath,
+				Slug:        spec.Slug,
+				ProjectSlug: projectSlug,
+				Data:        res.Data,
+			},
+			TTL:    spec.TTL,
+			Prefix: spec.Prefix,
+		})
+	}
+
+	return manager, nil
+}
+
+// Secrets returns the data of every lease as environment variables, each key prefixed with the prefix of its lease
+func (m *RunDynamicSecretLeases) Secrets() []models.SingleEnvironmentVariable {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	secrets := []models.SingleEnvironmentVariable{}
+	for _, lease := range m.leases {
+		secrets = append(secrets, dynamicSecretDataToSecrets(lease.Data, lease.Prefix)...)
+	}
+
+	return secrets
+}
+
+func dynamicSecretDataToSecrets(data map[string]interface{}, prefix string) []models.SingleEnvironmentVariable {
+	secrets := []models.SingleEnvironmentVariable{}
+	for key, value := range data {
+		var stringValue string
+		switch v := value.(type) {
+		case string:
+			stringValue = v
+		case nil:
+			stringValue = ""
+		case map[string]interface{}, []interface{}:
+			marshalled, _ := json.Marshal(v)
+			stringValue = string(marshalled)
+		default:
+			stringValue = fmt.Sprint(v)
+		}
+
+		secrets = append(secrets, models.SingleEnvironmentVariable{
+			Key:   prefix + key,
+			Value: stringValue,
+			Type:  "dynamic",
+		})
+	}
+
+	return util.SortSecretsByKeys(secrets)
+}
+
+// StartRenewal keeps every lease alive until RevokeAll is called
+func (m *RunDynamicSecretLeases) StartRenewal() {
+	for _, lease := range m.leases {
+		m.wg.Add(1)
+		go m.renewLease(lease)
+	}
+}
+
+func (m *RunDynamicSecretLeases) renewLease(lease *runDynamicSecretLease) {
+	defer m.wg.Done()
+
+	for {
+		m.mutex.Lock()
+		expireAt := lease.ExpireAt
+		m.mutex.Unlock()
+
+		select {
+		case <-time.After(leaseRenewalDelay(expireAt, time.Now())):
+		case <-m.stop:
+			return
+		}
+
+		newExpireAt, err := util.RenewDynamicSecretLease(m.accessToken, lease.ProjectSlug, lease.Environment, lease.SecretPath, lease.LeaseID, lease.TTL)
+		if err != nil {
+			util.PrintWarning(fmt.Sprintf("Unable to renew lease for dynamic secret [%s], it will expire at %s [err=%v]", lease.Slug, expireAt.Local().Format(time.RFC1123), err))
+			return
+		}
+
+		log.Debug().Msgf("renewed lease %s for dynamic secret %s, expires at %s", lease.LeaseID, lease.Slug, newExpireAt)
+
+		m.mutex.Lock()
+		lease.ExpireAt = newExpireAt
+		m.mutex.Unlock()
+	}
+}
+
+// leaseRenewalDelay renews once two thirds of the remaining lease time has passed, but never later than the renew buffer before expiry
+func leaseRenewalDelay(expireAt time.Time, now time.Time) time.Duration {
+	remaining := expireAt.Sub(now)
+	delay := remaining * 2 / 3
+
+	if remaining-delay < RUN_DYNAMIC_SECRET_RENEW_BUFFER {
+		delay = remaining - RUN_DYNAMIC_SECRET_RENEW_BUFFER
+	}
+
+	if delay < 0 {
+		return 0
+	}
+
+	return delay
+}
+
+// RevokeAll stops renewing and revokes every lease. Failures are reported as warnings since the lease will still expire on its own.
+func (m *RunDynamicSecretLeases) RevokeAll() {
+	close(m.stop)
+	m.wg.Wait()
+
+	for _, lease := range m.leases {
+		err := util.RevokeDynamicSecretLease(m.accessToken, lease.ProjectSlug, lease.Environment, lease.SecretPath, lease.LeaseID)
+		if err != nil {
+			util.PrintWarning(fmt.Sprintf("Unable to revoke lease for dynamic secret [%s] [err=%v]", lease.Slug, err))
+			continue
+		}
+
+		log.Debug().Msgf("revoked lease %s for dynamic secret %s", lease.LeaseID, lease.Slug)
+	}
+}
+
+// createDynamicSecretLeasesForRun resolves the credentials and project needed by the dynamic secret APIs and creates a lease for every --dynamic-secret flag
+func createDynamicSecretLeasesForRun(token *models.TokenDetails, projectId string, projectConfigDir string, environment string, secretPath string, values []string) *RunDynamicSecretLeases {
+	specs := []dynamicSecretSpec{}
+	for _, value := range values {
+		spec, err := parseDynamicSecretSpec(value)
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+		specs = append(specs, spec)
+	}
+
+	accessToken := ""
+	if token != nil && token.Type == util.SERVICE_TOKEN_IDENTIFIER {
+		util.PrintErrorMessageAndExit("Dynamic secrets cannot be leased with a service token, please use a machine identity or log in instead")
+	} else if token != nil && token.Type == util.UNIVERSAL_AUTH_TOKEN_IDENTIFIER {
+		if projectId == "" {
+			util.PrintErrorMessageAndExit("Project ID is required when using machine identity")
+		}
+		accessToken = token.Token
+	} else {
+		util.RequireLogin()
+
+		loggedInUserDetails, err := util.GetCurrentLoggedInUserDetails()
+		if err != nil {
+			util.HandleError(err, "Unable to authenticate")
+		}
+
+		if loggedInUserDetails.LoginExpired {
+			util.PrintErrorMessageAndExit("Your login session has expired, please run [infisical login] and try again")
+		}
+
+		accessToken = loggedInUserDetails.UserCredentials.JTWToken
+	}
+
+	if projectId == "" {
+		var workspaceFile models.WorkspaceConfigFile
+		var err error
+		if projectConfigDir != "" {
+			workspaceFile, err = util.GetWorkSpaceFromFilePath(projectConfigDir)
+		} else {
+			workspaceFile, err = util.GetWorkSpaceFromFile()
+		}
+
+		if err != nil {
+			util.HandleError(err, "Unable to get local project details")
+		}
+		projectId = workspaceFile.WorkspaceId
+	}
+
+	projectSlug, err := util.GetProjectSlug(accessToken, projectId)
+	if err != nil {
+		util.HandleError(err, "Unable to lease dynamic secrets")
+	}
+
+	leases, err := CreateRunDynamicSecretLeases(accessToken, projectSlug, environment, secretPath, specs)
+	if err != nil {
+		util.HandleError(err, "Unable to lease dynamic secrets")
+	}
+
+	return leases
+}
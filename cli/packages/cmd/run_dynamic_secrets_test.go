@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDynamicSecretSpec(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected dynamicSecretSpec
+	}{
+		{"postgres", dynamicSecretSpec{Slug: "postgres"}},
+		{"postgres:1h", dynamicSecretSpec{Slug: "postgres", TTL: "1h"}},
+		{"postgres=DB_", dynamicSecretSpec{Slug: "postgres", Prefix: "DB_"}},
+		{"postgres:30m=DB_", dynamicSecretSpec{Slug: "postgres", TTL: "30m", Prefix: "DB_"}},
+	}
+
+	for _, test := range tests {
+		spec, err := parseDynamicSecretSpec(test.value)
+		if err != nil {
+			t.Errorf("Expected %s to parse, got error %v", test.value, err)
+			continue
+		}
+		if spec != test.expected {
+			t.Errorf("Expected %s to parse to %+v, got %+v", test.value, test.expected, spec)
+		}
+	}
+
+	for _, value := range []string{"", ":1h", "postgres:", "postgres=", ":1h=DB_"} {
+		if _, err := parseDynamicSecretSpec(value); err == nil {
+			t.Errorf("Expected %q to be rejected", value)
+		}
+	}
+}
+
+func TestDynamicSecretDataToSecrets(t *testing.T) {
+	secrets := dynamicSecretDataToSecrets(map[string]interface{}{
+		"DB_USERNAME": "user",
+		"DB_PORT":     float64(5432),
+		"EXTRA":       map[string]interface{}{"a": "b"},
+	}, "PG_")
+
+	expected := map[string]string{
+		"PG_DB_PORT":     "5432",
+		"PG_DB_USERNAME": "user",
+		"PG_EXTRA":       `{"a":"b"}`,
+	}
+
+	if len(secrets) != len(expected) {
+		t.Fatalf("Expected %d secrets, got %d", len(expected), len(secrets))
+	}
+
+	for _, secret := range secrets {
+		if expected[secret.Key] != secret.Value {
+			t.Errorf("Expected %s to be %q, got %q", secret.Key, expected[secret.Key], secret.Value)
+		}
+	}
+}
+
+func TestLeaseRenewalDelay(t *testing.T) {
+	now := time.Now()
+
+	if delay := leaseRenewalDelay(now.Add(time.Hour), now); delay != 40*time.Minute {
+		t.Errorf("Expected renewal after 40m for a 1h lease, got %s", delay)
+	}
+
+	if delay := leaseRenewalDelay(now.Add(30*time.Second), now); delay != 15*time.Second {
+		t.Errorf("Expected renewal 15s before expiry, got %s", delay)
+	}
+
+	if delay := leaseRenewalDelay(now.Add(-time.Second), now); delay != 0 {
+		t.Errorf("Expected immediate renewal for an expired lease, got %s", delay)
+	}
+}
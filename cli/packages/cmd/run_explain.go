@@ -0,0 +1,249 @@
+/*
+Copyright (c) 2023 Infisical Inc.
+*/
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/Infisical/infisical-merge/packages/models"
+	"github.com/Infisical/infisical-merge/packages/util"
+	"github.com/fatih/color"
+)
+
+// runSecretExplanation describes how a single key ends up in the environment of the process
+type runSecretExplanation struct {
+	Key string
+	// the secret that is injected
+	Source models.SingleEnvironmentVariable
+	// secrets with the same key that lost against Source when personal and shared secrets were merged
+	Overridden []models.SingleEnvironmentVariable
+	References []runSecretReference
+	// why the key is not injected, empty when it is
+	FilteredReason string
+	// the key is already set in the current environment and is replaced by the secret
+	ShadowsEnv bool
+	Value      string
+}
+
+// runSecretReference is a single ${...} reference, Depth is greater than zero for references of referenced secrets
+type runSecretReference struct {
+	Reference  string
+	Depth      int
+	Resolution string
+}
+
+// explainRun prints where every secret of the given scope comes from, without executing anything
+func explainRun(out io.Writer, token *models.TokenDetails, projectId string, projectConfigDir string, options runSecretsOptions, dynamicSecrets []string, showValues bool) {
+	secrets := getAllSecretsForRun(token, projectId, projectConfigDir, options)
+	explanations := explainSecrets(secrets, options.SecretOverriding, options.ShouldExpandSecrets)
+
+	if showValues {
+		valuesByKey := fetchValuesForExplain(secrets, token, projectConfigDir, options)
+		for i := range explanations {
+			explanations[i].Value = valuesByKey[explanations[i].Key]
+		}
+	}
+
+	printRunExplanations(out, explanations, showValues)
+
+	if len(dynamicSecrets) > 0 {
+		fmt.Fprintf(out, "Dynamic secrets [%s] are leased when the command runs and take precedence over the secrets above, they are not leased when explaining\n", strings.Join(dynamicSecrets, ", "))
+	}
+}
+
+func explainProcfile(out io.Writer, procfilePath string, token *models.TokenDetails, projectId string, projectConfigDir string, defaults runSecretsOptions, dynamicSecrets []string, showValues bool) {
+	procfile := readRunProcfile(procfilePath)
+
+	for index, name := range procfile.ProcessNames() {
+		if index > 0 {
+			fmt.Fprintln(out)
+		}
+
+		scope := procfile.Processes[name].scopeFor(defaults)
+		fmt.Fprintln(out, color.New(color.Bold).Sprintf("Process %s (environment %s, path %s)", name, scope.Environment, scope.SecretsPath))
+		explainRun(out, token, projectId, projectConfigDir, scope, dynamicSecrets, showValues)
+	}
+}
+
+// fetchValuesForExplain runs the same overriding and expansion as `infisical run` to get the final values
+func fetchValuesForExplain(secrets []models.SingleEnvironmentVariable, token *models.TokenDetails, projectConfigDir string, options runSecretsOptions) map[string]string {
+	secretsCopy := append([]models.SingleEnvironmentVariable{}, secrets...)
+
+	if options.SecretOverriding {
+		secretsCopy = util.OverrideSecrets(secretsCopy, util.SECRET_TYPE_PERSONAL)
+	} else {
+		secretsCopy = util.OverrideSecrets(secretsCopy, util.SECRET_TYPE_SHARED)
+	}
+
+	if options.ShouldExpandSecrets {
+		secretsCopy = expandSecretReferences(secretsCopy, expandSecretsAuthentication(token), projectConfigDir, options.AllowMissingReferences)
+	}
+
+	valuesByKey := map[string]string{}
+	for _, secret := range secretsCopy {
+		valuesByKey[secret.Key] = secret.Value
+	}
+
+	return valuesByKey
+}
+
+// explainSecrets follows the same rules as util.OverrideSecrets: the last secret of the preferred type wins, since later
+// secrets overwrite earlier ones there, otherwise the first secret with the key
+func explainSecrets(secrets []models.SingleEnvironmentVariable, secretOverriding bool, shouldExpandSecrets bool) []runSecretExplanation {
+	preferredType := util.SECRET_TYPE_SHARED
+	if secretOverriding {
+		preferredType = util.SECRET_TYPE_PERSONAL
+	}
+
+	secretsByKey := map[string][]models.SingleEnvironmentVariable{}
+	for _, secret := range secrets {
+		secretsByKey[secret.Key] = append(secretsByKey[secret.Key], secret)
+	}
+
+	winners := map[string]models.SingleEnvironmentVariable{}
+	explanations := []runSecretExplanation{}
+	for key, candidates := range secretsByKey {
+		winnerIndex := 0
+		for index, candidate := range candidates {
+			if candidate.Type == preferredType {
+				winnerIndex = index
+			}
+		}
+
+		explanation := runSecretExplanation{
+			Key:            key,
+			Source:         candidates[winnerIndex],
+			FilteredReason: reservedEnvVarReason(key),
+		}
+
+		for index, candidate := range candidates {
+			if index != winnerIndex {
+				explanation.Overridden = append(explanation.Overridden, candidate)
+			}
+		}
+
+		if _, ok := os.LookupEnv(key); ok {
+			explanation.ShadowsEnv = true
+		}
+
+		winners[key] = explanation.Source
+		explanations = append(explanations, explanation)
+	}
+
+	sort.Slice(explanations, func(i, j int) bool {
+		return explanations[i].Key < explanations[j].Key
+	})
+
+	if shouldExpandSecrets {
+		for i := range explanations {
+			explanations[i].References = explainReferences(explanations[i].Source.Value, winners, map[string]bool{explanations[i].Key: true}, 0)
+		}
+	}
+
+	return explanations
+}
+
+// explainReferences resolves the references of a value. References to secrets in the same scope are followed, cross environment references are not since that would need another fetch.
+func explainReferences(value string, secretsByKey map[string]models.SingleEnvironmentVariable, visiting map[string]bool, depth int) []runSecretReference {
+	references := []runSecretReference{}
+
+	for _, secretReference := range util.GetSecretReferences(value) {
+		reference, parts := secretReference.Reference, secretReference.Parts
+
+		if len(parts) > 1 {
+			environment, folders, key := parts[0], parts[1:len(parts)-1], parts[len(parts)-1]
+			references = append(references, runSecretReference{
+				Reference:  reference,
+				Depth:      depth,
+				Resolution: fmt.Sprintf("%s in %s:%s", key, environment, path.Join(append([]string{"/"}, folders...)...)),
+			})
+			continue
+		}
+
+		key := parts[0]
+		secret, ok := secretsByKey[key]
+		if !ok {
+			resolution := "not found"
+			if secretReference.HasDefault {
+				resolution = "not found, the default value is used"
+			}
+			references = append(references, runSecretReference{Reference: reference, Depth: depth, Resolution: resolution})
+			continue
+		}
+
+		if visiting[key] {
+			references = append(references, runSecretReference{Reference: reference, Depth: depth, Resolution: "circular reference"})
+			continue
+		}
+
+		references = append(references, runSecretReference{
+			Reference:  reference,
+			Depth:      depth,
+			Resolution: describeSecretSource(secret),
+		})
+
+		visiting[key] = true
+		references = append(references, explainReferences(secret.Value, secretsByKey, visiting, depth+1)...)
+		delete(visiting, key)
+	}
+
+	return references
+}
+
+// describeSecretSource returns e.g. "shared secret in dev:/api" or "personal secret imported from prod:/shared"
+func describeSecretSource(secret models.SingleEnvironmentVariable) string {
+	secretType := secret.Type
+	if secretType == "" {
+		secretType = util.SECRET_TYPE_SHARED
+	}
+
+	location := "in"
+	if secret.IsImported {
+		location = "imported from"
+	}
+
+	return fmt.Sprintf("%s secret %s %s:%s", secretType, location, secret.Environment, secret.SecretPath)
+}
+
+func printRunExplanations(out io.Writer, explanations []runSecretExplanation, showValues bool) {
+	if len(explanations) == 0 {
+		fmt.Fprintln(out, "No secrets found for the given scope")
+		return
+	}
+
+	for _, explanation := range explanations {
+		status := color.GreenString("injected")
+		if explanation.FilteredReason != "" {
+			status = color.RedString("filtered, the name %s", explanation.FilteredReason)
+		} else if explanation.ShadowsEnv {
+			status = color.YellowString("injected, replaces a variable already set in your environment")
+		}
+
+		fmt.Fprintf(out, "%s [%s]\n", color.New(color.Bold).Sprint(explanation.Key), status)
+		fmt.Fprintf(out, "  source:     %s\n", describeSecretSource(explanation.Source))
+
+		for _, overridden := range explanation.Overridden {
+			fmt.Fprintf(out, "  overrides:  %s\n", describeSecretSource(overridden))
+		}
+
+		for index, reference := range explanation.References {
+			label := "  references: "
+			if index > 0 {
+				label = "              "
+			}
+			fmt.Fprintf(out, "%s%s${%s} -> %s\n", label, strings.Repeat("  ", reference.Depth), reference.Reference, reference.Resolution)
+		}
+
+		if showValues {
+			fmt.Fprintf(out, "  value:      %s\n", explanation.Value)
+		} else {
+			fmt.Fprintln(out, "  value:      <hidden, use --show-values to print it>")
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/Infisical/infisical-merge/packages/models"
+	"github.com/Infisical/infisical-merge/packages/util"
+)
+
+func TestExplainSecrets(t *testing.T) {
+	t.Setenv("DB_HOST", "localhost")
+
+	secrets := []models.SingleEnvironmentVariable{
+		{Key: "DB_URL", Value: "postgres://${DB_USER}@${DB_HOST}/${prod.db.NAME}", Type: "shared", Environment: "dev", SecretPath: "/"},
+		{Key: "DB_USER", Value: "shared-user", Type: "shared", Environment: "dev", SecretPath: "/"},
+		{Key: "DB_USER", Value: "personal-user", Type: "personal", Environment: "dev", SecretPath: "/"},
+		{Key: "DB_HOST", Value: "${DB_URL}", Type: "shared", Environment: "staging", SecretPath: "/common", IsImported: true},
+		{Key: "HOME", Value: "/tmp", Type: "shared", Environment: "dev", SecretPath: "/"},
+	}
+
+	explanations := explainSecrets(secrets, true, true)
+	byKey := map[string]runSecretExplanation{}
+	for _, explanation := range explanations {
+		byKey[explanation.Key] = explanation
+	}
+
+	if len(explanations) != 4 || explanations[0].Key != "DB_HOST" {
+		t.Fatalf("Expected 4 explanations sorted by key, got %+v", explanations)
+	}
+
+	user := byKey["DB_USER"]
+	if user.Source.Type != "personal" || len(user.Overridden) != 1 || user.Overridden[0].Type != "shared" {
+		t.Errorf("Expected the personal DB_USER to override the shared one, got %+v", user)
+	}
+
+	if shared := explainSecrets(secrets, false, false); shared[2].Source.Type != "shared" || shared[2].Key != "DB_USER" {
+		t.Errorf("Expected the shared DB_USER to win without secret overriding, got %+v", shared[2])
+	}
+
+	host := byKey["DB_HOST"]
+	if !host.ShadowsEnv {
+		t.Errorf("Expected DB_HOST to be reported as replacing an existing environment variable")
+	}
+	if describeSecretSource(host.Source) != "shared secret imported from staging:/common" {
+		t.Errorf("Unexpected source for DB_HOST: %s", describeSecretSource(host.Source))
+	}
+
+	if byKey["HOME"].FilteredReason == "" {
+		t.Errorf("Expected HOME to be filtered")
+	}
+
+	expected := []runSecretReference{
+		{Reference: "DB_USER", Depth: 0, Resolution: "personal secret in dev:/"},
+		{Reference: "DB_HOST", Depth: 0, Resolution: "shared secret imported from staging:/common"},
+		{Reference: "DB_URL", Depth: 1, Resolution: "circular reference"},
+		{Reference: "prod.db.NAME", Depth: 0, Resolution: "NAME in prod:/db"},
+	}
+
+	references := byKey["DB_URL"].References
+	if len(references) != len(expected) {
+		t.Fatalf("Expected %d references, got %+v", len(expected), references)
+	}
+	for index := range expected {
+		if references[index] != expected[index] {
+			t.Errorf("Expected reference %+v, got %+v", expected[index], references[index])
+		}
+	}
+}
+
+func TestExplainSecretsPicksTheSameWinnerAsOverrideSecrets(t *testing.T) {
+	secrets := []models.SingleEnvironmentVariable{
+		{Key: "API_KEY", Value: "from-root", Type: "shared", Environment: "dev", SecretPath: "/"},
+		{Key: "API_KEY", Value: "from-import", Type: "shared", Environment: "staging", SecretPath: "/common", IsImported: true},
+	}
+
+	overridden := util.OverrideSecrets(append([]models.SingleEnvironmentVariable{}, secrets...), util.SECRET_TYPE_SHARED)
+	explanations := explainSecrets(secrets, false, false)
+
+	if len(overridden) != 1 || len(explanations) != 1 {
+		t.Fatalf("Expected a single API_KEY, got %+v and %+v", overridden, explanations)
+	}
+	if explanations[0].Source.Value != overridden[0].Value {
+		t.Errorf("Expected the injected secret %q to be explained, got %q", overridden[0].Value, explanations[0].Source.Value)
+	}
+	if len(explanations[0].Overridden) != 1 || explanations[0].Overridden[0].Value != "from-root" {
+		t.Errorf("Expected the other API_KEY to be reported as overridden, got %+v", explanations[0].Overridden)
+	}
+}
@@ -0,0 +1,250 @@
+/*
+Copyright (c) 2023 Infisical Inc.
+*/
+package cmd
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Infisical/infisical-merge/packages/models"
+)
+
+// secret values shorter than this are not masked, they would redact too much unrelated output
+const RUN_MASK_MIN_SECRET_LENGTH = 4
+
+const RUN_MASK_REPLACEMENT = "***"
+
+// how long the possible start of a secret is held back when no more output follows. It is then written as
+// RUN_MASK_REPLACEMENT, so interactive output does not stall and a secret written in slow chunks is still masked
+const RUN_MASK_FLUSH_DELAY = 100 * time.Millisecond
+
+// secretMaskPatterns returns every value that should be masked for the given secrets: the raw values,
+// the individual lines of multi-line values and their base64 and URL-encoded forms. Longest patterns come first.
+func secretMaskPatterns(secrets map[string]models.SingleEnvironmentVariable) [][]byte {
+	unique := map[string]bool{}
+	add := func(value string) {
+		unique[value] = true
+	}
+
+	for _, secret := range secrets {
+		values := []string{secret.Value}
+		if strings.Contains(secret.Value, "\n") {
+			for _, line := range strings.Split(secret.Value, "\n") {
+				values = append(values, strings.TrimSpace(line))
+			}
+		}
+
+		for _, value := range values {
+			if len(value) < RUN_MASK_MIN_SECRET_LENGTH {
+				continue
+			}
+
+			add(value)
+			add(base64.StdEncoding.EncodeToString([]byte(value)))
+			add(base64.RawStdEncoding.EncodeToString([]byte(value)))
+			add(base64.URLEncoding.EncodeToString([]byte(value)))
+			add(base64.RawURLEncoding.EncodeToString([]byte(value)))
+			add(url.QueryEscape(value))
+			add(url.PathEscape(value))
+		}
+	}
+
+	patterns := make([][]byte, 0, len(unique))
+	for value := range unique {
+		patterns = append(patterns, []byte(value))
+	}
+
+	sort.Slice(patterns, func(i, j int) bool {
+		if len(patterns[i]) != len(patterns[j]) {
+			return len(patterns[i]) > len(patterns[j])
+		}
+		return bytes.Compare(patterns[i], patterns[j]) < 0
+	})
+
+	return patterns
+}
+
+// maskingWriter replaces every pattern written to it with RUN_MASK_REPLACEMENT before passing the output on, the
+// longest pattern wins when several match. When the end of a write could be the start of a pattern, those bytes are
+// held back until a write shows whether they match, so that values split across write boundaries are still masked.
+// When RUN_MASK_FLUSH_DELAY passes without a write, RUN_MASK_REPLACEMENT is written in their place and the bytes
+// stay held back, so the rest of the value is masked too once it arrives. Held back bytes are never written as is
+// before Close.
+type maskingWriter struct {
+	out             io.Writer
+	patternsByFirst map[byte][][]byte
+	pending         []byte
+	// set once RUN_MASK_REPLACEMENT has been written for the pending bytes after the flush delay
+	pendingShown bool
+	flushDelay   time.Duration
+	flushTimer   *time.Timer
+	// incremented by each write, so a flush scheduled before a write does not flush what that write held back
+	flushGeneration int
+	mutex           sync.Mutex
+}
+
+func newMaskingWriter(out io.Writer, patterns [][]byte) *maskingWriter {
+	patternsByFirst := map[byte][][]byte{}
+	for _, pattern := range patterns {
+		patternsByFirst[pattern[0]] = append(patternsByFirst[pattern[0]], pattern)
+	}
+
+	for _, firstPatterns := range patternsByFirst {
+		sort.SliceStable(firstPatterns, func(i, j int) bool {
+			return len(firstPatterns[i]) > len(firstPatterns[j])
+		})
+	}
+
+	return &maskingWriter{out: out, patternsByFirst: patternsByFirst, flushDelay: RUN_MASK_FLUSH_DELAY}
+}
+
+func (w *maskingWriter) Write(p []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.flushTimer != nil {
+		w.flushTimer.Stop()
+	}
+
+	data := append(append([]byte{}, w.pending...), p...)
+	w.flushGeneration++
+
+	if w.pendingShown {
+		rest, held := w.skipShown(data, len(w.pending))
+		if held {
+			w.pending = data
+			return len(p), nil
+		}
+
+		data = rest
+		w.pendingShown = false
+	}
+
+	masked, pending := w.mask(data, false)
+	w.pending = pending
+
+	if len(masked) > 0 {
+		if _, err := w.out.Write(masked); err != nil {
+			return 0, err
+		}
+	}
+
+	if len(w.pending) > 0 {
+		generation := w.flushGeneration
+		w.flushTimer = time.AfterFunc(w.flushDelay, func() {
+			w.mutex.Lock()
+			defer w.mutex.Unlock()
+			if generation == w.flushGeneration && !w.pendingShown {
+				w.pendingShown = true
+				_, _ = w.out.Write([]byte(RUN_MASK_REPLACEMENT))
+			}
+		})
+	}
+
+	return len(p), nil
+}
+
+func (w *maskingWriter) Close() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.flushTimer != nil {
+		w.flushTimer.Stop()
+	}
+
+	if w.pendingShown {
+		w.pending = nil
+		w.pendingShown = false
+		return nil
+	}
+
+	masked, _ := w.mask(w.pending, true)
+	w.pending = nil
+
+	if len(masked) == 0 {
+		return nil
+	}
+
+	_, err := w.out.Write(masked)
+	return err
+}
+
+// skipShown returns what follows the bytes already written as RUN_MASK_REPLACEMENT at the start of data: the shown
+// bytes, or the longest pattern matching there when it reaches further. held is set while data may still grow into
+// a longer pattern.
+func (w *maskingWriter) skipShown(data []byte, shown int) ([]byte, bool) {
+	skip := shown
+	for _, pattern := range w.patternsByFirst[data[0]] {
+		if len(data) < len(pattern) && bytes.HasPrefix(pattern, data) {
+			return nil, true
+		}
+		if bytes.HasPrefix(data, pattern) && len(pattern) > skip {
+			skip = len(pattern)
+		}
+	}
+
+	return data[skip:], false
+}
+
+// mask returns the masked data and, unless final is set, the trailing bytes that may still turn into a match. The
+// trailing bytes are held back even when a shorter pattern matches them already, since a longer one may match once
+// the rest of the output arrives
+func (w *maskingWriter) mask(data []byte, final bool) ([]byte, []byte) {
+	masked := make([]byte, 0, len(data))
+
+	for i := 0; i < len(data); {
+		// longest first
+		patterns := w.patternsByFirst[data[i]]
+
+		if !final {
+			for _, pattern := range patterns {
+				if len(data)-i < len(pattern) && bytes.HasPrefix(pattern, data[i:]) {
+					return masked, append([]byte{}, data[i:]...)
+				}
+			}
+		}
+
+		matched := false
+		for _, pattern := range patterns {
+			if bytes.HasPrefix(data[i:], pattern) {
+				masked = append(masked, RUN_MASK_REPLACEMENT...)
+				i += len(pattern)
+				matched = true
+				break
+			}
+		}
+
+		if matched {
+			continue
+		}
+
+		masked = append(masked, data[i])
+		i++
+	}
+
+	return masked, nil
+}
+
+// outputWriters returns the writers for the output of a child process. When mask patterns are given the
+// output goes through a maskingWriter, and the returned close function must be called once the child has exited.
+func outputWriters(maskPatterns [][]byte) (io.Writer, io.Writer, func()) {
+	if len(maskPatterns) == 0 {
+		return os.Stdout, os.Stderr, func() {}
+	}
+
+	stdout := newMaskingWriter(os.Stdout, maskPatterns)
+	stderr := newMaskingWriter(os.Stderr, maskPatterns)
+
+	return stdout, stderr, func() {
+		_ = stdout.Close()
+		_ = stderr.Close()
+	}
+}
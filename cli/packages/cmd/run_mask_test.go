@@ -0,0 +1,149 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/base64"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Infisical/infisical-merge/packages/models"
+)
+
+func newTestMaskingWriter(out *bytes.Buffer, values ...string) *maskingWriter {
+	secrets := map[string]models.SingleEnvironmentVariable{}
+	for index, value := range values {
+		secrets[string(rune('A'+index))] = models.SingleEnvironmentVariable{Value: value}
+	}
+
+	return newMaskingWriter(out, secretMaskPatterns(secrets))
+}
+
+func TestMaskingWriterMasksValuesAndEncodings(t *testing.T) {
+	var out bytes.Buffer
+	writer := newTestMaskingWriter(&out, "s3cr3t/value", "abc")
+
+	writer.Write([]byte("plain s3cr3t/value\n"))
+	writer.Write([]byte("base64 " + base64.StdEncoding.EncodeToString([]byte("s3cr3t/value")) + "\n"))
+	writer.Write([]byte("url " + url.QueryEscape("s3cr3t/value") + "\n"))
+	writer.Write([]byte("short abc\n"))
+	writer.Close()
+
+	expected := "plain ***\nbase64 ***\nurl ***\nshort abc\n"
+	if out.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, out.String())
+	}
+}
+
+func TestMaskingWriterHandlesSplitWrites(t *testing.T) {
+	var out bytes.Buffer
+	writer := newTestMaskingWriter(&out, "password123")
+
+	writer.Write([]byte("token=pass"))
+	if out.String() != "token=" {
+		t.Errorf("Expected the possible start of a secret to be held back, got %q", out.String())
+	}
+
+	writer.Write([]byte("word"))
+	writer.Write([]byte("123 done pass"))
+	writer.Close()
+
+	expected := "token=*** done pass"
+	if out.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, out.String())
+	}
+}
+
+func TestMaskingWriterMultiLineValues(t *testing.T) {
+	var out bytes.Buffer
+	writer := newTestMaskingWriter(&out, "-----BEGIN KEY-----\nMIIEvQIBADANBg\n-----END KEY-----")
+
+	writer.Write([]byte("line MIIEvQIBADANBg\n"))
+	writer.Close()
+
+	if out.String() != "line ***\n" {
+		t.Errorf("Expected each line of a multi-line secret to be masked, got %q", out.String())
+	}
+}
+
+func TestMaskingWriterPrefersLongestPatternAcrossWrites(t *testing.T) {
+	var out bytes.Buffer
+	writer := newTestMaskingWriter(&out, "secret", "secret-extended")
+
+	writer.Write([]byte("key=secret"))
+	if out.String() != "key=" {
+		t.Errorf("Expected the shorter secret to be held back while the longer one may still match, got %q", out.String())
+	}
+
+	writer.Write([]byte("-extended and secret!"))
+	writer.Close()
+
+	expected := "key=*** and ***!"
+	if out.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, out.String())
+	}
+}
+
+func waitForOutput(out *lockedBuffer, expected string) {
+	deadline := time.Now().Add(time.Second)
+	for out.String() != expected && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestMaskingWriterMasksPartialTailAfterDelay(t *testing.T) {
+	out := &lockedBuffer{}
+	writer := newMaskingWriter(out, secretMaskPatterns(map[string]models.SingleEnvironmentVariable{"A": {Value: "password123"}}))
+	writer.flushDelay = 10 * time.Millisecond
+
+	writer.Write([]byte("Enter pass"))
+	waitForOutput(out, "Enter ***")
+
+	if out.String() != "Enter ***" {
+		t.Errorf("Expected the partial tail to be masked after the flush delay, got %q", out.String())
+	}
+
+	writer.Write([]byte("ing done\n"))
+	writer.Close()
+
+	// the held bytes turned out not to be a secret, they stay masked
+	if out.String() != "Enter ***ing done\n" {
+		t.Errorf("Expected the output after the partial tail to be written, got %q", out.String())
+	}
+}
+
+func TestMaskingWriterMasksSecretWrittenInDelayedChunks(t *testing.T) {
+	out := &lockedBuffer{}
+	writer := newMaskingWriter(out, secretMaskPatterns(map[string]models.SingleEnvironmentVariable{"A": {Value: "password123"}}))
+	writer.flushDelay = 10 * time.Millisecond
+
+	writer.Write([]byte("token=pass"))
+	waitForOutput(out, "token=***")
+	time.Sleep(5 * writer.flushDelay)
+
+	writer.Write([]byte("word123 done\n"))
+	writer.Close()
+
+	expected := "token=*** done\n"
+	if out.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, out.String())
+	}
+}
+
+type lockedBuffer struct {
+	buffer bytes.Buffer
+	mutex  sync.Mutex
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buffer.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buffer.String()
+}
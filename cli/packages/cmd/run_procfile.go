@@ -0,0 +1,381 @@
+/*
+Copyright (c) 2023 Infisical Inc.
+*/
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Infisical/infisical-merge/packages/models"
+	"github.com/Infisical/infisical-merge/packages/util"
+	"github.com/fatih/color"
+	"github.com/posthog/posthog-go"
+	"github.com/rs/zerolog/log"
+	"gopkg.in/yaml.v2"
+)
+
+// RunProcfile is the format of the file given to `infisical run --procfile`
+type RunProcfile struct {
+	Processes map[string]RunProcfileProcess `yaml:"processes"`
+}
+
+// RunProcfileProcess is a single named process. Every scope field that is left empty falls back to the matching flag of the run command.
+type RunProcfileProcess struct {
+	Command        string `yaml:"command"`
+	Env            string `yaml:"env"`
+	Path           string `yaml:"path"`
+	Tags           string `yaml:"tags"`
+	IncludeImports *bool  `yaml:"include-imports"`
+	Recursive      *bool  `yaml:"recursive"`
+}
+
+// procfileCommand is a process that is ready to be started
+type procfileCommand struct {
+	Name    string
+	Command string
+	Env     []string
+}
+
+var procfileColors = []color.Attribute{
+	color.FgCyan,
+	color.FgYellow,
+	color.FgGreen,
+	color.FgMagenta,
+	color.FgBlue,
+	color.FgRed,
+}
+
+func ParseRunProcfile(data []byte) (*RunProcfile, error) {
+	var procfile RunProcfile
+	if err := yaml.UnmarshalStrict(data, &procfile); err != nil {
+		return nil, fmt.Errorf("unable to parse procfile [err=%v]", err)
+	}
+
+	if len(procfile.Processes) == 0 {
+		return nil, fmt.Errorf("procfile does not define any processes")
+	}
+
+	for name, process := range procfile.Processes {
+		if strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("procfile contains a process without a name")
+		}
+
+		if strings.TrimSpace(process.Command) == "" {
+			return nil, fmt.Errorf("process [%s] does not have a command", name)
+		}
+	}
+
+	return &procfile, nil
+}
+
+func readRunProcfile(procfilePath string) *RunProcfile {
+	data, err := os.ReadFile(procfilePath)
+	if err != nil {
+		util.HandleError(err, "Unable to read procfile")
+	}
+
+	procfile, err := ParseRunProcfile(data)
+	if err != nil {
+		util.HandleError(err)
+	}
+
+	return procfile
+}
+
+// ProcessNames returns the names of the processes in a stable order
+func (p *RunProcfile) ProcessNames() []string {
+	names := make([]string, 0, len(p.Processes))
+	for name := range p.Processes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// scopeFor applies the overrides of a process on top of the options given on the command line
+func (p RunProcfileProcess) scopeFor(defaults runSecretsOptions) runSecretsOptions {
+	options := defaults
+	if p.Env != "" {
+		options.Environment = p.Env
+	}
+	if p.Path != "" {
+		options.SecretsPath = p.Path
+	}
+	if p.Tags != "" {
+		options.TagSlugs = p.Tags
+	}
+	if p.IncludeImports != nil {
+		options.IncludeImports = *p.IncludeImports
+	}
+	if p.Recursive != nil {
+		options.Recursive = *p.Recursive
+	}
+
+	return options
+}
+
+// runProcfile fetches the secrets of every process in the procfile, starts them together and returns the aggregate exit code
+func runProcfile(procfilePath string, token *models.TokenDetails, projectId string, projectConfigDir string, defaults runSecretsOptions, dynamicSecrets []string, killTimeout time.Duration, maskOutput bool) int {
+	procfile := readRunProcfile(procfilePath)
+
+	// processes sharing a scope share a single fetch
+	secretsByScope := map[runSecretsOptions]map[string]models.SingleEnvironmentVariable{}
+	for _, name := range procfile.ProcessNames() {
+		scope := procfile.Processes[name].scopeFor(defaults)
+		if _, ok := secretsByScope[scope]; !ok {
+			secretsByScope[scope] = fetchSecretsForRun(token, projectId, projectConfigDir, scope)
+		}
+	}
+
+	var dynamicSecretLeases *RunDynamicSecretLeases
+	if len(dynamicSecrets) > 0 {
+		dynamicSecretLeases = createDynamicSecretLeasesForRun(token, projectId, projectConfigDir, defaults.Environment, defaults.SecretsPath, dynamicSecrets)
+	}
+
+	commands := []procfileCommand{}
+	allSecretsByKey := map[string]models.SingleEnvironmentVariable{}
+	for _, name := range procfile.ProcessNames() {
+		process := procfile.Processes[name]
+
+		secretsByKey := map[string]models.SingleEnvironmentVariable{}
+		for key, secret := range secretsByScope[process.scopeFor(defaults)] {
+			secretsByKey[key] = secret
+		}
+
+		// dynamic secret leases take precedence over static secrets with the same name
+		if dynamicSecretLeases != nil {
+			for _, secret := range dynamicSecretLeases.Secrets() {
+				secretsByKey[secret.Key] = secret
+			}
+		}
+
+		// the masked values are collected before reserved names are filtered out
+		for key, secret := range secretsByKey {
+			allSecretsByKey[name+"/"+key] = secret
+		}
+
+		log.Info().Msgf(color.GreenString("Injecting %v Infisical secrets into process [%s]", len(secretsByKey), name))
+
+		commands = append(commands, procfileCommand{
+			Name:    name,
+			Command: process.Command,
+			Env:     buildEnvironmentForRun(secretsByKey),
+		})
+	}
+
+	Telemetry.CaptureEvent("cli-command:run",
+		posthog.NewProperties().
+			Set("environment", defaults.Environment).
+			Set("isUsingServiceToken", token != nil && token.Type == util.SERVICE_TOKEN_IDENTIFIER).
+			Set("isUsingUniversalAuthToken", token != nil && token.Type == util.UNIVERSAL_AUTH_TOKEN_IDENTIFIER).
+			Set("procfileProcessCount", len(commands)).
+			Set("version", util.CLI_VERSION))
+
+	if dynamicSecretLeases != nil {
+		dynamicSecretLeases.StartRenewal()
+	}
+
+	var maskPatterns [][]byte
+	if maskOutput {
+		maskPatterns = secretMaskPatterns(allSecretsByKey)
+	}
+
+	stdout, stderr, closeOutput := outputWriters(maskPatterns)
+	exitCode := executeProcfileCommands(commands, stdout, stderr, killTimeout)
+	closeOutput()
+
+	if dynamicSecretLeases != nil {
+		dynamicSecretLeases.RevokeAll()
+	}
+
+	return exitCode
+}
+
+type procfileExit struct {
+	index int
+	state *os.ProcessState
+	err   error
+}
+
+// executeProcfileCommands starts every command in its own process group with prefixed output.
+// As soon as one of them exits, or we receive a termination signal, the others are asked to stop.
+// The exit code is the one of the process that exited first, or when shutdown was started by a
+// signal, the first non-zero exit code.
+func executeProcfileCommands(commands []procfileCommand, stdout io.Writer, stderr io.Writer, killTimeout time.Duration) int {
+	sigChannel := make(chan os.Signal, 1)
+	signal.Notify(sigChannel)
+	defer signal.Stop(sigChannel)
+
+	nameWidth := 0
+	for _, command := range commands {
+		if len(command.Name) > nameWidth {
+			nameWidth = len(command.Name)
+		}
+	}
+
+	outputMutex := &sync.Mutex{}
+	cmds := make([]*exec.Cmd, len(commands))
+	writers := make([][2]*prefixedWriter, len(commands))
+	exited := make(chan procfileExit, len(commands))
+	running := map[int]bool{}
+
+	stopRunning := func(stop func(cmd *exec.Cmd) error) {
+		for index := range running {
+			_ = stop(cmds[index])
+		}
+	}
+
+	for index, command := range commands {
+		prefix := color.New(procfileColors[index%len(procfileColors)]).Sprintf("%-*s | ", nameWidth, command.Name)
+		writers[index] = [2]*prefixedWriter{
+			newPrefixedWriter(stdout, prefix, outputMutex),
+			newPrefixedWriter(stderr, prefix, outputMutex),
+		}
+
+		cmd := newShellCommand(command.Command)
+		cmd.Stdout = writers[index][0]
+		cmd.Stderr = writers[index][1]
+		cmd.Env = command.Env
+		configureProcessGroup(cmd)
+
+		log.Debug().Msgf("starting process %s: %s", command.Name, strings.Join(cmd.Args, " "))
+
+		if err := cmd.Start(); err != nil {
+			fmt.Fprintf(stderr, "Unable to start process [%s] [err=%v]\n", command.Name, err)
+			stopRunning(terminateProcessGroup)
+			for range running {
+				<-exited
+			}
+			return exitCodeFromStartError(err)
+		}
+
+		cmds[index] = cmd
+		running[index] = true
+
+		go func(index int) {
+			err := cmds[index].Wait()
+			exited <- procfileExit{index: index, state: cmds[index].ProcessState, err: err}
+		}(index)
+	}
+
+	exitCode := -1
+	firstFailure := 0
+	shuttingDown := false
+	var escalate <-chan time.Time
+
+	startShutdown := func() {
+		shuttingDown = true
+		stopRunning(terminateProcessGroup)
+		if killTimeout > 0 {
+			escalate = time.After(killTimeout)
+		}
+	}
+
+	for len(running) > 0 {
+		select {
+		case sig := <-sigChannel:
+			if !isForwardableSignal(sig) {
+				continue
+			}
+
+			stopRunning(func(cmd *exec.Cmd) error { return signalProcessGroup(cmd, sig) })
+
+			if isTerminationSignal(sig) && !shuttingDown {
+				shuttingDown = true
+				if killTimeout > 0 {
+					escalate = time.After(killTimeout)
+				}
+			}
+		case <-escalate:
+			log.Debug().Msgf("processes did not exit within %s, sending SIGKILL", killTimeout)
+			stopRunning(func(cmd *exec.Cmd) error { return signalProcessGroup(cmd, os.Kill) })
+			escalate = nil
+		case result := <-exited:
+			delete(running, result.index)
+			writers[result.index][0].Flush()
+			writers[result.index][1].Flush()
+
+			code := 1
+			if result.state != nil {
+				code = exitCodeFromProcessState(result.state)
+			} else {
+				log.Debug().Msgf("failed to wait for process %s: %v", commands[result.index].Name, result.err)
+			}
+
+			outputMutex.Lock()
+			fmt.Fprintln(stderr, color.New(procfileColors[result.index%len(procfileColors)]).Sprintf("%-*s | exited with code %d", nameWidth, commands[result.index].Name, code))
+			outputMutex.Unlock()
+
+			if code != 0 && firstFailure == 0 {
+				firstFailure = code
+			}
+
+			if !shuttingDown {
+				exitCode = code
+				startShutdown()
+			}
+		}
+	}
+
+	if exitCode == -1 {
+		return firstFailure
+	}
+
+	return exitCode
+}
+
+// prefixedWriter prefixes every line written by a process. Lines are only written once complete
+// so the output of processes sharing a terminal does not interleave.
+type prefixedWriter struct {
+	out    io.Writer
+	prefix string
+	mutex  *sync.Mutex
+	buffer []byte
+}
+
+func newPrefixedWriter(out io.Writer, prefix string, mutex *sync.Mutex) *prefixedWriter {
+	return &prefixedWriter{out: out, prefix: prefix, mutex: mutex}
+}
+
+func (w *prefixedWriter) Write(p []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	w.buffer = append(w.buffer, p...)
+	for {
+		index := bytes.IndexByte(w.buffer, '\n')
+		if index == -1 {
+			break
+		}
+
+		if _, err := io.WriteString(w.out, w.prefix+string(w.buffer[:index+1])); err != nil {
+			return 0, err
+		}
+		w.buffer = w.buffer[index+1:]
+	}
+
+	return len(p), nil
+}
+
+// Flush writes a trailing line that was not terminated by a newline
+func (w *prefixedWriter) Flush() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if len(w.buffer) == 0 {
+		return
+	}
+
+	_, _ = io.WriteString(w.out, w.prefix+string(w.buffer)+"\n")
+	w.buffer = nil
+}
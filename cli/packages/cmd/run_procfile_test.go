@@ -0,0 +1,121 @@
+//go:build !windows
+
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+func TestParseRunProcfile(t *testing.T) {
+	procfile, err := ParseRunProcfile([]byte(`
+processes:
+  web:
+    command: npm run dev
+    path: /web
+  worker:
+    command: npm run worker
+    env: staging
+    tags: queue
+    include-imports: false
+`))
+	if err != nil {
+		t.Fatalf("Expected procfile to parse, got error %v", err)
+	}
+
+	names := procfile.ProcessNames()
+	if strings.Join(names, ",") != "web,worker" {
+		t.Fatalf("Expected processes web and worker, got %v", names)
+	}
+
+	defaults := runSecretsOptions{Environment: "dev", SecretsPath: "/", IncludeImports: true}
+
+	web := procfile.Processes["web"].scopeFor(defaults)
+	if web.Environment != "dev" || web.SecretsPath != "/web" || !web.IncludeImports {
+		t.Errorf("Unexpected scope for web: %+v", web)
+	}
+
+	worker := procfile.Processes["worker"].scopeFor(defaults)
+	if worker.Environment != "staging" || worker.SecretsPath != "/" || worker.TagSlugs != "queue" || worker.IncludeImports {
+		t.Errorf("Unexpected scope for worker: %+v", worker)
+	}
+
+	invalid := []string{
+		``,
+		`processes: {}`,
+		"processes:\n  web:\n    env: dev\n",
+		"processes:\n  web:\n    command: ls\n    unknown: true\n",
+	}
+	for _, data := range invalid {
+		if _, err := ParseRunProcfile([]byte(data)); err == nil {
+			t.Errorf("Expected procfile %q to be rejected", data)
+		}
+	}
+}
+
+func TestPrefixedWriter(t *testing.T) {
+	var out bytes.Buffer
+	writer := newPrefixedWriter(&out, "web | ", &sync.Mutex{})
+
+	writer.Write([]byte("hello\nwor"))
+	writer.Write([]byte("ld\npartial"))
+
+	if out.String() != "web | hello\nweb | world\n" {
+		t.Errorf("Unexpected output before flush: %q", out.String())
+	}
+
+	writer.Flush()
+
+	if out.String() != "web | hello\nweb | world\nweb | partial\n" {
+		t.Errorf("Unexpected output after flush: %q", out.String())
+	}
+}
+
+func TestExecuteProcfileCommandsStopsOthersOnExit(t *testing.T) {
+	color.NoColor = true
+	t.Setenv("SHELL", "/bin/sh")
+
+	var stdout, stderr bytes.Buffer
+	commands := []procfileCommand{
+		{Name: "failing", Command: "echo $GREETING; exit 3", Env: []string{"GREETING=hi"}},
+		{Name: "server", Command: "sleep 30"},
+	}
+
+	start := time.Now()
+	exitCode := executeProcfileCommands(commands, &stdout, &stderr, 5*time.Second)
+
+	if exitCode != 3 {
+		t.Errorf("Expected exit code 3 of the first process to exit, got %d", exitCode)
+	}
+
+	if time.Since(start) > 10*time.Second {
+		t.Errorf("Expected the remaining process to be stopped")
+	}
+
+	if !strings.Contains(stdout.String(), "failing | hi\n") {
+		t.Errorf("Expected prefixed output, got %q", stdout.String())
+	}
+
+	if !strings.Contains(stderr.String(), "server  | exited with code 143") {
+		t.Errorf("Expected the server to be terminated, got %q", stderr.String())
+	}
+}
+
+func TestExecuteProcfileCommandsAllSucceed(t *testing.T) {
+	color.NoColor = true
+	t.Setenv("SHELL", "/bin/sh")
+
+	var stdout, stderr bytes.Buffer
+	commands := []procfileCommand{
+		{Name: "a", Command: "exit 0"},
+	}
+
+	if exitCode := executeProcfileCommands(commands, &stdout, &stderr, 0); exitCode != 0 {
+		t.Errorf("Expected exit code 0, got %d", exitCode)
+	}
+}
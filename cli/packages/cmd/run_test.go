@@ -0,0 +1,170 @@
+//go:build !windows
+
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// TestHelperProcess is not a real test. It is re-executed by the tests below as the child process.
+func TestHelperProcess(t *testing.T) {
+	if os.Getenv("GO_WANT_HELPER_PROCESS") != "1" {
+		return
+	}
+
+	args := os.Args
+	for len(args) > 0 && args[0] != "--" {
+		args = args[1:]
+	}
+	args = args[1:]
+
+	switch args[0] {
+	case "exit":
+		code, _ := strconv.Atoi(args[1])
+		os.Exit(code)
+	case "kill-self":
+		_ = syscall.Kill(os.Getpid(), syscall.SIGTERM)
+		time.Sleep(time.Minute)
+	case "ignore-term":
+		signal.Ignore(syscall.SIGTERM)
+		fmt.Println("ready")
+		time.Sleep(time.Minute)
+	case "sleep":
+		time.Sleep(time.Minute)
+	}
+
+	os.Exit(0)
+}
+
+func helperArgs(args ...string) []string {
+	return append([]string{os.Args[0], "-test.run=TestHelperProcess", "--"}, args...)
+}
+
+func helperEnv() []string {
+	return append(os.Environ(), "GO_WANT_HELPER_PROCESS=1")
+}
+
+func waitWithDeadline(t *testing.T, cmd *exec.Cmd, sigChannel chan os.Signal, killTimeout time.Duration) *os.ProcessState {
+	type result struct {
+		state *os.ProcessState
+		err   error
+	}
+
+	resultChannel := make(chan result, 1)
+	go func() {
+		state, err := waitForCommand(cmd, sigChannel, killTimeout)
+		resultChannel <- result{state, err}
+	}()
+
+	select {
+	case res := <-resultChannel:
+		if res.err != nil {
+			t.Fatalf("unexpected error waiting for command: %v", res.err)
+		}
+		return res.state
+	case <-time.After(10 * time.Second):
+		_ = signalProcessGroup(cmd, os.Kill)
+		t.Fatalf("command did not exit in time")
+		return nil
+	}
+}
+
+func TestExecuteSingleCommandPropagatesExitCode(t *testing.T) {
+	state, err := executeSingleCommandWithEnvs(helperArgs("exit", "7"), 0, helperEnv(), 0, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if code := exitCodeFromProcessState(state); code != 7 {
+		t.Errorf("Expected exit code 7, got %d", code)
+	}
+}
+
+func TestExecuteSingleCommandPropagatesSignal(t *testing.T) {
+	state, err := executeSingleCommandWithEnvs(helperArgs("kill-self"), 0, helperEnv(), 0, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	status := state.Sys().(syscall.WaitStatus)
+	if !status.Signaled() || status.Signal() != syscall.SIGTERM {
+		t.Errorf("Expected child to be terminated by SIGTERM, got %v", status)
+	}
+
+	if code := exitCodeFromProcessState(state); code != 128+int(syscall.SIGTERM) {
+		t.Errorf("Expected exit code %d, got %d", 128+int(syscall.SIGTERM), code)
+	}
+}
+
+func TestExecuteCommandNotFound(t *testing.T) {
+	state, err := executeSingleCommandWithEnvs([]string{"infisical-command-that-does-not-exist"}, 0, nil, 0, nil)
+	if err == nil || state != nil {
+		t.Fatalf("Expected start error")
+	}
+
+	if code := exitCodeFromStartError(err); code != 127 {
+		t.Errorf("Expected exit code 127, got %d", code)
+	}
+}
+
+func TestSignalsAreForwardedToProcessGroup(t *testing.T) {
+	helper := fmt.Sprintf("%s -test.run=TestHelperProcess -- sleep", os.Args[0])
+	cmd := exec.Command("sh", "-c", fmt.Sprintf("%s & %s & wait", helper, helper))
+	cmd.Env = helperEnv()
+
+	// a buffer keeps Wait blocked until every process holding the pipe (including grandchildren) has exited
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+
+	configureProcessGroup(cmd)
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("unable to start command: %v", err)
+	}
+
+	sigChannel := make(chan os.Signal, 1)
+	sigChannel <- syscall.SIGTERM
+
+	state := waitWithDeadline(t, cmd, sigChannel, 0)
+	if state.Success() {
+		t.Errorf("Expected shell to be terminated by the forwarded signal")
+	}
+}
+
+func TestKillTimeoutEscalatesToSigkill(t *testing.T) {
+	cmd := exec.Command(os.Args[0], "-test.run=TestHelperProcess", "--", "ignore-term")
+	cmd.Env = helperEnv()
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatalf("unable to create stdout pipe: %v", err)
+	}
+
+	configureProcessGroup(cmd)
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("unable to start command: %v", err)
+	}
+
+	// wait until the helper has started ignoring SIGTERM
+	if _, err := bufio.NewReader(stdout).ReadString('\n'); err != nil {
+		t.Fatalf("unable to read helper output: %v", err)
+	}
+
+	sigChannel := make(chan os.Signal, 1)
+	sigChannel <- syscall.SIGTERM
+
+	state := waitWithDeadline(t, cmd, sigChannel, 200*time.Millisecond)
+
+	status := state.Sys().(syscall.WaitStatus)
+	if !status.Signaled() || status.Signal() != syscall.SIGKILL {
+		t.Errorf("Expected child to be terminated by SIGKILL, got %v", status)
+	}
+}
@@ -0,0 +1,95 @@
+//go:build !windows
+
+/*
+Copyright (c) 2023 Infisical Inc.
+*/
+package cmd
+
+import (
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+	"golang.org/x/term"
+)
+
+// configureProcessGroup places the child in a new process group so that signals reach every process it spawns.
+// When the child shares our terminal, its group is also made the terminal's foreground group so job control and tty reads keep working.
+func configureProcessGroup(cmd *exec.Cmd) {
+	attributes := &syscall.SysProcAttr{Setpgid: true}
+
+	if cmd.Stdin == os.Stdin && term.IsTerminal(int(os.Stdin.Fd())) {
+		attributes.Foreground = true
+		attributes.Ctty = int(os.Stdin.Fd())
+	}
+
+	cmd.SysProcAttr = attributes
+}
+
+// restoreTerminalForeground hands the terminal back to our process group once a foreground child has exited
+func restoreTerminalForeground(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil || !cmd.SysProcAttr.Foreground {
+		return
+	}
+
+	// we are a background group at this point, so tcsetpgrp would otherwise stop us with SIGTTOU
+	signal.Ignore(syscall.SIGTTOU)
+	defer signal.Reset(syscall.SIGTTOU)
+
+	_ = unix.IoctlSetPointerInt(cmd.SysProcAttr.Ctty, unix.TIOCSPGRP, syscall.Getpgrp())
+}
+
+func signalProcessGroup(cmd *exec.Cmd, sig os.Signal) error {
+	unixSignal, ok := sig.(syscall.Signal)
+	if !ok {
+		return cmd.Process.Signal(sig)
+	}
+
+	// a negative pid targets the whole process group
+	return syscall.Kill(-cmd.Process.Pid, unixSignal)
+}
+
+// terminateProcessGroup asks every process in the group of the command to shut down
+func terminateProcessGroup(cmd *exec.Cmd) error {
+	return signalProcessGroup(cmd, syscall.SIGTERM)
+}
+
+// isForwardableSignal filters out signals that only concern our own process
+func isForwardableSignal(sig os.Signal) bool {
+	switch sig {
+	case syscall.SIGCHLD, syscall.SIGURG, syscall.SIGPIPE, syscall.SIGTTIN, syscall.SIGTTOU:
+		return false
+	default:
+		return true
+	}
+}
+
+func isTerminationSignal(sig os.Signal) bool {
+	return sig == syscall.SIGTERM || sig == syscall.SIGINT
+}
+
+// exitCodeFromProcessState returns the child's exit code, or 128+signal when it was killed by a signal
+func exitCodeFromProcessState(state *os.ProcessState) int {
+	if status, ok := state.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return 128 + int(status.Signal())
+	}
+
+	return state.ExitCode()
+}
+
+// exitWithProcessState exits with the same status as the child. If the child was terminated by a signal
+// we raise that signal on ourselves so the parent observes it too. Signals that would make the Go
+// runtime dump goroutines (e.g. SIGQUIT) are reported through the 128+signal exit code instead.
+func exitWithProcessState(state *os.ProcessState) {
+	if status, ok := state.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		switch status.Signal() {
+		case syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM, syscall.SIGKILL:
+			signal.Reset(status.Signal())
+			_ = syscall.Kill(os.Getpid(), status.Signal())
+		}
+	}
+
+	os.Exit(exitCodeFromProcessState(state))
+}
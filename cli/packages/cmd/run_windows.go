@@ -0,0 +1,41 @@
+//go:build windows
+
+/*
+Copyright (c) 2023 Infisical Inc.
+*/
+package cmd
+
+import (
+	"os"
+	"os/exec"
+)
+
+// process groups are not available on windows, the child keeps sharing our console
+func configureProcessGroup(cmd *exec.Cmd) {}
+
+func restoreTerminalForeground(cmd *exec.Cmd) {}
+
+func signalProcessGroup(cmd *exec.Cmd, sig os.Signal) error {
+	return cmd.Process.Signal(sig)
+}
+
+// windows has no termination signal that can be sent to another process, so the process is killed instead
+func terminateProcessGroup(cmd *exec.Cmd) error {
+	return cmd.Process.Kill()
+}
+
+func isForwardableSignal(sig os.Signal) bool {
+	return true
+}
+
+func isTerminationSignal(sig os.Signal) bool {
+	return sig == os.Interrupt
+}
+
+func exitCodeFromProcessState(state *os.ProcessState) int {
+	return state.ExitCode()
+}
+
+func exitWithProcessState(state *os.ProcessState) {
+	os.Exit(exitCodeFromProcessState(state))
+}
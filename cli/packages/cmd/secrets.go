@@ -5,8 +5,7 @@ package cmd
 
 import (
 	"fmt"
-	"regexp"
-	"sort"
+	"os"
 	"strings"
 
 	"github.com/Infisical/infisical-merge/packages/api"
@@ -53,6 +52,11 @@ var secretsCmd = &cobra.Command{
 			util.HandleError(err)
 		}
 
+		allowMissingReferences, err := cmd.Flags().GetBool("allow-missing-references")
+		if err != nil {
+			util.HandleError(err)
+		}
+
 		includeImports, err := cmd.Flags().GetBool("include-imports")
 		if err != nil {
 			util.HandleError(err)
@@ -73,7 +77,7 @@ var secretsCmd = &cobra.Command{
 			util.HandleError(err, "Unable to parse flag")
 		}
 
-		plainOutput, err := cmd.Flags().GetBool("plain")
+		outputFormat, outputTemplate, err := getSecretsOutputFormat(cmd)
 		if err != nil {
 			util.HandleError(err, "Unable to parse flag")
 		}
@@ -112,19 +116,13 @@ var secretsCmd = &cobra.Command{
 				authParams.UniversalAuthAccessToken = token.Token
 			}
 
-			secrets = util.ExpandSecrets(secrets, authParams, "")
+			secrets = expandSecretReferences(secrets, authParams, "", allowMissingReferences)
 		}
 
 		// Sort the secrets by key so we can create a consistent output
 		secrets = util.SortSecretsByKeys(secrets)
 
-		if plainOutput {
-			for _, secret := range secrets {
-				fmt.Println(secret.Value)
-			}
-		} else {
-			visualize.PrintAllSecretDetails(secrets)
-		}
+		printSecretsOutput(secrets, outputFormat, outputTemplate)
 
 		Telemetry.CaptureEvent("cli-command:secrets", posthog.NewProperties().Set("secretCount", len(secrets)).Set("version", util.CLI_VERSION))
 	},
@@ -140,7 +138,7 @@ var secretsGetCmd = &cobra.Command{
 }
 
 var secretsGenerateExampleEnvCmd = &cobra.Command{
-	Example:               `secrets generate-example-env > .example-env`,
+	Example:               `secrets generate-example-env --output-file .env.example [--check] [--format dotenv|json-schema|typescript|go]`,
 	Short:                 "Used to generate a example .env file",
 	Use:                   "generate-example-env",
 	DisableFlagsInUseLine: true,
@@ -149,7 +147,7 @@ var secretsGenerateExampleEnvCmd = &cobra.Command{
 }
 
 var secretsSetCmd = &cobra.Command{
-	Example:               `secrets set <secretName=secretValue> <secretName=secretValue>..."`,
+	Example:               `secrets set <secretName=secretValue> <secretName=@path/to/file> <secretName=-> <secretName>..."`,
 	Short:                 "Used set secrets",
 	Use:                   "set [secrets]",
 	DisableFlagsInUseLine: true,
@@ -185,25 +183,98 @@ var secretsSetCmd = &cobra.Command{
 			util.HandleError(err, "Unable to parse secret type")
 		}
 
+		comment, err := cmd.Flags().GetString("comment")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		tags, err := cmd.Flags().GetString("tags")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		tagSlugs := parseTagSlugs(tags)
+		if len(tagSlugs) > 0 && token != nil && token.Type == util.SERVICE_TOKEN_IDENTIFIER {
+			util.PrintErrorMessageAndExit("Tags cannot be set with a service token, log in or use a machine identity instead")
+		}
+
+		shouldGenerate, err := cmd.Flags().GetBool("generate")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		showValues, err := cmd.Flags().GetBool("show")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		var secrets []models.SingleEnvironmentVariable
+		if shouldGenerate {
+			secrets, err = generateSecretArgs(args, getSecretGeneratorOptions(cmd))
+			if err != nil {
+				util.HandleError(err, "Unable to generate the secrets to set")
+			}
+		} else {
+			for _, flag := range []string{"format", "length", "charset", "exclude", "separator"} {
+				if cmd.Flags().Changed(flag) {
+					util.PrintErrorMessageAndExit(fmt.Sprintf("--%s can only be used with --generate", flag))
+				}
+			}
+
+			secrets, err = resolveSecretArgs(args, newSecretValueSources())
+			if err != nil {
+				util.HandleError(err, "Unable to read the secrets to set")
+			}
+		}
+
+		for i := range secrets {
+			secrets[i].Comment = comment
+		}
+
+		if err := util.ValidateSecretsToSet(secrets); err != nil {
+			util.PrintErrorMessageAndExit(err.Error())
+		}
+
 		var secretOperations []models.SecretSetOperation
 		if token != nil && (token.Type == util.SERVICE_TOKEN_IDENTIFIER || token.Type == util.UNIVERSAL_AUTH_TOKEN_IDENTIFIER) {
-			secretOperations, err = util.SetRawSecrets(args, secretType, environmentName, secretsPath, projectId, token)
+			secretOperations, err = util.SetRawSecretsFromList(secrets, secretType, environmentName, secretsPath, projectId, token, nil)
 		} else {
 			util.RequireLogin()
 			util.RequireLocalWorkspaceFile()
 
-			secretOperations, err = util.SetEncryptedSecrets(args, secretType, environmentName, secretsPath)
+			secretOperations, err = util.SetEncryptedSecretsFromList(secrets, secretType, environmentName, secretsPath, nil)
 		}
 
 		if err != nil {
 			util.HandleError(err, "Unable to set secrets")
 		}
 
+		if len(tagSlugs) > 0 {
+			params := models.GetAllSecretsParameters{Environment: environmentName, WorkspaceId: projectId, SecretsPath: secretsPath}
+			if token != nil && token.Type == util.UNIVERSAL_AUTH_TOKEN_IDENTIFIER {
+				params.UniversalAuthAccessToken = token.Token
+			}
+
+			secretNames := []string{}
+			for _, secret := range secrets {
+				secretNames = append(secretNames, secret.Key)
+			}
+
+			if err := util.AddSecretTags(params, secretNames, secretType, tagSlugs); err != nil {
+				util.HandleError(err, "Unable to add the tags")
+			}
+		}
+
 		// Print secret operations
 		headers := [...]string{"SECRET NAME", "SECRET VALUE", "STATUS"}
 		rows := [][3]string{}
 		for _, secretOperation := range secretOperations {
-			rows = append(rows, [...]string{secretOperation.SecretKey, secretOperation.SecretValue, secretOperation.SecretOperation})
+			// generated values are only known to the CLI, they are not printed unless asked for
+			secretValue := secretOperation.SecretValue
+			if shouldGenerate && !showValues {
+				secretValue = "(generated, use --show to print it)"
+			}
+			rows = append(rows, [...]string{secretOperation.SecretKey, secretValue, secretOperation.SecretOperation})
 		}
 
 		visualize.Table(headers, rows)
@@ -316,6 +387,11 @@ func getSecretsByNames(cmd *cobra.Command, args []string) {
 		util.HandleError(err, "Unable to parse flag")
 	}
 
+	allowMissingReferences, err := cmd.Flags().GetBool("allow-missing-references")
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+
 	tagSlugs, err := cmd.Flags().GetString("tags")
 	if err != nil {
 		util.HandleError(err, "Unable to parse flag")
@@ -336,13 +412,8 @@ func getSecretsByNames(cmd *cobra.Command, args []string) {
 		util.HandleError(err, "Unable to parse recursive flag")
 	}
 
-	// deprecated, in favor of --plain
-	showOnlyValue, err := cmd.Flags().GetBool("raw-value")
-	if err != nil {
-		util.HandleError(err, "Unable to parse flag")
-	}
-
-	plainOutput, err := cmd.Flags().GetBool("plain")
+	// --plain and the deprecated --raw-value are aliases of --output plain
+	outputFormat, outputTemplate, err := getSecretsOutputFormat(cmd)
 	if err != nil {
 		util.HandleError(err, "Unable to parse flag")
 	}
@@ -380,17 +451,19 @@ func getSecretsByNames(cmd *cobra.Command, args []string) {
 			authParams.UniversalAuthAccessToken = token.Token
 		}
 
-		secrets = util.ExpandSecrets(secrets, authParams, "")
+		secrets = expandSecretReferences(secrets, authParams, "", allowMissingReferences)
 	}
 
 	requestedSecrets := []models.SingleEnvironmentVariable{}
 
 	secretsMap := getSecretsByKeys(secrets)
 
+	missingSecrets := []string{}
 	for _, secretKeyFromArg := range args {
 		if value, ok := secretsMap[secretKeyFromArg]; ok {
 			requestedSecrets = append(requestedSecrets, value)
 		} else {
+			missingSecrets = append(missingSecrets, secretKeyFromArg)
 			requestedSecrets = append(requestedSecrets, models.SingleEnvironmentVariable{
 				Key:   secretKeyFromArg,
 				Type:  "*not found*",
@@ -399,15 +472,14 @@ func getSecretsByNames(cmd *cobra.Command, args []string) {
 		}
 	}
 
-	// showOnlyValue deprecated in favor of --plain, below only for backward compatibility
-	if plainOutput || showOnlyValue {
-		for _, secret := range requestedSecrets {
-			fmt.Println(secret.Value)
-		}
-	} else {
-		visualize.PrintAllSecretDetails(requestedSecrets)
+	// the table and plain outputs mark missing secrets, a placeholder in structured outputs would be read as a value
+	isStructuredOutput := outputTemplate != nil || (outputFormat != SECRETS_OUTPUT_TABLE && outputFormat != SECRETS_OUTPUT_PLAIN)
+	if isStructuredOutput && len(missingSecrets) > 0 {
+		util.PrintErrorMessageAndExit(fmt.Sprintf("secrets not found: %s", strings.Join(missingSecrets, ", ")))
 	}
 
+	printSecretsOutput(requestedSecrets, outputFormat, outputTemplate)
+
 	Telemetry.CaptureEvent("cli-command:secrets get", posthog.NewProperties().Set("secretCount", len(secrets)).Set("version", util.CLI_VERSION))
 }
 
@@ -440,6 +512,22 @@ func generateExampleEnv(cmd *cobra.Command, args []string) {
 		util.HandleError(err, "Unable to parse flag")
 	}
 
+	exampleEnvOptions, err := getExampleEnvFileOptions(cmd)
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+	exampleEnvOptions.FilteredTagSlugs = strings.Split(tagSlugs, ",")
+
+	outputFile, err := cmd.Flags().GetString("output-file")
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+
+	checkFile, err := cmd.Flags().GetBool("check")
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+
 	request := models.GetAllSecretsParameters{
 		Environment:   environmentName,
 		WorkspaceId:   projectId,
@@ -459,170 +547,37 @@ func generateExampleEnv(cmd *cobra.Command, args []string) {
 		util.HandleError(err, "To fetch all secrets")
 	}
 
-	tagsHashToSecretKey := make(map[string]int)
-	slugsToFilerBy := make(map[string]int)
-
-	for _, slug := range strings.Split(tagSlugs, ",") {
-		slugsToFilerBy[slug] = 1
-	}
-
-	type TagsAndSecrets struct {
-		Secrets []models.SingleEnvironmentVariable
-		Tags    []struct {
-			ID        string `json:"_id"`
-			Name      string `json:"name"`
-			Slug      string `json:"slug"`
-			Workspace string `json:"workspace"`
-		}
+	exampleEnvFile, err := formatExampleEnvFile(secrets, exampleEnvOptions)
+	if err != nil {
+		util.HandleError(err)
 	}
 
-	// sort secrets by associated tags (most number of tags to least tags)
-	sort.Slice(secrets, func(i, j int) bool {
-		return len(secrets[i].Tags) > len(secrets[j].Tags)
-	})
-
-	for i, secret := range secrets {
-		filteredTag := []struct {
-			ID        string "json:\"_id\""
-			Name      string "json:\"name\""
-			Slug      string "json:\"slug\""
-			Workspace string "json:\"workspace\""
-		}{}
-
-		for _, secretTag := range secret.Tags {
-			_, exists := slugsToFilerBy[secretTag.Slug]
-			if !exists {
-				filteredTag = append(filteredTag, secretTag)
-			}
+	if checkFile {
+		if outputFile == "" {
+			util.PrintErrorMessageAndExit("--check compares the generated file with --output-file, set it to the path of the checked-in file")
 		}
 
-		secret.Tags = filteredTag
-		secrets[i] = secret
-	}
-
-	for _, secret := range secrets {
-		listOfTagSlugs := []string{}
-
-		for _, tag := range secret.Tags {
-			listOfTagSlugs = append(listOfTagSlugs, tag.Slug)
-		}
-		sort.Strings(listOfTagSlugs)
-
-		tagsHash := util.GetHashFromStringList(listOfTagSlugs)
-
-		tagsHashToSecretKey[tagsHash] += 1
-	}
-
-	finalTagHashToSecretKey := make(map[string]TagsAndSecrets)
-
-	for _, secret := range secrets {
-		listOfTagSlugs := []string{}
-		for _, tag := range secret.Tags {
-			listOfTagSlugs = append(listOfTagSlugs, tag.Slug)
-		}
-
-		// sort the slug so we get the same hash each time
-		sort.Strings(listOfTagSlugs)
-
-		tagsHash := util.GetHashFromStringList(listOfTagSlugs)
-		occurrence, exists := tagsHashToSecretKey[tagsHash]
-		if exists && occurrence > 0 {
-
-			value, exists2 := finalTagHashToSecretKey[tagsHash]
-			allSecretsForTags := append(value.Secrets, secret)
-
-			// sort the the secrets by keys so that they can later be sorted by the first item in the secrets array
-			sort.Slice(allSecretsForTags, func(i, j int) bool {
-				return allSecretsForTags[i].Key < allSecretsForTags[j].Key
-			})
-
-			if exists2 {
-				finalTagHashToSecretKey[tagsHash] = TagsAndSecrets{
-					Tags:    secret.Tags,
-					Secrets: allSecretsForTags,
-				}
-			} else {
-				finalTagHashToSecretKey[tagsHash] = TagsAndSecrets{
-					Tags:    secret.Tags,
-					Secrets: []models.SingleEnvironmentVariable{secret},
-				}
-			}
-
-			tagsHashToSecretKey[tagsHash] -= 1
-		}
-	}
-
-	// sort the fianl result by secret key fo consistent print order
-	listOfsecretDetails := make([]TagsAndSecrets, 0, len(finalTagHashToSecretKey))
-	for _, secretDetails := range finalTagHashToSecretKey {
-		listOfsecretDetails = append(listOfsecretDetails, secretDetails)
-	}
-
-	// sort the order of the headings by the order of the secrets
-	sort.Slice(listOfsecretDetails, func(i, j int) bool {
-		return len(listOfsecretDetails[i].Tags) < len(listOfsecretDetails[j].Tags)
-	})
-
-	tableOfContents := []string{}
-	fullyGeneratedDocuments := []string{}
-	for _, secretDetails := range listOfsecretDetails {
-		listOfKeyValue := []string{}
-
-		for _, secret := range secretDetails.Secrets {
-			re := regexp.MustCompile(`(?s)(.*)DEFAULT:(.*)`)
-			match := re.FindStringSubmatch(secret.Comment)
-			defaultValue := ""
-			comment := secret.Comment
-
-			// Case: Only has default value
-			if len(match) == 2 {
-				defaultValue = strings.TrimSpace(match[1])
-			}
-
-			// Case: has a comment and a default value
-			if len(match) == 3 {
-				comment = match[1]
-				defaultValue = match[2]
-			}
-
-			row := ""
-			if comment != "" {
-				comment = addHash(comment)
-				row = fmt.Sprintf("%s \n%s=%s", strings.TrimSpace(comment), strings.TrimSpace(secret.Key), strings.TrimSpace(defaultValue))
-			} else {
-				row = fmt.Sprintf("%s=%s", strings.TrimSpace(secret.Key), strings.TrimSpace(defaultValue))
-			}
-
-			// each secret row to be added to the file
-			listOfKeyValue = append(listOfKeyValue, row)
+		checkedInFile, err := os.ReadFile(outputFile)
+		if err != nil {
+			util.HandleError(err, fmt.Sprintf("Unable to read %s", outputFile))
 		}
 
-		listOfTagNames := []string{}
-		for _, tag := range secretDetails.Tags {
-			listOfTagNames = append(listOfTagNames, tag.Name)
+		if string(checkedInFile) != exampleEnvFile {
+			util.PrintErrorMessageAndExit(fmt.Sprintf("%s is out of date with the project, run `infisical secrets generate-example-env --output-file %s` with the same flags to update it", outputFile, outputFile))
 		}
 
-		heading := CenterString(strings.Join(listOfTagNames, " & "), 80)
-
-		if len(listOfTagNames) == 0 {
-			fullyGeneratedDocuments = append(fullyGeneratedDocuments, fmt.Sprintf("\n%s \n", strings.Join(listOfKeyValue, "\n")))
-		} else {
-			fullyGeneratedDocuments = append(fullyGeneratedDocuments, fmt.Sprintf("\n\n\n%s \n%s \n", heading, strings.Join(listOfKeyValue, "\n")))
-			tableOfContents = append(tableOfContents, strings.ToUpper(strings.Join(listOfTagNames, " & ")))
+		fmt.Fprintf(os.Stderr, "%s is up to date\n", outputFile)
+	} else if outputFile != "" {
+		if err := os.WriteFile(outputFile, []byte(exampleEnvFile), 0644); err != nil {
+			util.HandleError(err, fmt.Sprintf("Unable to write %s", outputFile))
 		}
-	}
 
-	dashedList := []string{}
-	for _, item := range tableOfContents {
-		dashedList = append(dashedList, fmt.Sprintf("# - %s \n", item))
-	}
-	if len(dashedList) > 0 {
-		fmt.Println(CenterString("TABLE OF CONTENTS", 80))
-		fmt.Println(strings.Join(dashedList, ""))
+		fmt.Fprintf(os.Stderr, "Wrote %d secrets to %s\n", len(secrets), outputFile)
+	} else {
+		fmt.Print(exampleEnvFile)
 	}
-	fmt.Println(strings.Join(fullyGeneratedDocuments, ""))
 
-	Telemetry.CaptureEvent("cli-command:generate-example-env", posthog.NewProperties().Set("secretCount", len(secrets)).Set("version", util.CLI_VERSION))
+	Telemetry.CaptureEvent("cli-command:generate-example-env", posthog.NewProperties().Set("secretCount", len(secrets)).Set("format", exampleEnvOptions.Format).Set("check", checkFile).Set("version", util.CLI_VERSION))
 }
 
 func CenterString(s string, numStars int) string {
@@ -656,15 +611,23 @@ func init() {
 	secretsGenerateExampleEnvCmd.Flags().String("token", "", "Fetch secrets using service token or machine identity access token")
 	secretsGenerateExampleEnvCmd.Flags().String("projectId", "", "manually set the projectId when using machine identity based auth")
 	secretsGenerateExampleEnvCmd.Flags().String("path", "/", "Fetch secrets from within a folder path")
+	secretsGenerateExampleEnvCmd.Flags().String("output-file", "", "write the generated file to this path instead of printing it")
+	secretsGenerateExampleEnvCmd.Flags().Bool("check", false, "fail when the file at --output-file differs from the generated one, without writing it")
+	secretsGenerateExampleEnvCmd.Flags().String("format", EXAMPLE_ENV_FORMAT_DOTENV, "the format of the generated file: dotenv, json-schema, typescript or go")
+	secretsGenerateExampleEnvCmd.Flags().String("optional-tag", "optional", "the tag slug that marks secrets as optional, secrets without it are required")
+	secretsGenerateExampleEnvCmd.Flags().String("type-name", "Env", "the name of the generated TypeScript interface, Go struct or JSON Schema title")
+	secretsGenerateExampleEnvCmd.Flags().String("go-package", "config", "the package of the generated Go file")
 	secretsCmd.AddCommand(secretsGenerateExampleEnvCmd)
 
 	secretsGetCmd.Flags().String("token", "", "Fetch secrets using service token or machine identity access token")
 	secretsGetCmd.Flags().String("projectId", "", "manually set the project ID to fetch secrets from when using machine identity based auth")
 	secretsGetCmd.Flags().String("path", "/", "get secrets within a folder path")
-	secretsGetCmd.Flags().Bool("plain", false, "print values without formatting, one per line")
-	secretsGetCmd.Flags().Bool("raw-value", false, "deprecated. Returns only the value of secret, only works with one secret. Use --plain instead")
+	secretsGetCmd.Flags().Bool("plain", false, "deprecated. print values without formatting, one per line. Use --output plain instead")
+	secretsGetCmd.Flags().Bool("raw-value", false, "deprecated. Returns only the value of secret, only works with one secret. Use --output plain instead")
+	addSecretsOutputFlags(secretsGetCmd)
 	secretsGetCmd.Flags().Bool("include-imports", true, "Imported linked secrets ")
 	secretsGetCmd.Flags().Bool("expand", true, "Parse shell parameter expansions in your secrets, and process your referenced secrets")
+	secretsGetCmd.Flags().Bool("allow-missing-references", false, "Expand references to secrets that do not exist to an empty value with a warning, instead of failing")
 	secretsGetCmd.Flags().Bool("recursive", false, "Fetch secrets from all sub-folders")
 	secretsCmd.AddCommand(secretsGetCmd)
 	secretsCmd.Flags().Bool("secret-overriding", true, "Prioritizes personal secrets, if any, with the same name over shared secrets")
@@ -673,6 +636,15 @@ func init() {
 	secretsSetCmd.Flags().String("projectId", "", "manually set the project ID to for setting secrets when using machine identity based auth")
 	secretsSetCmd.Flags().String("path", "/", "set secrets within a folder path")
 	secretsSetCmd.Flags().String("type", util.SECRET_TYPE_SHARED, "the type of secret to create: personal or shared")
+	secretsSetCmd.Flags().String("comment", "", "the comment of the secrets, the comments of existing secrets can only be changed when logged in")
+	secretsSetCmd.Flags().String("tags", "", "the comma separated slugs of tags to add to the secrets")
+	secretsSetCmd.Flags().Bool("generate", false, "set each of the secrets to a random value generated with the options below")
+	secretsSetCmd.Flags().String("format", util.SECRET_GENERATOR_FORMAT_CHARS, "the format of generated values: chars, hex, base64, uuid or passphrase")
+	secretsSetCmd.Flags().Int("length", 0, "the length of generated values: characters for chars, random bytes for hex and base64, words for passphrase. Defaults to 32, or 6 words")
+	secretsSetCmd.Flags().StringSlice("charset", util.DEFAULT_SECRET_GENERATOR_CHARSETS, "the character classes of generated chars values: lower, upper, digits and symbols")
+	secretsSetCmd.Flags().String("exclude", "", "characters that generated chars values never contain, e.g. 0O1lI")
+	secretsSetCmd.Flags().String("separator", "-", "the separator between the words of generated passphrases")
+	secretsSetCmd.Flags().Bool("show", false, "print generated values")
 
 	secretsDeleteCmd.Flags().String("type", "personal", "the type of secret to delete: personal or shared  (default: personal)")
 	secretsDeleteCmd.Flags().String("token", "", "Fetch secrets using service token or machine identity access token")
@@ -687,6 +659,8 @@ func init() {
 	getCmd.Flags().StringP("path", "p", "/", "The path from where folders should be fetched from")
 	getCmd.Flags().String("token", "", "Fetch secrets using service token or machine identity access token")
 	getCmd.Flags().String("projectId", "", "manually set the projectId to fetch folders from when using machine identity based auth")
+	getCmd.Flags().Bool("recursive", false, "Print all sub-folders as a tree, with the number of secrets of each folder")
+	getCmd.Flags().StringP("output", "o", "table", "The output format: table or json")
 	folderCmd.AddCommand(getCmd)
 
 	// Add createCmd flags here
@@ -701,8 +675,18 @@ func init() {
 	deleteCmd.Flags().String("token", "", "Fetch secrets using service token or machine identity access token")
 	deleteCmd.Flags().String("projectId", "", "manually set the projectId to delete folders when using machine identity based auth")
 	deleteCmd.Flags().StringP("name", "n", "", "Name of the folder to be deleted within selected `--path`")
+	deleteCmd.Flags().Bool("recursive", false, "Delete the folder with all of its secrets and sub-folders, after listing them and asking for confirmation. Deleting a folder that is not empty without it is deprecated")
+	deleteCmd.Flags().BoolP("yes", "y", false, "Delete the contents of the folder without asking for confirmation")
 	folderCmd.AddCommand(deleteCmd)
 
+	// Add renameCmd flags here
+	renameCmd.Flags().StringP("path", "p", "/", "Path to the parent of the folder to be renamed")
+	renameCmd.Flags().StringP("name", "n", "", "Name of the folder to be renamed within selected `--path`")
+	renameCmd.Flags().String("new-name", "", "The new name of the folder")
+	renameCmd.Flags().String("token", "", "Fetch secrets using service token or machine identity access token")
+	renameCmd.Flags().String("projectId", "", "manually set the projectId to rename folders in when using machine identity based auth")
+	folderCmd.AddCommand(renameCmd)
+
 	secretsCmd.AddCommand(folderCmd)
 
 	// ** End of folders sub command
@@ -711,10 +695,12 @@ func init() {
 	secretsCmd.Flags().String("projectId", "", "manually set the projectId to fetch secrets when using machine identity based auth")
 	secretsCmd.PersistentFlags().String("env", "dev", "Used to select the environment name on which actions should be taken on")
 	secretsCmd.Flags().Bool("expand", true, "Parse shell parameter expansions in your secrets, and process your referenced secrets")
+	secretsCmd.Flags().Bool("allow-missing-references", false, "Expand references to secrets that do not exist to an empty value with a warning, instead of failing")
 	secretsCmd.Flags().Bool("include-imports", true, "Imported linked secrets ")
 	secretsCmd.Flags().Bool("recursive", false, "Fetch secrets from all sub-folders")
 	secretsCmd.PersistentFlags().StringP("tags", "t", "", "filter secrets by tag slugs")
 	secretsCmd.Flags().String("path", "/", "get secrets within a folder path")
-	secretsCmd.Flags().Bool("plain", false, "print values without formatting, one per line")
+	secretsCmd.Flags().Bool("plain", false, "deprecated. print values without formatting, one per line. Use --output plain instead")
+	addSecretsOutputFlags(secretsCmd)
 	rootCmd.AddCommand(secretsCmd)
 }
@@ -0,0 +1,443 @@
+/*
+Copyright (c) 2023 Infisical Inc.
+*/
+package cmd
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/Infisical/infisical-merge/packages/models"
+	"github.com/Infisical/infisical-merge/packages/util"
+	"github.com/Infisical/infisical-merge/packages/visualize"
+	"github.com/posthog/posthog-go"
+	"github.com/spf13/cobra"
+)
+
+// the actions of a planned copy
+const (
+	SECRET_COPY_CREATE    = "CREATE"
+	SECRET_COPY_OVERWRITE = "OVERWRITE"
+	SECRET_COPY_UNCHANGED = "UNCHANGED"
+	SECRET_COPY_SKIP      = "SKIP (EXISTS)"
+)
+
+// the policies for secrets that already exist at the destination
+const (
+	SECRET_COPY_POLICY_FAIL      = ""
+	SECRET_COPY_POLICY_OVERWRITE = "overwrite"
+	SECRET_COPY_POLICY_SKIP      = "skip"
+)
+
+var secretsCopyCmd = &cobra.Command{
+	Example:               `infisical secrets copy DB_HOST DB_PORT --from=dev:/ --to=staging:/`,
+	Short:                 "Used to copy secrets, with their comments and tags, to another environment or folder",
+	Use:                   "copy [secrets]",
+	DisableFlagsInUseLine: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		runSecretsCopy(cmd, args, false)
+	},
+}
+
+var secretsMoveCmd = &cobra.Command{
+	Example:               `infisical secrets move DB_HOST=DATABASE_HOST --from=dev:/ --to=dev:/database`,
+	Short:                 "Used to move or rename secrets, with their comments and tags, to another environment or folder",
+	Use:                   "move [secrets]",
+	DisableFlagsInUseLine: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		runSecretsCopy(cmd, args, true)
+	},
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{secretsCopyCmd, secretsMoveCmd} {
+		cmd.Flags().String("from", "", "The environment and folder to take the secrets from as env:/path, defaults to the --env environment and /")
+		cmd.Flags().String("to", "", "The environment and folder to put the secrets in as env:/path")
+		cmd.MarkFlagRequired("to")
+		cmd.Flags().Bool("overwrite", false, "Overwrite secrets that already exist at the destination")
+		cmd.Flags().Bool("skip-existing", false, "Leave secrets that already exist at the destination as they are")
+		cmd.Flags().Bool("dry-run", false, "Show what would be changed without changing anything")
+		cmd.Flags().Bool("recursive", false, "Take all secrets of the folder and its sub-folders, keeping the folder structure")
+		cmd.Flags().String("type", util.SECRET_TYPE_SHARED, "the type of the secrets: personal or shared")
+		cmd.Flags().String("token", "", "Use a service token or machine identity access token")
+		cmd.Flags().String("projectId", "", "manually set the project ID when using machine identity based auth")
+		secretsCmd.AddCommand(cmd)
+	}
+}
+
+// secretLocation is an environment and folder given as env:/path
+type secretLocation struct {
+	Environment string
+	Path        string
+}
+
+func (location secretLocation) String() string {
+	return location.Environment + ":" + location.Path
+}
+
+// parseSecretLocation reads env:/path, env (the root folder) or :/path (the default environment)
+func parseSecretLocation(value string, defaultEnvironment string) (secretLocation, error) {
+	environment, folderPath, _ := strings.Cut(value, ":")
+	if environment == "" {
+		environment = defaultEnvironment
+	}
+
+	if folderPath == "" {
+		folderPath = "/"
+	}
+
+	if !strings.HasPrefix(folderPath, "/") {
+		return secretLocation{}, fmt.Errorf("the folder path of %s must start with /, e.g. %s:/%s", value, environment, folderPath)
+	}
+
+	return secretLocation{Environment: environment, Path: path.Clean(folderPath)}, nil
+}
+
+type secretCopyPlan struct {
+	Source     models.SingleEnvironmentVariable
+	TargetKey  string
+	TargetPath string
+	Action     string
+}
+
+// selectSecretsToCopy picks the secrets named by the arguments, KEY or KEY=NEW_KEY to rename it, from the secrets of the
+// source folder. Without arguments, as with --recursive, all secrets are taken.
+func selectSecretsToCopy(secrets []models.SingleEnvironmentVariable, args []string, from secretLocation) ([]secretCopyPlan, error) {
+	plans := []secretCopyPlan{}
+	if len(args) == 0 {
+		for _, secret := range util.SortSecretsByKeys(secrets) {
+			plans = append(plans, secretCopyPlan{Source: secret, TargetKey: secret.Key})
+		}
+		return plans, nil
+	}
+
+	secretsByKey := map[string]models.SingleEnvironmentVariable{}
+	for _, secret := range secrets {
+		secretsByKey[secret.Key] = secret
+	}
+
+	for _, arg := range args {
+		key, targetKey, _ := strings.Cut(arg, "=")
+		if targetKey == "" {
+			targetKey = key
+		}
+
+		secret, ok := secretsByKey[key]
+		if !ok {
+			return nil, fmt.Errorf("the secret %s does not exist in %s", key, from)
+		}
+		plans = append(plans, secretCopyPlan{Source: secret, TargetKey: targetKey})
+	}
+
+	return plans, nil
+}
+
+// planSecretCopies decides the destination folder of each secret and what happens to it there. Secrets of sub-folders
+// keep their folder below the destination. Secrets that already exist with another value fail the copy unless the
+// policy overwrites or skips them.
+func planSecretCopies(plans []secretCopyPlan, from secretLocation, to secretLocation, targets []models.SingleEnvironmentVariable, policy string) ([]secretCopyPlan, error) {
+	existing := map[string]string{}
+	for _, target := range targets {
+		existing[path.Join(target.SecretPath, target.Key)] = target.Value
+	}
+
+	conflicts := []string{}
+	for i, plan := range plans {
+		plan.TargetPath = path.Join(append([]string{to.Path}, exportFolderOf(plan.Source, from.Path)...)...)
+		targetName := path.Join(plan.TargetPath, plan.TargetKey)
+
+		if from.Environment == to.Environment && path.Join(plan.Source.SecretPath, plan.Source.Key) == targetName {
+			return nil, fmt.Errorf("the secret %s would be copied onto itself, choose another destination or key", plan.Source.Key)
+		}
+
+		value, exists := existing[targetName]
+		switch {
+		case !exists:
+			plan.Action = SECRET_COPY_CREATE
+		case value == plan.Source.Value:
+			plan.Action = SECRET_COPY_UNCHANGED
+		case policy == SECRET_COPY_POLICY_OVERWRITE:
+			plan.Action = SECRET_COPY_OVERWRITE
+		case policy == SECRET_COPY_POLICY_SKIP:
+			plan.Action = SECRET_COPY_SKIP
+		default:
+			conflicts = append(conflicts, targetName)
+		}
+
+		plans[i] = plan
+	}
+
+	if len(conflicts) > 0 {
+		return nil, fmt.Errorf("%s already exist in %s with other values, use --overwrite or --skip-existing", strings.Join(conflicts, ", "), to.Environment)
+	}
+
+	return plans, nil
+}
+
+func runSecretsCopy(cmd *cobra.Command, args []string, move bool) {
+	environmentName, _ := cmd.Flags().GetString("env")
+	if !cmd.Flags().Changed("env") {
+		environmentFromWorkspace := util.GetEnvFromWorkspaceFile()
+		if environmentFromWorkspace != "" {
+			environmentName = environmentFromWorkspace
+		}
+	}
+
+	token, err := util.GetInfisicalToken(cmd)
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+
+	projectId, err := cmd.Flags().GetString("projectId")
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+
+	secretType, err := cmd.Flags().GetString("type")
+	if err != nil || (secretType != util.SECRET_TYPE_SHARED && secretType != util.SECRET_TYPE_PERSONAL) {
+		util.HandleError(err, "Unable to parse secret type")
+	}
+
+	fromFlag, err := cmd.Flags().GetString("from")
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+
+	toFlag, err := cmd.Flags().GetString("to")
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+
+	shouldOverwrite, err := cmd.Flags().GetBool("overwrite")
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+
+	shouldSkipExisting, err := cmd.Flags().GetBool("skip-existing")
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+
+	isDryRun, err := cmd.Flags().GetBool("dry-run")
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+
+	recursive, err := cmd.Flags().GetBool("recursive")
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+
+	if shouldOverwrite && shouldSkipExisting {
+		util.PrintErrorMessageAndExit("--overwrite and --skip-existing cannot be used together")
+	}
+
+	policy := SECRET_COPY_POLICY_FAIL
+	if shouldOverwrite {
+		policy = SECRET_COPY_POLICY_OVERWRITE
+	} else if shouldSkipExisting {
+		policy = SECRET_COPY_POLICY_SKIP
+	}
+
+	if recursive && len(args) > 0 {
+		util.PrintErrorMessageAndExit("--recursive takes all secrets of the folder, it cannot be combined with secret names")
+	} else if !recursive && len(args) == 0 {
+		util.PrintErrorMessageAndExit("Provide the names of the secrets, or use --recursive to take all secrets of the folder")
+	}
+
+	from, err := parseSecretLocation(fromFlag, environmentName)
+	if err != nil {
+		util.HandleError(err, "Unable to parse --from")
+	}
+
+	to, err := parseSecretLocation(toFlag, environmentName)
+	if err != nil {
+		util.HandleError(err, "Unable to parse --to")
+	}
+
+	sourceParams := models.GetAllSecretsParameters{Environment: from.Environment, SecretsPath: from.Path, WorkspaceId: projectId, Recursive: recursive}
+	if token != nil && token.Type == util.SERVICE_TOKEN_IDENTIFIER {
+		sourceParams.InfisicalToken = token.Token
+	} else if token != nil && token.Type == util.UNIVERSAL_AUTH_TOKEN_IDENTIFIER {
+		sourceParams.UniversalAuthAccessToken = token.Token
+	}
+
+	targetParams := sourceParams
+	targetParams.Environment = to.Environment
+	targetParams.SecretsPath = to.Path
+
+	sources, err := util.GetAllEnvironmentVariables(sourceParams, "")
+	if err != nil {
+		util.HandleError(err, "Unable to fetch the secrets to copy")
+	}
+
+	plans, err := selectSecretsToCopy(filterSecretsByType(sources, secretType), args, from)
+	if err != nil {
+		util.HandleError(err)
+	}
+
+	if len(plans) == 0 {
+		util.PrintErrorMessageAndExit(fmt.Sprintf("There are no secrets in %s", from))
+	}
+
+	foldersParams := models.GetAllFoldersParameters{
+		WorkspaceId:              projectId,
+		Environment:              to.Environment,
+		InfisicalToken:           sourceParams.InfisicalToken,
+		UniversalAuthAccessToken: sourceParams.UniversalAuthAccessToken,
+	}
+
+	targets := []models.SingleEnvironmentVariable{}
+	destinationExists, err := util.EnsureFolderPath(foldersParams, to.Path, false)
+	if err != nil {
+		util.HandleError(err, "Unable to check the destination folder")
+	}
+
+	if destinationExists {
+		targets, err = util.GetAllEnvironmentVariables(targetParams, "")
+		if err != nil {
+			util.HandleError(err, "Unable to fetch the secrets of the destination")
+		}
+	}
+
+	plans, err = planSecretCopies(plans, from, to, filterSecretsByType(targets, secretType), policy)
+	if err != nil {
+		util.HandleError(err)
+	}
+
+	printSecretCopyPlans(plans, to)
+
+	if isDryRun {
+		fmt.Println("Dry run, no secrets were changed")
+		return
+	}
+
+	plansByTargetPath := map[string][]secretCopyPlan{}
+	for _, plan := range plans {
+		if plan.Action == SECRET_COPY_CREATE || plan.Action == SECRET_COPY_OVERWRITE {
+			plansByTargetPath[plan.TargetPath] = append(plansByTargetPath[plan.TargetPath], plan)
+		}
+	}
+
+	targetPaths := []string{}
+	for targetPath := range plansByTargetPath {
+		targetPaths = append(targetPaths, targetPath)
+	}
+	sort.Strings(targetPaths)
+
+	if len(targetPaths) > 0 && sourceParams.InfisicalToken != "" {
+		util.PrintWarning("tags cannot be set with a service token, the tags of the secrets are not copied")
+	}
+
+	for _, targetPath := range targetPaths {
+		if _, err := util.EnsureFolderPath(foldersParams, targetPath, true); err != nil {
+			util.HandleError(err, "Unable to create the destination folder")
+		}
+
+		secretsToSet := []models.SingleEnvironmentVariable{}
+		for _, plan := range plansByTargetPath[targetPath] {
+			secretsToSet = append(secretsToSet, models.SingleEnvironmentVariable{Key: plan.TargetKey, Value: plan.Source.Value, Comment: plan.Source.Comment})
+		}
+
+		if token != nil && (token.Type == util.SERVICE_TOKEN_IDENTIFIER || token.Type == util.UNIVERSAL_AUTH_TOKEN_IDENTIFIER) {
+			_, err = util.SetRawSecretsFromList(secretsToSet, secretType, to.Environment, targetPath, projectId, token, nil)
+		} else {
+			util.RequireLogin()
+			util.RequireLocalWorkspaceFile()
+
+			_, err = util.SetEncryptedSecretsFromList(secretsToSet, secretType, to.Environment, targetPath, nil)
+		}
+
+		if err != nil {
+			util.HandleError(err, fmt.Sprintf("Unable to set the secrets in %s:%s", to.Environment, targetPath))
+		}
+
+		if sourceParams.InfisicalToken == "" {
+			copySecretTags(plansByTargetPath[targetPath], sourceParams, targetParams, secretType)
+		}
+	}
+
+	if move {
+		sourcesByPath := map[string][]string{}
+		for _, plan := range plans {
+			if plan.Action != SECRET_COPY_SKIP {
+				sourcesByPath[plan.Source.SecretPath] = append(sourcesByPath[plan.Source.SecretPath], plan.Source.Key)
+			}
+		}
+
+		for sourcePath, secretNames := range sourcesByPath {
+			deleteParams := sourceParams
+			deleteParams.SecretsPath = sourcePath
+
+			if err := util.DeleteSecrets(deleteParams, secretType, secretNames); err != nil {
+				util.HandleError(err, fmt.Sprintf("The secrets were copied to %s but could not be removed from %s:%s", to, from.Environment, sourcePath))
+			}
+		}
+	}
+
+	if move {
+		util.PrintSuccessMessage(fmt.Sprintf("Moved the secrets from %s to %s", from, to))
+		Telemetry.CaptureEvent("cli-command:secrets move", posthog.NewProperties().Set("secretCount", len(plans)).Set("recursive", recursive).Set("version", util.CLI_VERSION))
+	} else {
+		util.PrintSuccessMessage(fmt.Sprintf("Copied the secrets from %s to %s", from, to))
+		Telemetry.CaptureEvent("cli-command:secrets copy", posthog.NewProperties().Set("secretCount", len(plans)).Set("recursive", recursive).Set("version", util.CLI_VERSION))
+	}
+}
+
+// copySecretTags adds the tags of the source secrets to the copies. The raw secrets of machine identities are listed
+// without their tags, so those are fetched one by one.
+func copySecretTags(plans []secretCopyPlan, sourceParams models.GetAllSecretsParameters, targetParams models.GetAllSecretsParameters, secretType string) {
+	for _, plan := range plans {
+		source := plan.Source
+		if sourceParams.UniversalAuthAccessToken != "" {
+			detailsParams := sourceParams
+			detailsParams.SecretsPath = source.SecretPath
+
+			details, err := util.GetSecretDetails(detailsParams, source.Key, secretType)
+			if err != nil {
+				util.HandleError(err, fmt.Sprintf("Unable to get the tags of %s", source.Key))
+			}
+			source = details
+		}
+
+		tagSlugs := []string{}
+		for _, tag := range source.Tags {
+			tagSlugs = append(tagSlugs, tag.Slug)
+		}
+
+		if len(tagSlugs) == 0 {
+			continue
+		}
+
+		params := targetParams
+		params.SecretsPath = plan.TargetPath
+		if err := util.AddSecretTags(params, []string{plan.TargetKey}, secretType, tagSlugs); err != nil {
+			util.HandleError(err, fmt.Sprintf("Unable to copy the tags of %s", source.Key))
+		}
+	}
+}
+
+func filterSecretsByType(secrets []models.SingleEnvironmentVariable, secretType string) []models.SingleEnvironmentVariable {
+	filtered := []models.SingleEnvironmentVariable{}
+	for _, secret := range secrets {
+		if secret.Type == secretType && !secret.IsImported {
+			filtered = append(filtered, secret)
+		}
+	}
+
+	return filtered
+}
+
+func printSecretCopyPlans(plans []secretCopyPlan, to secretLocation) {
+	headers := [...]string{"SECRET NAME", "DESTINATION", "ACTION"}
+	rows := [][3]string{}
+	for _, plan := range plans {
+		source := path.Join(plan.Source.SecretPath, plan.Source.Key)
+		destination := to.Environment + ":" + path.Join(plan.TargetPath, plan.TargetKey)
+		rows = append(rows, [...]string{source, destination, plan.Action})
+	}
+
+	visualize.Table(headers, rows)
+}
@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Infisical/infisical-merge/packages/models"
+)
+
+func TestParseSecretLocation(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected secretLocation
+	}{
+		{"staging:/api", secretLocation{Environment: "staging", Path: "/api"}},
+		{"staging", secretLocation{Environment: "staging", Path: "/"}},
+		{":/api/", secretLocation{Environment: "dev", Path: "/api"}},
+		{"", secretLocation{Environment: "dev", Path: "/"}},
+	}
+
+	for _, test := range tests {
+		location, err := parseSecretLocation(test.value, "dev")
+		if err != nil {
+			t.Fatalf("parseSecretLocation(%q) returned %v", test.value, err)
+		}
+		if location != test.expected {
+			t.Errorf("parseSecretLocation(%q) = %+v, expected %+v", test.value, location, test.expected)
+		}
+	}
+
+	if _, err := parseSecretLocation("staging:api", "dev"); err == nil {
+		t.Errorf("expected an error for a relative folder path")
+	}
+}
+
+func TestPlanSecretCopies(t *testing.T) {
+	sources := []models.SingleEnvironmentVariable{
+		{Key: "DB_HOST", Value: "db", SecretPath: "/", Type: "shared"},
+		{Key: "DB_PORT", Value: "5432", SecretPath: "/", Type: "shared"},
+		{Key: "TOKEN", Value: "new", SecretPath: "/api", Type: "shared"},
+	}
+	targets := []models.SingleEnvironmentVariable{
+		{Key: "DB_PORT", Value: "5432", SecretPath: "/", Type: "shared"},
+		{Key: "TOKEN", Value: "old", SecretPath: "/api", Type: "shared"},
+	}
+	from := secretLocation{Environment: "dev", Path: "/"}
+	to := secretLocation{Environment: "prod", Path: "/"}
+
+	newPlans := func() []secretCopyPlan {
+		plans, err := selectSecretsToCopy(sources, nil, from)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return plans
+	}
+
+	if _, err := planSecretCopies(newPlans(), from, to, targets, SECRET_COPY_POLICY_FAIL); err == nil || !strings.Contains(err.Error(), "/api/TOKEN") {
+		t.Errorf("expected a conflict on /api/TOKEN, got %v", err)
+	}
+
+	for policy, expected := range map[string]string{SECRET_COPY_POLICY_OVERWRITE: SECRET_COPY_OVERWRITE, SECRET_COPY_POLICY_SKIP: SECRET_COPY_SKIP} {
+		plans, err := planSecretCopies(newPlans(), from, to, targets, policy)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		actions := map[string]string{}
+		for _, plan := range plans {
+			actions[plan.TargetPath+" "+plan.TargetKey] = plan.Action
+		}
+
+		if actions["/ DB_HOST"] != SECRET_COPY_CREATE || actions["/ DB_PORT"] != SECRET_COPY_UNCHANGED || actions["/api TOKEN"] != expected {
+			t.Errorf("unexpected actions with policy %q: %v", policy, actions)
+		}
+	}
+}
+
+func TestSelectSecretsToCopyRenames(t *testing.T) {
+	sources := []models.SingleEnvironmentVariable{{Key: "DB_HOST", Value: "db", SecretPath: "/", Type: "shared"}}
+	location := secretLocation{Environment: "dev", Path: "/"}
+
+	plans, err := selectSecretsToCopy(sources, []string{"DB_HOST=DATABASE_HOST"}, location)
+	if err != nil || len(plans) != 1 || plans[0].TargetKey != "DATABASE_HOST" {
+		t.Fatalf("unexpected plans %+v, err %v", plans, err)
+	}
+
+	if _, err := planSecretCopies(plans, location, location, nil, SECRET_COPY_POLICY_FAIL); err != nil {
+		t.Errorf("renaming in place should be allowed, got %v", err)
+	}
+
+	plans, _ = selectSecretsToCopy(sources, []string{"DB_HOST"}, location)
+	if _, err := planSecretCopies(plans, location, location, nil, SECRET_COPY_POLICY_FAIL); err == nil {
+		t.Errorf("expected an error when copying a secret onto itself")
+	}
+
+	if _, err := selectSecretsToCopy(sources, []string{"MISSING"}, location); err == nil {
+		t.Errorf("expected an error for a missing secret")
+	}
+}
@@ -0,0 +1,345 @@
+/*
+Copyright (c) 2023 Infisical Inc.
+*/
+package cmd
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/Infisical/infisical-merge/packages/models"
+	"github.com/Infisical/infisical-merge/packages/util"
+	"github.com/Infisical/infisical-merge/packages/visualize"
+	"github.com/posthog/posthog-go"
+	"github.com/spf13/cobra"
+)
+
+// the statuses of a secret that differs between two environments
+const (
+	SECRET_DIFF_ONLY_IN_FROM = "only_in_from"
+	SECRET_DIFF_ONLY_IN_TO   = "only_in_to"
+	SECRET_DIFF_CHANGED      = "changed"
+)
+
+// like diff(1), secrets diff exits with 1 when the environments differ and with 2 when it fails
+const SECRETS_DIFF_ERROR_EXIT_CODE = 2
+
+var secretsDiffCmd = &cobra.Command{
+	Example:               `infisical secrets diff --from=staging --to=prod --recursive`,
+	Short:                 "Used to compare the secrets of two environments",
+	Use:                   "diff",
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		token, err := util.GetInfisicalToken(cmd)
+		if err != nil {
+			util.PrintErrorAndExit(SECRETS_DIFF_ERROR_EXIT_CODE, err, "Unable to parse flag")
+		}
+
+		projectId, err := cmd.Flags().GetString("projectId")
+		if err != nil {
+			util.PrintErrorAndExit(SECRETS_DIFF_ERROR_EXIT_CODE, err, "Unable to parse flag")
+		}
+
+		fromEnvironment, err := cmd.Flags().GetString("from")
+		if err != nil {
+			util.PrintErrorAndExit(SECRETS_DIFF_ERROR_EXIT_CODE, err, "Unable to parse flag")
+		}
+
+		toEnvironment, err := cmd.Flags().GetString("to")
+		if err != nil {
+			util.PrintErrorAndExit(SECRETS_DIFF_ERROR_EXIT_CODE, err, "Unable to parse flag")
+		}
+
+		secretsPath, err := cmd.Flags().GetString("path")
+		if err != nil {
+			util.PrintErrorAndExit(SECRETS_DIFF_ERROR_EXIT_CODE, err, "Unable to parse flag")
+		}
+
+		recursive, err := cmd.Flags().GetBool("recursive")
+		if err != nil {
+			util.PrintErrorAndExit(SECRETS_DIFF_ERROR_EXIT_CODE, err, "Unable to parse flag")
+		}
+
+		showValues, err := cmd.Flags().GetBool("show-values")
+		if err != nil {
+			util.PrintErrorAndExit(SECRETS_DIFF_ERROR_EXIT_CODE, err, "Unable to parse flag")
+		}
+
+		outputFormat, err := cmd.Flags().GetString("output")
+		if err != nil {
+			util.PrintErrorAndExit(SECRETS_DIFF_ERROR_EXIT_CODE, err, "Unable to parse flag")
+		}
+
+		if outputFormat != "table" && outputFormat != "json" {
+			util.PrintErrorAndExit(SECRETS_DIFF_ERROR_EXIT_CODE, fmt.Errorf("invalid output format: %s. Available formats are [table json]", outputFormat))
+		}
+
+		if fromEnvironment == toEnvironment {
+			util.PrintErrorAndExit(SECRETS_DIFF_ERROR_EXIT_CODE, fmt.Errorf("--from and --to must be different environments"))
+		}
+
+		fromParams := models.GetAllSecretsParameters{Environment: fromEnvironment, SecretsPath: secretsPath, WorkspaceId: projectId, Recursive: recursive}
+		if token != nil && token.Type == util.SERVICE_TOKEN_IDENTIFIER {
+			fromParams.InfisicalToken = token.Token
+		} else if token != nil && token.Type == util.UNIVERSAL_AUTH_TOKEN_IDENTIFIER {
+			fromParams.UniversalAuthAccessToken = token.Token
+		}
+
+		toParams := fromParams
+		toParams.Environment = toEnvironment
+
+		fromSecrets, err := util.GetAllEnvironmentVariables(fromParams, "")
+		if err != nil {
+			util.PrintErrorAndExit(SECRETS_DIFF_ERROR_EXIT_CODE, err, fmt.Sprintf("Unable to fetch the secrets of %s", fromEnvironment))
+		}
+
+		toSecrets, err := util.GetAllEnvironmentVariables(toParams, "")
+		if err != nil {
+			util.PrintErrorAndExit(SECRETS_DIFF_ERROR_EXIT_CODE, err, fmt.Sprintf("Unable to fetch the secrets of %s", toEnvironment))
+		}
+
+		fromSecrets = filterSecretsByType(fromSecrets, util.SECRET_TYPE_SHARED)
+		toSecrets = filterSecretsByType(toSecrets, util.SECRET_TYPE_SHARED)
+
+		// secrets listed with a token come without their tags, so the tags of the secrets on both sides are fetched one by one
+		if fromParams.InfisicalToken != "" || fromParams.UniversalAuthAccessToken != "" {
+			fromSecrets, toSecrets = addTagsToCommonSecrets(fromParams, fromSecrets, toParams, toSecrets)
+		}
+
+		differences := diffSecrets(fromSecrets, toSecrets, showValues)
+
+		if outputFormat == "json" {
+			output, err := json.MarshalIndent(differences, "", "  ")
+			if err != nil {
+				util.PrintErrorAndExit(SECRETS_DIFF_ERROR_EXIT_CODE, err, "Unable to marshal the differences to JSON")
+			}
+			fmt.Println(string(output))
+		} else if len(differences) == 0 {
+			fmt.Printf("The secrets of %s and %s are the same\n", fromEnvironment, toEnvironment)
+		} else {
+			printSecretDifferences(differences, fromEnvironment, toEnvironment)
+		}
+
+		Telemetry.CaptureEvent("cli-command:secrets diff", posthog.NewProperties().Set("differenceCount", len(differences)).Set("recursive", recursive).Set("version", util.CLI_VERSION))
+
+		if len(differences) > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	secretsDiffCmd.Flags().String("from", "", "The environment to compare")
+	secretsDiffCmd.MarkFlagRequired("from")
+	secretsDiffCmd.Flags().String("to", "", "The environment to compare with")
+	secretsDiffCmd.MarkFlagRequired("to")
+	secretsDiffCmd.Flags().String("path", "/", "the folder path of the secrets to compare")
+	secretsDiffCmd.Flags().Bool("recursive", false, "Also compare the secrets of the sub-folders")
+	secretsDiffCmd.Flags().Bool("show-values", false, "Show the values of differing secrets instead of their fingerprints")
+	secretsDiffCmd.Flags().StringP("output", "o", "table", "The output format: table or json")
+	secretsDiffCmd.Flags().String("token", "", "Fetch secrets using service token or machine identity access token")
+	secretsDiffCmd.Flags().String("projectId", "", "manually set the project ID when using machine identity based auth")
+	secretsCmd.AddCommand(secretsDiffCmd)
+}
+
+type secretDiffSide struct {
+	Value            *string  `json:"value,omitempty"`
+	ValueFingerprint string   `json:"valueFingerprint"`
+	Comment          string   `json:"comment"`
+	Tags             []string `json:"tags"`
+}
+
+type secretDiff struct {
+	Key     string          `json:"key"`
+	Path    string          `json:"path"`
+	Status  string          `json:"status"`
+	Changes []string        `json:"changes,omitempty"`
+	From    *secretDiffSide `json:"from,omitempty"`
+	To      *secretDiffSide `json:"to,omitempty"`
+}
+
+// diffSecrets compares the secrets by folder and key. Values are compared in full but only reported as hashes unless
+// showValues is set.
+func diffSecrets(fromSecrets []models.SingleEnvironmentVariable, toSecrets []models.SingleEnvironmentVariable, showValues bool) []secretDiff {
+	fromByName := map[string]models.SingleEnvironmentVariable{}
+	for _, secret := range fromSecrets {
+		fromByName[path.Join(secret.SecretPath, secret.Key)] = secret
+	}
+
+	toByName := map[string]models.SingleEnvironmentVariable{}
+	for _, secret := range toSecrets {
+		toByName[path.Join(secret.SecretPath, secret.Key)] = secret
+	}
+
+	names := []string{}
+	for name := range fromByName {
+		names = append(names, name)
+	}
+	for name := range toByName {
+		if _, ok := fromByName[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	differences := []secretDiff{}
+	for _, name := range names {
+		from, inFrom := fromByName[name]
+		to, inTo := toByName[name]
+
+		difference := secretDiff{Key: path.Base(name), Path: path.Dir(name)}
+		if inFrom {
+			difference.From = newSecretDiffSide(from, showValues)
+		}
+		if inTo {
+			difference.To = newSecretDiffSide(to, showValues)
+		}
+
+		switch {
+		case !inTo:
+			difference.Status = SECRET_DIFF_ONLY_IN_FROM
+		case !inFrom:
+			difference.Status = SECRET_DIFF_ONLY_IN_TO
+		default:
+			if from.Value != to.Value {
+				difference.Changes = append(difference.Changes, "value")
+			}
+			if from.Comment != to.Comment {
+				difference.Changes = append(difference.Changes, "comment")
+			}
+			if !slices.Equal(difference.From.Tags, difference.To.Tags) {
+				difference.Changes = append(difference.Changes, "tags")
+			}
+
+			if len(difference.Changes) == 0 {
+				continue
+			}
+			difference.Status = SECRET_DIFF_CHANGED
+		}
+
+		differences = append(differences, difference)
+	}
+
+	return differences
+}
+
+func newSecretDiffSide(secret models.SingleEnvironmentVariable, showValue bool) *secretDiffSide {
+	side := &secretDiffSide{
+		ValueFingerprint: secretValueFingerprint(secret.Value),
+		Comment:          secret.Comment,
+		Tags:             []string{},
+	}
+
+	if showValue {
+		value := secret.Value
+		side.Value = &value
+	}
+
+	for _, tag := range secret.Tags {
+		side.Tags = append(side.Tags, tag.Slug)
+	}
+	sort.Strings(side.Tags)
+
+	return side
+}
+
+var (
+	secretValueFingerprintKey     []byte
+	secretValueFingerprintKeyOnce sync.Once
+)
+
+// secretValueFingerprint returns an HMAC of the value keyed by a random key chosen for each run. Equal values have the
+// same fingerprint in the output of a run, but a fingerprint can not be compared across runs nor used to guess the value
+// offline like a plain hash could
+func secretValueFingerprint(value string) string {
+	secretValueFingerprintKeyOnce.Do(func() {
+		secretValueFingerprintKey = make([]byte, 32)
+		if _, err := rand.Read(secretValueFingerprintKey); err != nil {
+			util.HandleError(err, "Unable to generate the key of the value fingerprints")
+		}
+	})
+
+	mac := hmac.New(sha256.New, secretValueFingerprintKey)
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))[:12]
+}
+
+// addTagsToCommonSecrets fetches the tags of the secrets that exist in both environments
+func addTagsToCommonSecrets(fromParams models.GetAllSecretsParameters, fromSecrets []models.SingleEnvironmentVariable, toParams models.GetAllSecretsParameters, toSecrets []models.SingleEnvironmentVariable) ([]models.SingleEnvironmentVariable, []models.SingleEnvironmentVariable) {
+	toNames := map[string]bool{}
+	for _, secret := range toSecrets {
+		toNames[path.Join(secret.SecretPath, secret.Key)] = true
+	}
+
+	common := map[string]bool{}
+	for _, secret := range fromSecrets {
+		if name := path.Join(secret.SecretPath, secret.Key); toNames[name] {
+			common[name] = true
+		}
+	}
+
+	addTags := func(params models.GetAllSecretsParameters, secrets []models.SingleEnvironmentVariable) {
+		for i, secret := range secrets {
+			if !common[path.Join(secret.SecretPath, secret.Key)] {
+				continue
+			}
+
+			detailsParams := params
+			detailsParams.SecretsPath = secret.SecretPath
+			details, err := util.GetSecretDetails(detailsParams, secret.Key, secret.Type)
+			if err != nil {
+				util.PrintErrorAndExit(SECRETS_DIFF_ERROR_EXIT_CODE, err, fmt.Sprintf("Unable to get the tags of %s in %s", secret.Key, params.Environment))
+			}
+			secrets[i].Tags = details.Tags
+		}
+	}
+
+	addTags(fromParams, fromSecrets)
+	addTags(toParams, toSecrets)
+
+	return fromSecrets, toSecrets
+}
+
+func printSecretDifferences(differences []secretDiff, fromEnvironment string, toEnvironment string) {
+	headers := [...]string{"SECRET NAME", "DIFFERENCE", "DETAILS"}
+	rows := [][3]string{}
+	for _, difference := range differences {
+		name := path.Join(difference.Path, difference.Key)
+
+		switch difference.Status {
+		case SECRET_DIFF_ONLY_IN_FROM:
+			rows = append(rows, [...]string{name, "only in " + fromEnvironment, ""})
+		case SECRET_DIFF_ONLY_IN_TO:
+			rows = append(rows, [...]string{name, "only in " + toEnvironment, ""})
+		default:
+			for _, change := range difference.Changes {
+				var from, to string
+				switch change {
+				case "value":
+					from, to = difference.From.ValueFingerprint, difference.To.ValueFingerprint
+					if difference.From.Value != nil {
+						from, to = *difference.From.Value, *difference.To.Value
+					}
+				case "comment":
+					from, to = difference.From.Comment, difference.To.Comment
+				case "tags":
+					from, to = strings.Join(difference.From.Tags, ", "), strings.Join(difference.To.Tags, ", ")
+				}
+				rows = append(rows, [...]string{name, change + " differs", fmt.Sprintf("%s: %s, %s: %s", fromEnvironment, from, toEnvironment, to)})
+			}
+		}
+	}
+
+	visualize.Table(headers, rows)
+}
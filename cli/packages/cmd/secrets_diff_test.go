@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/Infisical/infisical-merge/packages/models"
+)
+
+func TestDiffSecrets(t *testing.T) {
+	from := []models.SingleEnvironmentVariable{
+		{Key: "SAME", Value: "a", SecretPath: "/"},
+		{Key: "ONLY_FROM", Value: "a", SecretPath: "/"},
+		{Key: "VALUE", Value: "a", SecretPath: "/api"},
+		{Key: "META", Value: "a", SecretPath: "/", Comment: "old"},
+	}
+	from[3].Tags = make([]struct {
+		ID        string `json:"_id"`
+		Name      string `json:"name"`
+		Slug      string `json:"slug"`
+		Workspace string `json:"workspace"`
+	}, 1)
+	from[3].Tags[0].Slug = "prod"
+
+	to := []models.SingleEnvironmentVariable{
+		{Key: "SAME", Value: "a", SecretPath: "/"},
+		{Key: "ONLY_TO", Value: "a", SecretPath: "/"},
+		{Key: "VALUE", Value: "b", SecretPath: "/api"},
+		{Key: "META", Value: "a", SecretPath: "/", Comment: "new"},
+	}
+
+	differences := diffSecrets(from, to, false)
+
+	got := map[string][]string{}
+	for _, difference := range differences {
+		got[difference.Path+" "+difference.Key] = append([]string{difference.Status}, difference.Changes...)
+		if difference.From != nil && difference.From.Value != nil {
+			t.Errorf("values must not be shown without showValues")
+		}
+	}
+
+	expected := map[string][]string{
+		"/ ONLY_FROM": {SECRET_DIFF_ONLY_IN_FROM},
+		"/ ONLY_TO":   {SECRET_DIFF_ONLY_IN_TO},
+		"/api VALUE":  {SECRET_DIFF_CHANGED, "value"},
+		"/ META":      {SECRET_DIFF_CHANGED, "comment", "tags"},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("diffSecrets() = %v, expected %v", got, expected)
+	}
+
+	if differences := diffSecrets(from, to, true); *differences[len(differences)-1].To.Value != "b" {
+		t.Errorf("expected the values to be shown with showValues")
+	}
+}
+
+func TestSecretValueFingerprint(t *testing.T) {
+	fingerprint := secretValueFingerprint("postgres://db")
+	if fingerprint != secretValueFingerprint("postgres://db") || fingerprint == secretValueFingerprint("postgres://db2") {
+		t.Errorf("expected equal values to have the same fingerprint and different values different fingerprints")
+	}
+
+	// a plain SHA-256 of the value would let the value be guessed offline
+	if fingerprint == fmt.Sprintf("%x", sha256.Sum256([]byte("postgres://db")))[:12] {
+		t.Errorf("the fingerprint must not be a plain hash of the value")
+	}
+}
@@ -0,0 +1,455 @@
+/*
+Copyright (c) 2023 Infisical Inc.
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/Infisical/infisical-merge/packages/models"
+	"github.com/Infisical/infisical-merge/packages/util"
+	"github.com/spf13/cobra"
+)
+
+// the formats of secrets generate-example-env
+const (
+	EXAMPLE_ENV_FORMAT_DOTENV      = "dotenv"
+	EXAMPLE_ENV_FORMAT_JSON_SCHEMA = "json-schema"
+	EXAMPLE_ENV_FORMAT_TYPESCRIPT  = "typescript"
+	EXAMPLE_ENV_FORMAT_GO          = "go"
+)
+
+var exampleEnvFormats = []string{EXAMPLE_ENV_FORMAT_DOTENV, EXAMPLE_ENV_FORMAT_JSON_SCHEMA, EXAMPLE_ENV_FORMAT_TYPESCRIPT, EXAMPLE_ENV_FORMAT_GO}
+
+var identifierRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+type exampleEnvFileOptions struct {
+	Format string
+	// the tags of --tags, they are not used as headings since every secret has them
+	FilteredTagSlugs []string
+	// secrets with this tag are optional, all others are required
+	OptionalTag string
+	// the name of the TypeScript interface, the Go struct and the title of the JSON Schema
+	TypeName  string
+	GoPackage string
+}
+
+// exampleEnvVariable is a secret of the generated type definitions, keys of imported secrets appear once
+type exampleEnvVariable struct {
+	Key          string
+	Comment      string
+	DefaultValue string
+	Required     bool
+}
+
+func getExampleEnvFileOptions(cmd *cobra.Command) (exampleEnvFileOptions, error) {
+	options := exampleEnvFileOptions{}
+
+	var err error
+	if options.Format, err = cmd.Flags().GetString("format"); err != nil {
+		return options, err
+	}
+	if options.OptionalTag, err = cmd.Flags().GetString("optional-tag"); err != nil {
+		return options, err
+	}
+	if options.TypeName, err = cmd.Flags().GetString("type-name"); err != nil {
+		return options, err
+	}
+	if options.GoPackage, err = cmd.Flags().GetString("go-package"); err != nil {
+		return options, err
+	}
+
+	if !slices.Contains(exampleEnvFormats, options.Format) {
+		return options, fmt.Errorf("invalid format: %s. Available formats are %v", options.Format, exampleEnvFormats)
+	}
+
+	if !identifierRegex.MatchString(options.TypeName) {
+		return options, fmt.Errorf("the type name %q is not a valid identifier", options.TypeName)
+	}
+
+	if !identifierRegex.MatchString(options.GoPackage) {
+		return options, fmt.Errorf("the Go package %q is not a valid identifier", options.GoPackage)
+	}
+
+	return options, nil
+}
+
+// formatExampleEnvFile renders the secrets in the format of the options. The output only depends on the secrets so it can be checked in and compared with --check
+func formatExampleEnvFile(secrets []models.SingleEnvironmentVariable, options exampleEnvFileOptions) (string, error) {
+	switch options.Format {
+	case EXAMPLE_ENV_FORMAT_DOTENV:
+		return formatExampleEnv(secrets, options), nil
+	case EXAMPLE_ENV_FORMAT_JSON_SCHEMA:
+		return formatExampleEnvJsonSchema(getExampleEnvVariables(secrets, options.OptionalTag), options)
+	case EXAMPLE_ENV_FORMAT_TYPESCRIPT:
+		return formatExampleEnvTypeScript(getExampleEnvVariables(secrets, options.OptionalTag), options), nil
+	case EXAMPLE_ENV_FORMAT_GO:
+		return formatExampleEnvGo(getExampleEnvVariables(secrets, options.OptionalTag), options)
+	default:
+		return "", fmt.Errorf("invalid format: %s. Available formats are %v", options.Format, exampleEnvFormats)
+	}
+}
+
+// parseExampleEnvComment splits a secret comment into its description and the default value written after DEFAULT:
+func parseExampleEnvComment(secretComment string) (comment string, defaultValue string) {
+	re := regexp.MustCompile(`(?s)(.*)DEFAULT:(.*)`)
+	match := re.FindStringSubmatch(secretComment)
+	if len(match) == 3 {
+		return strings.TrimSpace(match[1]), strings.TrimSpace(match[2])
+	}
+
+	return strings.TrimSpace(secretComment), ""
+}
+
+// getOptionalExampleEnvKeys returns the keys that have the optional tag, a key is required when any of its secrets is required
+func getOptionalExampleEnvKeys(secrets []models.SingleEnvironmentVariable, optionalTag string) map[string]bool {
+	optionalKeys := map[string]bool{}
+	requiredKeys := map[string]bool{}
+	for _, secret := range secrets {
+		isOptional := false
+		for _, tag := range secret.Tags {
+			if optionalTag != "" && tag.Slug == optionalTag {
+				isOptional = true
+			}
+		}
+
+		if isOptional {
+			optionalKeys[secret.Key] = true
+		} else {
+			requiredKeys[secret.Key] = true
+		}
+	}
+
+	for key := range requiredKeys {
+		delete(optionalKeys, key)
+	}
+
+	return optionalKeys
+}
+
+func getExampleEnvVariables(secrets []models.SingleEnvironmentVariable, optionalTag string) []exampleEnvVariable {
+	optionalKeys := getOptionalExampleEnvKeys(secrets, optionalTag)
+
+	variables := []exampleEnvVariable{}
+	seenKeys := map[string]bool{}
+	for _, secret := range secrets {
+		if seenKeys[secret.Key] {
+			continue
+		}
+		seenKeys[secret.Key] = true
+
+		comment, defaultValue := parseExampleEnvComment(secret.Comment)
+		variables = append(variables, exampleEnvVariable{
+			Key:          secret.Key,
+			Comment:      comment,
+			DefaultValue: defaultValue,
+			Required:     !optionalKeys[secret.Key],
+		})
+	}
+
+	sort.Slice(variables, func(i, j int) bool {
+		return variables[i].Key < variables[j].Key
+	})
+
+	return variables
+}
+
+func formatExampleEnvJsonSchema(variables []exampleEnvVariable, options exampleEnvFileOptions) (string, error) {
+	type property struct {
+		Type        string `json:"type"`
+		Description string `json:"description,omitempty"`
+		Default     string `json:"default,omitempty"`
+	}
+
+	type schema struct {
+		Schema     string              `json:"$schema"`
+		Title      string              `json:"title"`
+		Type       string              `json:"type"`
+		Properties map[string]property `json:"properties"`
+		Required   []string            `json:"required"`
+	}
+
+	document := schema{
+		Schema:     "http://json-schema.org/draft-07/schema#",
+		Title:      options.TypeName,
+		Type:       "object",
+		Properties: map[string]property{},
+		Required:   []string{},
+	}
+
+	for _, variable := range variables {
+		document.Properties[variable.Key] = property{Type: "string", Description: variable.Comment, Default: variable.DefaultValue}
+		if variable.Required {
+			document.Required = append(document.Required, variable.Key)
+		}
+	}
+
+	// map keys are sorted by encoding/json, so the output is stable
+	output, err := json.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("unable to marshal the JSON Schema [err=%v]", err)
+	}
+
+	return string(output) + "\n", nil
+}
+
+func formatExampleEnvTypeScript(variables []exampleEnvVariable, options exampleEnvFileOptions) string {
+	var output strings.Builder
+	output.WriteString("// Code generated by infisical secrets generate-example-env. DO NOT EDIT.\n\n")
+	output.WriteString(fmt.Sprintf("export interface %s {\n", options.TypeName))
+
+	for _, variable := range variables {
+		if doc := exampleEnvDocComment(variable); doc != "" {
+			output.WriteString(fmt.Sprintf("  /** %s */\n", strings.ReplaceAll(doc, "*/", "*\\/")))
+		}
+
+		optionalMarker := ""
+		if !variable.Required {
+			optionalMarker = "?"
+		}
+
+		// keys that are not identifiers, such as keys with dashes, are quoted
+		key := variable.Key
+		if !identifierRegex.MatchString(key) {
+			key = fmt.Sprintf("%q", key)
+		}
+		output.WriteString(fmt.Sprintf("  %s%s: string;\n", key, optionalMarker))
+	}
+
+	output.WriteString("}\n")
+	return output.String()
+}
+
+func formatExampleEnvGo(variables []exampleEnvVariable, options exampleEnvFileOptions) (string, error) {
+	var output strings.Builder
+	output.WriteString("// Code generated by infisical secrets generate-example-env. DO NOT EDIT.\n\n")
+	output.WriteString(fmt.Sprintf("package %s\n\n", options.GoPackage))
+	output.WriteString(fmt.Sprintf("type %s struct {\n", options.TypeName))
+
+	fieldNames := map[string]int{}
+	for _, variable := range variables {
+		if doc := exampleEnvDocComment(variable); doc != "" {
+			output.WriteString(fmt.Sprintf("\t// %s\n", doc))
+		}
+
+		// keys that map to the same field name, such as DB_URL and DB-URL, get a number suffix
+		fieldName := goFieldName(variable.Key)
+		fieldNames[fieldName] += 1
+		if fieldNames[fieldName] > 1 {
+			fieldName = fmt.Sprintf("%s%d", fieldName, fieldNames[fieldName])
+		}
+
+		envTag := variable.Key
+		if variable.Required {
+			envTag += ",required"
+		}
+
+		tags := fmt.Sprintf("env:%q", envTag)
+		if variable.DefaultValue != "" {
+			tags += fmt.Sprintf(" envDefault:%q", variable.DefaultValue)
+		}
+
+		output.WriteString(fmt.Sprintf("\t%s string `%s`\n", fieldName, tags))
+	}
+
+	output.WriteString("}\n")
+
+	formatted, err := format.Source([]byte(output.String()))
+	if err != nil {
+		return "", fmt.Errorf("unable to format the generated Go file [err=%v]", err)
+	}
+
+	return string(formatted), nil
+}
+
+// exampleEnvDocComment is the comment of a variable on a single line, with its default value
+func exampleEnvDocComment(variable exampleEnvVariable) string {
+	doc := strings.Join(strings.Fields(variable.Comment), " ")
+	if variable.DefaultValue != "" {
+		doc = strings.TrimSpace(fmt.Sprintf("%s (default: %s)", doc, variable.DefaultValue))
+	}
+	return doc
+}
+
+// goFieldName converts a secret key such as DATABASE_URL to an exported field name such as DatabaseUrl
+func goFieldName(key string) string {
+	words := strings.FieldsFunc(key, func(character rune) bool {
+		return !unicode.IsLetter(character) && !unicode.IsDigit(character)
+	})
+
+	var fieldName strings.Builder
+	for _, word := range words {
+		runes := []rune(strings.ToLower(word))
+		runes[0] = unicode.ToUpper(runes[0])
+		fieldName.WriteString(string(runes))
+	}
+
+	name := fieldName.String()
+	if name == "" || !unicode.IsLetter([]rune(name)[0]) {
+		name = "Env" + name
+	}
+
+	return name
+}
+
+// formatExampleEnv renders a dotenv file with the secrets grouped under a heading per set of tags, values are the defaults of the comments
+func formatExampleEnv(secrets []models.SingleEnvironmentVariable, options exampleEnvFileOptions) string {
+	tagsHashToSecretKey := make(map[string]int)
+	slugsToFilerBy := make(map[string]int)
+
+	for _, slug := range options.FilteredTagSlugs {
+		slugsToFilerBy[slug] = 1
+	}
+	// the optional tag is a convention, not a heading
+	slugsToFilerBy[options.OptionalTag] = 1
+
+	type TagsAndSecrets struct {
+		Secrets []models.SingleEnvironmentVariable
+		Tags    []models.SecretTag
+	}
+
+	optionalKeys := getOptionalExampleEnvKeys(secrets, options.OptionalTag)
+
+	// sort secrets by associated tags (most number of tags to least tags)
+	sort.SliceStable(secrets, func(i, j int) bool {
+		return len(secrets[i].Tags) > len(secrets[j].Tags)
+	})
+
+	for i, secret := range secrets {
+		filteredTag := []models.SecretTag{}
+
+		for _, secretTag := range secret.Tags {
+			_, exists := slugsToFilerBy[secretTag.Slug]
+			if !exists {
+				filteredTag = append(filteredTag, secretTag)
+			}
+		}
+
+		secret.Tags = filteredTag
+		secrets[i] = secret
+	}
+
+	for _, secret := range secrets {
+		listOfTagSlugs := []string{}
+
+		for _, tag := range secret.Tags {
+			listOfTagSlugs = append(listOfTagSlugs, tag.Slug)
+		}
+		sort.Strings(listOfTagSlugs)
+
+		tagsHash := util.GetHashFromStringList(listOfTagSlugs)
+
+		tagsHashToSecretKey[tagsHash] += 1
+	}
+
+	finalTagHashToSecretKey := make(map[string]TagsAndSecrets)
+
+	for _, secret := range secrets {
+		listOfTagSlugs := []string{}
+		for _, tag := range secret.Tags {
+			listOfTagSlugs = append(listOfTagSlugs, tag.Slug)
+		}
+
+		// sort the slug so we get the same hash each time
+		sort.Strings(listOfTagSlugs)
+
+		tagsHash := util.GetHashFromStringList(listOfTagSlugs)
+		occurrence, exists := tagsHashToSecretKey[tagsHash]
+		if exists && occurrence > 0 {
+
+			value, exists2 := finalTagHashToSecretKey[tagsHash]
+			allSecretsForTags := append(value.Secrets, secret)
+
+			// sort the the secrets by keys so that they can later be sorted by the first item in the secrets array
+			sort.Slice(allSecretsForTags, func(i, j int) bool {
+				return allSecretsForTags[i].Key < allSecretsForTags[j].Key
+			})
+
+			if exists2 {
+				finalTagHashToSecretKey[tagsHash] = TagsAndSecrets{
+					Tags:    secret.Tags,
+					Secrets: allSecretsForTags,
+				}
+			} else {
+				finalTagHashToSecretKey[tagsHash] = TagsAndSecrets{
+					Tags:    secret.Tags,
+					Secrets: []models.SingleEnvironmentVariable{secret},
+				}
+			}
+
+			tagsHashToSecretKey[tagsHash] -= 1
+		}
+	}
+
+	// sort the fianl result by secret key fo consistent print order
+	listOfsecretDetails := make([]TagsAndSecrets, 0, len(finalTagHashToSecretKey))
+	for _, secretDetails := range finalTagHashToSecretKey {
+		listOfsecretDetails = append(listOfsecretDetails, secretDetails)
+	}
+
+	// sort the order of the headings by the order of the secrets, headings with the same number of tags by their first secret
+	sort.Slice(listOfsecretDetails, func(i, j int) bool {
+		if len(listOfsecretDetails[i].Tags) != len(listOfsecretDetails[j].Tags) {
+			return len(listOfsecretDetails[i].Tags) < len(listOfsecretDetails[j].Tags)
+		}
+		return listOfsecretDetails[i].Secrets[0].Key < listOfsecretDetails[j].Secrets[0].Key
+	})
+
+	tableOfContents := []string{}
+	fullyGeneratedDocuments := []string{}
+	for _, secretDetails := range listOfsecretDetails {
+		listOfKeyValue := []string{}
+
+		for _, secret := range secretDetails.Secrets {
+			comment, defaultValue := parseExampleEnvComment(secret.Comment)
+			if optionalKeys[secret.Key] {
+				comment = strings.TrimSpace(comment + "\n(optional)")
+			}
+
+			row := ""
+			if comment != "" {
+				comment = addHash(comment)
+				row = fmt.Sprintf("%s \n%s=%s", strings.TrimSpace(comment), strings.TrimSpace(secret.Key), strings.TrimSpace(defaultValue))
+			} else {
+				row = fmt.Sprintf("%s=%s", strings.TrimSpace(secret.Key), strings.TrimSpace(defaultValue))
+			}
+
+			// each secret row to be added to the file
+			listOfKeyValue = append(listOfKeyValue, row)
+		}
+
+		listOfTagNames := []string{}
+		for _, tag := range secretDetails.Tags {
+			listOfTagNames = append(listOfTagNames, tag.Name)
+		}
+
+		heading := CenterString(strings.Join(listOfTagNames, " & "), 80)
+
+		if len(listOfTagNames) == 0 {
+			fullyGeneratedDocuments = append(fullyGeneratedDocuments, fmt.Sprintf("\n%s \n", strings.Join(listOfKeyValue, "\n")))
+		} else {
+			fullyGeneratedDocuments = append(fullyGeneratedDocuments, fmt.Sprintf("\n\n\n%s \n%s \n", heading, strings.Join(listOfKeyValue, "\n")))
+			tableOfContents = append(tableOfContents, strings.ToUpper(strings.Join(listOfTagNames, " & ")))
+		}
+	}
+
+	var exampleEnv strings.Builder
+	dashedList := []string{}
+	for _, item := range tableOfContents {
+		dashedList = append(dashedList, fmt.Sprintf("# - %s \n", item))
+	}
+	if len(dashedList) > 0 {
+		exampleEnv.WriteString(CenterString("TABLE OF CONTENTS", 80) + "\n")
+		exampleEnv.WriteString(strings.Join(dashedList, "") + "\n")
+	}
+	exampleEnv.WriteString(strings.Join(fullyGeneratedDocuments, "") + "\n")
+
+	return exampleEnv.String()
+}
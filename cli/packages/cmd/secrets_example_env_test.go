@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Infisical/infisical-merge/packages/models"
+)
+
+func newExampleEnvTestSecrets() []models.SingleEnvironmentVariable {
+	secrets := []models.SingleEnvironmentVariable{
+		{Key: "DATABASE_URL", Comment: "The database\nDEFAULT: postgres://localhost"},
+		{Key: "SENTRY_DSN", Comment: "Error reporting"},
+		{Key: "PORT"},
+	}
+
+	for i, slug := range []string{"backend", "optional"} {
+		secrets[i+1].Tags = make([]struct {
+			ID        string `json:"_id"`
+			Name      string `json:"name"`
+			Slug      string `json:"slug"`
+			Workspace string `json:"workspace"`
+		}, 1)
+		secrets[i+1].Tags[0].Slug = slug
+		secrets[i+1].Tags[0].Name = slug
+	}
+
+	return secrets
+}
+
+func TestFormatExampleEnvFile(t *testing.T) {
+	options := exampleEnvFileOptions{OptionalTag: "optional", TypeName: "Env", GoPackage: "config"}
+
+	options.Format = EXAMPLE_ENV_FORMAT_DOTENV
+	dotenv, err := formatExampleEnvFile(newExampleEnvTestSecrets(), options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(dotenv, "# The database \nDATABASE_URL=postgres://localhost") || !strings.Contains(dotenv, "# (optional) \nPORT=") || strings.Contains(dotenv, "OPTIONAL") {
+		t.Errorf("unexpected dotenv file:\n%s", dotenv)
+	}
+
+	for i := 0; i < 10; i++ {
+		if again, _ := formatExampleEnvFile(newExampleEnvTestSecrets(), options); again != dotenv {
+			t.Fatalf("the dotenv file must be stable for --check, got:\n%s\nand:\n%s", dotenv, again)
+		}
+	}
+
+	options.Format = EXAMPLE_ENV_FORMAT_TYPESCRIPT
+	typescript, _ := formatExampleEnvFile(newExampleEnvTestSecrets(), options)
+	if !strings.Contains(typescript, "  /** The database (default: postgres://localhost) */\n  DATABASE_URL: string;\n  PORT?: string;\n") {
+		t.Errorf("unexpected TypeScript file:\n%s", typescript)
+	}
+
+	options.Format = EXAMPLE_ENV_FORMAT_GO
+	goFile, _ := formatExampleEnvFile(newExampleEnvTestSecrets(), options)
+	if !strings.Contains(goFile, "\tDatabaseUrl string `env:\"DATABASE_URL,required\" envDefault:\"postgres://localhost\"`\n\tPort        string `env:\"PORT\"`\n") {
+		t.Errorf("unexpected Go file:\n%s", goFile)
+	}
+
+	options.Format = EXAMPLE_ENV_FORMAT_JSON_SCHEMA
+	schema, _ := formatExampleEnvFile(newExampleEnvTestSecrets(), options)
+	if !strings.Contains(schema, "\"required\": [\n    \"DATABASE_URL\",\n    \"SENTRY_DSN\"\n  ]") {
+		t.Errorf("unexpected JSON Schema:\n%s", schema)
+	}
+}
@@ -0,0 +1,329 @@
+/*
+Copyright (c) 2023 Infisical Inc.
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Infisical/infisical-merge/packages/models"
+	"github.com/Infisical/infisical-merge/packages/util"
+	"github.com/manifoldco/promptui"
+	"github.com/posthog/posthog-go"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var secretsHistoryCmd = &cobra.Command{
+	Example:               `infisical secrets history DB_PASSWORD --env=prod`,
+	Short:                 "Used to list the versions of a secret",
+	Use:                   "history [secret]",
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		params := getSecretHistoryParams(cmd)
+
+		secretType, err := cmd.Flags().GetString("type")
+		if err != nil || (secretType != util.SECRET_TYPE_SHARED && secretType != util.SECRET_TYPE_PERSONAL) {
+			util.HandleError(err, "Unable to parse secret type")
+		}
+
+		limit, err := cmd.Flags().GetInt("limit")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		showValues, err := cmd.Flags().GetBool("show-values")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		outputFormat, err := cmd.Flags().GetString("output")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		versions, err := util.GetSecretVersions(params, args[0], secretType, 0, limit)
+		if err != nil {
+			util.HandleError(err, "Unable to get the versions of the secret")
+		}
+
+		output, err := formatSecretVersions(versions, outputFormat, showValues)
+		if err != nil {
+			util.HandleError(err)
+		}
+		fmt.Print(output)
+
+		Telemetry.CaptureEvent("cli-command:secrets history", posthog.NewProperties().Set("versionCount", len(versions)).Set("version", util.CLI_VERSION))
+	},
+}
+
+var secretsRollbackCmd = &cobra.Command{
+	Example:               `infisical secrets rollback DB_PASSWORD --version=3 --env=prod`,
+	Short:                 "Used to set a secret back to one of its versions",
+	Use:                   "rollback [secret]",
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		params := getSecretHistoryParams(cmd)
+
+		secretType, err := cmd.Flags().GetString("type")
+		if err != nil || (secretType != util.SECRET_TYPE_SHARED && secretType != util.SECRET_TYPE_PERSONAL) {
+			util.HandleError(err, "Unable to parse secret type")
+		}
+
+		version, err := cmd.Flags().GetInt("version")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		skipConfirmation, err := cmd.Flags().GetBool("yes")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		if version <= 0 {
+			util.PrintErrorMessageAndExit("Provide the version to roll back to with --version, run [infisical secrets history] to see the versions")
+		}
+
+		if !skipConfirmation && !confirmChange(fmt.Sprintf("Set %s in %s:%s back to version %d?", args[0], params.Environment, params.SecretsPath, version)) {
+			fmt.Println("The secret was not changed")
+			return
+		}
+
+		restoredVersion, err := util.RollbackSecretToVersion(params, args[0], secretType, version)
+		if err != nil {
+			util.HandleError(err, "Unable to roll back the secret")
+		}
+
+		util.PrintSuccessMessage(fmt.Sprintf("%s is set back to version %d from %s", args[0], restoredVersion.Version, restoredVersion.CreatedAt.Local().Format(time.RFC822)))
+
+		Telemetry.CaptureEvent("cli-command:secrets rollback", posthog.NewProperties().Set("version", util.CLI_VERSION))
+	},
+}
+
+var secretsSnapshotsCmd = &cobra.Command{
+	Example:               `infisical secrets snapshots list --env=prod --path=/api`,
+	Short:                 "Used to list and restore the snapshots of a folder",
+	Use:                   "snapshots",
+	DisableFlagsInUseLine: true,
+}
+
+var secretsSnapshotsListCmd = &cobra.Command{
+	Example:               `infisical secrets snapshots list --env=prod --path=/api`,
+	Short:                 "Used to list the snapshots of a folder, newest first",
+	Use:                   "list",
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		params := getSecretMetadataParams(cmd)
+
+		limit, err := cmd.Flags().GetInt("limit")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		outputFormat, err := cmd.Flags().GetString("output")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		snapshots, err := util.GetSecretSnapshots(params, 0, limit)
+		if err != nil {
+			util.HandleError(err, "Unable to list the snapshots")
+		}
+
+		output, err := formatSecretSnapshots(snapshots, outputFormat)
+		if err != nil {
+			util.HandleError(err)
+		}
+		fmt.Print(output)
+
+		Telemetry.CaptureEvent("cli-command:secrets snapshots list", posthog.NewProperties().Set("snapshotCount", len(snapshots)).Set("version", util.CLI_VERSION))
+	},
+}
+
+var secretsSnapshotsRestoreCmd = &cobra.Command{
+	Example:               `infisical secrets snapshots restore 3c0e5b0a-8c4d-4e6f-9a3b-2f1d7e6c5b4a`,
+	Short:                 "Used to roll the secrets and folders of a folder back to a snapshot",
+	Use:                   "restore [snapshot id]",
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		params := getSecretMetadataParams(cmd)
+
+		skipConfirmation, err := cmd.Flags().GetBool("yes")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		if !skipConfirmation && !confirmChange(fmt.Sprintf("Replace the secrets and folders of the snapshot's folder with those of snapshot %s?", args[0])) {
+			fmt.Println("No secrets were changed")
+			return
+		}
+
+		snapshot, err := util.RestoreSecretSnapshot(params, args[0])
+		if err != nil {
+			util.HandleError(err, "Unable to restore the snapshot")
+		}
+
+		util.PrintSuccessMessage(fmt.Sprintf("Restored the snapshot from %s", snapshot.CreatedAt.Local().Format(time.RFC822)))
+
+		Telemetry.CaptureEvent("cli-command:secrets snapshots restore", posthog.NewProperties().Set("version", util.CLI_VERSION))
+	},
+}
+
+func init() {
+	secretsHistoryCmd.Flags().String("type", util.SECRET_TYPE_SHARED, "the type of the secret: personal or shared")
+	secretsHistoryCmd.Flags().String("path", "/", "the folder path of the secret")
+	secretsHistoryCmd.Flags().Int("limit", 20, "The number of versions to show, newest first")
+	secretsHistoryCmd.Flags().Bool("show-values", false, "Show the values of the versions instead of their fingerprints")
+	secretsHistoryCmd.Flags().StringP("output", "o", "table", "The output format: table or json")
+	secretsCmd.AddCommand(secretsHistoryCmd)
+
+	secretsRollbackCmd.Flags().String("type", util.SECRET_TYPE_SHARED, "the type of the secret: personal or shared")
+	secretsRollbackCmd.Flags().String("path", "/", "the folder path of the secret")
+	secretsRollbackCmd.Flags().Int("version", 0, "The version to roll back to")
+	secretsRollbackCmd.MarkFlagRequired("version")
+	secretsRollbackCmd.Flags().BoolP("yes", "y", false, "Roll back without asking for confirmation")
+	secretsCmd.AddCommand(secretsRollbackCmd)
+
+	for _, cmd := range []*cobra.Command{secretsSnapshotsListCmd, secretsSnapshotsRestoreCmd} {
+		cmd.Flags().String("token", "", "Use a machine identity access token")
+		cmd.Flags().String("projectId", "", "manually set the project ID when using machine identity based auth")
+		secretsSnapshotsCmd.AddCommand(cmd)
+	}
+	secretsSnapshotsListCmd.Flags().String("path", "/", "the folder path of the snapshots")
+	secretsSnapshotsListCmd.Flags().Int("limit", 20, "The number of snapshots to show, newest first")
+	secretsSnapshotsListCmd.Flags().StringP("output", "o", "table", "The output format: table or json")
+	secretsSnapshotsRestoreCmd.Flags().String("path", "/", "the folder path of the snapshot")
+	secretsSnapshotsRestoreCmd.Flags().BoolP("yes", "y", false, "Restore without asking for confirmation")
+	secretsCmd.AddCommand(secretsSnapshotsCmd)
+}
+
+// getSecretHistoryParams reads the environment and folder of a secret. The versions of secrets can only be read when
+// logged in, so there are no token flags.
+func getSecretHistoryParams(cmd *cobra.Command) models.GetAllSecretsParameters {
+	environmentName, _ := cmd.Flags().GetString("env")
+	if !cmd.Flags().Changed("env") {
+		environmentFromWorkspace := util.GetEnvFromWorkspaceFile()
+		if environmentFromWorkspace != "" {
+			environmentName = environmentFromWorkspace
+		}
+	}
+
+	secretsPath, err := cmd.Flags().GetString("path")
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+
+	return models.GetAllSecretsParameters{Environment: environmentName, SecretsPath: secretsPath}
+}
+
+func confirmChange(label string) bool {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		util.PrintErrorMessageAndExit("Unable to ask for confirmation when not running in a terminal, use --yes to apply the change")
+	}
+
+	prompt := promptui.Select{
+		Label: label + " Select[Yes/No]",
+		Items: []string{"No", "Yes"},
+	}
+	_, result, err := prompt.Run()
+	if err != nil {
+		util.HandleError(err, "Unable to read your answer")
+	}
+
+	return result == "Yes"
+}
+
+type secretVersionRecord struct {
+	Version          int     `json:"version"`
+	Value            *string `json:"value,omitempty"`
+	ValueFingerprint string  `json:"valueFingerprint"`
+	Comment          string  `json:"comment"`
+	Actor            string  `json:"actor"`
+	CreatedAt        string  `json:"createdAt"`
+}
+
+func formatSecretVersions(versions []models.SecretVersion, outputFormat string, showValues bool) (string, error) {
+	records := []secretVersionRecord{}
+	for _, version := range versions {
+		record := secretVersionRecord{
+			Version:          version.Version,
+			ValueFingerprint: secretValueFingerprint(version.Value),
+			Comment:          version.Comment,
+			Actor:            version.Actor,
+			CreatedAt:        version.CreatedAt.UTC().Format(time.RFC3339),
+		}
+		if showValues {
+			value := version.Value
+			record.Value = &value
+		}
+		records = append(records, record)
+	}
+
+	switch strings.ToLower(outputFormat) {
+	case "json":
+		output, err := json.MarshalIndent(records, "", "  ")
+		if err != nil {
+			return "", fmt.Errorf("unable to marshal the versions to JSON [err=%v]", err)
+		}
+		return string(output) + "\n", nil
+	case "table":
+		var builder strings.Builder
+		writer := tabwriter.NewWriter(&builder, 0, 0, 2, ' ', 0)
+		valueHeader := "VALUE FINGERPRINT"
+		if showValues {
+			valueHeader = "VALUE"
+		}
+		fmt.Fprintf(writer, "VERSION\tCREATED AT\tACTOR\t%s\n", valueHeader)
+		for _, record := range records {
+			value := record.ValueFingerprint
+			if record.Value != nil {
+				value = *record.Value
+			}
+			fmt.Fprintf(writer, "%d\t%s\t%s\t%s\n", record.Version, record.CreatedAt, record.Actor, value)
+		}
+		writer.Flush()
+		return builder.String(), nil
+	default:
+		return "", fmt.Errorf("invalid output format: %s. Available formats are [table json]", outputFormat)
+	}
+}
+
+func formatSecretSnapshots(snapshots []models.SecretSnapshot, outputFormat string) (string, error) {
+	switch strings.ToLower(outputFormat) {
+	case "json":
+		records := []map[string]string{}
+		for _, snapshot := range snapshots {
+			records = append(records, map[string]string{
+				"id":          snapshot.ID,
+				"environment": snapshot.Environment,
+				"path":        snapshot.Path,
+				"createdAt":   snapshot.CreatedAt.UTC().Format(time.RFC3339),
+			})
+		}
+		output, err := json.MarshalIndent(records, "", "  ")
+		if err != nil {
+			return "", fmt.Errorf("unable to marshal the snapshots to JSON [err=%v]", err)
+		}
+		return string(output) + "\n", nil
+	case "table":
+		var builder strings.Builder
+		writer := tabwriter.NewWriter(&builder, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(writer, "SNAPSHOT ID\tCREATED AT")
+		for _, snapshot := range snapshots {
+			fmt.Fprintf(writer, "%s\t%s\n", snapshot.ID, snapshot.CreatedAt.UTC().Format(time.RFC3339))
+		}
+		writer.Flush()
+		return builder.String(), nil
+	default:
+		return "", fmt.Errorf("invalid output format: %s. Available formats are [table json]", outputFormat)
+	}
+}
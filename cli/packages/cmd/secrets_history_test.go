@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Infisical/infisical-merge/packages/models"
+)
+
+func TestFormatSecretVersionsHidesValues(t *testing.T) {
+	versions := []models.SecretVersion{{Version: 2, Key: "DB_PASSWORD", Value: "hunter2", Actor: "jane@example.com", CreatedAt: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)}}
+
+	for _, format := range []string{"table", "json"} {
+		output, err := formatSecretVersions(versions, format, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(output, "hunter2") || !strings.Contains(output, secretValueFingerprint("hunter2")) || !strings.Contains(output, "jane@example.com") || !strings.Contains(output, "2024-01-02T00:00:00Z") {
+			t.Errorf("unexpected %s output:\n%s", format, output)
+		}
+
+		output, _ = formatSecretVersions(versions, format, true)
+		if !strings.Contains(output, "hunter2") {
+			t.Errorf("expected the value in the %s output with showValues:\n%s", format, output)
+		}
+	}
+
+	if _, err := formatSecretVersions(versions, "yaml", false); err == nil {
+		t.Errorf("expected an error for an unknown format")
+	}
+}
@@ -0,0 +1,287 @@
+/*
+Copyright (c) 2023 Infisical Inc.
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Infisical/infisical-merge/packages/models"
+	"github.com/Infisical/infisical-merge/packages/util"
+	"github.com/Infisical/infisical-merge/packages/visualize"
+	"github.com/posthog/posthog-go"
+	"github.com/spf13/cobra"
+)
+
+var secretsImportsCmd = &cobra.Command{
+	Example:               `secrets imports list --env=dev --path=/apps/api`,
+	Short:                 "Used to list, add, remove and reorder the secret imports of a folder",
+	Use:                   "imports",
+	DisableFlagsInUseLine: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Help()
+	},
+}
+
+var secretsImportsListCmd = &cobra.Command{
+	Example:               `secrets imports list --env=dev --path=/apps/api`,
+	Short:                 "Used to list the imports of a folder in the order they are applied",
+	Use:                   "list",
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		params := getSecretMetadataParams(cmd)
+
+		outputFormat, err := cmd.Flags().GetString("output")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		if err := printSecretImports(getSecretImportsOrExit(params), outputFormat); err != nil {
+			util.HandleError(err)
+		}
+
+		Telemetry.CaptureEvent("cli-command:secrets imports list", posthog.NewProperties().Set("version", util.CLI_VERSION))
+	},
+}
+
+var secretsImportsAddCmd = &cobra.Command{
+	Example:               `secrets imports add shared:/database --env=dev --path=/apps/api`,
+	Short:                 "Used to import the secrets of another environment or folder, after the existing imports",
+	Use:                   "add <environment>:<path>",
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		params := getSecretMetadataParams(cmd)
+		source := parseSecretImportSource(args[0])
+
+		position, err := cmd.Flags().GetInt("position")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		if err := util.AddSecretImport(params, source.Environment, source.Path); err != nil {
+			util.HandleError(err, "Unable to add the import")
+		}
+
+		if position > 0 {
+			if err := util.MoveSecretImport(params, source.Environment, source.Path, position); err != nil {
+				util.HandleError(err, "The import was added but could not be moved")
+			}
+		}
+
+		util.PrintSuccessMessage(fmt.Sprintf("%s:%s now imports %s", params.Environment, params.SecretsPath, source))
+
+		Telemetry.CaptureEvent("cli-command:secrets imports add", posthog.NewProperties().Set("version", util.CLI_VERSION))
+	},
+}
+
+var secretsImportsRemoveCmd = &cobra.Command{
+	Example:               `secrets imports remove shared:/database --env=dev --path=/apps/api`,
+	Short:                 "Used to remove an import from a folder, the imported secrets are not changed",
+	Use:                   "remove <environment>:<path>",
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		params := getSecretMetadataParams(cmd)
+		source := parseSecretImportSource(args[0])
+
+		if err := util.RemoveSecretImport(params, source.Environment, source.Path); err != nil {
+			util.HandleError(err, "Unable to remove the import")
+		}
+
+		util.PrintSuccessMessage(fmt.Sprintf("%s:%s no longer imports %s", params.Environment, params.SecretsPath, source))
+
+		Telemetry.CaptureEvent("cli-command:secrets imports remove", posthog.NewProperties().Set("version", util.CLI_VERSION))
+	},
+}
+
+var secretsImportsReorderCmd = &cobra.Command{
+	Example:               `secrets imports reorder shared:/database --position=1 --env=dev --path=/apps/api`,
+	Short:                 "Used to move an import to another position, imports with a higher position take precedence",
+	Use:                   "reorder <environment>:<path>",
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		params := getSecretMetadataParams(cmd)
+		source := parseSecretImportSource(args[0])
+
+		position, err := cmd.Flags().GetInt("position")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		if position < 1 {
+			util.PrintErrorMessageAndExit("--position must be 1 or more, 1 is the first import")
+		}
+
+		if err := util.MoveSecretImport(params, source.Environment, source.Path, position); err != nil {
+			util.HandleError(err, "Unable to reorder the imports")
+		}
+
+		if err := printSecretImports(getSecretImportsOrExit(params), "table"); err != nil {
+			util.HandleError(err)
+		}
+
+		Telemetry.CaptureEvent("cli-command:secrets imports reorder", posthog.NewProperties().Set("version", util.CLI_VERSION))
+	},
+}
+
+var secretsImportsChainCmd = &cobra.Command{
+	Example:               `secrets imports chain --env=dev --path=/apps/api`,
+	Short:                 "Used to show the imports of a folder and of the folders it imports, with the secrets each of them provides",
+	Use:                   "chain",
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		params := getSecretMetadataParams(cmd)
+
+		chain, err := util.GetSecretImportChain(params)
+		if err != nil {
+			util.HandleError(err, "Unable to get the import chain")
+		}
+
+		fmt.Print(formatSecretImportChain(chain))
+
+		Telemetry.CaptureEvent("cli-command:secrets imports chain", posthog.NewProperties().Set("version", util.CLI_VERSION))
+	},
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{secretsImportsListCmd, secretsImportsAddCmd, secretsImportsRemoveCmd, secretsImportsReorderCmd, secretsImportsChainCmd} {
+		cmd.Flags().String("path", "/", "the folder whose imports are managed")
+		cmd.Flags().String("token", "", "Fetch secrets using service token or machine identity access token")
+		cmd.Flags().String("projectId", "", "manually set the project ID when using machine identity based auth")
+		secretsImportsCmd.AddCommand(cmd)
+	}
+
+	secretsImportsListCmd.Flags().StringP("output", "o", "table", "The output format: table or json")
+	secretsImportsAddCmd.Flags().Int("position", 0, "the position of the new import, 1 is the first import. Defaults to after the existing imports")
+	secretsImportsReorderCmd.Flags().Int("position", 0, "the new position of the import, 1 is the first import")
+	secretsImportsReorderCmd.MarkFlagRequired("position")
+
+	secretsCmd.AddCommand(secretsImportsCmd)
+}
+
+func parseSecretImportSource(value string) secretLocation {
+	if !strings.Contains(value, ":") {
+		util.PrintErrorMessageAndExit(fmt.Sprintf("invalid import %s, use <environment>:<path>, e.g. prod:/database", value))
+	}
+
+	source, err := parseSecretLocation(value, "")
+	if err != nil {
+		util.HandleError(err)
+	}
+
+	if source.Environment == "" {
+		util.PrintErrorMessageAndExit(fmt.Sprintf("invalid import %s, the environment is missing, e.g. prod:/database", value))
+	}
+
+	return source
+}
+
+func getSecretImportsOrExit(params models.GetAllSecretsParameters) []models.SecretImport {
+	secretImports, err := util.GetSecretImports(params)
+	if err != nil {
+		util.HandleError(err, "Unable to get the imports")
+	}
+	return secretImports
+}
+
+type secretImportRecord struct {
+	Position    int    `json:"position"`
+	Environment string `json:"environment"`
+	Path        string `json:"path"`
+	Replication bool   `json:"replication"`
+}
+
+func printSecretImports(secretImports []models.SecretImport, outputFormat string) error {
+	switch outputFormat {
+	case "json":
+		records := []secretImportRecord{}
+		for _, secretImport := range secretImports {
+			records = append(records, secretImportRecord{Position: secretImport.Position, Environment: secretImport.Environment, Path: secretImport.Path, Replication: secretImport.IsReplication})
+		}
+
+		output, err := json.MarshalIndent(records, "", "  ")
+		if err != nil {
+			return fmt.Errorf("unable to marshal the imports to JSON [err=%v]", err)
+		}
+		fmt.Println(string(output))
+	case "table":
+		if len(secretImports) == 0 {
+			fmt.Println("The folder has no imports")
+			return nil
+		}
+
+		rows := [][3]string{}
+		for _, secretImport := range secretImports {
+			importPath := secretImport.Path
+			if secretImport.IsReplication {
+				importPath += " (replicated)"
+			}
+			rows = append(rows, [3]string{strconv.Itoa(secretImport.Position), secretImport.Environment, importPath})
+		}
+
+		visualize.Table([3]string{"POSITION", "ENVIRONMENT", "PATH"}, rows)
+		fmt.Println("Imports with a higher position take precedence, secrets of the folder itself take precedence over all imports")
+	default:
+		return fmt.Errorf("invalid output format: %s. Available formats are [table json]", outputFormat)
+	}
+
+	return nil
+}
+
+// formatSecretImportChain draws the folder and its imports as a tree. Each folder shows how many of its secrets are
+// shadowed by a folder that takes precedence: the folder itself, then its imports from the highest position down, where
+// each import comes with its own imports in the same order
+func formatSecretImportChain(chain models.SecretImportChain) string {
+	shadowed := map[*models.SecretImportChain]int{}
+	countShadowedImportKeys(&chain, map[string]bool{}, shadowed)
+
+	var output strings.Builder
+	output.WriteString(fmt.Sprintf("%s:%s (%s)\n", chain.Environment, chain.Path, pluralizeSecrets(len(chain.Keys))))
+	writeSecretImportChainLevel(&output, &chain, "", shadowed)
+	output.WriteString("\nSecrets of a folder take precedence over its imports, and imports further down over the ones above\n")
+	return output.String()
+}
+
+func countShadowedImportKeys(folder *models.SecretImportChain, seenKeys map[string]bool, shadowed map[*models.SecretImportChain]int) {
+	for _, key := range folder.Keys {
+		if seenKeys[key] {
+			shadowed[folder] += 1
+		}
+		seenKeys[key] = true
+	}
+
+	for i := len(folder.Imports) - 1; i >= 0; i-- {
+		if !folder.Imports[i].Cycle {
+			countShadowedImportKeys(&folder.Imports[i], seenKeys, shadowed)
+		}
+	}
+}
+
+func writeSecretImportChainLevel(output *strings.Builder, folder *models.SecretImportChain, prefix string, shadowed map[*models.SecretImportChain]int) {
+	for i := range folder.Imports {
+		importedFolder := &folder.Imports[i]
+
+		branch, childPrefix := "├── ", "│   "
+		if i == len(folder.Imports)-1 {
+			branch, childPrefix = "└── ", "    "
+		}
+
+		details := "already imported above, not imported again"
+		if !importedFolder.Cycle {
+			details = pluralizeSecrets(len(importedFolder.Keys))
+			if shadowed[importedFolder] > 0 {
+				details += fmt.Sprintf(", %d shadowed", shadowed[importedFolder])
+			}
+		}
+
+		output.WriteString(fmt.Sprintf("%s%s%d. %s:%s (%s)\n", prefix, branch, importedFolder.Position, importedFolder.Environment, importedFolder.Path, details))
+		writeSecretImportChainLevel(output, importedFolder, prefix+childPrefix, shadowed)
+	}
+}
@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Infisical/infisical-merge/packages/models"
+)
+
+func TestFormatSecretImportChain(t *testing.T) {
+	chain := models.SecretImportChain{
+		Environment: "dev",
+		Path:        "/api",
+		Keys:        []string{"PORT"},
+		Imports: []models.SecretImportChain{
+			{Environment: "shared", Path: "/common", Position: 1, Keys: []string{"DB_URL", "LOG_LEVEL", "PORT"}},
+			{Environment: "shared", Path: "/database", Position: 2, Keys: []string{"DB_URL"}, Imports: []models.SecretImportChain{
+				{Environment: "shared", Path: "/common", Position: 1, Cycle: true},
+			}},
+		},
+	}
+
+	expected := strings.Join([]string{
+		"dev:/api (1 secret)",
+		"├── 1. shared:/common (3 secrets, 2 shadowed)",
+		"└── 2. shared:/database (1 secret)",
+		"    └── 1. shared:/common (already imported above, not imported again)",
+	}, "\n")
+
+	if output := formatSecretImportChain(chain); !strings.HasPrefix(output, expected+"\n") {
+		t.Errorf("formatSecretImportChain() =\n%s\nexpected:\n%s", output, expected)
+	}
+}
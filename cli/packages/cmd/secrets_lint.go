@@ -0,0 +1,177 @@
+/*
+Copyright (c) 2023 Infisical Inc.
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/Infisical/infisical-merge/packages/models"
+	"github.com/Infisical/infisical-merge/packages/util"
+	"github.com/Infisical/infisical-merge/packages/visualize"
+	"github.com/posthog/posthog-go"
+	"github.com/spf13/cobra"
+)
+
+var secretsLintCmd = &cobra.Command{
+	Example:               `secrets lint --env=prod --path=/apps`,
+	Short:                 "Used to find references to secrets that do not exist and reference cycles, without printing any value",
+	Use:                   "lint",
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		params := getSecretMetadataParams(cmd)
+
+		token, err := util.GetInfisicalToken(cmd)
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		recursive, err := cmd.Flags().GetBool("recursive")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		includeImports, err := cmd.Flags().GetBool("include-imports")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		outputFormat, err := cmd.Flags().GetString("output")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		if outputFormat != "table" && outputFormat != "json" {
+			util.PrintErrorMessageAndExit(fmt.Sprintf("invalid output format: %s. Available formats are [table json]", outputFormat))
+		}
+
+		folderPaths := []string{path.Join("/", params.SecretsPath)}
+		if recursive {
+			treeRequest := models.GetAllFoldersParameters{
+				Environment:              params.Environment,
+				WorkspaceId:              getFolderProjectId(token, params.WorkspaceId),
+				FoldersPath:              params.SecretsPath,
+				InfisicalToken:           params.InfisicalToken,
+				UniversalAuthAccessToken: params.UniversalAuthAccessToken,
+			}
+
+			tree, err := util.GetFolderTree(treeRequest)
+			if err != nil {
+				util.HandleError(err, "Unable to get the folders")
+			}
+			folderPaths = getFolderTreePaths(tree)
+		}
+
+		auth := models.ExpandSecretsAuthentication{InfisicalToken: params.InfisicalToken, UniversalAuthAccessToken: params.UniversalAuthAccessToken}
+
+		records := []secretLintRecord{}
+		for _, folderPath := range folderPaths {
+			folderParams := params
+			folderParams.SecretsPath = folderPath
+			folderParams.IncludeImport = includeImports
+
+			secrets, err := util.GetAllEnvironmentVariables(folderParams, "")
+			if err != nil {
+				util.HandleError(err, fmt.Sprintf("Unable to get the secrets of %s", folderPath))
+			}
+			secrets = util.OverrideSecrets(secrets, util.SECRET_TYPE_SHARED)
+
+			problems := util.LintSecretReferences(secrets, auth, "", params.WorkspaceId)
+			records = append(records, newSecretLintRecords(folderPath, secrets, problems)...)
+		}
+
+		switch outputFormat {
+		case "json":
+			output, err := json.MarshalIndent(records, "", "  ")
+			if err != nil {
+				util.HandleError(err, "Unable to marshal the problems to JSON")
+			}
+			fmt.Println(string(output))
+		default:
+			if len(records) == 0 {
+				util.PrintSuccessMessage(fmt.Sprintf("No broken references found in %d folder(s) of %s", len(folderPaths), params.Environment))
+				break
+			}
+
+			rows := [][3]string{}
+			for _, record := range records {
+				rows = append(rows, [3]string{record.Path, record.Secret, record.Problem})
+			}
+			visualize.Table([3]string{"PATH", "SECRET", "PROBLEM"}, rows)
+		}
+
+		Telemetry.CaptureEvent("cli-command:secrets lint", posthog.NewProperties().Set("folderCount", len(folderPaths)).Set("problemCount", len(records)).Set("version", util.CLI_VERSION))
+
+		if len(records) > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	secretsLintCmd.Flags().String("path", "/", "the folder whose secrets are checked")
+	secretsLintCmd.Flags().Bool("recursive", true, "Check the secrets of all sub-folders too, each folder resolves its references on its own")
+	secretsLintCmd.Flags().Bool("include-imports", true, "Resolve references to imported secrets. Problems in the imported secrets themselves are reported for the folder they are stored in")
+	secretsLintCmd.Flags().String("token", "", "Fetch secrets using service token or machine identity access token")
+	secretsLintCmd.Flags().String("projectId", "", "manually set the project ID when using machine identity based auth")
+	secretsLintCmd.Flags().StringP("output", "o", "table", "The output format: table or json")
+	secretsCmd.AddCommand(secretsLintCmd)
+}
+
+// secretLintRecord is a reference problem of a folder, it holds no secret value
+type secretLintRecord struct {
+	Path      string   `json:"path"`
+	Secret    string   `json:"secret"`
+	Reference string   `json:"reference"`
+	Cycle     []string `json:"cycle,omitempty"`
+	Problem   string   `json:"problem"`
+}
+
+// newSecretLintRecords returns the problems of a folder, without the problems of imported secrets: those belong to the
+// folder the secrets are imported from
+func newSecretLintRecords(folderPath string, secrets []models.SingleEnvironmentVariable, problems []util.SecretReferenceProblem) []secretLintRecord {
+	importedKeys := map[string]bool{}
+	for _, secret := range secrets {
+		if secret.IsImported {
+			importedKeys[secret.Key] = true
+		}
+	}
+
+	records := []secretLintRecord{}
+	for _, problem := range problems {
+		isImported := importedKeys[problem.Secret]
+		for _, node := range problem.Cycle {
+			isImported = isImported && importedKeys[node]
+		}
+
+		if isImported {
+			continue
+		}
+
+		problemText := fmt.Sprintf("${%s} does not exist", problem.Reference)
+		if len(problem.Cycle) > 0 {
+			problemText = fmt.Sprintf("reference cycle %s", strings.Join(problem.Cycle, " -> "))
+		} else if problem.FetchError != nil {
+			problemText = fmt.Sprintf("the folder of ${%s} could not be fetched", problem.Reference)
+		} else if problem.NestedDefault {
+			problemText = fmt.Sprintf("the default value of ${%s} holds a reference, which is not supported", problem.Reference)
+		}
+
+		records = append(records, secretLintRecord{Path: folderPath, Secret: problem.Secret, Reference: problem.Reference, Cycle: problem.Cycle, Problem: problemText})
+	}
+
+	return records
+}
+
+func getFolderTreePaths(tree models.FolderTree) []string {
+	paths := []string{tree.Path}
+	for _, folder := range tree.Folders {
+		paths = append(paths, getFolderTreePaths(folder)...)
+	}
+	return paths
+}
@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Infisical/infisical-merge/packages/models"
+	"github.com/Infisical/infisical-merge/packages/util"
+)
+
+func TestNewSecretLintRecords(t *testing.T) {
+	secrets := []models.SingleEnvironmentVariable{
+		{Key: "API_URL", Value: "super-secret"},
+		{Key: "SHARED", IsImported: true},
+		{Key: "OTHER", IsImported: true},
+	}
+
+	problems := []util.SecretReferenceProblem{
+		{Secret: "API_URL", Reference: "HOST"},
+		{Secret: "SHARED", Reference: "HOST"},
+		{Secret: "SHARED", Reference: "OTHER", Cycle: []string{"SHARED", "OTHER", "SHARED"}},
+		{Secret: "API_URL", Reference: "SHARED", Cycle: []string{"API_URL", "SHARED", "API_URL"}},
+		{Secret: "API_URL", Reference: "prod.db.URL", FetchError: errors.New("forbidden")},
+	}
+
+	records := newSecretLintRecords("/api", secrets, problems)
+
+	expected := []string{
+		"${HOST} does not exist",
+		"reference cycle API_URL -> SHARED -> API_URL",
+		"the folder of ${prod.db.URL} could not be fetched",
+	}
+
+	if len(records) != len(expected) {
+		t.Fatalf("expected the problems of imported secrets to be skipped, got %+v", records)
+	}
+
+	for i, record := range records {
+		if record.Problem != expected[i] || record.Path != "/api" {
+			t.Errorf("unexpected record %+v", record)
+		}
+	}
+}
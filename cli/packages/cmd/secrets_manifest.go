@@ -0,0 +1,532 @@
+/*
+Copyright (c) 2023 Infisical Inc.
+*/
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/Infisical/infisical-merge/packages/models"
+	"github.com/Infisical/infisical-merge/packages/util"
+	"github.com/fatih/color"
+	"github.com/posthog/posthog-go"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+	"gopkg.in/yaml.v2"
+)
+
+const DEFAULT_SECRETS_MANIFEST_FILE = "secrets-manifest.yaml"
+
+// where the value of a secret created from the manifest comes from
+const (
+	SECRET_MANIFEST_VALUE_UNSET    = "unset"
+	SECRET_MANIFEST_VALUE_PROMPT   = "prompt"
+	SECRET_MANIFEST_VALUE_GENERATE = "generate"
+)
+
+// the actions of a manifest plan
+const (
+	SECRET_MANIFEST_CREATE = "create"
+	SECRET_MANIFEST_UPDATE = "update"
+	SECRET_MANIFEST_DELETE = "delete"
+)
+
+var secretsPlanCmd = &cobra.Command{
+	Example:               `infisical secrets plan -f secrets-manifest.yaml`,
+	Short:                 "Used to show the changes needed to make the secrets match a manifest",
+	Use:                   "plan",
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		runSecretsManifest(cmd, false)
+	},
+}
+
+var secretsApplyCmd = &cobra.Command{
+	Example:               `infisical secrets apply -f secrets-manifest.yaml`,
+	Short:                 "Used to change the secrets so they match a manifest",
+	Use:                   "apply",
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		runSecretsManifest(cmd, true)
+	},
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{secretsPlanCmd, secretsApplyCmd} {
+		cmd.Flags().StringP("file", "f", DEFAULT_SECRETS_MANIFEST_FILE, "The manifest describing the secrets, - reads it from stdin")
+		cmd.Flags().String("token", "", "Manage secrets using service token or machine identity access token")
+		cmd.Flags().String("projectId", "", "manually set the project ID when using machine identity based auth")
+		secretsCmd.AddCommand(cmd)
+	}
+	secretsApplyCmd.Flags().BoolP("yes", "y", false, "Apply the changes without asking for confirmation")
+}
+
+// secretsManifest describes which secrets exist in which environments and folders, the values stay in Infisical
+type secretsManifest struct {
+	// the environments of secrets that do not list their own
+	Environments []string `yaml:"environments"`
+	// delete the secrets of the folders in the manifest that the manifest does not list
+	Prune   bool                    `yaml:"prune"`
+	Secrets []secretsManifestSecret `yaml:"secrets"`
+}
+
+type secretsManifestSecret struct {
+	Key          string   `yaml:"key"`
+	Path         string   `yaml:"path"`
+	Environments []string `yaml:"environments"`
+	// an empty comment leaves the comment as it is
+	Comment string `yaml:"comment"`
+	// nil leaves the tags as they are, an empty list removes all tags
+	Tags *[]string `yaml:"tags"`
+	// the source of the value when the secret is created: unset, prompt or generate
+	Value    string                   `yaml:"value"`
+	Generate secretsManifestGenerator `yaml:"generate"`
+}
+
+// secretsManifestGenerator holds the options of util.GenerateSecretValue
+type secretsManifestGenerator struct {
+	Format    string   `yaml:"format"`
+	Length    int      `yaml:"length"`
+	Charsets  []string `yaml:"charsets"`
+	Exclude   string   `yaml:"exclude"`
+	Separator string   `yaml:"separator"`
+}
+
+func (generator secretsManifestGenerator) options() util.SecretGeneratorOptions {
+	return util.SecretGeneratorOptions{
+		Format:    generator.Format,
+		Length:    generator.Length,
+		Charsets:  generator.Charsets,
+		Exclude:   generator.Exclude,
+		Separator: generator.Separator,
+	}
+}
+
+type secretsManifestScope struct {
+	Environment string
+	Path        string
+}
+
+func (scope secretsManifestScope) String() string {
+	return scope.Environment + ":" + scope.Path
+}
+
+// secretsManifestEntry is a secret of the manifest in one of its environments
+type secretsManifestEntry struct {
+	Scope  secretsManifestScope
+	Secret secretsManifestSecret
+}
+
+type secretsManifestChange struct {
+	Action string
+	Scope  secretsManifestScope
+	Key    string
+	// the current value, kept when only the comment changes
+	Value string
+	// the manifest secret of creates and updates
+	Secret          secretsManifestSecret
+	PreviousComment string
+	CommentChanged  bool
+	TagsToAdd       []string
+	TagsToRemove    []string
+}
+
+// parseSecretsManifest reads the manifest and expands its secrets to one entry per environment
+func parseSecretsManifest(data []byte) (secretsManifest, []secretsManifestEntry, error) {
+	var manifest secretsManifest
+	if err := yaml.UnmarshalStrict(data, &manifest); err != nil {
+		return secretsManifest{}, nil, fmt.Errorf("unable to parse the manifest [err=%v]", err)
+	}
+
+	entries := []secretsManifestEntry{}
+	seen := map[string]bool{}
+	for i, secret := range manifest.Secrets {
+		if secret.Key == "" {
+			return secretsManifest{}, nil, fmt.Errorf("secret %d of the manifest has no key", i+1)
+		}
+
+		if secret.Path == "" {
+			secret.Path = "/"
+		}
+		if !strings.HasPrefix(secret.Path, "/") {
+			return secretsManifest{}, nil, fmt.Errorf("the path of %s must start with /", secret.Key)
+		}
+		secret.Path = path.Clean(secret.Path)
+
+		if secret.Value == "" {
+			secret.Value = SECRET_MANIFEST_VALUE_UNSET
+		}
+		if secret.Value != SECRET_MANIFEST_VALUE_UNSET && secret.Value != SECRET_MANIFEST_VALUE_PROMPT && secret.Value != SECRET_MANIFEST_VALUE_GENERATE {
+			return secretsManifest{}, nil, fmt.Errorf("the value of %s must be one of unset, prompt or generate, got %s", secret.Key, secret.Value)
+		}
+		if secret.Value == SECRET_MANIFEST_VALUE_GENERATE {
+			if _, err := util.GenerateSecretValue(secret.Generate.options()); err != nil {
+				return secretsManifest{}, nil, fmt.Errorf("unable to generate the value of %s [err=%v]", secret.Key, err)
+			}
+		}
+
+		environments := secret.Environments
+		if len(environments) == 0 {
+			environments = manifest.Environments
+		}
+		if len(environments) == 0 {
+			return secretsManifest{}, nil, fmt.Errorf("%s has no environments, list them on the secret or at the top of the manifest", secret.Key)
+		}
+
+		for _, environment := range environments {
+			scope := secretsManifestScope{Environment: environment, Path: secret.Path}
+			name := scope.String() + "/" + secret.Key
+			if seen[name] {
+				return secretsManifest{}, nil, fmt.Errorf("%s is listed more than once in %s", secret.Key, scope)
+			}
+			seen[name] = true
+
+			entries = append(entries, secretsManifestEntry{Scope: scope, Secret: secret})
+		}
+	}
+
+	return manifest, entries, nil
+}
+
+// secretsManifestScopes returns the environments and folders of the entries in a stable order
+func secretsManifestScopes(entries []secretsManifestEntry) []secretsManifestScope {
+	scopes := []secretsManifestScope{}
+	for _, entry := range entries {
+		if !slices.Contains(scopes, entry.Scope) {
+			scopes = append(scopes, entry.Scope)
+		}
+	}
+
+	sort.Slice(scopes, func(i, j int) bool {
+		return scopes[i].String() < scopes[j].String()
+	})
+
+	return scopes
+}
+
+// planSecretsManifest compares the manifest with the shared secrets of its folders. Values of existing secrets are never
+// changed, only their comments and tags.
+func planSecretsManifest(entries []secretsManifestEntry, live map[secretsManifestScope][]models.SingleEnvironmentVariable, prune bool) []secretsManifestChange {
+	changes := []secretsManifestChange{}
+
+	for _, scope := range secretsManifestScopes(entries) {
+		existing := map[string]models.SingleEnvironmentVariable{}
+		for _, secret := range live[scope] {
+			existing[secret.Key] = secret
+		}
+
+		listed := map[string]bool{}
+		scopeChanges := []secretsManifestChange{}
+		for _, entry := range entries {
+			if entry.Scope != scope {
+				continue
+			}
+			listed[entry.Secret.Key] = true
+
+			secret, exists := existing[entry.Secret.Key]
+			if !exists {
+				change := secretsManifestChange{Action: SECRET_MANIFEST_CREATE, Scope: scope, Key: entry.Secret.Key, Secret: entry.Secret}
+				if entry.Secret.Tags != nil {
+					change.TagsToAdd = append(change.TagsToAdd, *entry.Secret.Tags...)
+				}
+				scopeChanges = append(scopeChanges, change)
+				continue
+			}
+
+			change := secretsManifestChange{Action: SECRET_MANIFEST_UPDATE, Scope: scope, Key: secret.Key, Value: secret.Value, Secret: entry.Secret, PreviousComment: secret.Comment}
+			change.CommentChanged = entry.Secret.Comment != "" && entry.Secret.Comment != secret.Comment
+
+			if entry.Secret.Tags != nil {
+				currentTags := []string{}
+				for _, tag := range secret.Tags {
+					currentTags = append(currentTags, tag.Slug)
+					if !slices.Contains(*entry.Secret.Tags, tag.Slug) {
+						change.TagsToRemove = append(change.TagsToRemove, tag.Slug)
+					}
+				}
+				for _, tag := range *entry.Secret.Tags {
+					if !slices.Contains(currentTags, tag) {
+						change.TagsToAdd = append(change.TagsToAdd, tag)
+					}
+				}
+			}
+
+			if change.CommentChanged || len(change.TagsToAdd) > 0 || len(change.TagsToRemove) > 0 {
+				scopeChanges = append(scopeChanges, change)
+			}
+		}
+
+		if prune {
+			for _, secret := range live[scope] {
+				if !listed[secret.Key] {
+					scopeChanges = append(scopeChanges, secretsManifestChange{Action: SECRET_MANIFEST_DELETE, Scope: scope, Key: secret.Key, Value: secret.Value})
+				}
+			}
+		}
+
+		sort.SliceStable(scopeChanges, func(i, j int) bool {
+			return scopeChanges[i].Key < scopeChanges[j].Key
+		})
+		changes = append(changes, scopeChanges...)
+	}
+
+	return changes
+}
+
+func printSecretsManifestPlan(out io.Writer, changes []secretsManifestChange) {
+	if len(changes) == 0 {
+		fmt.Fprintln(out, "No changes. The secrets match the manifest.")
+		return
+	}
+
+	fmt.Fprintln(out, "Infisical will perform the following actions:")
+	fmt.Fprintln(out)
+
+	counts := map[string]int{}
+	for _, change := range changes {
+		counts[change.Action]++
+		name := change.Scope.Environment + ":" + path.Join(change.Scope.Path, change.Key)
+
+		switch change.Action {
+		case SECRET_MANIFEST_CREATE:
+			fmt.Fprintf(out, "  %s %s\n", color.GreenString("+"), name)
+			fmt.Fprintf(out, "      value:   (%s)\n", change.Secret.Value)
+			if change.Secret.Comment != "" {
+				fmt.Fprintf(out, "      comment: %q\n", change.Secret.Comment)
+			}
+			if len(change.TagsToAdd) > 0 {
+				fmt.Fprintf(out, "      tags:    %s\n", strings.Join(change.TagsToAdd, ", "))
+			}
+		case SECRET_MANIFEST_UPDATE:
+			fmt.Fprintf(out, "  %s %s\n", color.YellowString("~"), name)
+			if change.CommentChanged {
+				fmt.Fprintf(out, "      comment: %q -> %q\n", change.PreviousComment, change.Secret.Comment)
+			}
+			if len(change.TagsToAdd) > 0 || len(change.TagsToRemove) > 0 {
+				tagChanges := []string{}
+				for _, tag := range change.TagsToAdd {
+					tagChanges = append(tagChanges, "+"+tag)
+				}
+				for _, tag := range change.TagsToRemove {
+					tagChanges = append(tagChanges, "-"+tag)
+				}
+				fmt.Fprintf(out, "      tags:    %s\n", strings.Join(tagChanges, " "))
+			}
+		case SECRET_MANIFEST_DELETE:
+			fmt.Fprintf(out, "  %s %s\n", color.RedString("-"), name)
+		}
+	}
+
+	fmt.Fprintln(out)
+	fmt.Fprintf(out, "Plan: %d to create, %d to update, %d to delete.\n", counts[SECRET_MANIFEST_CREATE], counts[SECRET_MANIFEST_UPDATE], counts[SECRET_MANIFEST_DELETE])
+}
+
+func runSecretsManifest(cmd *cobra.Command, apply bool) {
+	token, err := util.GetInfisicalToken(cmd)
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+
+	projectId, err := cmd.Flags().GetString("projectId")
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+
+	manifestFile, err := cmd.Flags().GetString("file")
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+
+	skipConfirmation := false
+	if apply {
+		skipConfirmation, err = cmd.Flags().GetBool("yes")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+	}
+
+	data, err := readImportFile(manifestFile)
+	if err != nil {
+		util.HandleError(err, "Unable to read the manifest")
+	}
+
+	manifest, entries, err := parseSecretsManifest(data)
+	if err != nil {
+		util.HandleError(err)
+	}
+
+	params := models.GetAllSecretsParameters{WorkspaceId: projectId}
+	if token != nil && token.Type == util.SERVICE_TOKEN_IDENTIFIER {
+		params.InfisicalToken = token.Token
+	} else if token != nil && token.Type == util.UNIVERSAL_AUTH_TOKEN_IDENTIFIER {
+		params.UniversalAuthAccessToken = token.Token
+	}
+
+	live := map[secretsManifestScope][]models.SingleEnvironmentVariable{}
+	for _, scope := range secretsManifestScopes(entries) {
+		scopeParams := params
+		scopeParams.Environment = scope.Environment
+		scopeParams.SecretsPath = scope.Path
+
+		secrets, err := util.GetAllEnvironmentVariables(scopeParams, "")
+		if err != nil {
+			util.HandleError(err, fmt.Sprintf("Unable to fetch the secrets of %s", scope))
+		}
+		live[scope] = addManagedSecretTags(scopeParams, filterSecretsByType(secrets, util.SECRET_TYPE_SHARED), entries, scope)
+	}
+
+	changes := planSecretsManifest(entries, live, manifest.Prune)
+	printSecretsManifestPlan(os.Stdout, changes)
+
+	if !apply {
+		Telemetry.CaptureEvent("cli-command:secrets plan", posthog.NewProperties().Set("changeCount", len(changes)).Set("version", util.CLI_VERSION))
+		return
+	}
+
+	if len(changes) == 0 {
+		return
+	}
+
+	if params.InfisicalToken != "" && slices.ContainsFunc(changes, func(change secretsManifestChange) bool {
+		return len(change.TagsToAdd) > 0 || len(change.TagsToRemove) > 0
+	}) {
+		util.PrintErrorMessageAndExit("The plan changes tags, which cannot be done with a service token. Log in or use a machine identity instead")
+	}
+
+	if !skipConfirmation {
+		// stdin holds the manifest when it is read from -, so the confirmation cannot be read from it
+		if manifestFile == "-" || !term.IsTerminal(int(os.Stdin.Fd())) {
+			util.PrintErrorMessageAndExit("Unable to ask for confirmation when not running in a terminal, use --yes to apply the changes")
+		}
+
+		shouldApply, err := shouldApplyImportPrompt()
+		if err != nil {
+			util.HandleError(err, "Unable to read your answer")
+		}
+		if !shouldApply {
+			fmt.Println("No secrets were changed")
+			return
+		}
+	}
+
+	// all values are known before anything is changed, so a cancelled prompt leaves the secrets as they were
+	values := map[string]string{}
+	for _, change := range changes {
+		if change.Action == SECRET_MANIFEST_CREATE {
+			value, err := resolveManifestSecretValue(change)
+			if err != nil {
+				util.HandleError(err)
+			}
+			values[change.Scope.String()+"/"+change.Key] = value
+		}
+	}
+
+	for _, scope := range secretsManifestScopes(entries) {
+		scopeParams := params
+		scopeParams.Environment = scope.Environment
+		scopeParams.SecretsPath = scope.Path
+
+		secretsToSet := []models.SingleEnvironmentVariable{}
+		secretsToDelete := []string{}
+		for _, change := range changes {
+			if change.Scope != scope {
+				continue
+			}
+
+			switch {
+			case change.Action == SECRET_MANIFEST_CREATE:
+				secretsToSet = append(secretsToSet, models.SingleEnvironmentVariable{Key: change.Key, Value: values[scope.String()+"/"+change.Key], Comment: change.Secret.Comment})
+			case change.Action == SECRET_MANIFEST_UPDATE && change.CommentChanged:
+				secretsToSet = append(secretsToSet, models.SingleEnvironmentVariable{Key: change.Key, Value: change.Value, Comment: change.Secret.Comment})
+			case change.Action == SECRET_MANIFEST_DELETE:
+				secretsToDelete = append(secretsToDelete, change.Key)
+			}
+		}
+
+		if len(secretsToSet) > 0 {
+			if token != nil && (token.Type == util.SERVICE_TOKEN_IDENTIFIER || token.Type == util.UNIVERSAL_AUTH_TOKEN_IDENTIFIER) {
+				_, err = util.SetRawSecretsFromList(secretsToSet, util.SECRET_TYPE_SHARED, scope.Environment, scope.Path, projectId, token, nil)
+			} else {
+				util.RequireLogin()
+				util.RequireLocalWorkspaceFile()
+
+				_, err = util.SetEncryptedSecretsFromList(secretsToSet, util.SECRET_TYPE_SHARED, scope.Environment, scope.Path, nil)
+			}
+
+			if err != nil {
+				util.HandleError(err, fmt.Sprintf("Unable to set the secrets of %s", scope))
+			}
+		}
+
+		for _, change := range changes {
+			if change.Scope != scope {
+				continue
+			}
+
+			if len(change.TagsToAdd) > 0 {
+				if err := util.AddSecretTags(scopeParams, []string{change.Key}, util.SECRET_TYPE_SHARED, change.TagsToAdd); err != nil {
+					util.HandleError(err, fmt.Sprintf("Unable to add the tags of %s", change.Key))
+				}
+			}
+
+			if len(change.TagsToRemove) > 0 {
+				if err := util.RemoveSecretTags(scopeParams, []string{change.Key}, util.SECRET_TYPE_SHARED, change.TagsToRemove); err != nil {
+					util.HandleError(err, fmt.Sprintf("Unable to remove the tags of %s", change.Key))
+				}
+			}
+		}
+
+		if len(secretsToDelete) > 0 {
+			if err := util.DeleteSecrets(scopeParams, util.SECRET_TYPE_SHARED, secretsToDelete); err != nil {
+				util.HandleError(err, fmt.Sprintf("Unable to delete the secrets of %s", scope))
+			}
+		}
+	}
+
+	util.PrintSuccessMessage("The secrets match the manifest")
+	Telemetry.CaptureEvent("cli-command:secrets apply", posthog.NewProperties().Set("changeCount", len(changes)).Set("version", util.CLI_VERSION))
+}
+
+func resolveManifestSecretValue(change secretsManifestChange) (string, error) {
+	switch change.Secret.Value {
+	case SECRET_MANIFEST_VALUE_PROMPT:
+		return promptForSecretValue(change.Scope.Environment + ":" + path.Join(change.Scope.Path, change.Key))
+	case SECRET_MANIFEST_VALUE_GENERATE:
+		return util.GenerateSecretValue(change.Secret.Generate.options())
+	default:
+		return "", nil
+	}
+}
+
+// addManagedSecretTags fetches the tags of the existing secrets whose tags the manifest sets, since secrets listed with
+// a token come without their tags
+func addManagedSecretTags(params models.GetAllSecretsParameters, secrets []models.SingleEnvironmentVariable, entries []secretsManifestEntry, scope secretsManifestScope) []models.SingleEnvironmentVariable {
+	if params.InfisicalToken == "" && params.UniversalAuthAccessToken == "" {
+		return secrets
+	}
+
+	for i, secret := range secrets {
+		managesTags := slices.ContainsFunc(entries, func(entry secretsManifestEntry) bool {
+			return entry.Scope == scope && entry.Secret.Key == secret.Key && entry.Secret.Tags != nil
+		})
+		if !managesTags {
+			continue
+		}
+
+		details, err := util.GetSecretDetails(params, secret.Key, secret.Type)
+		if err != nil {
+			util.HandleError(err, fmt.Sprintf("Unable to get the tags of %s in %s", secret.Key, scope))
+		}
+		secrets[i].Tags = details.Tags
+	}
+
+	return secrets
+}
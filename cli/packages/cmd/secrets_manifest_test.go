@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/Infisical/infisical-merge/packages/models"
+)
+
+const testSecretsManifest = `
+environments: [dev, prod]
+prune: true
+secrets:
+  - key: DB_PASSWORD
+    path: /database/
+    comment: Database password
+    tags: [database]
+    value: generate
+    generate:
+      length: 16
+  - key: API_URL
+    environments: [prod]
+`
+
+func TestParseSecretsManifest(t *testing.T) {
+	manifest, entries, err := parseSecretsManifest([]byte(testSecretsManifest))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !manifest.Prune || len(entries) != 3 {
+		t.Fatalf("expected 3 entries with prune, got %d entries and prune %v", len(entries), manifest.Prune)
+	}
+
+	if entries[0].Scope != (secretsManifestScope{Environment: "dev", Path: "/database"}) || entries[2].Scope != (secretsManifestScope{Environment: "prod", Path: "/"}) {
+		t.Errorf("unexpected scopes %+v and %+v", entries[0].Scope, entries[2].Scope)
+	}
+
+	if entries[2].Secret.Value != SECRET_MANIFEST_VALUE_UNSET || entries[2].Secret.Tags != nil {
+		t.Errorf("expected API_URL to be unset with unmanaged tags, got %+v", entries[2].Secret)
+	}
+
+	invalid := map[string]string{
+		"unknown field": "secrets:\n  - key: A\n    environments: [dev]\n    tag: [x]\n",
+		"value source":  "secrets:\n  - key: A\n    environments: [dev]\n    value: random\n",
+		"environments":  "secrets:\n  - key: A\n",
+		"generator":     "secrets:\n  - key: A\n    environments: [dev]\n    value: generate\n    generate:\n      charsets: [emoji]\n",
+		"duplicate":     "environments: [dev]\nsecrets:\n  - key: A\n  - key: A\n    path: /\n",
+	}
+	for name, manifest := range invalid {
+		if _, _, err := parseSecretsManifest([]byte(manifest)); err == nil {
+			t.Errorf("expected an error for the %s manifest", name)
+		}
+	}
+}
+
+func TestPlanSecretsManifest(t *testing.T) {
+	_, entries, err := parseSecretsManifest([]byte(testSecretsManifest))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	existingPassword := models.SingleEnvironmentVariable{Key: "DB_PASSWORD", Value: "secret", Comment: "old"}
+	existingPassword.Tags = make([]struct {
+		ID        string `json:"_id"`
+		Name      string `json:"name"`
+		Slug      string `json:"slug"`
+		Workspace string `json:"workspace"`
+	}, 1)
+	existingPassword.Tags[0].Slug = "legacy"
+
+	live := map[secretsManifestScope][]models.SingleEnvironmentVariable{
+		{Environment: "prod", Path: "/database"}: {existingPassword, {Key: "OLD", Value: "x"}},
+		{Environment: "prod", Path: "/"}:         {{Key: "API_URL", Value: "https://example.com"}},
+	}
+
+	changes := planSecretsManifest(entries, live, true)
+
+	got := []string{}
+	for _, change := range changes {
+		got = append(got, change.Action+" "+change.Scope.String()+"/"+change.Key)
+	}
+
+	expected := []string{
+		"create dev:/database/DB_PASSWORD",
+		"update prod:/database/DB_PASSWORD",
+		"delete prod:/database/OLD",
+	}
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("planSecretsManifest() = %v, expected %v", got, expected)
+	}
+
+	update := changes[1]
+	if !update.CommentChanged || update.Value != "secret" || strings.Join(update.TagsToAdd, ",") != "database" || strings.Join(update.TagsToRemove, ",") != "legacy" {
+		t.Errorf("unexpected update %+v", update)
+	}
+
+	var out bytes.Buffer
+	printSecretsManifestPlan(&out, changes)
+	if !strings.Contains(out.String(), "Plan: 1 to create, 1 to update, 1 to delete.") || strings.Contains(out.String(), "secret\n") {
+		t.Errorf("unexpected plan output:\n%s", out.String())
+	}
+}
@@ -0,0 +1,222 @@
+/*
+Copyright (c) 2023 Infisical Inc.
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/Infisical/infisical-merge/packages/models"
+	"github.com/Infisical/infisical-merge/packages/util"
+	"github.com/posthog/posthog-go"
+	"github.com/spf13/cobra"
+)
+
+var secretsTagCmd = &cobra.Command{
+	Example:               `infisical secrets tag add DB_PASSWORD --tags=production,database`,
+	Short:                 "Used to add tags to secrets or remove tags from them",
+	Use:                   "tag",
+	DisableFlagsInUseLine: true,
+}
+
+var secretsTagAddCmd = &cobra.Command{
+	Example:               `infisical secrets tag add DB_PASSWORD DB_USER --tags=production,database`,
+	Short:                 "Used to add tags to secrets",
+	Use:                   "add [secrets]",
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		params, secretType, tagSlugs := getSecretTagsFlags(cmd)
+
+		if err := util.AddSecretTags(params, args, secretType, tagSlugs); err != nil {
+			util.HandleError(err, "Unable to add the tags")
+		}
+
+		util.PrintSuccessMessage(fmt.Sprintf("Added the tags %s to %s", strings.Join(tagSlugs, ", "), strings.Join(args, ", ")))
+
+		Telemetry.CaptureEvent("cli-command:secrets tag add", posthog.NewProperties().Set("secretCount", len(args)).Set("version", util.CLI_VERSION))
+	},
+}
+
+var secretsTagRemoveCmd = &cobra.Command{
+	Example:               `infisical secrets tag remove DB_PASSWORD --tags=production`,
+	Short:                 "Used to remove tags from secrets",
+	Use:                   "remove [secrets]",
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		params, secretType, tagSlugs := getSecretTagsFlags(cmd)
+
+		if err := util.RemoveSecretTags(params, args, secretType, tagSlugs); err != nil {
+			util.HandleError(err, "Unable to remove the tags")
+		}
+
+		util.PrintSuccessMessage(fmt.Sprintf("Removed the tags %s from %s", strings.Join(tagSlugs, ", "), strings.Join(args, ", ")))
+
+		Telemetry.CaptureEvent("cli-command:secrets tag remove", posthog.NewProperties().Set("secretCount", len(args)).Set("version", util.CLI_VERSION))
+	},
+}
+
+var secretsDescribeCmd = &cobra.Command{
+	Example:               `infisical secrets describe DB_PASSWORD --output=json`,
+	Short:                 "Used to show the type, comment and tags of a secret",
+	Use:                   "describe [secret]",
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		params := getSecretMetadataParams(cmd)
+
+		secretType, err := cmd.Flags().GetString("type")
+		if err != nil || (secretType != util.SECRET_TYPE_SHARED && secretType != util.SECRET_TYPE_PERSONAL) {
+			util.HandleError(err, "Unable to parse secret type")
+		}
+
+		outputFormat, err := cmd.Flags().GetString("output")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		showValue, err := cmd.Flags().GetBool("show-value")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		secret, err := util.GetSecretDetails(params, args[0], secretType)
+		if err != nil {
+			util.HandleError(err, "Unable to get the secret")
+		}
+
+		output, err := formatSecretDescription(secret, outputFormat, showValue)
+		if err != nil {
+			util.HandleError(err)
+		}
+		fmt.Print(output)
+
+		Telemetry.CaptureEvent("cli-command:secrets describe", posthog.NewProperties().Set("version", util.CLI_VERSION))
+	},
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{secretsTagAddCmd, secretsTagRemoveCmd} {
+		cmd.Flags().String("tags", "", "The comma separated slugs of the tags")
+		cmd.MarkFlagRequired("tags")
+		cmd.Flags().String("type", util.SECRET_TYPE_SHARED, "the type of the secrets: personal or shared")
+		cmd.Flags().String("token", "", "Change tags using a machine identity access token")
+		cmd.Flags().String("projectId", "", "manually set the project ID of the secrets when using machine identity based auth")
+		cmd.Flags().String("path", "/", "the folder path of the secrets")
+		secretsTagCmd.AddCommand(cmd)
+	}
+	secretsCmd.AddCommand(secretsTagCmd)
+
+	secretsDescribeCmd.Flags().String("type", util.SECRET_TYPE_SHARED, "the type of the secret: personal or shared")
+	secretsDescribeCmd.Flags().String("token", "", "Fetch the secret using service token or machine identity access token")
+	secretsDescribeCmd.Flags().String("projectId", "", "manually set the project ID of the secret when using machine identity based auth")
+	secretsDescribeCmd.Flags().String("path", "/", "the folder path of the secret")
+	secretsDescribeCmd.Flags().StringP("output", "o", "table", "The output format: table or json")
+	secretsDescribeCmd.Flags().Bool("show-value", false, "Include the value of the secret")
+	secretsCmd.AddCommand(secretsDescribeCmd)
+}
+
+func getSecretMetadataParams(cmd *cobra.Command) models.GetAllSecretsParameters {
+	environmentName, _ := cmd.Flags().GetString("env")
+	if !cmd.Flags().Changed("env") {
+		environmentFromWorkspace := util.GetEnvFromWorkspaceFile()
+		if environmentFromWorkspace != "" {
+			environmentName = environmentFromWorkspace
+		}
+	}
+
+	token, err := util.GetInfisicalToken(cmd)
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+
+	projectId, err := cmd.Flags().GetString("projectId")
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+
+	secretsPath, err := cmd.Flags().GetString("path")
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+
+	params := models.GetAllSecretsParameters{
+		Environment: environmentName,
+		WorkspaceId: projectId,
+		SecretsPath: secretsPath,
+	}
+
+	if token != nil && token.Type == util.SERVICE_TOKEN_IDENTIFIER {
+		params.InfisicalToken = token.Token
+	} else if token != nil && token.Type == util.UNIVERSAL_AUTH_TOKEN_IDENTIFIER {
+		params.UniversalAuthAccessToken = token.Token
+	}
+
+	return params
+}
+
+func getSecretTagsFlags(cmd *cobra.Command) (models.GetAllSecretsParameters, string, []string) {
+	params := getSecretMetadataParams(cmd)
+
+	secretType, err := cmd.Flags().GetString("type")
+	if err != nil || (secretType != util.SECRET_TYPE_SHARED && secretType != util.SECRET_TYPE_PERSONAL) {
+		util.HandleError(err, "Unable to parse secret type")
+	}
+
+	tags, err := cmd.Flags().GetString("tags")
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+
+	tagSlugs := parseTagSlugs(tags)
+	if len(tagSlugs) == 0 {
+		util.PrintErrorMessageAndExit("Provide at least one tag slug with --tags")
+	}
+
+	return params, secretType, tagSlugs
+}
+
+// parseTagSlugs splits comma separated tag slugs, ignoring empty ones
+func parseTagSlugs(tags string) []string {
+	tagSlugs := []string{}
+	for _, slug := range strings.Split(tags, ",") {
+		if slug = strings.TrimSpace(slug); slug != "" {
+			tagSlugs = append(tagSlugs, slug)
+		}
+	}
+
+	return tagSlugs
+}
+
+func formatSecretDescription(secret models.SingleEnvironmentVariable, outputFormat string, showValue bool) (string, error) {
+	record := newExportSecretMetadata(secret, !showValue)
+
+	switch strings.ToLower(outputFormat) {
+	case "json":
+		output, err := json.MarshalIndent(record, "", "  ")
+		if err != nil {
+			return "", fmt.Errorf("unable to marshal the secret to JSON [err=%v]", err)
+		}
+		return string(output) + "\n", nil
+	case "table":
+		var builder strings.Builder
+		writer := tabwriter.NewWriter(&builder, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(writer, "Key:\t%s\n", record.Key)
+		if record.Value != nil {
+			fmt.Fprintf(writer, "Value:\t%s\n", *record.Value)
+		}
+		fmt.Fprintf(writer, "Type:\t%s\n", record.Type)
+		fmt.Fprintf(writer, "Environment:\t%s\n", record.Environment)
+		fmt.Fprintf(writer, "Path:\t%s\n", record.Path)
+		fmt.Fprintf(writer, "Comment:\t%s\n", record.Comment)
+		fmt.Fprintf(writer, "Tags:\t%s\n", strings.Join(record.Tags, ", "))
+		writer.Flush()
+		return builder.String(), nil
+	default:
+		return "", fmt.Errorf("invalid output format: %s. Available formats are [table json]", outputFormat)
+	}
+}
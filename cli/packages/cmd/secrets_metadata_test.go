@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Infisical/infisical-merge/packages/models"
+)
+
+func TestParseTagSlugs(t *testing.T) {
+	if slugs := parseTagSlugs(" production, ,database,"); !reflect.DeepEqual(slugs, []string{"production", "database"}) {
+		t.Errorf("Unexpected tag slugs %v", slugs)
+	}
+}
+
+func TestFormatSecretDescription(t *testing.T) {
+	secret := models.SingleEnvironmentVariable{Key: "DB_PASSWORD", Value: "hunter2", Type: "shared", Environment: "dev", SecretPath: "/db", Comment: "rotated monthly"}
+	secret.Tags = exportMetadataTestSecrets[0].Tags
+
+	output, err := formatSecretDescription(secret, "table", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(output, "hunter2") || !strings.Contains(output, "Comment:      rotated monthly") || !strings.Contains(output, "Path:         /db") {
+		t.Errorf("Unexpected description\n%s", output)
+	}
+
+	output, err = formatSecretDescription(secret, "json", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(output, `"value": "hunter2"`) || !strings.Contains(output, `"comment": "rotated monthly"`) {
+		t.Errorf("Unexpected description\n%s", output)
+	}
+
+	if _, err := formatSecretDescription(secret, "xml", false); err == nil {
+		t.Error("Expected an error for an unknown output format")
+	}
+}
@@ -0,0 +1,191 @@
+/*
+Copyright (c) 2023 Infisical Inc.
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"text/template"
+
+	"github.com/Infisical/infisical-merge/packages/models"
+	"github.com/Infisical/infisical-merge/packages/util"
+	"github.com/Infisical/infisical-merge/packages/visualize"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	SECRETS_OUTPUT_TABLE = "table"
+	// the values only, one per line
+	SECRETS_OUTPUT_PLAIN = "plain"
+)
+
+var secretsOutputFormats = []string{SECRETS_OUTPUT_TABLE, FormatJson, FormatYaml, FormatDotenv, SECRETS_OUTPUT_PLAIN}
+
+// secretOutputRecord is the schema of the json and yaml outputs of secrets and secrets get, and the data of --template.
+// Fields may be added but must not be renamed or removed, scripts depend on them
+type secretOutputRecord struct {
+	Key     string   `json:"key" yaml:"key"`
+	Value   string   `json:"value" yaml:"value"`
+	Type    string   `json:"type" yaml:"type"`
+	Path    string   `json:"path" yaml:"path"`
+	Tags    []string `json:"tags" yaml:"tags"`
+	Comment string   `json:"comment" yaml:"comment"`
+}
+
+func newSecretOutputRecord(secret models.SingleEnvironmentVariable) secretOutputRecord {
+	record := secretOutputRecord{
+		Key:     secret.Key,
+		Value:   secret.Value,
+		Type:    secret.Type,
+		Path:    secret.SecretPath,
+		Tags:    []string{},
+		Comment: secret.Comment,
+	}
+
+	if record.Type == "" {
+		record.Type = util.SECRET_TYPE_SHARED
+	}
+
+	if record.Path == "" {
+		record.Path = "/"
+	}
+
+	for _, tag := range secret.Tags {
+		record.Tags = append(record.Tags, tag.Slug)
+	}
+
+	return record
+}
+
+var secretOutputTemplateFuncs = template.FuncMap{
+	"join": strings.Join,
+	"json": func(value interface{}) (string, error) {
+		output, err := json.Marshal(value)
+		return string(output), err
+	},
+	"quote": util.QuoteDotEnvValue,
+}
+
+// getSecretsOutputFormat reads --output and --template, and maps the deprecated --plain and --raw-value flags to the plain format
+func getSecretsOutputFormat(cmd *cobra.Command) (string, *template.Template, error) {
+	format, err := cmd.Flags().GetString("output")
+	if err != nil {
+		return "", nil, err
+	}
+	format = strings.ToLower(format)
+
+	for _, deprecatedFlag := range []string{"plain", "raw-value"} {
+		if cmd.Flags().Lookup(deprecatedFlag) == nil {
+			continue
+		}
+
+		isSet, err := cmd.Flags().GetBool(deprecatedFlag)
+		if err != nil {
+			return "", nil, err
+		}
+
+		if isSet {
+			if cmd.Flags().Changed("output") && format != SECRETS_OUTPUT_PLAIN {
+				return "", nil, fmt.Errorf("--%s can not be used with --output %s", deprecatedFlag, format)
+			}
+			format = SECRETS_OUTPUT_PLAIN
+		}
+	}
+
+	templateText, err := cmd.Flags().GetString("template")
+	if err != nil {
+		return "", nil, err
+	}
+
+	if templateText != "" {
+		if cmd.Flags().Changed("output") || format == SECRETS_OUTPUT_PLAIN {
+			return "", nil, fmt.Errorf("--template can not be used with another output format")
+		}
+
+		outputTemplate, err := template.New("secret").Funcs(secretOutputTemplateFuncs).Parse(templateText)
+		if err != nil {
+			return "", nil, fmt.Errorf("unable to parse the template [err=%v]", err)
+		}
+		return "", outputTemplate, nil
+	}
+
+	if !slices.Contains(secretsOutputFormats, format) {
+		return "", nil, fmt.Errorf("invalid output format: %s. Available formats are %v", format, secretsOutputFormats)
+	}
+
+	return format, nil, nil
+}
+
+// formatSecretsOutput renders the secrets in one of secretsOutputFormats other than table, or with the template when it is set.
+// The template is executed once per secret, with a secretOutputRecord
+func formatSecretsOutput(secrets []models.SingleEnvironmentVariable, format string, outputTemplate *template.Template) (string, error) {
+	records := make([]secretOutputRecord, 0, len(secrets))
+	for _, secret := range secrets {
+		records = append(records, newSecretOutputRecord(secret))
+	}
+
+	if outputTemplate != nil {
+		var output strings.Builder
+		for _, record := range records {
+			var line strings.Builder
+			if err := outputTemplate.Execute(&line, record); err != nil {
+				return "", fmt.Errorf("unable to render the template for %s [err=%v]", record.Key, err)
+			}
+
+			output.WriteString(line.String())
+			if !strings.HasSuffix(line.String(), "\n") {
+				output.WriteString("\n")
+			}
+		}
+		return output.String(), nil
+	}
+
+	switch format {
+	case FormatJson:
+		output, err := json.MarshalIndent(records, "", "  ")
+		if err != nil {
+			return "", fmt.Errorf("unable to marshal secrets to JSON [err=%v]", err)
+		}
+		return string(output) + "\n", nil
+	case FormatYaml:
+		output, err := yaml.Marshal(records)
+		if err != nil {
+			return "", fmt.Errorf("unable to marshal secrets to YAML [err=%v]", err)
+		}
+		return string(output), nil
+	case FormatDotenv:
+		return formatAsDotEnv(secrets), nil
+	case SECRETS_OUTPUT_PLAIN:
+		var output strings.Builder
+		for _, record := range records {
+			output.WriteString(record.Value + "\n")
+		}
+		return output.String(), nil
+	default:
+		return "", fmt.Errorf("invalid output format: %s. Available formats are %v", format, secretsOutputFormats)
+	}
+}
+
+// printSecretsOutput prints the secrets in the format chosen with getSecretsOutputFormat
+func printSecretsOutput(secrets []models.SingleEnvironmentVariable, format string, outputTemplate *template.Template) {
+	if outputTemplate == nil && format == SECRETS_OUTPUT_TABLE {
+		visualize.PrintAllSecretDetails(secrets)
+		return
+	}
+
+	output, err := formatSecretsOutput(secrets, format, outputTemplate)
+	if err != nil {
+		util.HandleError(err)
+	}
+
+	fmt.Print(output)
+}
+
+func addSecretsOutputFlags(cmd *cobra.Command) {
+	cmd.Flags().StringP("output", "o", SECRETS_OUTPUT_TABLE, "The output format: table, json, yaml, dotenv or plain")
+	cmd.Flags().String("template", "", "A Go template executed for each secret, with the fields .Key, .Value, .Type, .Path, .Tags and .Comment")
+}
@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/Infisical/infisical-merge/packages/models"
+	"github.com/spf13/cobra"
+)
+
+func newSecretsOutputTestCommand(args ...string) *cobra.Command {
+	cmd := &cobra.Command{}
+	cmd.Flags().Bool("plain", false, "")
+	addSecretsOutputFlags(cmd)
+	cmd.Flags().Parse(args)
+	return cmd
+}
+
+func TestGetSecretsOutputFormat(t *testing.T) {
+	format, outputTemplate, err := getSecretsOutputFormat(newSecretsOutputTestCommand("--plain"))
+	if err != nil || format != SECRETS_OUTPUT_PLAIN || outputTemplate != nil {
+		t.Errorf("expected --plain to be an alias of --output plain, got %q %v", format, err)
+	}
+
+	format, _, err = getSecretsOutputFormat(newSecretsOutputTestCommand("-o", "JSON"))
+	if err != nil || format != FormatJson {
+		t.Errorf("expected the json format, got %q %v", format, err)
+	}
+
+	invalid := [][]string{
+		{"-o", "xml"},
+		{"--plain", "-o", "json"},
+		{"--template", "{{.Key}}", "-o", "yaml"},
+		{"--template", "{{.Key"},
+	}
+	for _, args := range invalid {
+		if _, _, err := getSecretsOutputFormat(newSecretsOutputTestCommand(args...)); err == nil {
+			t.Errorf("expected an error for %v", args)
+		}
+	}
+}
+
+func TestFormatSecretsOutput(t *testing.T) {
+	secrets := []models.SingleEnvironmentVariable{
+		{Key: "A", Value: "one two", Type: "shared", SecretPath: "/api", Comment: "first"},
+		{Key: "B", Value: "2"},
+	}
+	secrets[0].Tags = make([]struct {
+		ID        string `json:"_id"`
+		Name      string `json:"name"`
+		Slug      string `json:"slug"`
+		Workspace string `json:"workspace"`
+	}, 1)
+	secrets[0].Tags[0].Slug = "backend"
+
+	output, err := formatSecretsOutput(secrets, FormatJson, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var records []map[string]interface{}
+	if err := json.Unmarshal([]byte(output), &records); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `[{"comment":"first","key":"A","path":"/api","tags":["backend"],"type":"shared","value":"one two"},{"comment":"","key":"B","path":"/","tags":[],"type":"shared","value":"2"}]`
+	if normalized, _ := json.Marshal(records); string(normalized) != expected {
+		t.Errorf("unexpected json output %s", normalized)
+	}
+
+	_, outputTemplate, err := getSecretsOutputFormat(newSecretsOutputTestCommand("--template", `{{.Key}}={{quote .Value}} {{join .Tags ","}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output, err = formatSecretsOutput(secrets, "", outputTemplate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output != "A='one two' backend\nB='2' \n" {
+		t.Errorf("unexpected template output %q", output)
+	}
+}
@@ -0,0 +1,184 @@
+/*
+Copyright (c) 2023 Infisical Inc.
+*/
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Infisical/infisical-merge/packages/models"
+	"github.com/Infisical/infisical-merge/packages/util"
+	"github.com/manifoldco/promptui"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+const (
+	// KEY=@path reads the value of KEY from a file, KEY=@@value sets a value that starts with @
+	SECRET_VALUE_FILE_PREFIX = "@"
+	// KEY=- reads the value of KEY from stdin
+	SECRET_VALUE_STDIN = "-"
+)
+
+// secretValueSources reads secret values that are not given inline
+type secretValueSources struct {
+	stdin      io.Reader
+	readFile   func(filePath string) ([]byte, error)
+	promptFor  func(key string) (string, error)
+	stdinTaken bool
+}
+
+func newSecretValueSources() *secretValueSources {
+	return &secretValueSources{
+		stdin:     os.Stdin,
+		readFile:  os.ReadFile,
+		promptFor: promptForSecretValue,
+	}
+}
+
+// resolveSecretArgs turns the arguments of secrets set into secrets. Besides KEY=VALUE, the value can be read from a
+// file with KEY=@path, from stdin with KEY=- or from a hidden prompt when only KEY is given.
+func resolveSecretArgs(args []string, sources *secretValueSources) ([]models.SingleEnvironmentVariable, error) {
+	secrets := []models.SingleEnvironmentVariable{}
+	for _, arg := range args {
+		key, value, hasValue := strings.Cut(arg, "=")
+		key = strings.TrimSpace(key)
+		if key == "" {
+			return nil, fmt.Errorf("ensure that each secret has a none empty key and value. Modify the input and try again")
+		}
+
+		var err error
+		switch {
+		case !hasValue:
+			value, err = sources.promptFor(key)
+		case value == SECRET_VALUE_STDIN:
+			value, err = sources.readStdin(key)
+		case strings.HasPrefix(value, SECRET_VALUE_FILE_PREFIX+SECRET_VALUE_FILE_PREFIX):
+			value = strings.TrimPrefix(value, SECRET_VALUE_FILE_PREFIX)
+		case strings.HasPrefix(value, SECRET_VALUE_FILE_PREFIX):
+			value, err = sources.readValueFile(key, strings.TrimPrefix(value, SECRET_VALUE_FILE_PREFIX))
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		secrets = append(secrets, models.SingleEnvironmentVariable{Key: key, Value: value})
+	}
+
+	return secrets, nil
+}
+
+func (sources *secretValueSources) readStdin(key string) (string, error) {
+	if sources.stdinTaken {
+		return "", fmt.Errorf("only one secret can be read from stdin, %s cannot read it again", key)
+	}
+	sources.stdinTaken = true
+
+	data, err := io.ReadAll(sources.stdin)
+	if err != nil {
+		return "", fmt.Errorf("unable to read the value of %s from stdin [err=%v]", key, err)
+	}
+
+	return secretValueFromBytes(key, data, "stdin")
+}
+
+func (sources *secretValueSources) readValueFile(key string, filePath string) (string, error) {
+	if filePath == "" {
+		return "", fmt.Errorf("the value of %s is missing a file path after %s", key, SECRET_VALUE_FILE_PREFIX)
+	}
+
+	data, err := sources.readFile(filePath)
+	if err != nil {
+		return "", fmt.Errorf("unable to read the value of %s [err=%v]", key, err)
+	}
+
+	return secretValueFromBytes(key, data, filePath)
+}
+
+// secretValueFromBytes drops a single trailing newline, as added by echo and most editors, and rejects content
+// that cannot be stored as a secret value
+func secretValueFromBytes(key string, data []byte, source string) (string, error) {
+	if !utf8.Valid(data) || bytes.IndexByte(data, 0) != -1 {
+		return "", fmt.Errorf("the value of %s read from %s is not valid UTF-8 text, encode binary content (e.g. with base64) before setting it", key, source)
+	}
+
+	value := strings.TrimSuffix(string(data), "\n")
+	value = strings.TrimSuffix(value, "\r")
+
+	return value, nil
+}
+
+func promptForSecretValue(key string) (string, error) {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return "", fmt.Errorf("the value of %s is missing, use %s=VALUE, %s=@file or %s=- when not running in a terminal", key, key, key, key)
+	}
+
+	valuePrompt := promptui.Prompt{
+		Label:       fmt.Sprintf("Value of %s", key),
+		Mask:        '*',
+		HideEntered: true,
+	}
+
+	value, err := valuePrompt.Run()
+	if err != nil {
+		return "", fmt.Errorf("unable to read the value of %s [err=%v]", key, err)
+	}
+
+	return value, nil
+}
+
+// generateSecretArgs gives each of the keys of secrets set --generate a random value
+func generateSecretArgs(args []string, options util.SecretGeneratorOptions) ([]models.SingleEnvironmentVariable, error) {
+	secrets := []models.SingleEnvironmentVariable{}
+	for _, arg := range args {
+		key := strings.TrimSpace(arg)
+		if strings.Contains(key, "=") {
+			return nil, fmt.Errorf("%s has a value, only give the names of the secrets to generate values for", key)
+		}
+
+		value, err := util.GenerateSecretValue(options)
+		if err != nil {
+			return nil, err
+		}
+
+		secrets = append(secrets, models.SingleEnvironmentVariable{Key: key, Value: value})
+	}
+
+	return secrets, nil
+}
+
+// getSecretGeneratorOptions reads the flags of secrets set --generate
+func getSecretGeneratorOptions(cmd *cobra.Command) util.SecretGeneratorOptions {
+	format, err := cmd.Flags().GetString("format")
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+
+	length, err := cmd.Flags().GetInt("length")
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+
+	charsets, err := cmd.Flags().GetStringSlice("charset")
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+
+	exclude, err := cmd.Flags().GetString("exclude")
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+
+	separator, err := cmd.Flags().GetString("separator")
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+
+	return util.SecretGeneratorOptions{Format: format, Length: length, Charsets: charsets, Exclude: exclude, Separator: separator}
+}
@@ -0,0 +1,123 @@
+package crypto
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+)
+
+// ParseAgeRecipients parses X25519 recipients (age1...), separated by commas
+func ParseAgeRecipients(recipients string) ([]*age.X25519Recipient, error) {
+	parsed := []*age.X25519Recipient{}
+	for _, recipient := range strings.Split(recipients, ",") {
+		recipient = strings.TrimSpace(recipient)
+		if recipient == "" {
+			continue
+		}
+
+		x25519Recipient, err := age.ParseX25519Recipient(recipient)
+		if err != nil {
+			return nil, fmt.Errorf("invalid age recipient [%s] [err=%v]", recipient, err)
+		}
+		parsed = append(parsed, x25519Recipient)
+	}
+
+	if len(parsed) == 0 {
+		return nil, fmt.Errorf("no age recipient was given")
+	}
+
+	return parsed, nil
+}
+
+// ReadAgeIdentities reads the age identities used for decryption. The identity file is taken from the given path
+// and otherwise found the same way sops does: the SOPS_AGE_KEY and SOPS_AGE_KEY_FILE environment variables and then <user config dir>/sops/age/keys.txt
+func ReadAgeIdentities(identityFilePath string) ([]age.Identity, error) {
+	if identityFilePath == "" {
+		if key := os.Getenv("SOPS_AGE_KEY"); key != "" {
+			return age.ParseIdentities(strings.NewReader(key))
+		}
+
+		identityFilePath = os.Getenv("SOPS_AGE_KEY_FILE")
+	}
+
+	if identityFilePath == "" {
+		configDir, err := os.UserConfigDir()
+		if err != nil {
+			return nil, fmt.Errorf("unable to find the age identity file, set it with --identity [err=%v]", err)
+		}
+		identityFilePath = filepath.Join(configDir, "sops", "age", "keys.txt")
+	}
+
+	file, err := os.Open(identityFilePath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the age identity file [err=%v]", err)
+	}
+	defer file.Close()
+
+	identities, err := age.ParseIdentities(file)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse the age identity file %s [err=%v]", identityFilePath, err)
+	}
+
+	return identities, nil
+}
+
+// AgeEncrypt encrypts the data to the recipients and returns it ASCII armored
+func AgeEncrypt(plaintext []byte, recipients ...*age.X25519Recipient) ([]byte, error) {
+	ageRecipients := make([]age.Recipient, 0, len(recipients))
+	for _, recipient := range recipients {
+		ageRecipients = append(ageRecipients, recipient)
+	}
+
+	var output bytes.Buffer
+	armorWriter := armor.NewWriter(&output)
+
+	writer, err := age.Encrypt(armorWriter, ageRecipients...)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := writer.Write(plaintext); err != nil {
+		return nil, err
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	if err := armorWriter.Close(); err != nil {
+		return nil, err
+	}
+
+	return output.Bytes(), nil
+}
+
+// AgeDecrypt decrypts ASCII armored or binary age data with any of the identities
+func AgeDecrypt(ciphertext []byte, identities []age.Identity) ([]byte, error) {
+	var reader io.Reader = bytes.NewReader(ciphertext)
+	if IsAgeArmored(ciphertext) {
+		reader = armor.NewReader(bytes.NewReader(bytes.TrimSpace(ciphertext)))
+	}
+
+	decrypted, err := age.Decrypt(reader, identities...)
+	if err != nil {
+		return nil, err
+	}
+
+	return io.ReadAll(decrypted)
+}
+
+func IsAgeArmored(data []byte) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(data), []byte(armor.Header))
+}
+
+// IsAgeEncrypted reports whether the data is an age file, armored or binary
+func IsAgeEncrypted(data []byte) bool {
+	return IsAgeArmored(data) || bytes.HasPrefix(data, []byte("age-encryption.org/"))
+}
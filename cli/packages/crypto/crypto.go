@@ -12,6 +12,11 @@ import (
 
 // will decrypt cipher text to plain text using iv and tag
 func DecryptSymmetric(key []byte, cipherText []byte, tag []byte, iv []byte) ([]byte, error) {
+	return DecryptSymmetricWithAdditionalData(key, cipherText, tag, iv, nil)
+}
+
+// DecryptSymmetricWithAdditionalData is DecryptSymmetric for cipher texts that were sealed together with additional authenticated data
+func DecryptSymmetricWithAdditionalData(key []byte, cipherText []byte, tag []byte, iv []byte, additionalData []byte) ([]byte, error) {
 	// Case: empty string
 	if len(cipherText) == 0 && len(tag) == 0 && len(iv) == 0 {
 		return []byte{}, nil
@@ -30,7 +35,7 @@ func DecryptSymmetric(key []byte, cipherText []byte, tag []byte, iv []byte) ([]b
 	var nonce = iv
 	var ciphertext = append(cipherText, tag...) // the aesgcm open method expects auth tag at the end of the cipher text
 
-	plaintext, err := aesgcm.Open(nil, nonce, ciphertext, nil)
+	plaintext, err := aesgcm.Open(nil, nonce, ciphertext, additionalData)
 	if err != nil {
 		return nil, err
 	}
@@ -46,12 +51,17 @@ func GenerateNewKey() (newKey []byte, keyErr error) {
 
 // Will encrypt a plain text with the provided key
 func EncryptSymmetric(plaintext []byte, key []byte) (result models.SymmetricEncryptionResult, err error) {
+	return EncryptSymmetricWithAdditionalData(plaintext, key, 16, nil) // default is 12, 16 because https://github.com/Infisical/infisical/blob/bea0ff6e05a4de73a5db625d4ae181a015b50855/backend/src/utils/aes-gcm.ts#L4
+}
+
+// EncryptSymmetricWithAdditionalData encrypts a plain text with a random nonce of the given size and binds the additional data to the auth tag
+func EncryptSymmetricWithAdditionalData(plaintext []byte, key []byte, nonceSize int, additionalData []byte) (result models.SymmetricEncryptionResult, err error) {
 	block, err := aes.NewCipher(key)
 	if err != nil {
 		return models.SymmetricEncryptionResult{}, err
 	}
 
-	aesgcm, err := cipher.NewGCMWithNonceSize(block, 16) // default is 12, 16 because https://github.com/Infisical/infisical/blob/bea0ff6e05a4de73a5db625d4ae181a015b50855/backend/src/utils/aes-gcm.ts#L4
+	aesgcm, err := cipher.NewGCMWithNonceSize(block, nonceSize)
 	if err != nil {
 		return models.SymmetricEncryptionResult{}, err
 	}
@@ -59,10 +69,10 @@ func EncryptSymmetric(plaintext []byte, key []byte) (result models.SymmetricEncr
 	// create a nonce
 	nonce := make([]byte, aesgcm.NonceSize())
 	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
-		panic(err)
+		return models.SymmetricEncryptionResult{}, err
 	}
 
-	ciphertext := aesgcm.Seal(nil, nonce, plaintext, nil)
+	ciphertext := aesgcm.Seal(nil, nonce, plaintext, additionalData)
 
 	ciphertextOnly := ciphertext[:len(ciphertext)-16] // combines the auth tag with the cipher text so we need to extract it
 
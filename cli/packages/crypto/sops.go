@@ -0,0 +1,323 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/base64"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"filippo.io/age"
+	"gopkg.in/yaml.v2"
+)
+
+// Documents are written in the format of this sops version, so `sops -d` can read them back
+const (
+	SOPS_METADATA_KEY       = "sops"
+	SOPS_VERSION            = "3.8.1"
+	SOPS_UNENCRYPTED_SUFFIX = "_unencrypted"
+)
+
+var sopsValueRegex = regexp.MustCompile(`^ENC\[AES256_GCM,data:(.+),iv:(.+),tag:(.+),type:(.+)\]$`)
+
+// IsSopsDocument reports whether the document carries sops metadata
+func IsSopsDocument(document yaml.MapSlice) bool {
+	for _, item := range document {
+		if item.Key == SOPS_METADATA_KEY {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SopsEncrypt encrypts every value of the document the same way sops does: each value with AES256-GCM under a random data key,
+// authenticated with its path in the document. The data key is encrypted to every age recipient and stored with the MAC of all values under the `sops` key.
+func SopsEncrypt(document yaml.MapSlice, recipients []*age.X25519Recipient, lastModified time.Time) (yaml.MapSlice, error) {
+	if len(recipients) == 0 {
+		return nil, fmt.Errorf("at least one age recipient is required to encrypt")
+	}
+
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, err
+	}
+
+	hash := sha512.New()
+	encrypted, err := walkSopsDocument(document, nil, func(value interface{}, path []string) (interface{}, error) {
+		plaintext, valueType, err := sopsPlaintext(value)
+		if err != nil {
+			return nil, fmt.Errorf("unable to encrypt %s [err=%v]", strings.Join(path, "."), err)
+		}
+		hash.Write(plaintext)
+
+		// like sops, empty strings and values below keys with the unencrypted suffix are kept as they are
+		if isSopsUnencrypted(path) || len(plaintext) == 0 {
+			return value, nil
+		}
+
+		return encryptSopsValue(dataKey, plaintext, valueType, strings.Join(path, ":")+":")
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	lastModifiedString := lastModified.UTC().Format(time.RFC3339)
+	mac, err := encryptSopsValue(dataKey, []byte(fmt.Sprintf("%X", hash.Sum(nil))), "str", lastModifiedString)
+	if err != nil {
+		return nil, err
+	}
+
+	ageKeys := []interface{}{}
+	for _, recipient := range recipients {
+		encryptedDataKey, err := AgeEncrypt(dataKey, recipient)
+		if err != nil {
+			return nil, fmt.Errorf("unable to encrypt the data key to %s [err=%v]", recipient.String(), err)
+		}
+
+		ageKeys = append(ageKeys, yaml.MapSlice{
+			{Key: "recipient", Value: recipient.String()},
+			{Key: "enc", Value: string(encryptedDataKey)},
+		})
+	}
+
+	metadata := yaml.MapSlice{
+		{Key: "kms", Value: []interface{}{}},
+		{Key: "gcp_kms", Value: []interface{}{}},
+		{Key: "azure_kv", Value: []interface{}{}},
+		{Key: "hc_vault", Value: []interface{}{}},
+		{Key: "age", Value: ageKeys},
+		{Key: "lastmodified", Value: lastModifiedString},
+		{Key: "mac", Value: mac},
+		{Key: "pgp", Value: []interface{}{}},
+		{Key: "unencrypted_suffix", Value: SOPS_UNENCRYPTED_SUFFIX},
+		{Key: "version", Value: SOPS_VERSION},
+	}
+
+	return append(encrypted.(yaml.MapSlice), yaml.MapItem{Key: SOPS_METADATA_KEY, Value: metadata}), nil
+}
+
+// SopsDecrypt decrypts a document encrypted by sops, or by SopsEncrypt, with age and verifies its MAC. The sops metadata is not part of the result.
+func SopsDecrypt(document yaml.MapSlice, identities []age.Identity) (yaml.MapSlice, error) {
+	var metadata yaml.MapSlice
+	data := yaml.MapSlice{}
+	for _, item := range document {
+		if item.Key != SOPS_METADATA_KEY {
+			data = append(data, item)
+			continue
+		}
+
+		metadata, _ = item.Value.(yaml.MapSlice)
+	}
+
+	if metadata == nil {
+		return nil, fmt.Errorf("the document does not contain sops metadata")
+	}
+
+	unencryptedSuffix := SOPS_UNENCRYPTED_SUFFIX
+	if suffix, ok := lookupSopsMetadata(metadata, "unencrypted_suffix").(string); ok {
+		unencryptedSuffix = suffix
+	}
+
+	dataKey, err := decryptSopsDataKey(metadata, identities)
+	if err != nil {
+		return nil, err
+	}
+
+	hash := sha512.New()
+	decrypted, err := walkSopsDocument(data, nil, func(value interface{}, path []string) (interface{}, error) {
+		encryptedValue, isString := value.(string)
+		if isString && sopsValueRegex.MatchString(encryptedValue) && !hasSopsSuffix(path, unencryptedSuffix) {
+			plaintext, valueType, err := decryptSopsValue(dataKey, encryptedValue, strings.Join(path, ":")+":")
+			if err != nil {
+				return nil, fmt.Errorf("unable to decrypt %s [err=%v]", strings.Join(path, "."), err)
+			}
+			hash.Write(plaintext)
+
+			return sopsValueFromPlaintext(plaintext, valueType)
+		}
+
+		plaintext, _, err := sopsPlaintext(value)
+		if err != nil {
+			return nil, err
+		}
+		hash.Write(plaintext)
+
+		return value, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	mac, _ := lookupSopsMetadata(metadata, "mac").(string)
+	lastModified := fmt.Sprint(lookupSopsMetadata(metadata, "lastmodified"))
+	expectedMac, _, err := decryptSopsValue(dataKey, mac, lastModified)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decrypt the MAC of the document [err=%v]", err)
+	}
+
+	if !strings.EqualFold(string(expectedMac), fmt.Sprintf("%X", hash.Sum(nil))) {
+		return nil, fmt.Errorf("the MAC of the document does not match its values, the file was modified after it was encrypted")
+	}
+
+	return decrypted.(yaml.MapSlice), nil
+}
+
+func decryptSopsDataKey(metadata yaml.MapSlice, identities []age.Identity) ([]byte, error) {
+	ageKeys, _ := lookupSopsMetadata(metadata, "age").([]interface{})
+	if len(ageKeys) == 0 {
+		return nil, fmt.Errorf("the document was not encrypted with age, other sops key types are not supported")
+	}
+
+	recipients := []string{}
+	for _, ageKey := range ageKeys {
+		entry, ok := ageKey.(yaml.MapSlice)
+		if !ok {
+			continue
+		}
+
+		recipients = append(recipients, fmt.Sprint(lookupSopsMetadata(entry, "recipient")))
+		encryptedDataKey, _ := lookupSopsMetadata(entry, "enc").(string)
+
+		dataKey, err := AgeDecrypt([]byte(encryptedDataKey), identities)
+		if err == nil {
+			return dataKey, nil
+		}
+	}
+
+	return nil, fmt.Errorf("none of the age identities can decrypt the document, it was encrypted to [%s]", strings.Join(recipients, ", "))
+}
+
+func lookupSopsMetadata(metadata yaml.MapSlice, key string) interface{} {
+	for _, item := range metadata {
+		if item.Key == key {
+			return item.Value
+		}
+	}
+
+	return nil
+}
+
+// walkSopsDocument calls leaf for every scalar of the document. Like sops, list items share the path of their list.
+func walkSopsDocument(value interface{}, path []string, leaf func(value interface{}, path []string) (interface{}, error)) (interface{}, error) {
+	switch typedValue := value.(type) {
+	case yaml.MapSlice:
+		walked := make(yaml.MapSlice, 0, len(typedValue))
+		for _, item := range typedValue {
+			itemPath := append(append([]string{}, path...), fmt.Sprint(item.Key))
+			itemValue, err := walkSopsDocument(item.Value, itemPath, leaf)
+			if err != nil {
+				return nil, err
+			}
+			walked = append(walked, yaml.MapItem{Key: item.Key, Value: itemValue})
+		}
+		return walked, nil
+	case []interface{}:
+		walked := make([]interface{}, 0, len(typedValue))
+		for _, item := range typedValue {
+			itemValue, err := walkSopsDocument(item, path, leaf)
+			if err != nil {
+				return nil, err
+			}
+			walked = append(walked, itemValue)
+		}
+		return walked, nil
+	case nil:
+		return nil, nil
+	default:
+		return leaf(value, path)
+	}
+}
+
+func isSopsUnencrypted(path []string) bool {
+	return hasSopsSuffix(path, SOPS_UNENCRYPTED_SUFFIX)
+}
+
+func hasSopsSuffix(path []string, suffix string) bool {
+	if suffix == "" {
+		return false
+	}
+
+	for _, key := range path {
+		if strings.HasSuffix(key, suffix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// sopsPlaintext returns the bytes sops encrypts, and adds to the MAC, for a scalar together with its sops type
+func sopsPlaintext(value interface{}) ([]byte, string, error) {
+	switch typedValue := value.(type) {
+	case string:
+		return []byte(typedValue), "str", nil
+	case int:
+		return []byte(strconv.Itoa(typedValue)), "int", nil
+	case int64:
+		return []byte(strconv.FormatInt(typedValue, 10)), "int", nil
+	case float64:
+		return []byte(strconv.FormatFloat(typedValue, 'f', -1, 64)), "float", nil
+	case bool:
+		if typedValue {
+			return []byte("True"), "bool", nil
+		}
+		return []byte("False"), "bool", nil
+	default:
+		return nil, "", fmt.Errorf("values of type %T are not supported", value)
+	}
+}
+
+func sopsValueFromPlaintext(plaintext []byte, valueType string) (interface{}, error) {
+	switch valueType {
+	case "str", "bytes":
+		return string(plaintext), nil
+	case "int":
+		return strconv.Atoi(string(plaintext))
+	case "float":
+		return strconv.ParseFloat(string(plaintext), 64)
+	case "bool":
+		return strconv.ParseBool(string(plaintext))
+	default:
+		return nil, fmt.Errorf("unknown sops value type %s", valueType)
+	}
+}
+
+func encryptSopsValue(dataKey []byte, plaintext []byte, valueType string, additionalData string) (string, error) {
+	result, err := EncryptSymmetricWithAdditionalData(plaintext, dataKey, 32, []byte(additionalData))
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("ENC[AES256_GCM,data:%s,iv:%s,tag:%s,type:%s]",
+		base64.StdEncoding.EncodeToString(result.CipherText),
+		base64.StdEncoding.EncodeToString(result.Nonce),
+		base64.StdEncoding.EncodeToString(result.AuthTag),
+		valueType), nil
+}
+
+func decryptSopsValue(dataKey []byte, value string, additionalData string) ([]byte, string, error) {
+	matches := sopsValueRegex.FindStringSubmatch(value)
+	if matches == nil {
+		return nil, "", fmt.Errorf("the value is not in the sops format")
+	}
+
+	parts := make([][]byte, 3)
+	for i := range parts {
+		decoded, err := base64.StdEncoding.DecodeString(matches[i+1])
+		if err != nil {
+			return nil, "", err
+		}
+		parts[i] = decoded
+	}
+
+	plaintext, err := DecryptSymmetricWithAdditionalData(dataKey, parts[0], parts[2], parts[1], []byte(additionalData))
+	if err != nil {
+		return nil, "", err
+	}
+
+	return plaintext, matches[4], nil
+}
@@ -22,19 +22,28 @@ type LoggedInUser struct {
 	Domain string `json:"domain"`
 }
 
+// SecretTag is a tag of a secret, as returned with the encrypted secrets of the API and in the JSON output. It is an
+// alias of the struct type, so tags written as a struct literal can still be assigned to it
+type SecretTag = struct {
+	ID        string `json:"_id"`
+	Name      string `json:"name"`
+	Slug      string `json:"slug"`
+	Workspace string `json:"workspace"`
+}
+
 type SingleEnvironmentVariable struct {
-	Key         string `json:"key"`
-	WorkspaceId string `json:"workspace"`
-	Value       string `json:"value"`
-	Type        string `json:"type"`
-	ID          string `json:"_id"`
-	Tags        []struct {
-		ID        string `json:"_id"`
-		Name      string `json:"name"`
-		Slug      string `json:"slug"`
-		Workspace string `json:"workspace"`
-	} `json:"tags"`
-	Comment string `json:"comment"`
+	Key         string      `json:"key"`
+	WorkspaceId string      `json:"workspace"`
+	Value       string      `json:"value"`
+	Type        string      `json:"type"`
+	ID          string      `json:"_id"`
+	Tags        []SecretTag `json:"tags"`
+	Comment     string      `json:"comment"`
+	// the environment and folder the secret was read from, for imported secrets these point to the imported folder.
+	// They are not part of the JSON of the secret, which is the shape of export --format json and of the backups
+	Environment string `json:"-"`
+	SecretPath  string `json:"-"`
+	IsImported  bool   `json:"-"`
 }
 
 type PlaintextSecretResult struct {
@@ -125,6 +134,24 @@ type DeleteFolderParameters struct {
 	InfisicalToken string
 }
 
+type RenameFolderParameters struct {
+	FolderName     string
+	NewFolderName  string
+	WorkspaceId    string
+	Environment    string
+	FolderPath     string
+	InfisicalToken string
+}
+
+// FolderTree is a folder with its secrets and all of its sub-folders
+type FolderTree struct {
+	Name        string                      `json:"name"`
+	Path        string                      `json:"path"`
+	SecretCount int                         `json:"secretCount"`
+	Secrets     []SingleEnvironmentVariable `json:"-"`
+	Folders     []FolderTree                `json:"folders"`
+}
+
 type ExpandSecretsAuthentication struct {
 	InfisicalToken           string
 	UniversalAuthAccessToken string
@@ -140,3 +167,42 @@ type SecretSetOperation struct {
 	SecretValue     string
 	SecretOperation string
 }
+
+type SecretVersion struct {
+	Version   int
+	Key       string
+	Value     string
+	Comment   string
+	Type      string
+	Actor     string
+	CreatedAt time.Time
+}
+
+type SecretSnapshot struct {
+	ID          string
+	Environment string
+	Path        string
+	CreatedAt   time.Time
+}
+
+type SecretImport struct {
+	ID string
+	// the environment slug and folder the secrets are imported from
+	Environment   string
+	Path          string
+	Position      int
+	IsReplication bool
+}
+
+// SecretImportChain is a folder with the folders it imports, and the folders they import in turn
+type SecretImportChain struct {
+	Environment string
+	Path        string
+	// the position of the import in the importing folder, 0 for the folder the chain starts at
+	Position int
+	// the keys of the shared secrets stored in the folder itself
+	Keys    []string
+	Imports []SecretImportChain
+	// set when the folder is already imported at a level above, the backend then skips it with its imports
+	Cycle bool
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"path/filepath"
 
 	"github.com/Infisical/infisical-merge/packages/config"
 )
@@ -23,6 +24,37 @@ func WriteToFile(fileName string, dataToWrite []byte, filePerm os.FileMode) erro
 	return nil
 }
 
+// WritePrivateFile writes the data to a new file only the current user can read, then moves it to the given path.
+// Unlike WriteToFile, the data is never readable with the permissions of a file that already exists at the path
+func WritePrivateFile(fileName string, dataToWrite []byte) error {
+	file, err := os.CreateTemp(filepath.Dir(fileName), "."+filepath.Base(fileName)+".*")
+	if err != nil {
+		return fmt.Errorf("unable to create the file [err=%v]", err)
+	}
+	defer os.Remove(file.Name())
+
+	// CreateTemp already uses 0600, set it anyway in case the umask or the platform differ
+	if err := file.Chmod(0600); err != nil {
+		file.Close()
+		return fmt.Errorf("unable to restrict the permissions of the file [err=%v]", err)
+	}
+
+	if _, err := file.Write(dataToWrite); err != nil {
+		file.Close()
+		return fmt.Errorf("unable to write to the file [err=%v]", err)
+	}
+
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("unable to write to the file [err=%v]", err)
+	}
+
+	if err := os.Rename(file.Name(), fileName); err != nil {
+		return fmt.Errorf("unable to write to the file [err=%v]", err)
+	}
+
+	return nil
+}
+
 func ValidateInfisicalAPIConnection() (ok bool) {
 	_, err := http.Get(fmt.Sprintf("%v/status", config.INFISICAL_URL))
 	return err == nil
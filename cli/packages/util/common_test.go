@@ -0,0 +1,41 @@
+package util
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestWritePrivateFileReplacesWiderPermissions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file modes are not enforced on windows")
+	}
+
+	fileName := filepath.Join(t.TempDir(), "secrets.env")
+	if err := os.WriteFile(fileName, []byte("OLD=1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := WritePrivateFile(fileName, []byte("TOKEN=secret\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("expected the file to be readable by its owner only, got %v", info.Mode().Perm())
+	}
+
+	content, _ := os.ReadFile(fileName)
+	if string(content) != "TOKEN=secret\n" {
+		t.Errorf("unexpected content %q", content)
+	}
+
+	entries, _ := os.ReadDir(filepath.Dir(fileName))
+	if len(entries) != 1 {
+		t.Errorf("expected the temporary file to be renamed, got %d files", len(entries))
+	}
+}
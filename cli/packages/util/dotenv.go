@@ -0,0 +1,160 @@
+package util
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DotEnvEntry is a single variable of a dotenv file. Comment holds the comment lines directly above it.
+type DotEnvEntry struct {
+	Key     string
+	Value   string
+	Comment string
+}
+
+// ParseDotEnv parses a dotenv file. Values can be unquoted, single quoted (taken literally) or double quoted
+// (supporting the \n, \r, \t, \", \\ and \$ escapes). Quoted values may span multiple lines and lines may start with `export`.
+func ParseDotEnv(data string) ([]DotEnvEntry, error) {
+	entries := []DotEnvEntry{}
+	comments := []string{}
+
+	lines := strings.Split(strings.ReplaceAll(data, "\r\n", "\n"), "\n")
+	for lineNumber := 0; lineNumber < len(lines); lineNumber++ {
+		line := strings.TrimSpace(lines[lineNumber])
+
+		if line == "" {
+			comments = []string{}
+			continue
+		}
+
+		if strings.HasPrefix(line, "#") {
+			comments = append(comments, strings.TrimSpace(strings.TrimPrefix(line, "#")))
+			continue
+		}
+
+		// keep trailing whitespace, it is part of quoted values spanning multiple lines
+		line = strings.TrimPrefix(strings.TrimLeft(lines[lineNumber], " \t"), "export ")
+
+		key, rest, found := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !found || !isValidDotEnvKey(key) {
+			return nil, fmt.Errorf("invalid line %d in dotenv file, expected KEY=VALUE", lineNumber+1)
+		}
+
+		rest = strings.TrimLeft(rest, " \t")
+
+		var value string
+		if strings.HasPrefix(rest, "'") || strings.HasPrefix(rest, `"`) {
+			quote := rest[0]
+			raw := rest[1:]
+			startLine := lineNumber
+
+			// a quoted value continues on the next lines until its closing quote
+			end := findClosingQuote(raw, quote)
+			for end == -1 {
+				lineNumber++
+				if lineNumber >= len(lines) {
+					return nil, fmt.Errorf("unterminated quoted value for %s on line %d of dotenv file", key, startLine+1)
+				}
+				raw += "\n" + lines[lineNumber]
+				end = findClosingQuote(raw, quote)
+			}
+
+			trailing := strings.TrimSpace(raw[end+1:])
+			if trailing != "" && !strings.HasPrefix(trailing, "#") {
+				return nil, fmt.Errorf("unexpected characters after the value of %s on line %d of dotenv file", key, lineNumber+1)
+			}
+
+			value = raw[:end]
+			if quote == '"' {
+				value = unescapeDotEnvValue(value)
+			}
+		} else {
+			// unquoted values end at an inline comment
+			if strings.HasPrefix(rest, "#") {
+				rest = ""
+			} else if index := strings.Index(rest, " #"); index != -1 {
+				rest = rest[:index]
+			}
+			value = strings.TrimSpace(rest)
+		}
+
+		entries = append(entries, DotEnvEntry{Key: key, Value: value, Comment: strings.Join(comments, "\n")})
+		comments = []string{}
+	}
+
+	return entries, nil
+}
+
+// QuoteDotEnvValue quotes a value so ParseDotEnv, and the common dotenv parsers, read it back unchanged.
+// Values without single quotes or line breaks are single quoted and taken literally, all others are double quoted and escaped.
+func QuoteDotEnvValue(value string) string {
+	if !strings.ContainsAny(value, "'\n\r") {
+		return "'" + value + "'"
+	}
+
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "$", `\$`)
+	return `"` + replacer.Replace(value) + `"`
+}
+
+// QuoteShellValue quotes a value for POSIX shells, single quotes inside the value are closed, escaped and reopened
+func QuoteShellValue(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+func isValidDotEnvKey(key string) bool {
+	if key == "" {
+		return false
+	}
+
+	for index, char := range key {
+		isLetter := (char >= 'a' && char <= 'z') || (char >= 'A' && char <= 'Z') || char == '_'
+		isOther := (char >= '0' && char <= '9') || char == '.' || char == '-'
+		if !isLetter && (index == 0 || !isOther) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func findClosingQuote(value string, quote byte) int {
+	for i := 0; i < len(value); i++ {
+		if quote == '"' && value[i] == '\\' {
+			i++
+			continue
+		}
+		if value[i] == quote {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func unescapeDotEnvValue(value string) string {
+	var builder strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] != '\\' || i == len(value)-1 {
+			builder.WriteByte(value[i])
+			continue
+		}
+
+		i++
+		switch value[i] {
+		case 'n':
+			builder.WriteByte('\n')
+		case 'r':
+			builder.WriteByte('\r')
+		case 't':
+			builder.WriteByte('\t')
+		case '"', '\\', '$':
+			builder.WriteByte(value[i])
+		default:
+			builder.WriteByte('\\')
+			builder.WriteByte(value[i])
+		}
+	}
+
+	return builder.String()
+}
@@ -2,6 +2,8 @@ package util
 
 import (
 	"fmt"
+	"path"
+	"sort"
 	"strings"
 
 	"github.com/Infisical/infisical-merge/packages/api"
@@ -267,3 +269,208 @@ func DeleteFolder(params models.DeleteFolderParameters) ([]models.SingleFolder,
 
 	return folders, nil
 }
+
+// EnsureFolderPath checks that every folder of the path exists, e.g. /api and /api/db for /api/db. Missing folders are
+// created when create is set, otherwise false is returned. Creating folders requires the project ID.
+func EnsureFolderPath(params models.GetAllFoldersParameters, folderPath string, create bool) (bool, error) {
+	parentPath := "/"
+	for _, folderName := range strings.Split(strings.Trim(folderPath, "/"), "/") {
+		if folderName == "" {
+			continue
+		}
+
+		params.FoldersPath = parentPath
+		folders, err := GetAllFolders(params)
+		if err != nil {
+			return false, fmt.Errorf("unable to get the folders of %s [err=%v]", parentPath, err)
+		}
+
+		exists := false
+		for _, folder := range folders {
+			if folder.Name == folderName {
+				exists = true
+				break
+			}
+		}
+
+		if !exists {
+			if !create {
+				return false, nil
+			}
+
+			createFolderParams := models.CreateFolderParameters{
+				FolderName:  folderName,
+				WorkspaceId: params.WorkspaceId,
+				Environment: params.Environment,
+				FolderPath:  parentPath,
+			}
+			if params.InfisicalToken != "" {
+				createFolderParams.InfisicalToken = params.InfisicalToken
+			} else if params.UniversalAuthAccessToken != "" {
+				createFolderParams.InfisicalToken = params.UniversalAuthAccessToken
+			}
+
+			if _, err := CreateFolder(createFolderParams); err != nil {
+				return false, fmt.Errorf("unable to create the folder %s in %s [err=%v]", folderName, parentPath, err)
+			}
+		}
+
+		parentPath = path.Join(parentPath, folderName)
+	}
+
+	return true, nil
+}
+
+// RenameFolder renames the folder named params.FolderName in params.FolderPath
+func RenameFolder(params models.RenameFolderParameters) (models.SingleFolder, error) {
+	// If no token is provided, we will try to get the token from the current logged in user
+	if params.InfisicalToken == "" {
+		RequireLogin()
+		RequireLocalWorkspaceFile()
+
+		loggedInUserDetails, err := GetCurrentLoggedInUserDetails()
+		if err != nil {
+			return models.SingleFolder{}, err
+		}
+
+		if loggedInUserDetails.LoginExpired {
+			PrintErrorMessageAndExit("Your login session has expired, please run [infisical login] and try again")
+		}
+
+		params.InfisicalToken = loggedInUserDetails.UserCredentials.JTWToken
+	}
+
+	httpClient := resty.New()
+	httpClient.
+		SetAuthToken(params.InfisicalToken).
+		SetHeader("Accept", "application/json").
+		SetHeader("Content-Type", "application/json")
+
+	// folders are renamed by ID
+	foldersResponse, err := api.CallGetFoldersV1(httpClient, api.GetFoldersV1Request{
+		WorkspaceId: params.WorkspaceId,
+		Environment: params.Environment,
+		FoldersPath: params.FolderPath,
+	})
+	if err != nil {
+		return models.SingleFolder{}, err
+	}
+
+	folderId := ""
+	for _, folder := range foldersResponse.Folders {
+		if folder.Name == params.NewFolderName {
+			return models.SingleFolder{}, fmt.Errorf("a folder named %s already exists in %s", params.NewFolderName, params.FolderPath)
+		}
+		if folder.Name == params.FolderName {
+			folderId = folder.ID
+		}
+	}
+
+	if folderId == "" {
+		return models.SingleFolder{}, fmt.Errorf("there is no folder named %s in %s", params.FolderName, params.FolderPath)
+	}
+
+	apiResponse, err := api.CallUpdateFolderV1(httpClient, api.UpdateFolderV1Request{
+		FolderId:    folderId,
+		Name:        params.NewFolderName,
+		WorkspaceId: params.WorkspaceId,
+		Environment: params.Environment,
+		Path:        params.FolderPath,
+	})
+	if err != nil {
+		return models.SingleFolder{}, err
+	}
+
+	return models.SingleFolder{
+		Name: apiResponse.Folder.Name,
+		ID:   apiResponse.Folder.ID,
+	}, nil
+}
+
+// GetFolderTree returns the folder at params.FoldersPath with all of its sub-folders, each with the secrets stored in it.
+// Imported secrets are not part of the folders.
+func GetFolderTree(params models.GetAllFoldersParameters) (models.FolderTree, error) {
+	rootPath := path.Join("/", params.FoldersPath)
+
+	root, err := getFolderTreeNode(params, rootPath)
+	if err != nil {
+		return models.FolderTree{}, err
+	}
+
+	secrets, err := GetAllEnvironmentVariables(models.GetAllSecretsParameters{
+		Environment:              params.Environment,
+		WorkspaceId:              params.WorkspaceId,
+		SecretsPath:              rootPath,
+		InfisicalToken:           params.InfisicalToken,
+		UniversalAuthAccessToken: params.UniversalAuthAccessToken,
+		Recursive:                true,
+	}, "")
+	if err != nil {
+		return models.FolderTree{}, fmt.Errorf("unable to get the secrets of %s [err=%v]", rootPath, err)
+	}
+
+	secretsByPath := map[string][]models.SingleEnvironmentVariable{}
+	for _, secret := range secrets {
+		secretPath := path.Join("/", secret.SecretPath)
+		secretsByPath[secretPath] = append(secretsByPath[secretPath], secret)
+	}
+
+	addFolderTreeSecrets(&root, secretsByPath)
+	return root, nil
+}
+
+func getFolderTreeNode(params models.GetAllFoldersParameters, folderPath string) (models.FolderTree, error) {
+	node := models.FolderTree{
+		Name:    path.Base(folderPath),
+		Path:    folderPath,
+		Folders: []models.FolderTree{},
+	}
+
+	params.FoldersPath = folderPath
+	folders, err := GetAllFolders(params)
+	if err != nil {
+		return models.FolderTree{}, fmt.Errorf("unable to get the folders of %s [err=%v]", folderPath, err)
+	}
+
+	sort.Slice(folders, func(i, j int) bool {
+		return folders[i].Name < folders[j].Name
+	})
+
+	for _, folder := range folders {
+		child, err := getFolderTreeNode(params, path.Join(folderPath, folder.Name))
+		if err != nil {
+			return models.FolderTree{}, err
+		}
+		node.Folders = append(node.Folders, child)
+	}
+
+	return node, nil
+}
+
+func addFolderTreeSecrets(node *models.FolderTree, secretsByPath map[string][]models.SingleEnvironmentVariable) {
+	node.Secrets = secretsByPath[node.Path]
+	node.SecretCount = len(node.Secrets)
+
+	for i := range node.Folders {
+		addFolderTreeSecrets(&node.Folders[i], secretsByPath)
+	}
+}
+
+// GetServiceTokenProjectId returns the ID of the project a service token belongs to
+func GetServiceTokenProjectId(fullServiceToken string) (string, error) {
+	serviceTokenParts := strings.SplitN(fullServiceToken, ".", 4)
+	if len(serviceTokenParts) < 4 {
+		return "", fmt.Errorf("invalid service token entered. Please double check your service token and try again")
+	}
+
+	httpClient := resty.New()
+	httpClient.SetAuthToken(fmt.Sprintf("%v.%v.%v", serviceTokenParts[0], serviceTokenParts[1], serviceTokenParts[2])).
+		SetHeader("Accept", "application/json")
+
+	serviceTokenDetails, err := api.CallGetServiceTokenDetailsV2(httpClient)
+	if err != nil {
+		return "", fmt.Errorf("unable to get service token details. [err=%v]", err)
+	}
+
+	return serviceTokenDetails.Workspace, nil
+}
@@ -0,0 +1,69 @@
+package util
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Infisical/infisical-merge/packages/api"
+	"github.com/Infisical/infisical-merge/packages/config"
+	"github.com/Infisical/infisical-merge/packages/models"
+)
+
+func TestRenameFolder(t *testing.T) {
+	var update api.UpdateFolderV1Request
+	updatedFolderId := ""
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/api/v1/folders":
+			if r.URL.Query().Get("directory") != "/apps" {
+				t.Errorf("expected the folders of /apps, got %s", r.URL.Query().Get("directory"))
+			}
+			w.Write([]byte(`{"folders": [{"id": "folder-api", "name": "api"}, {"id": "folder-web", "name": "web"}]}`))
+		case r.Method == http.MethodPatch && r.URL.Path == "/api/v1/folders/folder-api":
+			updatedFolderId = "folder-api"
+			json.NewDecoder(r.Body).Decode(&update)
+			w.Write([]byte(`{"folder": {"id": "folder-api", "name": "backend"}}`))
+		default:
+			t.Errorf("unexpected call %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	previousUrl := config.INFISICAL_URL
+	config.INFISICAL_URL = server.URL + "/api"
+	t.Cleanup(func() { config.INFISICAL_URL = previousUrl })
+
+	params := models.RenameFolderParameters{
+		FolderName:     "api",
+		NewFolderName:  "backend",
+		WorkspaceId:    "project-id",
+		Environment:    "dev",
+		FolderPath:     "/apps",
+		InfisicalToken: "access-token",
+	}
+
+	folder, err := RenameFolder(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if folder.Name != "backend" || updatedFolderId != "folder-api" || update.Name != "backend" || update.Path != "/apps" || update.WorkspaceId != "project-id" {
+		t.Errorf("unexpected rename of %s to %+v, got %+v", updatedFolderId, update, folder)
+	}
+
+	params.NewFolderName = "web"
+	if _, err := RenameFolder(params); err == nil {
+		t.Errorf("expected an error when a folder with the new name exists")
+	}
+
+	params.FolderName, params.NewFolderName = "missing", "other"
+	if _, err := RenameFolder(params); err == nil {
+		t.Errorf("expected an error when the folder does not exist")
+	}
+}
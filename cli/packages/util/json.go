@@ -0,0 +1,132 @@
+package util
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// UnmarshalOrderedJSON decodes JSON into the same types yaml.v2 uses for a yaml.MapSlice, so objects keep the order of their keys
+func UnmarshalOrderedJSON(data []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	value, err := decodeOrderedJSONValue(decoder)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse JSON [err=%v]", err)
+	}
+
+	if _, err := decoder.Token(); err == nil {
+		return nil, fmt.Errorf("unable to parse JSON, unexpected data after the top level value")
+	}
+
+	return value, nil
+}
+
+// MarshalOrderedJSON encodes values made of yaml.MapSlice, slices and scalars as indented JSON, keeping the order of the keys
+func MarshalOrderedJSON(value interface{}) ([]byte, error) {
+	var buffer bytes.Buffer
+	if err := encodeOrderedJSONValue(&buffer, value); err != nil {
+		return nil, err
+	}
+
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, buffer.Bytes(), "", "  "); err != nil {
+		return nil, err
+	}
+
+	return indented.Bytes(), nil
+}
+
+func decodeOrderedJSONValue(decoder *json.Decoder) (interface{}, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch typedToken := token.(type) {
+	case json.Delim:
+		if typedToken == '{' {
+			object := yaml.MapSlice{}
+			for decoder.More() {
+				keyToken, err := decoder.Token()
+				if err != nil {
+					return nil, err
+				}
+
+				value, err := decodeOrderedJSONValue(decoder)
+				if err != nil {
+					return nil, err
+				}
+				object = append(object, yaml.MapItem{Key: keyToken, Value: value})
+			}
+			_, err = decoder.Token()
+			return object, err
+		}
+
+		array := []interface{}{}
+		for decoder.More() {
+			value, err := decodeOrderedJSONValue(decoder)
+			if err != nil {
+				return nil, err
+			}
+			array = append(array, value)
+		}
+		_, err = decoder.Token()
+		return array, err
+	case json.Number:
+		if integer, err := typedToken.Int64(); err == nil && !strings.ContainsAny(typedToken.String(), ".eE") {
+			return int(integer), nil
+		}
+		return typedToken.Float64()
+	default:
+		return token, nil
+	}
+}
+
+func encodeOrderedJSONValue(buffer *bytes.Buffer, value interface{}) error {
+	switch typedValue := value.(type) {
+	case yaml.MapSlice:
+		buffer.WriteByte('{')
+		for index, item := range typedValue {
+			if index > 0 {
+				buffer.WriteByte(',')
+			}
+
+			key, err := json.Marshal(fmt.Sprint(item.Key))
+			if err != nil {
+				return err
+			}
+			buffer.Write(key)
+			buffer.WriteByte(':')
+
+			if err := encodeOrderedJSONValue(buffer, item.Value); err != nil {
+				return err
+			}
+		}
+		buffer.WriteByte('}')
+	case []interface{}:
+		buffer.WriteByte('[')
+		for index, item := range typedValue {
+			if index > 0 {
+				buffer.WriteByte(',')
+			}
+
+			if err := encodeOrderedJSONValue(buffer, item); err != nil {
+				return err
+			}
+		}
+		buffer.WriteByte(']')
+	default:
+		encoded, err := json.Marshal(typedValue)
+		if err != nil {
+			return err
+		}
+		buffer.Write(encoded)
+	}
+
+	return nil
+}
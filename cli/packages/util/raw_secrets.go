@@ -0,0 +1,245 @@
+package util
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Infisical/infisical-merge/packages/api"
+	"github.com/Infisical/infisical-merge/packages/models"
+	"github.com/go-resty/resty/v2"
+	"github.com/rs/zerolog/log"
+)
+
+// SetSecretsError is returned when setting secrets fails part way. The changes made before the failure are rolled back,
+// AppliedKeys holds the secrets whose changes could not be rolled back and are still applied.
+type SetSecretsError struct {
+	AppliedKeys    []string
+	RolledBackKeys []string
+	Err            error
+}
+
+func (e *SetSecretsError) Error() string {
+	message := e.Err.Error()
+	if len(e.RolledBackKeys) > 0 {
+		message += fmt.Sprintf(". The changes to %s were rolled back", strings.Join(e.RolledBackKeys, ", "))
+	}
+
+	if len(e.AppliedKeys) > 0 {
+		return message + fmt.Sprintf(". The changes to %s are applied and could not be rolled back", strings.Join(e.AppliedKeys, ", "))
+	}
+
+	return message + ". No secrets were changed"
+}
+
+func (e *SetSecretsError) Unwrap() error {
+	return e.Err
+}
+
+// rawSecretsApplier creates and updates secrets through the raw API. Shared secrets are sent to the batch endpoints, which
+// apply all of them or none, and are otherwise set one by one. When a call fails the changes made before it are undone.
+// Only the batch endpoint updates the comments of existing secrets, so a comment change fails without it.
+type rawSecretsApplier struct {
+	httpClient   *resty.Client
+	tokenDetails *models.TokenDetails
+	projectId    string
+	projectSlug  string
+	environment  string
+	secretPath   string
+
+	// the values before they were changed, by type and key
+	previousSecrets map[string]models.SingleEnvironmentVariable
+
+	created  []models.SingleEnvironmentVariable
+	modified []models.SingleEnvironmentVariable
+}
+
+func newRawSecretsApplier(httpClient *resty.Client, tokenDetails *models.TokenDetails, projectId string, environment string, secretPath string, existingSecrets []models.SingleEnvironmentVariable) *rawSecretsApplier {
+	applier := &rawSecretsApplier{
+		httpClient:      httpClient,
+		tokenDetails:    tokenDetails,
+		projectId:       projectId,
+		environment:     environment,
+		secretPath:      secretPath,
+		previousSecrets: map[string]models.SingleEnvironmentVariable{},
+	}
+
+	for _, secret := range existingSecrets {
+		applier.previousSecrets[secret.Type+"/"+secret.Key] = secret
+	}
+
+	return applier
+}
+
+func getProjectSlugForToken(httpClient *resty.Client, tokenDetails *models.TokenDetails, projectId string) (string, error) {
+	if projectId == "" && tokenDetails.Type == SERVICE_TOKEN_IDENTIFIER {
+		serviceTokenDetails, err := api.CallGetServiceTokenDetailsV2(httpClient)
+		if err != nil {
+			return "", err
+		}
+		projectId = serviceTokenDetails.Workspace
+	}
+
+	project, err := api.CallGetProjectByIdV1(httpClient, api.GetProjectByIdV1Request{ProjectId: projectId})
+	if err != nil {
+		return "", err
+	}
+
+	return project.Workspace.Slug, nil
+}
+
+func (applier *rawSecretsApplier) apply(secretsToCreate []models.SingleEnvironmentVariable, secretsToModify []models.SingleEnvironmentVariable) error {
+	sharedToCreate, _ := splitSecretsByType(secretsToCreate)
+	sharedToModify, _ := splitSecretsByType(secretsToModify)
+	if len(sharedToCreate) > 0 || len(sharedToModify) > 0 {
+		projectSlug, err := getProjectSlugForToken(applier.httpClient, applier.tokenDetails, applier.projectId)
+		if err != nil {
+			log.Debug().Msgf("unable to get the project slug, secrets are set one by one [err=%v]", err)
+		}
+		applier.projectSlug = projectSlug
+	}
+
+	err := applier.createSecrets(secretsToCreate)
+	if err == nil {
+		err = applier.updateSecrets(secretsToModify)
+	}
+
+	if err != nil {
+		return applier.rollback(err)
+	}
+
+	return nil
+}
+
+func (applier *rawSecretsApplier) createSecrets(secrets []models.SingleEnvironmentVariable) error {
+	shared, personal := splitSecretsByType(secrets)
+
+	if len(shared) > 0 && applier.projectSlug != "" {
+		batch := api.BatchRawSecretsV3Request{ProjectSlug: applier.projectSlug, Environment: applier.environment, SecretPath: applier.secretPath}
+		for _, secret := range shared {
+			batch.Secrets = append(batch.Secrets, api.BatchRawSecretV3{SecretKey: secret.Key, SecretValue: secret.Value, SecretComment: secret.Comment})
+		}
+
+		err := api.CallCreateRawSecretsBatchV3(applier.httpClient, batch)
+		if err == nil {
+			applier.created = append(applier.created, shared...)
+			shared = nil
+		} else if !errors.Is(err, api.ErrBatchNotSupported) {
+			return fmt.Errorf("unable to process new secret creations [err=%v]", err)
+		}
+	}
+
+	for _, secret := range append(shared, personal...) {
+		createSecretRequest := api.CreateRawSecretV3Request{
+			SecretName:    secret.Key,
+			SecretValue:   secret.Value,
+			SecretComment: secret.Comment,
+			Type:          secret.Type,
+			SecretPath:    applier.secretPath,
+			WorkspaceID:   applier.projectId,
+			Environment:   applier.environment,
+		}
+
+		if err := api.CallCreateRawSecretsV3(applier.httpClient, createSecretRequest); err != nil {
+			return fmt.Errorf("unable to process new secret creations [err=%v]", err)
+		}
+		applier.created = append(applier.created, secret)
+	}
+
+	return nil
+}
+
+func (applier *rawSecretsApplier) updateSecrets(secrets []models.SingleEnvironmentVariable) error {
+	shared, personal := splitSecretsByType(secrets)
+
+	if len(shared) > 0 && applier.projectSlug != "" {
+		batch := api.BatchRawSecretsV3Request{ProjectSlug: applier.projectSlug, Environment: applier.environment, SecretPath: applier.secretPath}
+		for _, secret := range shared {
+			batch.Secrets = append(batch.Secrets, api.BatchRawSecretV3{SecretKey: secret.Key, SecretValue: secret.Value, SecretComment: secret.Comment})
+		}
+
+		err := api.CallUpdateRawSecretsBatchV3(applier.httpClient, batch)
+		if err == nil {
+			applier.modified = append(applier.modified, shared...)
+			shared = nil
+		} else if !errors.Is(err, api.ErrBatchNotSupported) {
+			return fmt.Errorf("unable to process secret update request [err=%v]", err)
+		}
+	}
+
+	// the single update only takes the value, so a comment change would silently be dropped
+	for _, secret := range append(shared, personal...) {
+		if secret.Comment != "" {
+			return fmt.Errorf("unable to update the comment of %s, this Infisical instance can only update comments with a token through the batch endpoint", secret.Key)
+		}
+	}
+
+	for _, secret := range append(shared, personal...) {
+		if err := applier.updateSecretValue(secret, secret.Value); err != nil {
+			return fmt.Errorf("unable to process secret update request [err=%v]", err)
+		}
+		applier.modified = append(applier.modified, secret)
+	}
+
+	return nil
+}
+
+func (applier *rawSecretsApplier) updateSecretValue(secret models.SingleEnvironmentVariable, value string) error {
+	return api.CallUpdateRawSecretsV3(applier.httpClient, api.UpdateRawSecretByNameV3Request{
+		SecretName:  secret.Key,
+		SecretValue: value,
+		SecretPath:  applier.secretPath,
+		WorkspaceID: applier.projectId,
+		Environment: applier.environment,
+		Type:        secret.Type,
+	})
+}
+
+// rollback restores the previous values of the modified secrets and deletes the created ones, one by one so a
+// single failure does not keep the other changes
+func (applier *rawSecretsApplier) rollback(cause error) error {
+	setSecretsError := &SetSecretsError{Err: cause}
+
+	for _, secret := range applier.modified {
+		previousSecret := applier.previousSecrets[secret.Type+"/"+secret.Key]
+		if err := applier.updateSecretValue(secret, previousSecret.Value); err != nil {
+			log.Debug().Msgf("unable to restore the previous value of %s [err=%v]", secret.Key, err)
+			setSecretsError.AppliedKeys = append(setSecretsError.AppliedKeys, secret.Key)
+			continue
+		}
+		setSecretsError.RolledBackKeys = append(setSecretsError.RolledBackKeys, secret.Key)
+	}
+
+	for _, secret := range applier.created {
+		deleteSecretRequest := api.DeleteRawSecretV3Request{
+			SecretName:  secret.Key,
+			WorkspaceID: applier.projectId,
+			Environment: applier.environment,
+			SecretPath:  applier.secretPath,
+			Type:        secret.Type,
+		}
+
+		if err := api.CallDeleteRawSecretsV3(applier.httpClient, deleteSecretRequest); err != nil {
+			log.Debug().Msgf("unable to delete the created secret %s [err=%v]", secret.Key, err)
+			setSecretsError.AppliedKeys = append(setSecretsError.AppliedKeys, secret.Key)
+			continue
+		}
+		setSecretsError.RolledBackKeys = append(setSecretsError.RolledBackKeys, secret.Key)
+	}
+
+	return setSecretsError
+}
+
+func splitSecretsByType(secrets []models.SingleEnvironmentVariable) ([]models.SingleEnvironmentVariable, []models.SingleEnvironmentVariable) {
+	shared := []models.SingleEnvironmentVariable{}
+	personal := []models.SingleEnvironmentVariable{}
+	for _, secret := range secrets {
+		if secret.Type == SECRET_TYPE_PERSONAL {
+			personal = append(personal, secret)
+		} else {
+			shared = append(shared, secret)
+		}
+	}
+
+	return shared, personal
+}
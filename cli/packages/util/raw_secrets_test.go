@@ -0,0 +1,235 @@
+package util
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Infisical/infisical-merge/packages/config"
+	"github.com/Infisical/infisical-merge/packages/models"
+)
+
+// rawSecretsStub is an in-memory stub of the v3 raw secrets API
+type rawSecretsStub struct {
+	mu       sync.Mutex
+	secrets  map[string]string
+	comments map[string]string
+	calls    []string
+
+	withoutBatch bool
+	// requests failing with a server error, e.g. "PATCH /api/v3/secrets/raw/B"
+	failing map[string]bool
+}
+
+func newRawSecretsStub(t *testing.T, secrets map[string]string) *rawSecretsStub {
+	stub := &rawSecretsStub{secrets: secrets, comments: map[string]string{}, failing: map[string]bool{}}
+
+	server := httptest.NewServer(stub)
+	t.Cleanup(server.Close)
+
+	previousUrl := config.INFISICAL_URL
+	config.INFISICAL_URL = server.URL + "/api"
+	t.Cleanup(func() { config.INFISICAL_URL = previousUrl })
+
+	return stub
+}
+
+func (stub *rawSecretsStub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	stub.mu.Lock()
+	defer stub.mu.Unlock()
+
+	call := r.Method + " " + r.URL.Path
+	stub.calls = append(stub.calls, call)
+	w.Header().Set("Content-Type", "application/json")
+
+	if stub.failing[call] {
+		http.Error(w, `{"message":"failed"}`, http.StatusInternalServerError)
+		return
+	}
+
+	var body struct {
+		SecretValue string `json:"secretValue"`
+		Secrets     []struct {
+			SecretKey     string `json:"secretKey"`
+			SecretValue   string `json:"secretValue"`
+			SecretComment string `json:"secretComment"`
+		} `json:"secrets"`
+	}
+	json.NewDecoder(r.Body).Decode(&body)
+
+	switch {
+	case call == "GET /api/v3/secrets/raw":
+		secrets := []map[string]string{}
+		for key, value := range stub.secrets {
+			secrets = append(secrets, map[string]string{"secretKey": key, "secretValue": value, "type": SECRET_TYPE_SHARED})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"secrets": secrets, "imports": []interface{}{}})
+	case strings.HasPrefix(call, "GET /api/v1/workspace/"):
+		fmt.Fprint(w, `{"workspace":{"id":"project-id","name":"project","slug":"project-slug"}}`)
+	case strings.HasSuffix(call, " /api/v3/secrets/batch/raw"):
+		if stub.withoutBatch {
+			http.Error(w, fmt.Sprintf(`{"message":"Route %s:%s not found","error":"Not Found","statusCode":404}`, r.Method, r.URL.Path), http.StatusNotFound)
+			return
+		}
+		for _, secret := range body.Secrets {
+			stub.secrets[secret.SecretKey] = secret.SecretValue
+			if secret.SecretComment != "" {
+				stub.comments[secret.SecretKey] = secret.SecretComment
+			}
+		}
+		fmt.Fprint(w, `{"secrets":[]}`)
+	case strings.HasPrefix(r.URL.Path, "/api/v3/secrets/raw/"):
+		key := strings.TrimPrefix(r.URL.Path, "/api/v3/secrets/raw/")
+		if r.Method == http.MethodDelete {
+			delete(stub.secrets, key)
+		} else {
+			stub.secrets[key] = body.SecretValue
+		}
+		fmt.Fprint(w, `{}`)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func setRawTestSecrets(secrets []models.SingleEnvironmentVariable) ([]models.SecretSetOperation, error) {
+	tokenDetails := &models.TokenDetails{Type: UNIVERSAL_AUTH_TOKEN_IDENTIFIER, Token: "token"}
+	return SetRawSecretsFromList(secrets, SECRET_TYPE_SHARED, "dev", "/", "project-id", tokenDetails, nil)
+}
+
+var rawTestSecretsToSet = []models.SingleEnvironmentVariable{
+	{Key: "A", Value: "new"},
+	{Key: "B", Value: "changed"},
+	{Key: "C", Value: "same"},
+}
+
+func TestSetRawSecretsUsesBatchEndpoints(t *testing.T) {
+	stub := newRawSecretsStub(t, map[string]string{"B": "old", "C": "same"})
+
+	operations, err := setRawTestSecrets(rawTestSecretsToSet)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	statuses := []string{}
+	for _, operation := range operations {
+		statuses = append(statuses, operation.SecretKey+" "+operation.SecretOperation)
+	}
+	if !reflect.DeepEqual(statuses, []string{"A SECRET CREATED", "B SECRET VALUE MODIFIED", "C SECRET VALUE UNCHANGED"}) {
+		t.Errorf("Unexpected operations %v", statuses)
+	}
+
+	expectedCalls := []string{"GET /api/v3/secrets/raw", "GET /api/v1/workspace/project-id", "POST /api/v3/secrets/batch/raw", "PATCH /api/v3/secrets/batch/raw"}
+	if !reflect.DeepEqual(stub.calls, expectedCalls) {
+		t.Errorf("Expected the calls %v, got %v", expectedCalls, stub.calls)
+	}
+
+	if !reflect.DeepEqual(stub.secrets, map[string]string{"A": "new", "B": "changed", "C": "same"}) {
+		t.Errorf("Unexpected secrets %v", stub.secrets)
+	}
+}
+
+func TestSetRawSecretsFallsBackWithoutBatchEndpoints(t *testing.T) {
+	stub := newRawSecretsStub(t, map[string]string{"B": "old", "C": "same"})
+	stub.withoutBatch = true
+
+	if _, err := setRawTestSecrets(rawTestSecretsToSet); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(stub.secrets, map[string]string{"A": "new", "B": "changed", "C": "same"}) {
+		t.Errorf("Unexpected secrets %v", stub.secrets)
+	}
+}
+
+func TestSetRawSecretsUpdatesCommentsThroughBatch(t *testing.T) {
+	secrets := []models.SingleEnvironmentVariable{{Key: "A", Value: "new"}, {Key: "C", Value: "same", Comment: "owned by the api team"}}
+
+	stub := newRawSecretsStub(t, map[string]string{"C": "same"})
+	operations, err := setRawTestSecrets(secrets)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if operations[1].SecretOperation != "SECRET COMMENT MODIFIED" || stub.comments["C"] != "owned by the api team" {
+		t.Errorf("Expected the comment of C to be updated, got %+v and the comments %v", operations, stub.comments)
+	}
+
+	// without the batch endpoint the comment can not be updated, so nothing is changed instead of dropping it
+	stub = newRawSecretsStub(t, map[string]string{"C": "same"})
+	stub.withoutBatch = true
+	_, err = setRawTestSecrets(secrets)
+
+	var setSecretsError *SetSecretsError
+	if !errors.As(err, &setSecretsError) || !strings.Contains(err.Error(), "comment of C") {
+		t.Fatalf("Expected the comment change to fail, got %v", err)
+	}
+	if !reflect.DeepEqual(stub.secrets, map[string]string{"C": "same"}) || len(stub.comments) != 0 {
+		t.Errorf("Expected the created secret to be rolled back, got %v %v", stub.secrets, stub.comments)
+	}
+}
+
+func TestSetRawSecretsRollsBackOnFailure(t *testing.T) {
+	tests := []struct {
+		name            string
+		withoutBatch    bool
+		failing         []string
+		expectedSecrets map[string]string
+		rolledBack      []string
+		applied         []string
+	}{
+		{
+			name:            "batch",
+			failing:         []string{"PATCH /api/v3/secrets/batch/raw"},
+			expectedSecrets: map[string]string{"B": "old", "C": "same", "D": "kept"},
+			rolledBack:      []string{"A"},
+		},
+		{
+			name:            "one by one",
+			withoutBatch:    true,
+			failing:         []string{"PATCH /api/v3/secrets/raw/D"},
+			expectedSecrets: map[string]string{"B": "old", "C": "same", "D": "kept"},
+			rolledBack:      []string{"A", "B"},
+		},
+		{
+			name:            "failed rollback",
+			withoutBatch:    true,
+			failing:         []string{"PATCH /api/v3/secrets/raw/D", "DELETE /api/v3/secrets/raw/A"},
+			expectedSecrets: map[string]string{"A": "new", "B": "old", "C": "same", "D": "kept"},
+			rolledBack:      []string{"B"},
+			applied:         []string{"A"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			stub := newRawSecretsStub(t, map[string]string{"B": "old", "C": "same", "D": "kept"})
+			stub.withoutBatch = test.withoutBatch
+			for _, call := range test.failing {
+				stub.failing[call] = true
+			}
+
+			_, err := setRawTestSecrets(append(rawTestSecretsToSet, models.SingleEnvironmentVariable{Key: "D", Value: "fails"}))
+
+			var setSecretsError *SetSecretsError
+			if !errors.As(err, &setSecretsError) {
+				t.Fatalf("Expected a SetSecretsError, got %v", err)
+			}
+
+			if !reflect.DeepEqual(stub.secrets, test.expectedSecrets) {
+				t.Errorf("Expected the secrets %v after the rollback, got %v", test.expectedSecrets, stub.secrets)
+			}
+
+			sort.Strings(setSecretsError.RolledBackKeys)
+			if !reflect.DeepEqual(setSecretsError.RolledBackKeys, test.rolledBack) || !reflect.DeepEqual(setSecretsError.AppliedKeys, test.applied) {
+				t.Errorf("Expected %v to be rolled back and %v to be applied, got %+v", test.rolledBack, test.applied, setSecretsError)
+			}
+		})
+	}
+}
@@ -0,0 +1,187 @@
+package util
+
+import (
+	"crypto/rand"
+	_ "embed"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// the formats of generated values
+const (
+	SECRET_GENERATOR_FORMAT_CHARS      = "chars"
+	SECRET_GENERATOR_FORMAT_HEX        = "hex"
+	SECRET_GENERATOR_FORMAT_BASE64     = "base64"
+	SECRET_GENERATOR_FORMAT_UUID       = "uuid"
+	SECRET_GENERATOR_FORMAT_PASSPHRASE = "passphrase"
+)
+
+const DEFAULT_GENERATED_SECRET_LENGTH = 32
+const DEFAULT_GENERATED_PASSPHRASE_WORDS = 6
+
+// the character classes of the chars format
+var secretGeneratorCharsets = map[string]string{
+	"lower":   "abcdefghijklmnopqrstuvwxyz",
+	"upper":   "ABCDEFGHIJKLMNOPQRSTUVWXYZ",
+	"digits":  "0123456789",
+	"symbols": "!#$%&()*+,-./:;<=>?@[]^_{|}~",
+}
+
+var DEFAULT_SECRET_GENERATOR_CHARSETS = []string{"lower", "upper", "digits"}
+
+// the EFF large wordlist (https://www.eff.org/files/2016/07/18/eff_large_wordlist.txt), 7776 words or about 12.9 bits
+// of entropy per word, so the 6 words of a default passphrase are about 77 bits
+//
+//go:embed passphrase-words.txt
+var passphraseWordList string
+
+type SecretGeneratorOptions struct {
+	// chars, hex, base64, uuid or passphrase
+	Format string
+	// characters for chars, random bytes for hex and base64, words for passphrase. Zero uses the default of the format
+	Length int
+	// the character classes of the chars format: lower, upper, digits and symbols
+	Charsets []string
+	// characters that the chars format never uses
+	Exclude string
+	// the separator between the words of a passphrase
+	Separator string
+}
+
+// GenerateSecretValue returns a random value read from crypto/rand
+func GenerateSecretValue(options SecretGeneratorOptions) (string, error) {
+	if options.Length < 0 {
+		return "", fmt.Errorf("the length of a generated value must be positive, got %d", options.Length)
+	}
+
+	switch options.Format {
+	case "", SECRET_GENERATOR_FORMAT_CHARS:
+		return generateFromCharsets(options)
+	case SECRET_GENERATOR_FORMAT_HEX, SECRET_GENERATOR_FORMAT_BASE64:
+		length := options.Length
+		if length == 0 {
+			length = DEFAULT_GENERATED_SECRET_LENGTH
+		}
+
+		randomBytes := make([]byte, length)
+		if _, err := rand.Read(randomBytes); err != nil {
+			return "", fmt.Errorf("unable to generate a random value [err=%v]", err)
+		}
+
+		if options.Format == SECRET_GENERATOR_FORMAT_HEX {
+			return hex.EncodeToString(randomBytes), nil
+		}
+		return base64.StdEncoding.EncodeToString(randomBytes), nil
+	case SECRET_GENERATOR_FORMAT_UUID:
+		randomBytes := make([]byte, 16)
+		if _, err := rand.Read(randomBytes); err != nil {
+			return "", fmt.Errorf("unable to generate a random value [err=%v]", err)
+		}
+
+		// version 4, variant 10
+		randomBytes[6] = (randomBytes[6] & 0x0f) | 0x40
+		randomBytes[8] = (randomBytes[8] & 0x3f) | 0x80
+		return fmt.Sprintf("%x-%x-%x-%x-%x", randomBytes[0:4], randomBytes[4:6], randomBytes[6:8], randomBytes[8:10], randomBytes[10:16]), nil
+	case SECRET_GENERATOR_FORMAT_PASSPHRASE:
+		words := options.Length
+		if words == 0 {
+			words = DEFAULT_GENERATED_PASSPHRASE_WORDS
+		}
+
+		separator := options.Separator
+		if separator == "" {
+			separator = "-"
+		}
+
+		wordList := strings.Fields(passphraseWordList)
+		passphrase := make([]string, words)
+		for i := range passphrase {
+			index, err := randomIndex(len(wordList))
+			if err != nil {
+				return "", err
+			}
+			passphrase[i] = wordList[index]
+		}
+
+		return strings.Join(passphrase, separator), nil
+	default:
+		return "", fmt.Errorf("invalid format: %s. Available formats are [chars hex base64 uuid passphrase]", options.Format)
+	}
+}
+
+// generateFromCharsets picks the characters of the classes, without the excluded ones, and makes sure each class is
+// used at least once so the value passes password policies that require them
+func generateFromCharsets(options SecretGeneratorOptions) (string, error) {
+	length := options.Length
+	if length == 0 {
+		length = DEFAULT_GENERATED_SECRET_LENGTH
+	}
+
+	charsetNames := options.Charsets
+	if len(charsetNames) == 0 {
+		charsetNames = DEFAULT_SECRET_GENERATOR_CHARSETS
+	}
+
+	classes := []string{}
+	for _, name := range charsetNames {
+		charset, ok := secretGeneratorCharsets[name]
+		if !ok {
+			return "", fmt.Errorf("invalid character class: %s. Available classes are [lower upper digits symbols]", name)
+		}
+
+		charset = strings.Map(func(character rune) rune {
+			if strings.ContainsRune(options.Exclude, character) {
+				return -1
+			}
+			return character
+		}, charset)
+
+		if charset == "" {
+			return "", fmt.Errorf("all characters of the class %s are excluded", name)
+		}
+		classes = append(classes, charset)
+	}
+
+	if length < len(classes) {
+		return "", fmt.Errorf("the length %d is too short to use each of the %d character classes", length, len(classes))
+	}
+
+	allCharacters := strings.Join(classes, "")
+	value := make([]byte, length)
+	for i := range value {
+		// the first characters come from each class in turn, they are shuffled below
+		charset := allCharacters
+		if i < len(classes) {
+			charset = classes[i]
+		}
+
+		index, err := randomIndex(len(charset))
+		if err != nil {
+			return "", err
+		}
+		value[i] = charset[index]
+	}
+
+	// Fisher-Yates shuffle
+	for i := len(value) - 1; i > 0; i-- {
+		j, err := randomIndex(i + 1)
+		if err != nil {
+			return "", err
+		}
+		value[i], value[j] = value[j], value[i]
+	}
+
+	return string(value), nil
+}
+
+func randomIndex(max int) (int, error) {
+	index, err := rand.Int(rand.Reader, big.NewInt(int64(max)))
+	if err != nil {
+		return 0, fmt.Errorf("unable to generate a random value [err=%v]", err)
+	}
+
+	return int(index.Int64()), nil
+}
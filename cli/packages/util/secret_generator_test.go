@@ -0,0 +1,86 @@
+package util
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestGenerateSecretValueFormats(t *testing.T) {
+	tests := []struct {
+		options SecretGeneratorOptions
+		check   func(value string) bool
+	}{
+		{SecretGeneratorOptions{}, regexp.MustCompile(`^[a-zA-Z0-9]{32}$`).MatchString},
+		{SecretGeneratorOptions{Format: SECRET_GENERATOR_FORMAT_HEX, Length: 16}, func(value string) bool {
+			decoded, err := hex.DecodeString(value)
+			return err == nil && len(decoded) == 16
+		}},
+		{SecretGeneratorOptions{Format: SECRET_GENERATOR_FORMAT_BASE64, Length: 24}, func(value string) bool {
+			decoded, err := base64.StdEncoding.DecodeString(value)
+			return err == nil && len(decoded) == 24
+		}},
+		{SecretGeneratorOptions{Format: SECRET_GENERATOR_FORMAT_UUID}, regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`).MatchString},
+		{SecretGeneratorOptions{Format: SECRET_GENERATOR_FORMAT_PASSPHRASE, Length: 4, Separator: "."}, regexp.MustCompile(`^[a-z-]+(\.[a-z-]+){3}$`).MatchString},
+	}
+
+	for _, test := range tests {
+		value, err := GenerateSecretValue(test.options)
+		if err != nil {
+			t.Fatalf("GenerateSecretValue(%+v) returned %v", test.options, err)
+		}
+		if !test.check(value) {
+			t.Errorf("GenerateSecretValue(%+v) = %q", test.options, value)
+		}
+	}
+}
+
+func TestPassphraseWordList(t *testing.T) {
+	words := strings.Fields(passphraseWordList)
+
+	unique := map[string]bool{}
+	for _, word := range words {
+		unique[word] = true
+	}
+
+	if len(words) != 7776 || len(unique) != len(words) {
+		t.Errorf("expected the 7776 distinct words of the EFF large wordlist, got %d words of which %d are distinct", len(words), len(unique))
+	}
+}
+
+func TestGenerateSecretValueCharsets(t *testing.T) {
+	options := SecretGeneratorOptions{Length: 4, Charsets: []string{"lower", "upper", "digits", "symbols"}, Exclude: "0O1lI"}
+
+	// each class must be used even when the value is as short as the number of classes
+	for i := 0; i < 50; i++ {
+		value, err := GenerateSecretValue(options)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, charset := range []string{"lower", "upper", "digits", "symbols"} {
+			if !strings.ContainsAny(value, secretGeneratorCharsets[charset]) {
+				t.Fatalf("%q does not contain a character of %s", value, charset)
+			}
+		}
+
+		if strings.ContainsAny(value, options.Exclude) {
+			t.Fatalf("%q contains an excluded character", value)
+		}
+	}
+
+	invalid := []SecretGeneratorOptions{
+		{Charsets: []string{"emoji"}},
+		{Charsets: []string{"digits"}, Exclude: "0123456789"},
+		{Length: 2, Charsets: []string{"lower", "upper", "digits"}},
+		{Format: "words"},
+		{Length: -1},
+	}
+	for _, options := range invalid {
+		if _, err := GenerateSecretValue(options); err == nil {
+			t.Errorf("expected an error for %+v", options)
+		}
+	}
+}
@@ -0,0 +1,277 @@
+package util
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/Infisical/infisical-merge/packages/api"
+	"github.com/Infisical/infisical-merge/packages/models"
+	"github.com/go-resty/resty/v2"
+)
+
+// the depth at which the backend stops following nested imports
+const SECRET_IMPORT_MAX_DEPTH = 10
+
+func newSecretImportsClient(params models.GetAllSecretsParameters) (*resty.Client, string, error) {
+	projectId := params.WorkspaceId
+	var token string
+
+	if params.InfisicalToken != "" {
+		serviceTokenParts := strings.SplitN(params.InfisicalToken, ".", 4)
+		if len(serviceTokenParts) < 4 {
+			return nil, "", fmt.Errorf("invalid service token entered. Please double check your service token and try again")
+		}
+		token = fmt.Sprintf("%v.%v.%v", serviceTokenParts[0], serviceTokenParts[1], serviceTokenParts[2])
+
+		if projectId == "" {
+			serviceTokenProjectId, err := GetServiceTokenProjectId(params.InfisicalToken)
+			if err != nil {
+				return nil, "", err
+			}
+			projectId = serviceTokenProjectId
+		}
+	} else if params.UniversalAuthAccessToken != "" {
+		if projectId == "" {
+			PrintErrorMessageAndExit("Project ID is required when using machine identity")
+		}
+		token = params.UniversalAuthAccessToken
+	} else {
+		RequireLogin()
+		RequireLocalWorkspaceFile()
+
+		loggedInUserDetails, err := GetCurrentLoggedInUserDetails()
+		if err != nil {
+			return nil, "", fmt.Errorf("unable to authenticate [err=%v]", err)
+		}
+
+		if loggedInUserDetails.LoginExpired {
+			PrintErrorMessageAndExit("Your login session has expired, please run [infisical login] and try again")
+		}
+
+		if projectId == "" {
+			workspaceFile, err := GetWorkSpaceFromFile()
+			if err != nil {
+				return nil, "", fmt.Errorf("unable to get your local config details [err=%v]", err)
+			}
+			projectId = workspaceFile.WorkspaceId
+		}
+		token = loggedInUserDetails.UserCredentials.JTWToken
+	}
+
+	httpClient := resty.New().
+		SetAuthToken(token).
+		SetHeader("Accept", "application/json")
+
+	return httpClient, projectId, nil
+}
+
+// GetSecretImports returns the imports of the folder of params, ordered by position. Secrets of imports with a higher
+// position take precedence over the ones of lower positions, and secrets of the folder itself over all imports.
+func GetSecretImports(params models.GetAllSecretsParameters) ([]models.SecretImport, error) {
+	httpClient, projectId, err := newSecretImportsClient(params)
+	if err != nil {
+		return nil, err
+	}
+
+	return getSecretImports(httpClient, projectId, params.Environment, params.SecretsPath, false)
+}
+
+// getSecretImports returns the imports of the folder ordered by position. Reserved imports, which hold the replicated
+// secrets of replication imports, are only included with includeReserved
+func getSecretImports(httpClient *resty.Client, projectId string, environment string, secretPath string, includeReserved bool) ([]models.SecretImport, error) {
+	response, err := api.CallGetSecretImportsV1(httpClient, api.GetSecretImportsV1Request{
+		WorkspaceId: projectId,
+		Environment: environment,
+		Path:        secretPath,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	secretImports := []models.SecretImport{}
+	for _, secretImport := range response.SecretImports {
+		// reserved imports hold the replicated secrets of replication imports, they are not managed directly
+		if secretImport.IsReserved && !includeReserved {
+			continue
+		}
+
+		secretImports = append(secretImports, models.SecretImport{
+			ID:            secretImport.ID,
+			Environment:   secretImport.ImportEnv.Slug,
+			Path:          secretImport.ImportPath,
+			Position:      secretImport.Position,
+			IsReplication: secretImport.IsReplication,
+		})
+	}
+
+	sort.SliceStable(secretImports, func(i, j int) bool {
+		return secretImports[i].Position < secretImports[j].Position
+	})
+
+	return secretImports, nil
+}
+
+// AddSecretImport imports the secrets of the environment and folder into the folder of params, after its other imports
+func AddSecretImport(params models.GetAllSecretsParameters, environment string, secretPath string) error {
+	httpClient, projectId, err := newSecretImportsClient(params)
+	if err != nil {
+		return err
+	}
+
+	return api.CallCreateSecretImportV1(httpClient, api.CreateSecretImportV1Request{
+		WorkspaceId: projectId,
+		Environment: params.Environment,
+		Path:        params.SecretsPath,
+		Import: api.SecretImportSourceV1{
+			Environment: environment,
+			Path:        secretPath,
+		},
+	})
+}
+
+// RemoveSecretImport removes the import of the environment and folder from the folder of params
+func RemoveSecretImport(params models.GetAllSecretsParameters, environment string, secretPath string) error {
+	httpClient, projectId, err := newSecretImportsClient(params)
+	if err != nil {
+		return err
+	}
+
+	secretImport, err := findSecretImport(httpClient, projectId, params, environment, secretPath)
+	if err != nil {
+		return err
+	}
+
+	return api.CallDeleteSecretImportV1(httpClient, api.DeleteSecretImportV1Request{
+		SecretImportId: secretImport.ID,
+		WorkspaceId:    projectId,
+		Environment:    params.Environment,
+		Path:           params.SecretsPath,
+	})
+}
+
+// MoveSecretImport moves the import of the environment and folder to the position, the imports in between shift by one
+func MoveSecretImport(params models.GetAllSecretsParameters, environment string, secretPath string, position int) error {
+	httpClient, projectId, err := newSecretImportsClient(params)
+	if err != nil {
+		return err
+	}
+
+	secretImport, err := findSecretImport(httpClient, projectId, params, environment, secretPath)
+	if err != nil {
+		return err
+	}
+
+	return api.CallUpdateSecretImportV1(httpClient, api.UpdateSecretImportV1Request{
+		SecretImportId: secretImport.ID,
+		WorkspaceId:    projectId,
+		Environment:    params.Environment,
+		Path:           params.SecretsPath,
+		Import:         api.SecretImportSourceV1{Position: position},
+	})
+}
+
+func findSecretImport(httpClient *resty.Client, projectId string, params models.GetAllSecretsParameters, environment string, secretPath string) (models.SecretImport, error) {
+	secretImports, err := getSecretImports(httpClient, projectId, params.Environment, params.SecretsPath, false)
+	if err != nil {
+		return models.SecretImport{}, err
+	}
+
+	for _, secretImport := range secretImports {
+		if secretImport.Environment == environment && path.Clean(secretImport.Path) == path.Clean(secretPath) {
+			return secretImport, nil
+		}
+	}
+
+	return models.SecretImport{}, fmt.Errorf("%s:%s does not import %s:%s", params.Environment, params.SecretsPath, environment, secretPath)
+}
+
+// GetSecretImportChain returns the folder of params with its imports, followed the way the backend resolves them: level
+// by level and up to SECRET_IMPORT_MAX_DEPTH levels deep. An import is not followed when the same folder is imported at
+// a level above, while the imports of a single level are all followed, even the same folder twice. The folder the
+// chain starts at is not part of the imported folders, so an import of it is followed once. Below the first level the
+// backend skips replication imports and reads the replicated secrets from their reserved imports instead
+func GetSecretImportChain(params models.GetAllSecretsParameters) (models.SecretImportChain, error) {
+	httpClient, projectId, err := newSecretImportsClient(params)
+	if err != nil {
+		return models.SecretImportChain{}, err
+	}
+
+	params.WorkspaceId = projectId
+	params.IncludeImport = false
+	params.Recursive = false
+	params.TagSlugs = ""
+
+	chain := models.SecretImportChain{Environment: params.Environment, Path: path.Clean("/" + params.SecretsPath)}
+	imported := map[string]bool{}
+
+	level := []*models.SecretImportChain{&chain}
+	for depth := 0; len(level) > 0; depth++ {
+		nextLevel := []*models.SecretImportChain{}
+		levelKeys := []string{}
+		for _, folder := range level {
+			if err := addSecretImportChainKeys(params, folder); err != nil {
+				return models.SecretImportChain{}, err
+			}
+
+			if depth >= SECRET_IMPORT_MAX_DEPTH {
+				continue
+			}
+
+			isDeeperImport := depth > 0
+			secretImports, err := getSecretImports(httpClient, projectId, folder.Environment, folder.Path, isDeeperImport)
+			if err != nil {
+				return models.SecretImportChain{}, fmt.Errorf("unable to get the imports of %s:%s [err=%v]", folder.Environment, folder.Path, err)
+			}
+
+			folder.Imports = []models.SecretImportChain{}
+			for _, secretImport := range secretImports {
+				if isDeeperImport && secretImport.IsReplication {
+					continue
+				}
+
+				importedFolder := models.SecretImportChain{Environment: secretImport.Environment, Path: path.Clean(secretImport.Path), Position: secretImport.Position}
+				key := importedFolder.Environment + ":" + importedFolder.Path
+				importedFolder.Cycle = imported[key]
+				levelKeys = append(levelKeys, key)
+				folder.Imports = append(folder.Imports, importedFolder)
+			}
+
+			for i := range folder.Imports {
+				if !folder.Imports[i].Cycle {
+					nextLevel = append(nextLevel, &folder.Imports[i])
+				}
+			}
+		}
+
+		// like the backend, the imports of a level are marked once the whole level is read
+		for _, key := range levelKeys {
+			imported[key] = true
+		}
+		level = nextLevel
+	}
+
+	return chain, nil
+}
+
+func addSecretImportChainKeys(params models.GetAllSecretsParameters, folder *models.SecretImportChain) error {
+	params.Environment = folder.Environment
+	params.SecretsPath = folder.Path
+
+	secrets, err := GetAllEnvironmentVariables(params, "")
+	if err != nil {
+		return fmt.Errorf("unable to get the secrets of %s:%s [err=%v]", folder.Environment, folder.Path, err)
+	}
+
+	folder.Keys = []string{}
+	for _, secret := range secrets {
+		// only shared secrets are imported
+		if secret.Type == "" || secret.Type == SECRET_TYPE_SHARED {
+			folder.Keys = append(folder.Keys, secret.Key)
+		}
+	}
+	sort.Strings(folder.Keys)
+
+	return nil
+}
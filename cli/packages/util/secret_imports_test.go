@@ -0,0 +1,146 @@
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Infisical/infisical-merge/packages/api"
+	"github.com/Infisical/infisical-merge/packages/config"
+	"github.com/Infisical/infisical-merge/packages/models"
+)
+
+func TestSecretImports(t *testing.T) {
+	var update api.UpdateSecretImportV1Request
+	calls := []string{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		calls = append(calls, r.Method+" "+r.URL.Path)
+
+		switch r.Method {
+		case http.MethodGet:
+			if r.URL.Query().Get("environment") != "dev" || r.URL.Query().Get("path") != "/api" {
+				t.Errorf("unexpected folder %s", r.URL.RawQuery)
+			}
+			w.Write([]byte(`{"secretImports": [
+				{"id": "import-2", "importPath": "/database", "position": 2, "isReplication": true, "importEnv": {"slug": "shared"}},
+				{"id": "reserved", "importPath": "/api/__reserve_replication_import-2", "position": 3, "isReserved": true, "importEnv": {"slug": "dev"}},
+				{"id": "import-1", "importPath": "/common", "position": 1, "importEnv": {"slug": "shared"}}
+			]}`))
+		case http.MethodPatch:
+			json.NewDecoder(r.Body).Decode(&update)
+			w.Write([]byte(`{}`))
+		case http.MethodDelete:
+			w.Write([]byte(`{}`))
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	previousUrl := config.INFISICAL_URL
+	config.INFISICAL_URL = server.URL + "/api"
+	t.Cleanup(func() { config.INFISICAL_URL = previousUrl })
+
+	params := models.GetAllSecretsParameters{Environment: "dev", SecretsPath: "/api", WorkspaceId: "project-id", UniversalAuthAccessToken: "access-token"}
+
+	secretImports, err := GetSecretImports(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(secretImports) != 2 || secretImports[0].ID != "import-1" || secretImports[1].Path != "/database" || !secretImports[1].IsReplication {
+		t.Errorf("expected the two imports ordered by position without the reserved one, got %+v", secretImports)
+	}
+
+	if err := MoveSecretImport(params, "shared", "/database/", 1); err != nil {
+		t.Fatal(err)
+	}
+	if update.Import.Position != 1 || update.WorkspaceId != "project-id" || calls[len(calls)-1] != "PATCH /api/v1/secret-imports/import-2" {
+		t.Errorf("unexpected update %+v with calls %v", update, calls)
+	}
+
+	if err := RemoveSecretImport(params, "shared", "/common"); err != nil {
+		t.Fatal(err)
+	}
+	if calls[len(calls)-1] != "DELETE /api/v1/secret-imports/import-1" {
+		t.Errorf("expected import-1 to be deleted, got calls %v", calls)
+	}
+
+	if err := RemoveSecretImport(params, "prod", "/common"); err == nil {
+		t.Errorf("expected an error for an import that does not exist")
+	}
+}
+
+func TestGetSecretImportChainFollowsTheBackend(t *testing.T) {
+	secretImports := map[string]string{
+		"dev:/app": `{"importPath": "/a", "position": 1, "importEnv": {"slug": "shared"}},
+			{"importPath": "/b", "position": 2, "importEnv": {"slug": "shared"}}`,
+		// the folder the chain starts at is imported once more
+		"shared:/a": `{"importPath": "/c", "position": 1, "importEnv": {"slug": "shared"}},
+			{"importPath": "/app", "position": 2, "importEnv": {"slug": "dev"}}`,
+		// below the first level the replication import is skipped and its reserved import is followed
+		"shared:/b": `{"importPath": "/c", "position": 1, "importEnv": {"slug": "shared"}},
+			{"importPath": "/r", "position": 2, "isReplication": true, "importEnv": {"slug": "shared"}},
+			{"importPath": "/b/__reserve_replication_r", "position": 3, "isReserved": true, "importEnv": {"slug": "shared"}}`,
+		"shared:/c": `{"importPath": "/a", "position": 1, "importEnv": {"slug": "shared"}}`,
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		query := r.URL.Query()
+
+		switch r.URL.Path {
+		case "/api/v1/secret-imports":
+			fmt.Fprintf(w, `{"secretImports": [%s]}`, secretImports[query.Get("environment")+":"+query.Get("path")])
+		case "/api/v3/secrets/raw":
+			fmt.Fprintf(w, `{"secrets": [{"secretKey": "KEY_%s", "type": "shared"}], "imports": []}`, strings.Trim(query.Get("secretPath"), "/"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	previousUrl := config.INFISICAL_URL
+	config.INFISICAL_URL = server.URL + "/api"
+	t.Cleanup(func() { config.INFISICAL_URL = previousUrl })
+
+	chain, err := GetSecretImportChain(models.GetAllSecretsParameters{Environment: "dev", SecretsPath: "/app", WorkspaceId: "project-id", UniversalAuthAccessToken: "access-token"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var lines []string
+	var walk func(folder models.SecretImportChain, indent string)
+	walk = func(folder models.SecretImportChain, indent string) {
+		line := fmt.Sprintf("%s%s:%s %v", indent, folder.Environment, folder.Path, folder.Keys)
+		if folder.Cycle {
+			line = fmt.Sprintf("%s%s:%s skipped", indent, folder.Environment, folder.Path)
+		}
+		lines = append(lines, line)
+		for _, importedFolder := range folder.Imports {
+			walk(importedFolder, indent+"  ")
+		}
+	}
+	walk(chain, "")
+
+	// the same folder imported twice on one level is followed both times
+	expected := []string{
+		"dev:/app [KEY_app]",
+		"  shared:/a [KEY_a]",
+		"    shared:/c [KEY_c]",
+		"      shared:/a skipped",
+		"    dev:/app [KEY_app]",
+		"      shared:/a skipped",
+		"      shared:/b skipped",
+		"  shared:/b [KEY_b]",
+		"    shared:/c [KEY_c]",
+		"      shared:/a skipped",
+		"    shared:/b/__reserve_replication_r [KEY_b/__reserve_replication_r]",
+	}
+	if strings.Join(lines, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("unexpected chain:\n%s\nexpected:\n%s", strings.Join(lines, "\n"), strings.Join(expected, "\n"))
+	}
+}
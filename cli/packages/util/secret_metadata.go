@@ -0,0 +1,286 @@
+package util
+
+import (
+	"encoding/base64"
+	"fmt"
+	"slices"
+
+	"github.com/Infisical/infisical-merge/packages/api"
+	"github.com/Infisical/infisical-merge/packages/crypto"
+	"github.com/Infisical/infisical-merge/packages/models"
+	"github.com/go-resty/resty/v2"
+)
+
+// GetSecretDetails returns a single secret of the given type together with its comment and tags
+func GetSecretDetails(params models.GetAllSecretsParameters, secretName string, secretType string) (models.SingleEnvironmentVariable, error) {
+	if params.InfisicalToken == "" && params.UniversalAuthAccessToken == "" {
+		secrets, err := GetAllEnvironmentVariables(params, "")
+		if err != nil {
+			return models.SingleEnvironmentVariable{}, err
+		}
+
+		for _, secret := range secrets {
+			if secret.Key == secretName && secret.Type == secretType {
+				return secret, nil
+			}
+		}
+
+		return models.SingleEnvironmentVariable{}, fmt.Errorf("the %s secret %s does not exist in %s", secretType, secretName, params.SecretsPath)
+	}
+
+	token := params.InfisicalToken
+	if params.UniversalAuthAccessToken != "" {
+		if params.WorkspaceId == "" {
+			PrintErrorMessageAndExit("Project ID is required when using machine identity")
+		}
+		token = params.UniversalAuthAccessToken
+	}
+
+	httpClient := resty.New().
+		SetAuthToken(token).
+		SetHeader("Accept", "application/json")
+
+	response, err := api.CallGetRawSecretByNameV3(httpClient, api.GetRawSecretByNameV3Request{
+		SecretName:  secretName,
+		WorkspaceId: params.WorkspaceId,
+		Environment: params.Environment,
+		SecretPath:  params.SecretsPath,
+		Type:        secretType,
+	})
+	if err != nil {
+		return models.SingleEnvironmentVariable{}, err
+	}
+
+	tags := make([]models.SecretTag, len(response.Secret.Tags))
+	for i, tag := range response.Secret.Tags {
+		tags[i] = models.SecretTag{ID: tag.ID, Name: tag.Name, Slug: tag.Slug, Workspace: tag.Workspace}
+	}
+
+	secrets := []models.SingleEnvironmentVariable{{
+		Key:         response.Secret.SecretKey,
+		WorkspaceId: response.Secret.Workspace,
+		Value:       response.Secret.SecretValue,
+		Type:        response.Secret.Type,
+		ID:          response.Secret.ID,
+		Tags:        tags,
+		Comment:     response.Secret.SecretComment,
+	}}
+	setSecretSource(secrets, params.Environment, params.SecretsPath)
+
+	return secrets[0], nil
+}
+
+// AddSecretTags adds the tags to each of the secrets, tags the secrets already have are kept
+func AddSecretTags(params models.GetAllSecretsParameters, secretNames []string, secretType string, tagSlugs []string) error {
+	target, err := newSecretTagsTarget(params)
+	if err != nil {
+		return err
+	}
+
+	for _, secretName := range secretNames {
+		err := api.CallAttachSecretTagsV3(target.httpClient, api.SecretTagsV3Request{
+			SecretName:  secretName,
+			ProjectSlug: target.projectSlug,
+			Environment: params.Environment,
+			SecretPath:  params.SecretsPath,
+			Type:        secretType,
+			TagSlugs:    tagSlugs,
+		})
+		if err != nil {
+			return fmt.Errorf("unable to add the tags to %s [err=%v]", secretName, err)
+		}
+	}
+
+	return nil
+}
+
+// RemoveSecretTags removes the tags from each of the secrets. Machine identities detach the tags, logged in users
+// update the secrets with the tags that are left since only machine identities can detach tags.
+func RemoveSecretTags(params models.GetAllSecretsParameters, secretNames []string, secretType string, tagSlugs []string) error {
+	target, err := newSecretTagsTarget(params)
+	if err != nil {
+		return err
+	}
+
+	if target.loggedInUserDetails == nil {
+		for _, secretName := range secretNames {
+			err := api.CallDetachSecretTagsV3(target.httpClient, api.SecretTagsV3Request{
+				SecretName:  secretName,
+				ProjectSlug: target.projectSlug,
+				Environment: params.Environment,
+				SecretPath:  params.SecretsPath,
+				Type:        secretType,
+				TagSlugs:    tagSlugs,
+			})
+			if err != nil {
+				return fmt.Errorf("unable to remove the tags from %s [err=%v]", secretName, err)
+			}
+		}
+
+		return nil
+	}
+
+	plainTextEncryptionKey, err := getPlainTextWorkspaceKey(target.httpClient, *target.loggedInUserDetails, target.projectId)
+	if err != nil {
+		return err
+	}
+
+	secrets, err := GetAllEnvironmentVariables(models.GetAllSecretsParameters{Environment: params.Environment, SecretsPath: params.SecretsPath, WorkspaceId: target.projectId}, "")
+	if err != nil {
+		return fmt.Errorf("unable to retrieve secrets [err=%v]", err)
+	}
+
+	for _, secretName := range secretNames {
+		index := slices.IndexFunc(secrets, func(secret models.SingleEnvironmentVariable) bool {
+			return secret.Key == secretName && secret.Type == secretType
+		})
+		if index == -1 {
+			return fmt.Errorf("the %s secret %s does not exist in %s", secretType, secretName, params.SecretsPath)
+		}
+		secret := secrets[index]
+
+		remainingTagIds := []string{}
+		for _, tag := range secret.Tags {
+			if slices.Contains(tagSlugs, tag.Slug) {
+				continue
+			}
+			if tag.ID == "" {
+				return fmt.Errorf("unable to remove the tags from %s, the ID of its tag %s is unknown", secretName, tag.Slug)
+			}
+			remainingTagIds = append(remainingTagIds, tag.ID)
+		}
+
+		if len(remainingTagIds) == len(secret.Tags) {
+			continue
+		}
+
+		encryptedValue, err := crypto.EncryptSymmetric([]byte(secret.Value), plainTextEncryptionKey)
+		if err != nil {
+			return fmt.Errorf("unable to encrypt your secrets [err=%v]", err)
+		}
+
+		updateSecretRequest := api.UpdateSecretByNameV3Request{
+			WorkspaceID:           target.projectId,
+			Environment:           params.Environment,
+			Type:                  secret.Type,
+			SecretPath:            params.SecretsPath,
+			SecretValueCiphertext: base64.StdEncoding.EncodeToString(encryptedValue.CipherText),
+			SecretValueIV:         base64.StdEncoding.EncodeToString(encryptedValue.Nonce),
+			SecretValueTag:        base64.StdEncoding.EncodeToString(encryptedValue.AuthTag),
+			Tags:                  &remainingTagIds,
+		}
+
+		if err := api.CallUpdateSecretsV3(target.httpClient, updateSecretRequest, secretName); err != nil {
+			return fmt.Errorf("unable to remove the tags from %s [err=%v]", secretName, err)
+		}
+	}
+
+	return nil
+}
+
+type secretTagsTarget struct {
+	httpClient  *resty.Client
+	projectId   string
+	projectSlug string
+	// set when the tags are changed with the credentials of the logged in user
+	loggedInUserDetails *LoggedInUserDetails
+}
+
+func newSecretTagsTarget(params models.GetAllSecretsParameters) (secretTagsTarget, error) {
+	if params.InfisicalToken != "" {
+		return secretTagsTarget{}, fmt.Errorf("tags cannot be changed with a service token, log in or use a machine identity instead")
+	}
+
+	target := secretTagsTarget{projectId: params.WorkspaceId}
+	token := params.UniversalAuthAccessToken
+
+	if token == "" {
+		RequireLogin()
+		RequireLocalWorkspaceFile()
+
+		loggedInUserDetails, err := GetCurrentLoggedInUserDetails()
+		if err != nil {
+			return secretTagsTarget{}, fmt.Errorf("unable to authenticate [err=%v]", err)
+		}
+
+		if loggedInUserDetails.LoginExpired {
+			PrintErrorMessageAndExit("Your login session has expired, please run [infisical login] and try again")
+		}
+
+		if target.projectId == "" {
+			workspaceFile, err := GetWorkSpaceFromFile()
+			if err != nil {
+				return secretTagsTarget{}, fmt.Errorf("unable to get your local config details [err=%v]", err)
+			}
+			target.projectId = workspaceFile.WorkspaceId
+		}
+
+		target.loggedInUserDetails = &loggedInUserDetails
+		token = loggedInUserDetails.UserCredentials.JTWToken
+	} else if target.projectId == "" {
+		PrintErrorMessageAndExit("Project ID is required when using machine identity")
+	}
+
+	target.httpClient = resty.New().
+		SetAuthToken(token).
+		SetHeader("Accept", "application/json")
+
+	projectSlug, err := GetProjectSlug(token, target.projectId)
+	if err != nil {
+		return secretTagsTarget{}, err
+	}
+	target.projectSlug = projectSlug
+
+	return target, nil
+}
+
+// DeleteSecrets deletes the secrets of the given type from the environment and folder of params
+func DeleteSecrets(params models.GetAllSecretsParameters, secretType string, secretNames []string) error {
+	httpClient := resty.New().
+		SetHeader("Accept", "application/json")
+
+	projectId := params.WorkspaceId
+	if params.InfisicalToken != "" {
+		httpClient.SetAuthToken(params.InfisicalToken)
+	} else if params.UniversalAuthAccessToken != "" {
+		httpClient.SetAuthToken(params.UniversalAuthAccessToken)
+	} else {
+		RequireLogin()
+		RequireLocalWorkspaceFile()
+
+		loggedInUserDetails, err := GetCurrentLoggedInUserDetails()
+		if err != nil {
+			return fmt.Errorf("unable to authenticate [err=%v]", err)
+		}
+
+		if loggedInUserDetails.LoginExpired {
+			PrintErrorMessageAndExit("Your login session has expired, please run [infisical login] and try again")
+		}
+
+		if projectId == "" {
+			workspaceFile, err := GetWorkSpaceFromFile()
+			if err != nil {
+				return fmt.Errorf("unable to get your local config details [err=%v]", err)
+			}
+			projectId = workspaceFile.WorkspaceId
+		}
+
+		httpClient.SetAuthToken(loggedInUserDetails.UserCredentials.JTWToken)
+	}
+
+	for _, secretName := range secretNames {
+		request := api.DeleteSecretV3Request{
+			WorkspaceId: projectId,
+			Environment: params.Environment,
+			SecretName:  secretName,
+			Type:        secretType,
+			SecretPath:  params.SecretsPath,
+		}
+
+		if err := api.CallDeleteSecretsV3(httpClient, request); err != nil {
+			return fmt.Errorf("unable to delete %s [err=%v]", secretName, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,451 @@
+package util
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/Infisical/infisical-merge/packages/models"
+)
+
+// The reference syntax and its semantics match the ones of the operator (k8-operator/packages/util/secret_references.go), keep them in sync.
+// The shared code is kept textually identical so the two copies can be diffed. Only the handling of references to
+// secrets that do not exist differs, on purpose: the CLI fails on them unless --allow-missing-references is set, while
+// the operator, which syncs unattended and has no such switch, expands them to an empty value and logs them, so that a
+// missing secret does not stop the sync of all the others.
+
+// SecretReference is a ${...} reference in a secret value. ${KEY} references a secret of the same folder, and
+// ${env.KEY} or ${env.folder.sub-folder.KEY} a secret of another environment or folder, where a segment can be quoted
+// when it contains dots: ${env."v1.2".KEY}. ${KEY:-default} expands to the default when the secret does not exist or is
+// empty, and $${KEY} is the literal text ${KEY}. A default can not hold a reference: the first } would end the outer
+// reference, so ${A:-${B}} is reported as a problem instead of being expanded.
+type SecretReference struct {
+	// the reference as written between ${ and }, without the default value
+	Reference string
+	// the key, or the environment, folders and key, without quotes
+	Parts      []string
+	Default    string
+	HasDefault bool
+}
+
+// replaceSecretReferences returns the value with each reference replaced by the result of replace, and $${ replaced by ${.
+// A ${ without a closing } is kept as is.
+func replaceSecretReferences(value string, replace func(reference SecretReference) string) string {
+	var output strings.Builder
+
+	for i := 0; i < len(value); {
+		if strings.HasPrefix(value[i:], "$${") {
+			output.WriteString("${")
+			i += 3
+			continue
+		}
+
+		if strings.HasPrefix(value[i:], "${") {
+			end := findSecretReferenceEnd(value, i+2)
+			if end == -1 {
+				output.WriteString(value[i:])
+				break
+			}
+
+			output.WriteString(replace(parseSecretReference(value[i+2 : end])))
+			i = end + 1
+			continue
+		}
+
+		output.WriteByte(value[i])
+		i++
+	}
+
+	return output.String()
+}
+
+// findSecretReferenceEnd returns the index of the } closing the reference starting at start, a } between quotes does not count
+func findSecretReferenceEnd(value string, start int) int {
+	inQuotes := false
+	for i := start; i < len(value); i++ {
+		switch value[i] {
+		case '"':
+			inQuotes = !inQuotes
+		case '}':
+			if !inQuotes {
+				return i
+			}
+		}
+	}
+
+	return -1
+}
+
+// splitSecretReference splits text around sep, ignoring the separators between quotes. n limits the number of parts
+// like strings.SplitN
+func splitSecretReference(text string, sep string, n int) []string {
+	parts := []string{}
+	inQuotes := false
+	start := 0
+
+	for i := 0; i < len(text); i++ {
+		if text[i] == '"' {
+			inQuotes = !inQuotes
+			continue
+		}
+
+		if !inQuotes && strings.HasPrefix(text[i:], sep) && (n < 0 || len(parts) < n-1) {
+			parts = append(parts, text[start:i])
+			start = i + len(sep)
+			i += len(sep) - 1
+		}
+	}
+
+	return append(parts, text[start:])
+}
+
+func parseSecretReference(text string) SecretReference {
+	reference := SecretReference{Reference: text}
+
+	if parts := splitSecretReference(text, ":-", 2); len(parts) == 2 {
+		reference.Reference, reference.Default, reference.HasDefault = parts[0], parts[1], true
+	}
+
+	for _, part := range splitSecretReference(reference.Reference, ".", -1) {
+		if len(part) >= 2 && strings.HasPrefix(part, `"`) && strings.HasSuffix(part, `"`) {
+			part = part[1 : len(part)-1]
+		}
+		reference.Parts = append(reference.Parts, part)
+	}
+
+	return reference
+}
+
+// formatSecretReferenceParts joins the parts of a reference with dots, quoting the parts that contain a dot
+func formatSecretReferenceParts(parts []string) string {
+	formattedParts := []string{}
+	for _, part := range parts {
+		if strings.Contains(part, ".") {
+			part = `"` + part + `"`
+		}
+		formattedParts = append(formattedParts, part)
+	}
+
+	return strings.Join(formattedParts, ".")
+}
+
+// GetSecretReferences returns the references in a secret value, e.g. DB_HOST for ${DB_HOST} or dev.db.PASSWORD for ${dev.db.PASSWORD}
+func GetSecretReferences(value string) []SecretReference {
+	references := []SecretReference{}
+	replaceSecretReferences(value, func(reference SecretReference) string {
+		references = append(references, reference)
+		return ""
+	})
+
+	return references
+}
+
+// SecretReferenceProblem is a reference that can not be expanded: a reference to a secret that does not exist or can
+// not be fetched, a reference that is part of a cycle, or a reference whose default holds another reference
+type SecretReferenceProblem struct {
+	// the secret whose value holds the reference, env.folder.KEY for secrets of other folders
+	Secret string
+	// the reference as written between ${ and }
+	Reference string
+	// the secrets of the cycle, starting and ending with the same secret. Empty for missing references
+	Cycle []string
+	// set when the folder of a cross folder reference could not be fetched
+	FetchError error
+	// set when the default value holds a reference, like ${A:-${B}}, which is not supported
+	NestedDefault bool
+	// set when the missing secret was referenced in another environment or folder
+	CrossFolder bool
+}
+
+func (problem SecretReferenceProblem) Error() string {
+	if len(problem.Cycle) > 0 {
+		return fmt.Sprintf("reference cycle: %s", strings.Join(problem.Cycle, " -> "))
+	}
+
+	if problem.FetchError != nil {
+		return fmt.Sprintf("%s references ${%s}, whose folder could not be fetched [err=%v]", problem.Secret, problem.Reference, problem.FetchError)
+	}
+
+	if problem.NestedDefault {
+		return fmt.Sprintf("%s references ${%s} with a default value that holds a reference, references in default values are not supported", problem.Secret, problem.Reference)
+	}
+
+	return fmt.Sprintf("%s references ${%s}, which does not exist", problem.Secret, problem.Reference)
+}
+
+// SecretReferencesError holds all problems found while expanding secrets
+type SecretReferencesError struct {
+	Problems []SecretReferenceProblem
+}
+
+func (e *SecretReferencesError) Error() string {
+	messages := []string{}
+	for _, problem := range e.Problems {
+		messages = append(messages, problem.Error())
+	}
+
+	return fmt.Sprintf("unable to expand the secret references: %s", strings.Join(messages, "; "))
+}
+
+// secretReferenceGraph expands references by walking them depth first. Secrets are nodes named by their key, and
+// secrets of other folders by env.folder.KEY, the way they are referenced with folders containing dots quoted;
+// references are edges.
+type secretReferenceGraph struct {
+	values   map[string]string
+	expanded map[string]string
+
+	// the secrets being expanded, to find cycles
+	stack   []string
+	onStack map[string]bool
+
+	// fetches the secrets of another folder by key, the result is cached
+	fetchFolder    func(environment string, secretPath string) (map[string]string, error)
+	fetchedFolders map[string]error
+
+	problems     []SecretReferenceProblem
+	seenProblems map[string]bool
+}
+
+func newSecretReferenceGraph(secrets []models.SingleEnvironmentVariable, fetchFolder func(environment string, secretPath string) (map[string]string, error)) *secretReferenceGraph {
+	graph := &secretReferenceGraph{
+		values:         map[string]string{},
+		expanded:       map[string]string{},
+		onStack:        map[string]bool{},
+		fetchFolder:    fetchFolder,
+		fetchedFolders: map[string]error{},
+		seenProblems:   map[string]bool{},
+	}
+
+	for _, secret := range secrets {
+		graph.values[secret.Key] = secret.Value
+	}
+
+	return graph
+}
+
+// resolveReference returns the node of a reference made from a secret of the folder given by scope, which is empty
+// for the folder being expanded and env, folders for other folders. A reference with several parts points to another
+// folder: ${env.KEY} to the root folder of env, and ${env.a.b.KEY} to /a/b of env. The returned parts are the
+// environment, folders and key of the node.
+func resolveReference(scope []string, referenceParts []string) (node string, parts []string, environment string, secretPath string, isCrossFolder bool) {
+	parts = referenceParts
+	if len(parts) == 1 {
+		if len(scope) == 0 {
+			return parts[0], parts, "", "", false
+		}
+		parts = append(append([]string{}, scope...), parts[0])
+	}
+
+	environment = parts[0]
+	secretPath = path.Join(append([]string{"/"}, parts[1:len(parts)-1]...)...)
+	return formatSecretReferenceParts(parts), parts, environment, secretPath, true
+}
+
+func (graph *secretReferenceGraph) addProblem(problem SecretReferenceProblem) {
+	id := problem.Secret + "\x00" + problem.Reference
+	if len(problem.Cycle) > 0 {
+		id = normalizeReferenceCycle(problem.Cycle)
+	}
+
+	if !graph.seenProblems[id] {
+		graph.seenProblems[id] = true
+		graph.problems = append(graph.problems, problem)
+	}
+}
+
+// normalizeReferenceCycle identifies a cycle independently of the secret it was found from
+func normalizeReferenceCycle(cycle []string) string {
+	nodes := cycle[:len(cycle)-1]
+	start := 0
+	for i, node := range nodes {
+		if node < nodes[start] {
+			start = i
+		}
+	}
+
+	return strings.Join(append(append([]string{}, nodes[start:]...), nodes[:start]...), " -> ")
+}
+
+// loadFolder adds the secrets of another folder as env.folder.KEY nodes
+func (graph *secretReferenceGraph) loadFolder(environment string, secretPath string) error {
+	folderKey := environment + ":" + secretPath
+	if err, fetched := graph.fetchedFolders[folderKey]; fetched {
+		return err
+	}
+
+	values, err := graph.fetchFolder(environment, secretPath)
+	graph.fetchedFolders[folderKey] = err
+	if err != nil {
+		return err
+	}
+
+	scope := []string{environment}
+	if secretPath != "/" {
+		scope = append(scope, strings.Split(strings.Trim(secretPath, "/"), "/")...)
+	}
+
+	for key, value := range values {
+		graph.values[formatSecretReferenceParts(append(append([]string{}, scope...), key))] = value
+	}
+
+	return nil
+}
+
+// expand returns the value of the node with its references expanded. References that can not be expanded are
+// recorded as problems and expand to an empty value. A reference to a secret that does not exist expands to its
+// default value instead when it has one.
+func (graph *secretReferenceGraph) expand(node string, scope []string) string {
+	if value, ok := graph.expanded[node]; ok {
+		return value
+	}
+
+	graph.stack = append(graph.stack, node)
+	graph.onStack[node] = true
+
+	value := replaceSecretReferences(graph.values[node], func(reference SecretReference) string {
+		referencedNode, parts, environment, secretPath, isCrossFolder := resolveReference(scope, reference.Parts)
+
+		if reference.HasDefault && strings.Contains(reference.Default, "${") {
+			graph.addProblem(SecretReferenceProblem{Secret: node, Reference: reference.Reference, NestedDefault: true})
+			return ""
+		}
+
+		if graph.onStack[referencedNode] {
+			cycle := []string{referencedNode}
+			for i := len(graph.stack) - 1; graph.stack[i] != referencedNode; i-- {
+				cycle = append([]string{graph.stack[i]}, cycle...)
+			}
+			graph.addProblem(SecretReferenceProblem{Secret: node, Reference: reference.Reference, Cycle: append([]string{referencedNode}, cycle...)})
+			return ""
+		}
+
+		if isCrossFolder {
+			// a default stands in for a secret that does not exist, not for a folder that can not be fetched
+			if err := graph.loadFolder(environment, secretPath); err != nil {
+				graph.addProblem(SecretReferenceProblem{Secret: node, Reference: reference.Reference, FetchError: err})
+				return ""
+			}
+		}
+
+		if _, exists := graph.values[referencedNode]; !exists {
+			if reference.HasDefault {
+				return reference.Default
+			}
+			graph.addProblem(SecretReferenceProblem{Secret: node, Reference: reference.Reference, CrossFolder: isCrossFolder})
+			return ""
+		}
+
+		// references in the secrets of other folders are relative to their folder
+		referencedScope := scope
+		if isCrossFolder {
+			referencedScope = parts[:len(parts)-1]
+		}
+
+		value := graph.expand(referencedNode, referencedScope)
+		if value == "" && reference.HasDefault {
+			return reference.Default
+		}
+		return value
+	})
+
+	graph.stack = graph.stack[:len(graph.stack)-1]
+	delete(graph.onStack, node)
+
+	graph.expanded[node] = value
+	return value
+}
+
+// expandSecretReferences expands the references of each secret and returns the problems found. Each cycle and each
+// missing reference is reported once.
+func expandSecretReferences(secrets []models.SingleEnvironmentVariable, fetchFolder func(environment string, secretPath string) (map[string]string, error)) ([]models.SingleEnvironmentVariable, []SecretReferenceProblem) {
+	graph := newSecretReferenceGraph(secrets, fetchFolder)
+
+	for i, secret := range secrets {
+		secrets[i].Value = graph.expand(secret.Key, nil)
+	}
+
+	return secrets, graph.problems
+}
+
+func newSecretFolderFetcher(auth models.ExpandSecretsAuthentication, projectConfigPathDir string, projectId string) func(environment string, secretPath string) (map[string]string, error) {
+	return func(environment string, secretPath string) (map[string]string, error) {
+		params := models.GetAllSecretsParameters{Environment: environment, SecretsPath: secretPath}
+		if auth.InfisicalToken != "" {
+			params.InfisicalToken = auth.InfisicalToken
+		} else if auth.UniversalAuthAccessToken != "" {
+			params.UniversalAuthAccessToken = auth.UniversalAuthAccessToken
+			params.WorkspaceId = projectId
+		} else if !IsLoggedIn() {
+			return nil, errors.New("no authentication provided, log in or use a token to fetch the referenced secrets")
+		}
+
+		secrets, err := GetAllEnvironmentVariables(params, projectConfigPathDir)
+		if err != nil {
+			return nil, err
+		}
+
+		values := map[string]string{}
+		for _, secret := range secrets {
+			values[secret.Key] = secret.Value
+		}
+		return values, nil
+	}
+}
+
+// ExpandSecretReferences replaces the references in the values of the secrets, see SecretReference. Cycles and
+// folders that can not be fetched are always an error. References to secrets that do not exist are an error too,
+// unless allowMissing is set, in which case they expand to an empty value with a warning. Missing secrets of other
+// environments or folders used to expand to an empty value, they still do with a deprecation warning for now.
+func ExpandSecretReferences(secrets []models.SingleEnvironmentVariable, auth models.ExpandSecretsAuthentication, projectConfigPathDir string, allowMissing bool) ([]models.SingleEnvironmentVariable, error) {
+	projectId := ""
+	for _, secret := range secrets {
+		if secret.WorkspaceId != "" {
+			projectId = secret.WorkspaceId
+			break
+		}
+	}
+
+	secrets, problems := expandSecretReferences(secrets, newSecretFolderFetcher(auth, projectConfigPathDir, projectId))
+
+	errorProblems := []SecretReferenceProblem{}
+	for _, problem := range problems {
+		if isFatalSecretReferenceProblem(problem, allowMissing) {
+			errorProblems = append(errorProblems, problem)
+		} else if !allowMissing {
+			PrintWarning(fmt.Sprintf("%s, it is expanded to an empty value. Missing references to other environments or folders are deprecated and will fail in the next release unless --allow-missing-references is set", problem.Error()))
+		} else {
+			PrintWarning(fmt.Sprintf("%s, it is expanded to an empty value", problem.Error()))
+		}
+	}
+
+	if len(errorProblems) > 0 {
+		return nil, &SecretReferencesError{Problems: errorProblems}
+	}
+
+	return secrets, nil
+}
+
+// isFatalSecretReferenceProblem tells whether the problem fails the expansion. Only references to secrets that do not
+// exist can be allowed, a folder that can not be fetched may hold the secret and hide an authentication error. Missing
+// secrets of other environments or folders are allowed until the next release, see ExpandSecretReferences
+func isFatalSecretReferenceProblem(problem SecretReferenceProblem, allowMissing bool) bool {
+	if len(problem.Cycle) > 0 || problem.FetchError != nil || problem.NestedDefault {
+		return true
+	}
+
+	return !allowMissing && !problem.CrossFolder
+}
+
+// LintSecretReferences returns the missing and cyclic references of the secrets, sorted by secret, without expanding them
+func LintSecretReferences(secrets []models.SingleEnvironmentVariable, auth models.ExpandSecretsAuthentication, projectConfigPathDir string, projectId string) []SecretReferenceProblem {
+	secretsCopy := append([]models.SingleEnvironmentVariable{}, secrets...)
+	_, problems := expandSecretReferences(secretsCopy, newSecretFolderFetcher(auth, projectConfigPathDir, projectId))
+
+	sort.SliceStable(problems, func(i, j int) bool {
+		return problems[i].Secret < problems[j].Secret
+	})
+
+	return problems
+}
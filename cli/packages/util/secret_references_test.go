@@ -0,0 +1,192 @@
+package util
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/Infisical/infisical-merge/packages/models"
+)
+
+func TestExpandSecretReferences(t *testing.T) {
+	fetchedFolders := []string{}
+	fetchFolder := func(environment string, secretPath string) (map[string]string, error) {
+		fetchedFolders = append(fetchedFolders, environment+":"+secretPath)
+		switch environment + ":" + secretPath {
+		case "prod:/db":
+			return map[string]string{"HOST": "db.internal", "URL": "postgres://${HOST}:${PORT}", "PORT": "5432"}, nil
+		case "prod:/":
+			return map[string]string{"REGION": "eu"}, nil
+		}
+		return nil, errors.New("folder not found")
+	}
+
+	secrets := []models.SingleEnvironmentVariable{
+		{Key: "DATABASE_URL", Value: "${prod.db.URL}/app"},
+		{Key: "NAME", Value: "app-${REGION_NAME}"},
+		{Key: "REGION_NAME", Value: "${prod.REGION}"},
+		{Key: "HOST", Value: "localhost"},
+		{Key: "MISSING", Value: "[${UNKNOWN}] [${staging.KEY}]"},
+		{Key: "UNFETCHED", Value: "${staging.OTHER:-default}"},
+	}
+
+	expanded, problems := expandSecretReferences(secrets, fetchFolder)
+
+	values := map[string]string{}
+	for _, secret := range expanded {
+		values[secret.Key] = secret.Value
+	}
+
+	expected := map[string]string{
+		"DATABASE_URL": "postgres://db.internal:5432/app",
+		"NAME":         "app-eu",
+		"REGION_NAME":  "eu",
+		"HOST":         "localhost",
+		"MISSING":      "[] []",
+		"UNFETCHED":    "",
+	}
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("unexpected values %v", values)
+	}
+
+	if !reflect.DeepEqual(fetchedFolders, []string{"prod:/db", "prod:/", "staging:/"}) {
+		t.Errorf("expected each folder to be fetched once, got %v", fetchedFolders)
+	}
+
+	// the default is not used when the folder can not be fetched
+	if len(problems) != 3 || problems[0].Reference != "UNKNOWN" || problems[1].Reference != "staging.KEY" || problems[1].FetchError == nil || problems[2].Reference != "staging.OTHER" || problems[2].FetchError == nil {
+		t.Errorf("unexpected problems %+v", problems)
+	}
+}
+
+func TestExpandSecretReferencesCycles(t *testing.T) {
+	secrets := []models.SingleEnvironmentVariable{
+		{Key: "A", Value: "${B}"},
+		{Key: "B", Value: "x${C}"},
+		{Key: "C", Value: "${A}"},
+		{Key: "SELF", Value: "${SELF}"},
+		{Key: "D", Value: "${A}"},
+	}
+
+	_, problems := expandSecretReferences(secrets, nil)
+
+	cycles := [][]string{}
+	for _, problem := range problems {
+		cycles = append(cycles, problem.Cycle)
+	}
+
+	expected := [][]string{{"A", "B", "C", "A"}, {"SELF", "SELF"}}
+	if !reflect.DeepEqual(cycles, expected) {
+		t.Errorf("expected each cycle to be reported once, got %v", cycles)
+	}
+
+	if problems[0].Error() != "reference cycle: A -> B -> C -> A" {
+		t.Errorf("unexpected message %q", problems[0].Error())
+	}
+}
+
+func TestExpandSecretReferencesAllowMissing(t *testing.T) {
+	secrets := []models.SingleEnvironmentVariable{{Key: "A", Value: "${B}"}}
+
+	if _, err := ExpandSecretReferences(append([]models.SingleEnvironmentVariable{}, secrets...), models.ExpandSecretsAuthentication{}, "", false); err == nil {
+		t.Error("expected a missing reference to fail by default")
+	}
+
+	expanded, err := ExpandSecretReferences(append([]models.SingleEnvironmentVariable{}, secrets...), models.ExpandSecretsAuthentication{}, "", true)
+	if err != nil || expanded[0].Value != "" {
+		t.Errorf("expected a missing reference to expand to an empty value, got %+v %v", expanded, err)
+	}
+
+	cyclic := []models.SingleEnvironmentVariable{{Key: "A", Value: "${A}"}}
+	if _, err := ExpandSecretReferences(cyclic, models.ExpandSecretsAuthentication{}, "", true); err == nil {
+		t.Error("expected a cycle to fail when missing references are allowed")
+	}
+
+	if !isFatalSecretReferenceProblem(SecretReferenceProblem{Secret: "A", Reference: "prod.B", FetchError: errors.New("unauthorized")}, true) {
+		t.Error("expected a folder that can not be fetched to fail when missing references are allowed")
+	}
+}
+
+func TestExpandSecretReferencesMissingCrossFolderIsDeprecated(t *testing.T) {
+	fetchFolder := func(environment string, secretPath string) (map[string]string, error) {
+		return map[string]string{}, nil
+	}
+
+	secrets := []models.SingleEnvironmentVariable{{Key: "A", Value: "[${prod.db.B}]"}}
+	expanded, problems := expandSecretReferences(secrets, fetchFolder)
+	if len(problems) != 1 || !problems[0].CrossFolder || expanded[0].Value != "[]" {
+		t.Fatalf("expected the missing cross folder reference to expand to an empty value, got %+v %+v", expanded, problems)
+	}
+
+	if isFatalSecretReferenceProblem(problems[0], false) {
+		t.Error("expected a missing cross folder reference to only warn until the next release")
+	}
+}
+
+func TestExpandSecretReferencesSyntax(t *testing.T) {
+	fetchFolder := func(environment string, secretPath string) (map[string]string, error) {
+		if environment+":"+secretPath == "prod:/v1.2/db" {
+			return map[string]string{"HOST": "db-v1"}, nil
+		}
+		return nil, errors.New("folder not found")
+	}
+
+	secrets := []models.SingleEnvironmentVariable{
+		{Key: "SHELL", Value: "echo $${HOME} $$HOME ${NAME"},
+		{Key: "PORT", Value: "${HTTP_PORT:-8080}"},
+		{Key: "EMPTY", Value: ""},
+		{Key: "FALLBACK", Value: `${EMPTY:-fallback}:${prod."v1.2".db.KEY:-none}`},
+		{Key: "HOST", Value: `${prod."v1.2".db.HOST}`},
+		{Key: "URL", Value: `http://${HOST}:${PORT}`},
+	}
+
+	expanded, problems := expandSecretReferences(secrets, fetchFolder)
+	if len(problems) != 0 {
+		t.Errorf("expected no problems, got %+v", problems)
+	}
+
+	values := map[string]string{}
+	for _, secret := range expanded {
+		values[secret.Key] = secret.Value
+	}
+
+	expected := map[string]string{
+		"SHELL":    "echo ${HOME} $$HOME ${NAME",
+		"PORT":     "8080",
+		"EMPTY":    "",
+		"FALLBACK": "fallback:none",
+		"HOST":     "db-v1",
+		"URL":      "http://db-v1:8080",
+	}
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("unexpected values %v", values)
+	}
+}
+
+func TestExpandSecretReferencesRejectsNestedDefaults(t *testing.T) {
+	secrets := []models.SingleEnvironmentVariable{
+		{Key: "B", Value: "b"},
+		{Key: "NESTED", Value: "x${A:-${B}}y"},
+	}
+
+	_, problems := expandSecretReferences(secrets, nil)
+	if len(problems) != 1 || !problems[0].NestedDefault || problems[0].Reference != "A" {
+		t.Fatalf("expected the nested default to be reported, got %+v", problems)
+	}
+
+	if !isFatalSecretReferenceProblem(problems[0], true) {
+		t.Error("expected a nested default to fail when missing references are allowed")
+	}
+}
+
+func TestGetSecretReferences(t *testing.T) {
+	references := GetSecretReferences(`$${SKIPPED} ${A} ${dev."a.b}".KEY:-x:-y}`)
+
+	expected := []SecretReference{
+		{Reference: "A", Parts: []string{"A"}},
+		{Reference: `dev."a.b}".KEY`, Parts: []string{"dev", "a.b}", "KEY"}, Default: "x:-y", HasDefault: true},
+	}
+	if !reflect.DeepEqual(references, expected) {
+		t.Errorf("unexpected references %+v", references)
+	}
+}
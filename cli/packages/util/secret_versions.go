@@ -0,0 +1,336 @@
+package util
+
+import (
+	"encoding/base64"
+	"fmt"
+
+	"github.com/Infisical/infisical-merge/packages/api"
+	"github.com/Infisical/infisical-merge/packages/crypto"
+	"github.com/Infisical/infisical-merge/packages/models"
+	"github.com/go-resty/resty/v2"
+	"github.com/rs/zerolog/log"
+)
+
+// the number of versions fetched at once while looking for a version to roll back to
+const secretVersionsPageSize = 50
+
+// loggedInProject holds what is needed to read and write the encrypted secrets of a project as the logged in user
+type loggedInProject struct {
+	httpClient   *resty.Client
+	projectId    string
+	workspaceKey []byte
+}
+
+// newLoggedInProject is used for secret versions, which the API only returns to logged in users
+func newLoggedInProject(params models.GetAllSecretsParameters) (loggedInProject, error) {
+	if params.InfisicalToken != "" || params.UniversalAuthAccessToken != "" {
+		return loggedInProject{}, fmt.Errorf("secret versions can only be read when logged in, run [infisical login] and try again without --token")
+	}
+
+	RequireLogin()
+	RequireLocalWorkspaceFile()
+
+	loggedInUserDetails, err := GetCurrentLoggedInUserDetails()
+	if err != nil {
+		return loggedInProject{}, fmt.Errorf("unable to authenticate [err=%v]", err)
+	}
+
+	if loggedInUserDetails.LoginExpired {
+		PrintErrorMessageAndExit("Your login session has expired, please run [infisical login] and try again")
+	}
+
+	projectId := params.WorkspaceId
+	if projectId == "" {
+		workspaceFile, err := GetWorkSpaceFromFile()
+		if err != nil {
+			return loggedInProject{}, fmt.Errorf("unable to get your local config details [err=%v]", err)
+		}
+		projectId = workspaceFile.WorkspaceId
+	}
+
+	httpClient := resty.New().
+		SetAuthToken(loggedInUserDetails.UserCredentials.JTWToken).
+		SetHeader("Accept", "application/json")
+
+	workspaceKey, err := getPlainTextWorkspaceKey(httpClient, loggedInUserDetails, projectId)
+	if err != nil {
+		return loggedInProject{}, err
+	}
+
+	return loggedInProject{httpClient: httpClient, projectId: projectId, workspaceKey: workspaceKey}, nil
+}
+
+// GetSecretVersions returns the versions of a secret, newest first
+func GetSecretVersions(params models.GetAllSecretsParameters, secretName string, secretType string, offset int, limit int) ([]models.SecretVersion, error) {
+	project, err := newLoggedInProject(params)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := project.findSecret(params, secretName, secretType)
+	if err != nil {
+		return nil, err
+	}
+
+	versions, err := project.getSecretVersions(secret.ID, offset, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return project.addVersionActors(versions), nil
+}
+
+// RollbackSecretToVersion sets the value and comment of a secret back to those of one of its versions, which adds a new
+// version to its history
+func RollbackSecretToVersion(params models.GetAllSecretsParameters, secretName string, secretType string, version int) (models.SecretVersion, error) {
+	project, err := newLoggedInProject(params)
+	if err != nil {
+		return models.SecretVersion{}, err
+	}
+
+	return project.rollbackSecretToVersion(params, secretName, secretType, version)
+}
+
+func (project loggedInProject) findSecret(params models.GetAllSecretsParameters, secretName string, secretType string) (models.SingleEnvironmentVariable, error) {
+	encryptedSecrets, err := api.CallGetSecretsV3(project.httpClient, api.GetEncryptedSecretsV3Request{
+		WorkspaceId: project.projectId,
+		Environment: params.Environment,
+		SecretPath:  params.SecretsPath,
+	})
+	if err != nil {
+		return models.SingleEnvironmentVariable{}, err
+	}
+
+	secrets, err := GetPlainTextSecrets(project.workspaceKey, encryptedSecrets.Secrets)
+	if err != nil {
+		return models.SingleEnvironmentVariable{}, err
+	}
+
+	for _, secret := range secrets {
+		if secret.Key == secretName && secret.Type == secretType {
+			return secret, nil
+		}
+	}
+
+	return models.SingleEnvironmentVariable{}, fmt.Errorf("the %s secret %s does not exist in %s", secretType, secretName, params.SecretsPath)
+}
+
+func (project loggedInProject) getSecretVersions(secretId string, offset int, limit int) ([]models.SecretVersion, error) {
+	response, err := api.CallGetSecretVersionsV1(project.httpClient, api.GetSecretVersionsV1Request{SecretId: secretId, Offset: offset, Limit: limit})
+	if err != nil {
+		return nil, err
+	}
+
+	versions := []models.SecretVersion{}
+	for _, encryptedVersion := range response.SecretVersions {
+		version, err := decryptSecretVersion(project.workspaceKey, encryptedVersion)
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, version)
+	}
+
+	return versions, nil
+}
+
+// addVersionActors replaces the user IDs of the versions with the emails of the project members. Versions made by
+// service tokens and machine identities have no user.
+func (project loggedInProject) addVersionActors(versions []models.SecretVersion) []models.SecretVersion {
+	users, err := api.CallGetProjectUsersV1(project.httpClient, project.projectId)
+	if err != nil {
+		log.Debug().Msgf("unable to get the members of the project, the versions show user IDs [err=%v]", err)
+	}
+
+	emails := map[string]string{}
+	for _, member := range users.Users {
+		emails[member.User.ID] = member.User.Email
+	}
+
+	for i, version := range versions {
+		if version.Actor == "" {
+			versions[i].Actor = "service token or machine identity"
+		} else if email, ok := emails[version.Actor]; ok {
+			versions[i].Actor = email
+		}
+	}
+
+	return versions
+}
+
+func (project loggedInProject) rollbackSecretToVersion(params models.GetAllSecretsParameters, secretName string, secretType string, versionNumber int) (models.SecretVersion, error) {
+	secret, err := project.findSecret(params, secretName, secretType)
+	if err != nil {
+		return models.SecretVersion{}, err
+	}
+
+	var version *models.SecretVersion
+	for offset := 0; version == nil; offset += secretVersionsPageSize {
+		versions, err := project.getSecretVersions(secret.ID, offset, secretVersionsPageSize)
+		if err != nil {
+			return models.SecretVersion{}, err
+		}
+
+		for i := range versions {
+			if versions[i].Version == versionNumber {
+				version = &versions[i]
+				break
+			}
+		}
+
+		if version == nil && len(versions) < secretVersionsPageSize {
+			return models.SecretVersion{}, fmt.Errorf("%s has no version %d, run [infisical secrets history %s] to see its versions", secretName, versionNumber, secretName)
+		}
+	}
+
+	encryptedSecret, err := encryptSecretForRequest(models.SingleEnvironmentVariable{Key: secretName, Value: version.Value, Comment: version.Comment}, project.workspaceKey)
+	if err != nil {
+		return models.SecretVersion{}, err
+	}
+
+	updateSecretRequest := api.UpdateSecretByNameV3Request{
+		WorkspaceID:             project.projectId,
+		Environment:             params.Environment,
+		Type:                    secretType,
+		SecretPath:              params.SecretsPath,
+		SecretValueCiphertext:   encryptedSecret.SecretValueCiphertext,
+		SecretValueIV:           encryptedSecret.SecretValueIV,
+		SecretValueTag:          encryptedSecret.SecretValueTag,
+		SecretCommentCiphertext: encryptedSecret.SecretCommentCiphertext,
+		SecretCommentIV:         encryptedSecret.SecretCommentIV,
+		SecretCommentTag:        encryptedSecret.SecretCommentTag,
+	}
+
+	if err := api.CallUpdateSecretsV3(project.httpClient, updateSecretRequest, secretName); err != nil {
+		return models.SecretVersion{}, fmt.Errorf("unable to roll back %s [err=%v]", secretName, err)
+	}
+
+	return *version, nil
+}
+
+func decryptSecretVersion(key []byte, encryptedVersion api.SecretVersionV1) (models.SecretVersion, error) {
+	decrypt := func(cipherText string, iv string, tag string) (string, error) {
+		decodedCipherText, err := base64.StdEncoding.DecodeString(cipherText)
+		if err != nil {
+			return "", err
+		}
+
+		decodedIV, err := base64.StdEncoding.DecodeString(iv)
+		if err != nil {
+			return "", err
+		}
+
+		decodedTag, err := base64.StdEncoding.DecodeString(tag)
+		if err != nil {
+			return "", err
+		}
+
+		plainText, err := crypto.DecryptSymmetric(key, decodedCipherText, decodedTag, decodedIV)
+		return string(plainText), err
+	}
+
+	version := models.SecretVersion{
+		Version:   encryptedVersion.Version,
+		Type:      encryptedVersion.Type,
+		Actor:     encryptedVersion.UserId,
+		CreatedAt: encryptedVersion.CreatedAt,
+	}
+
+	var err error
+	if version.Key, err = decrypt(encryptedVersion.SecretKeyCiphertext, encryptedVersion.SecretKeyIV, encryptedVersion.SecretKeyTag); err != nil {
+		return models.SecretVersion{}, fmt.Errorf("unable to decrypt the key of version %d [err=%v]", encryptedVersion.Version, err)
+	}
+
+	if version.Value, err = decrypt(encryptedVersion.SecretValueCiphertext, encryptedVersion.SecretValueIV, encryptedVersion.SecretValueTag); err != nil {
+		return models.SecretVersion{}, fmt.Errorf("unable to decrypt the value of version %d [err=%v]", encryptedVersion.Version, err)
+	}
+
+	// versions created without a comment have none to decrypt
+	if encryptedVersion.SecretCommentIV != "" {
+		if version.Comment, err = decrypt(encryptedVersion.SecretCommentCiphertext, encryptedVersion.SecretCommentIV, encryptedVersion.SecretCommentTag); err != nil {
+			return models.SecretVersion{}, fmt.Errorf("unable to decrypt the comment of version %d [err=%v]", encryptedVersion.Version, err)
+		}
+	}
+
+	return version, nil
+}
+
+// newSnapshotsClient is used for snapshots, which can be listed and restored by logged in users and machine identities
+func newSnapshotsClient(params models.GetAllSecretsParameters) (*resty.Client, string, error) {
+	if params.InfisicalToken != "" {
+		return nil, "", fmt.Errorf("snapshots cannot be used with a service token, log in or use a machine identity instead")
+	}
+
+	projectId := params.WorkspaceId
+	token := params.UniversalAuthAccessToken
+	if token == "" {
+		RequireLogin()
+		RequireLocalWorkspaceFile()
+
+		loggedInUserDetails, err := GetCurrentLoggedInUserDetails()
+		if err != nil {
+			return nil, "", fmt.Errorf("unable to authenticate [err=%v]", err)
+		}
+
+		if loggedInUserDetails.LoginExpired {
+			PrintErrorMessageAndExit("Your login session has expired, please run [infisical login] and try again")
+		}
+
+		if projectId == "" {
+			workspaceFile, err := GetWorkSpaceFromFile()
+			if err != nil {
+				return nil, "", fmt.Errorf("unable to get your local config details [err=%v]", err)
+			}
+			projectId = workspaceFile.WorkspaceId
+		}
+		token = loggedInUserDetails.UserCredentials.JTWToken
+	} else if projectId == "" {
+		PrintErrorMessageAndExit("Project ID is required when using machine identity")
+	}
+
+	httpClient := resty.New().
+		SetAuthToken(token).
+		SetHeader("Accept", "application/json")
+
+	return httpClient, projectId, nil
+}
+
+// GetSecretSnapshots returns the snapshots of the folder of params, newest first
+func GetSecretSnapshots(params models.GetAllSecretsParameters, offset int, limit int) ([]models.SecretSnapshot, error) {
+	httpClient, projectId, err := newSnapshotsClient(params)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := api.CallGetSecretSnapshotsV1(httpClient, api.GetSecretSnapshotsV1Request{
+		WorkspaceId: projectId,
+		Environment: params.Environment,
+		Path:        params.SecretsPath,
+		Offset:      offset,
+		Limit:       limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	snapshots := []models.SecretSnapshot{}
+	for _, snapshot := range response.SecretSnapshots {
+		snapshots = append(snapshots, models.SecretSnapshot{ID: snapshot.ID, Environment: params.Environment, Path: params.SecretsPath, CreatedAt: snapshot.CreatedAt})
+	}
+
+	return snapshots, nil
+}
+
+// RestoreSecretSnapshot rolls the secrets and folders of the snapshot's folder back to the snapshot
+func RestoreSecretSnapshot(params models.GetAllSecretsParameters, snapshotId string) (models.SecretSnapshot, error) {
+	httpClient, _, err := newSnapshotsClient(params)
+	if err != nil {
+		return models.SecretSnapshot{}, err
+	}
+
+	response, err := api.CallRollbackSecretSnapshotV1(httpClient, snapshotId)
+	if err != nil {
+		return models.SecretSnapshot{}, fmt.Errorf("unable to restore the snapshot %s [err=%v]", snapshotId, err)
+	}
+
+	return models.SecretSnapshot{ID: response.SecretSnapshot.ID, CreatedAt: response.SecretSnapshot.CreatedAt}, nil
+}
@@ -0,0 +1,186 @@
+package util
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Infisical/infisical-merge/packages/api"
+	"github.com/Infisical/infisical-merge/packages/config"
+	"github.com/Infisical/infisical-merge/packages/crypto"
+	"github.com/Infisical/infisical-merge/packages/models"
+	"github.com/go-resty/resty/v2"
+)
+
+var testWorkspaceKey = []byte("0123456789abcdef0123456789abcdef")
+
+// secretVersionsStub is an in-memory stub of the secret versions and snapshots API
+type secretVersionsStub struct {
+	t        *testing.T
+	versions []api.SecretVersionV1
+	calls    []string
+	updates  []api.UpdateSecretByNameV3Request
+}
+
+func newSecretVersionsStub(t *testing.T, values ...string) *secretVersionsStub {
+	stub := &secretVersionsStub{t: t}
+	for i, value := range values {
+		version := api.SecretVersionV1{Version: i + 1, Type: SECRET_TYPE_SHARED, SecretId: "secret-id", UserId: "user-id", CreatedAt: time.Date(2024, 1, i+1, 0, 0, 0, 0, time.UTC)}
+		version.SecretKeyCiphertext, version.SecretKeyIV, version.SecretKeyTag = stub.encrypt("DB_PASSWORD")
+		version.SecretValueCiphertext, version.SecretValueIV, version.SecretValueTag = stub.encrypt(value)
+		// the newest version comes first, like the API returns them
+		stub.versions = append([]api.SecretVersionV1{version}, stub.versions...)
+	}
+
+	server := httptest.NewServer(stub)
+	t.Cleanup(server.Close)
+
+	previousUrl := config.INFISICAL_URL
+	config.INFISICAL_URL = server.URL + "/api"
+	t.Cleanup(func() { config.INFISICAL_URL = previousUrl })
+
+	return stub
+}
+
+func (stub *secretVersionsStub) encrypt(plainText string) (string, string, string) {
+	encrypted, err := crypto.EncryptSymmetric([]byte(plainText), testWorkspaceKey)
+	if err != nil {
+		stub.t.Fatal(err)
+	}
+
+	return base64.StdEncoding.EncodeToString(encrypted.CipherText), base64.StdEncoding.EncodeToString(encrypted.Nonce), base64.StdEncoding.EncodeToString(encrypted.AuthTag)
+}
+
+func (stub *secretVersionsStub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	call := r.Method + " " + r.URL.Path
+	stub.calls = append(stub.calls, call)
+	w.Header().Set("Content-Type", "application/json")
+
+	switch {
+	case call == "GET /api/v3/secrets":
+		secret := api.EncryptedSecretV3{ID: "secret-id", Type: SECRET_TYPE_SHARED}
+		secret.SecretKeyCiphertext, secret.SecretKeyIV, secret.SecretKeyTag = stub.encrypt("DB_PASSWORD")
+		secret.SecretValueCiphertext, secret.SecretValueIV, secret.SecretValueTag = stub.encrypt("current")
+		secret.SecretCommentCiphertext, secret.SecretCommentIV, secret.SecretCommentTag = stub.encrypt("")
+		json.NewEncoder(w).Encode(api.GetEncryptedSecretsV3Response{Secrets: []api.EncryptedSecretV3{secret}})
+	case call == "GET /api/v1/secret/secret-id/secret-versions":
+		var offset, limit int
+		fmt.Sscan(r.URL.Query().Get("offset"), &offset)
+		fmt.Sscan(r.URL.Query().Get("limit"), &limit)
+		end := min(offset+limit, len(stub.versions))
+		json.NewEncoder(w).Encode(api.GetSecretVersionsV1Response{SecretVersions: stub.versions[min(offset, end):end]})
+	case call == "GET /api/v1/workspace/project-id/users":
+		fmt.Fprint(w, `{"users":[{"user":{"id":"user-id","email":"jane@example.com"}}]}`)
+	case call == "PATCH /api/v3/secrets/DB_PASSWORD":
+		var request api.UpdateSecretByNameV3Request
+		json.NewDecoder(r.Body).Decode(&request)
+		stub.updates = append(stub.updates, request)
+		fmt.Fprint(w, `{}`)
+	case call == "GET /api/v1/workspace/project-id/secret-snapshots":
+		if r.URL.Query().Get("environment") != "dev" || r.URL.Query().Get("path") != "/api" {
+			http.Error(w, `{"message":"wrong scope"}`, http.StatusBadRequest)
+			return
+		}
+		fmt.Fprint(w, `{"secretSnapshots":[{"id":"snapshot-id","envId":"env-id","folderId":"folder-id","createdAt":"2024-01-02T00:00:00Z"}]}`)
+	case call == "POST /api/v1/secret-snapshot/snapshot-id/rollback":
+		fmt.Fprint(w, `{"secretSnapshot":{"id":"snapshot-id","createdAt":"2024-01-02T00:00:00Z"}}`)
+	default:
+		http.Error(w, `{"message":"not found"}`, http.StatusNotFound)
+	}
+}
+
+func newTestLoggedInProject() loggedInProject {
+	return loggedInProject{httpClient: resty.New(), projectId: "project-id", workspaceKey: testWorkspaceKey}
+}
+
+func TestGetSecretVersionsDecryptsAndAddsActors(t *testing.T) {
+	newSecretVersionsStub(t, "first", "second")
+	project := newTestLoggedInProject()
+	params := models.GetAllSecretsParameters{Environment: "dev", SecretsPath: "/"}
+
+	secret, err := project.findSecret(params, "DB_PASSWORD", SECRET_TYPE_SHARED)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	versions, err := project.getSecretVersions(secret.ID, 0, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	versions = project.addVersionActors(versions)
+
+	if len(versions) != 2 || versions[0].Version != 2 || versions[0].Value != "second" || versions[1].Value != "first" {
+		t.Fatalf("unexpected versions %+v", versions)
+	}
+
+	if versions[0].Key != "DB_PASSWORD" || versions[0].Actor != "jane@example.com" {
+		t.Errorf("expected the key and the actor's email, got %+v", versions[0])
+	}
+}
+
+func TestRollbackSecretToVersion(t *testing.T) {
+	values := []string{}
+	for i := 1; i <= secretVersionsPageSize+5; i++ {
+		values = append(values, fmt.Sprintf("value-%d", i))
+	}
+	stub := newSecretVersionsStub(t, values...)
+	project := newTestLoggedInProject()
+	params := models.GetAllSecretsParameters{Environment: "dev", SecretsPath: "/"}
+
+	// version 3 is on the second page of versions
+	version, err := project.rollbackSecretToVersion(params, "DB_PASSWORD", SECRET_TYPE_SHARED, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if version.Value != "value-3" || len(stub.updates) != 1 {
+		t.Fatalf("expected a single update to version 3, got version %+v and %d updates", version, len(stub.updates))
+	}
+
+	update := stub.updates[0]
+	cipherText, _ := base64.StdEncoding.DecodeString(update.SecretValueCiphertext)
+	tag, _ := base64.StdEncoding.DecodeString(update.SecretValueTag)
+	iv, _ := base64.StdEncoding.DecodeString(update.SecretValueIV)
+	value, err := crypto.DecryptSymmetric(testWorkspaceKey, cipherText, tag, iv)
+	if err != nil || string(value) != "value-3" || update.Environment != "dev" || update.WorkspaceID != "project-id" {
+		t.Errorf("unexpected update %+v with value %q", update, value)
+	}
+
+	if _, err := project.rollbackSecretToVersion(params, "DB_PASSWORD", SECRET_TYPE_SHARED, 100); err == nil || !strings.Contains(err.Error(), "no version 100") {
+		t.Errorf("expected an error for a missing version, got %v", err)
+	}
+	if len(stub.updates) != 1 {
+		t.Errorf("a missing version must not update the secret")
+	}
+}
+
+func TestSecretSnapshotsWithMachineIdentity(t *testing.T) {
+	stub := newSecretVersionsStub(t)
+	params := models.GetAllSecretsParameters{Environment: "dev", SecretsPath: "/api", WorkspaceId: "project-id", UniversalAuthAccessToken: "identity-token"}
+
+	snapshots, err := GetSecretSnapshots(params, 0, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(snapshots) != 1 || snapshots[0].ID != "snapshot-id" || snapshots[0].Path != "/api" {
+		t.Fatalf("unexpected snapshots %+v", snapshots)
+	}
+
+	if _, err := RestoreSecretSnapshot(params, "snapshot-id"); err != nil {
+		t.Fatal(err)
+	}
+
+	if stub.calls[len(stub.calls)-1] != "POST /api/v1/secret-snapshot/snapshot-id/rollback" {
+		t.Errorf("expected the snapshot to be rolled back, got calls %v", stub.calls)
+	}
+
+	if _, err := GetSecretSnapshots(models.GetAllSecretsParameters{InfisicalToken: "st.token"}, 0, 20); err == nil {
+		t.Errorf("expected an error for a service token")
+	}
+}
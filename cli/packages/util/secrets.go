@@ -7,9 +7,8 @@ import (
 	"errors"
 	"fmt"
 	"os"
-	"path"
-	"regexp"
 	"strings"
+	"time"
 	"unicode"
 
 	"github.com/Infisical/infisical-merge/packages/api"
@@ -73,6 +72,8 @@ func GetPlainTextSecretsViaServiceToken(fullServiceToken string, environment str
 		return nil, api.GetServiceTokenDetailsResponse{}, fmt.Errorf("unable to decrypt your secrets [err=%v]", err)
 	}
 
+	setSecretSource(plainTextSecrets, environment, secretPath)
+
 	if includeImports {
 		plainTextSecrets, err = InjectImportedSecret(plainTextWorkspaceKey, plainTextSecrets, encryptedSecrets.ImportedSecrets)
 		if err != nil {
@@ -146,6 +147,8 @@ func GetPlainTextSecretsViaJTW(JTWToken string, receiversPrivateKey string, work
 		return nil, fmt.Errorf("unable to decrypt your secrets [err=%v]", err)
 	}
 
+	setSecretSource(plainTextSecrets, environmentName, secretsPath)
+
 	if includeImports {
 		plainTextSecrets, err = InjectImportedSecret(plainTextWorkspaceKey, plainTextSecrets, encryptedSecrets.ImportedSecrets)
 		if err != nil {
@@ -185,9 +188,11 @@ func GetPlainTextSecretsViaMachineIdentity(accessToken string, workspaceId strin
 	}
 
 	for _, secret := range rawSecrets.Secrets {
-		plainTextSecrets = append(plainTextSecrets, models.SingleEnvironmentVariable{Key: secret.SecretKey, Value: secret.SecretValue, Type: secret.Type, WorkspaceId: secret.Workspace})
+		plainTextSecrets = append(plainTextSecrets, models.SingleEnvironmentVariable{Key: secret.SecretKey, Value: secret.SecretValue, Type: secret.Type, WorkspaceId: secret.Workspace, Comment: secret.SecretComment, SecretPath: secret.SecretPath})
 	}
 
+	setSecretSource(plainTextSecrets, environmentName, secretsPath)
+
 	if includeImports {
 		plainTextSecrets, err = InjectRawImportedSecret(plainTextSecrets, rawSecrets.Imports)
 		if err != nil {
@@ -226,6 +231,66 @@ func CreateDynamicSecretLease(accessToken string, projectSlug string, environmen
 	}, nil
 }
 
+func RenewDynamicSecretLease(accessToken string, projectSlug string, environmentName string, secretsPath string, leaseId string, ttl string) (time.Time, error) {
+	httpClient := resty.New()
+	httpClient.SetAuthToken(accessToken).
+		SetHeader("Accept", "application/json")
+
+	renewedLease, err := api.CallRenewDynamicSecretLeaseV1(httpClient, api.RenewDynamicSecretLeaseV1Request{
+		LeaseId:     leaseId,
+		ProjectSlug: projectSlug,
+		Environment: environmentName,
+		SecretPath:  secretsPath,
+		TTL:         ttl,
+	})
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return renewedLease.Lease.ExpireAt, nil
+}
+
+func RevokeDynamicSecretLease(accessToken string, projectSlug string, environmentName string, secretsPath string, leaseId string) error {
+	httpClient := resty.New()
+	httpClient.SetAuthToken(accessToken).
+		SetHeader("Accept", "application/json")
+
+	return api.CallRevokeDynamicSecretLeaseV1(httpClient, api.RevokeDynamicSecretLeaseV1Request{
+		LeaseId:     leaseId,
+		ProjectSlug: projectSlug,
+		Environment: environmentName,
+		SecretPath:  secretsPath,
+	})
+}
+
+func GetProjectSlug(accessToken string, projectId string) (string, error) {
+	httpClient := resty.New()
+	httpClient.SetAuthToken(accessToken).
+		SetHeader("Accept", "application/json")
+
+	project, err := api.CallGetProjectByIdV1(httpClient, api.GetProjectByIdV1Request{ProjectId: projectId})
+	if err != nil {
+		return "", fmt.Errorf("unable to get project details [err=%v]", err)
+	}
+
+	return project.Workspace.Slug, nil
+}
+
+// setSecretSource records the environment and folder the secrets were fetched from. Secrets for which the API
+// already returned a folder, such as the ones fetched recursively, keep it.
+func setSecretSource(secrets []models.SingleEnvironmentVariable, environment string, secretPath string) {
+	if secretPath == "" {
+		secretPath = "/"
+	}
+
+	for i := range secrets {
+		secrets[i].Environment = environment
+		if secrets[i].SecretPath == "" {
+			secrets[i].SecretPath = secretPath
+		}
+	}
+}
+
 func InjectImportedSecret(plainTextWorkspaceKey []byte, secrets []models.SingleEnvironmentVariable, importedSecrets []api.ImportedSecretV3) ([]models.SingleEnvironmentVariable, error) {
 	if importedSecrets == nil {
 		return secrets, nil
@@ -246,6 +311,9 @@ func InjectImportedSecret(plainTextWorkspaceKey []byte, secrets []models.SingleE
 
 		for _, sec := range plainTextImportedSecrets {
 			if _, ok := hasOverriden[sec.Key]; !ok {
+				sec.Environment = importSec.Environment
+				sec.SecretPath = importSec.SecretPath
+				sec.IsImported = true
 				secrets = append(secrets, sec)
 				hasOverriden[sec.Key] = true
 			}
@@ -276,6 +344,10 @@ func InjectRawImportedSecret(secrets []models.SingleEnvironmentVariable, importe
 					Value:       sec.SecretValue,
 					Type:        sec.Type,
 					ID:          sec.ID,
+					Comment:     sec.SecretComment,
+					Environment: importSec.Environment,
+					SecretPath:  importSec.SecretPath,
+					IsImported:  true,
 				})
 				hasOverriden[sec.SecretKey] = true
 			}
@@ -401,118 +473,6 @@ func GetAllEnvironmentVariables(params models.GetAllSecretsParameters, projectCo
 	return secretsToReturn, errorToReturn
 }
 
-var secRefRegex = regexp.MustCompile(`\${([^\}]*)}`)
-
-func recursivelyExpandSecret(expandedSecs map[string]string, interpolatedSecs map[string]string, crossSecRefFetch func(env string, path []string, key string) string, key string) string {
-	if v, ok := expandedSecs[key]; ok {
-		return v
-	}
-
-	interpolatedVal, ok := interpolatedSecs[key]
-	if !ok {
-		HandleError(fmt.Errorf("could not find refered secret -  %s", key), "Kindly check whether its provided")
-	}
-
-	refs := secRefRegex.FindAllStringSubmatch(interpolatedVal, -1)
-	for _, val := range refs {
-		// key: "${something}" val: [${something},something]
-		interpolatedExp, interpolationKey := val[0], val[1]
-		ref := strings.Split(interpolationKey, ".")
-
-		// ${KEY1} => [key1]
-		if len(ref) == 1 {
-			val := recursivelyExpandSecret(expandedSecs, interpolatedSecs, crossSecRefFetch, interpolationKey)
-			interpolatedVal = strings.ReplaceAll(interpolatedVal, interpolatedExp, val)
-			continue
-		}
-
-		// cross board reference ${env.folder.key1} => [env folder key1]
-		if len(ref) > 1 {
-			secEnv, tmpSecPath, secKey := ref[0], ref[1:len(ref)-1], ref[len(ref)-1]
-			interpolatedSecs[interpolationKey] = crossSecRefFetch(secEnv, tmpSecPath, secKey) // get the reference value
-			val := recursivelyExpandSecret(expandedSecs, interpolatedSecs, crossSecRefFetch, interpolationKey)
-			interpolatedVal = strings.ReplaceAll(interpolatedVal, interpolatedExp, val)
-		}
-
-	}
-	expandedSecs[key] = interpolatedVal
-	return interpolatedVal
-}
-
-func getSecretsByKeys(secrets []models.SingleEnvironmentVariable) map[string]models.SingleEnvironmentVariable {
-	secretMapByName := make(map[string]models.SingleEnvironmentVariable, len(secrets))
-
-	for _, secret := range secrets {
-		secretMapByName[secret.Key] = secret
-	}
-
-	return secretMapByName
-}
-
-func ExpandSecrets(secrets []models.SingleEnvironmentVariable, auth models.ExpandSecretsAuthentication, projectConfigPathDir string) []models.SingleEnvironmentVariable {
-	expandedSecs := make(map[string]string)
-	interpolatedSecs := make(map[string]string)
-	// map[env.secret-path][keyname]Secret
-	crossEnvRefSecs := make(map[string]map[string]models.SingleEnvironmentVariable) // a cache to hold all cross board reference secrets
-
-	for _, sec := range secrets {
-		// get all references in a secret
-		refs := secRefRegex.FindAllStringSubmatch(sec.Value, -1)
-		// nil means its a secret without reference
-		if refs == nil {
-			expandedSecs[sec.Key] = sec.Value // atomic secrets without any interpolation
-		} else {
-			interpolatedSecs[sec.Key] = sec.Value
-		}
-	}
-
-	for i, sec := range secrets {
-		// already present pick that up
-		if expandedVal, ok := expandedSecs[sec.Key]; ok {
-			secrets[i].Value = expandedVal
-			continue
-		}
-
-		expandedVal := recursivelyExpandSecret(expandedSecs, interpolatedSecs, func(env string, secPaths []string, secKey string) string {
-			secPaths = append([]string{"/"}, secPaths...)
-			secPath := path.Join(secPaths...)
-
-			secPathDot := strings.Join(secPaths, ".")
-			uniqKey := fmt.Sprintf("%s.%s", env, secPathDot)
-
-			if crossRefSec, ok := crossEnvRefSecs[uniqKey]; !ok {
-
-				var refSecs []models.SingleEnvironmentVariable
-				var err error
-
-				// if not in cross reference cache, fetch it from server
-				if auth.InfisicalToken != "" {
-					refSecs, err = GetAllEnvironmentVariables(models.GetAllSecretsParameters{Environment: env, InfisicalToken: auth.InfisicalToken, SecretsPath: secPath}, projectConfigPathDir)
-				} else if auth.UniversalAuthAccessToken != "" {
-					refSecs, err = GetAllEnvironmentVariables((models.GetAllSecretsParameters{Environment: env, UniversalAuthAccessToken: auth.UniversalAuthAccessToken, SecretsPath: secPath, WorkspaceId: sec.WorkspaceId}), projectConfigPathDir)
-				} else if IsLoggedIn() {
-					refSecs, err = GetAllEnvironmentVariables(models.GetAllSecretsParameters{Environment: env, SecretsPath: secPath}, projectConfigPathDir)
-				} else {
-					HandleError(errors.New("no authentication provided"), "Please provide authentication to fetch secrets")
-				}
-				if err != nil {
-					HandleError(err, fmt.Sprintf("Could not fetch secrets in environment: %s secret-path: %s", env, secPath), "If you are using a service token to fetch secrets, please ensure it is valid")
-				}
-				refSecsByKey := getSecretsByKeys(refSecs)
-				// save it to avoid calling api again for same environment and folder path
-				crossEnvRefSecs[uniqKey] = refSecsByKey
-				return refSecsByKey[secKey].Value
-
-			} else {
-				return crossRefSec[secKey].Value
-			}
-		}, sec.Key)
-
-		secrets[i].Value = expandedVal
-	}
-	return secrets
-}
-
 func OverrideSecrets(secrets []models.SingleEnvironmentVariable, secretType string) []models.SingleEnvironmentVariable {
 	personalSecrets := make(map[string]models.SingleEnvironmentVariable)
 	sharedSecrets := make(map[string]models.SingleEnvironmentVariable)
@@ -623,12 +583,13 @@ func GetPlainTextSecrets(key []byte, encryptedSecrets []api.EncryptedSecretV3) (
 		}
 
 		plainTextSecret := models.SingleEnvironmentVariable{
-			Key:     string(plainTextKey),
-			Value:   string(plainTextValue),
-			Type:    string(secret.Type),
-			ID:      secret.ID,
-			Tags:    secret.Tags,
-			Comment: string(plainTextComment),
+			Key:        string(plainTextKey),
+			Value:      string(plainTextValue),
+			Type:       string(secret.Type),
+			ID:         secret.ID,
+			Tags:       secret.Tags,
+			Comment:    string(plainTextComment),
+			SecretPath: secret.SecretPath,
 		}
 
 		plainTextSecrets = append(plainTextSecrets, plainTextSecret)
@@ -809,8 +770,133 @@ func GetPlainTextWorkspaceKey(authenticationToken string, receiverPrivateKey str
 	return crypto.DecryptAsymmetric(encryptedWorkspaceKey, encryptedWorkspaceKeyNonce, encryptedWorkspaceKeySenderPublicKey, currentUsersPrivateKey), nil
 }
 
+// ConfirmSecretOperations is shown the planned operations before anything is changed, returning false cancels all of them
+type ConfirmSecretOperations func(operations []models.SecretSetOperation) bool
+
+// ParseSecretArgs parses the KEY=VALUE arguments of infisical secrets set
+func ParseSecretArgs(secretArgs []string) []models.SingleEnvironmentVariable {
+	secrets := []models.SingleEnvironmentVariable{}
+	for _, arg := range secretArgs {
+		splitKeyValueFromArg := strings.SplitN(arg, "=", 2)
+		if len(splitKeyValueFromArg) < 2 {
+			PrintErrorMessageAndExit("ensure that each secret has a none empty key and value. Modify the input and try again")
+		}
+
+		secrets = append(secrets, models.SingleEnvironmentVariable{Key: strings.TrimSpace(splitKeyValueFromArg[0]), Value: splitKeyValueFromArg[1]})
+	}
+
+	return secrets
+}
+
+// ValidateSecretsToSet checks that every secret has a valid key and value and that no key is given twice
+func ValidateSecretsToSet(secrets []models.SingleEnvironmentVariable) error {
+	seen := map[string]bool{}
+	for _, secret := range secrets {
+		if secret.Key == "" || secret.Value == "" {
+			return fmt.Errorf("ensure that each secret has a none empty key and value. Modify the input and try again")
+		}
+
+		if unicode.IsNumber(rune(secret.Key[0])) {
+			return fmt.Errorf("keys of secrets cannot start with a number. Modify the key name(s) and try again")
+		}
+
+		if seen[secret.Key] {
+			return fmt.Errorf("the secret %s is given more than once. Modify the input and try again", secret.Key)
+		}
+		seen[secret.Key] = true
+	}
+
+	return nil
+}
+
+// classifySecretOperations compares the secrets to set with the existing secrets of the same type. Secrets to modify carry the
+// ID and type of the existing secret and a comment only when it changes. When comments cannot be updated the keys of
+// existing secrets with a changed comment are returned instead.
+func classifySecretOperations(existingSecrets []models.SingleEnvironmentVariable, secrets []models.SingleEnvironmentVariable, secretType string, canUpdateComments bool) ([]models.SingleEnvironmentVariable, []models.SingleEnvironmentVariable, []models.SecretSetOperation, []string) {
+	sharedSecretMapByName := make(map[string]models.SingleEnvironmentVariable, len(existingSecrets))
+	personalSecretMapByName := make(map[string]models.SingleEnvironmentVariable, len(existingSecrets))
+
+	for _, secret := range existingSecrets {
+		if secret.Type == SECRET_TYPE_PERSONAL {
+			personalSecretMapByName[secret.Key] = secret
+		} else {
+			sharedSecretMapByName[secret.Key] = secret
+		}
+	}
+
+	secretsToCreate := []models.SingleEnvironmentVariable{}
+	secretsToModify := []models.SingleEnvironmentVariable{}
+	secretOperations := []models.SecretSetOperation{}
+	commentsNotUpdated := []string{}
+
+	for _, secret := range secrets {
+		var existingSecret models.SingleEnvironmentVariable
+		var doesSecretExist bool
+
+		if secretType == SECRET_TYPE_SHARED {
+			existingSecret, doesSecretExist = sharedSecretMapByName[secret.Key]
+		} else {
+			existingSecret, doesSecretExist = personalSecretMapByName[secret.Key]
+		}
+
+		if !doesSecretExist {
+			// case: secret doesn't exist in project so it needs to be created
+			secret.Type = secretType
+			secretsToCreate = append(secretsToCreate, secret)
+			secretOperations = append(secretOperations, models.SecretSetOperation{
+				SecretKey:       secret.Key,
+				SecretValue:     secret.Value,
+				SecretOperation: "SECRET CREATED",
+			})
+			continue
+		}
+
+		// case: secret exists in project so it needs to be modified when its value or comment changes
+		commentChanged := secret.Comment != "" && secret.Comment != existingSecret.Comment
+		if commentChanged && !canUpdateComments {
+			commentsNotUpdated = append(commentsNotUpdated, secret.Key)
+			commentChanged = false
+		}
+		if !commentChanged {
+			secret.Comment = ""
+		}
+
+		secret.ID = existingSecret.ID
+		secret.Type = existingSecret.Type
+
+		operation := "SECRET VALUE UNCHANGED"
+		if existingSecret.Value != secret.Value {
+			operation = "SECRET VALUE MODIFIED"
+		} else if commentChanged {
+			operation = "SECRET COMMENT MODIFIED"
+		}
+
+		if operation != "SECRET VALUE UNCHANGED" {
+			secretsToModify = append(secretsToModify, secret)
+		}
+
+		secretOperations = append(secretOperations, models.SecretSetOperation{
+			SecretKey:       secret.Key,
+			SecretValue:     secret.Value,
+			SecretOperation: operation,
+		})
+	}
+
+	return secretsToCreate, secretsToModify, secretOperations, commentsNotUpdated
+}
+
 func SetEncryptedSecrets(secretArgs []string, secretType string, environmentName string, secretsPath string) ([]models.SecretSetOperation, error) {
+	secrets := ParseSecretArgs(secretArgs)
+	if err := ValidateSecretsToSet(secrets); err != nil {
+		PrintErrorMessageAndExit(err.Error())
+	}
 
+	return SetEncryptedSecretsFromList(secrets, secretType, environmentName, secretsPath, nil)
+}
+
+// SetEncryptedSecretsFromList creates or updates the secrets, including their comments, with the logged in user's credentials.
+// When confirm is given it is called with the planned operations before anything is changed.
+func SetEncryptedSecretsFromList(secretsToSet []models.SingleEnvironmentVariable, secretType string, environmentName string, secretsPath string, confirm ConfirmSecretOperations) ([]models.SecretSetOperation, error) {
 	workspaceFile, err := GetWorkSpaceFromFile()
 	if err != nil {
 		return nil, fmt.Errorf("unable to get your local config details [err=%v]", err)
@@ -829,28 +915,11 @@ func SetEncryptedSecrets(secretArgs []string, secretType string, environmentName
 		SetAuthToken(loggedInUserDetails.UserCredentials.JTWToken).
 		SetHeader("Accept", "application/json")
 
-	request := api.GetEncryptedWorkspaceKeyRequest{
-		WorkspaceId: workspaceFile.WorkspaceId,
-	}
-
-	workspaceKeyResponse, err := api.CallGetEncryptedWorkspaceKey(httpClient, request)
+	plainTextEncryptionKey, err := getPlainTextWorkspaceKey(httpClient, loggedInUserDetails, workspaceFile.WorkspaceId)
 	if err != nil {
-		return nil, fmt.Errorf("unable to get your encrypted workspace key [err=%v]", err)
-	}
-
-	encryptedWorkspaceKey, _ := base64.StdEncoding.DecodeString(workspaceKeyResponse.EncryptedKey)
-	encryptedWorkspaceKeySenderPublicKey, _ := base64.StdEncoding.DecodeString(workspaceKeyResponse.Sender.PublicKey)
-	encryptedWorkspaceKeyNonce, _ := base64.StdEncoding.DecodeString(workspaceKeyResponse.Nonce)
-	currentUsersPrivateKey, _ := base64.StdEncoding.DecodeString(loggedInUserDetails.UserCredentials.PrivateKey)
-
-	if len(currentUsersPrivateKey) == 0 || len(encryptedWorkspaceKeySenderPublicKey) == 0 {
-		log.Debug().Msgf("Missing credentials for generating plainTextEncryptionKey: [currentUsersPrivateKey=%s] [encryptedWorkspaceKeySenderPublicKey=%s]", currentUsersPrivateKey, encryptedWorkspaceKeySenderPublicKey)
-		PrintErrorMessageAndExit("Some required user credentials are missing to generate your [plainTextEncryptionKey]. Please run [infisical login] then try again")
+		return nil, err
 	}
 
-	// decrypt workspace key
-	plainTextEncryptionKey := crypto.DecryptAsymmetric(encryptedWorkspaceKey, encryptedWorkspaceKeyNonce, encryptedWorkspaceKeySenderPublicKey, currentUsersPrivateKey)
-
 	infisicalTokenEnv := os.Getenv(INFISICAL_TOKEN_NAME)
 
 	// pull current secrets
@@ -859,151 +928,124 @@ func SetEncryptedSecrets(secretArgs []string, secretType string, environmentName
 		return nil, fmt.Errorf("unable to retrieve secrets [err=%v]", err)
 	}
 
-	secretsToCreate := []api.Secret{}
-	secretsToModify := []api.Secret{}
-	secretOperations := []models.SecretSetOperation{}
-
-	sharedSecretMapByName := make(map[string]models.SingleEnvironmentVariable, len(secrets))
-	personalSecretMapByName := make(map[string]models.SingleEnvironmentVariable, len(secrets))
+	secretsToCreate, secretsToModify, secretOperations, _ := classifySecretOperations(secrets, secretsToSet, secretType, true)
 
-	for _, secret := range secrets {
-		if secret.Type == SECRET_TYPE_PERSONAL {
-			personalSecretMapByName[secret.Key] = secret
-		} else {
-			sharedSecretMapByName[secret.Key] = secret
-		}
+	if confirm != nil && !confirm(secretOperations) {
+		return secretOperations, nil
 	}
 
-	for _, arg := range secretArgs {
-		splitKeyValueFromArg := strings.SplitN(arg, "=", 2)
-		if splitKeyValueFromArg[0] == "" || splitKeyValueFromArg[1] == "" {
-			PrintErrorMessageAndExit("ensure that each secret has a none empty key and value. Modify the input and try again")
+	for _, secret := range secretsToCreate {
+		encryptedSecret, err := encryptSecretForRequest(secret, plainTextEncryptionKey)
+		if err != nil {
+			return nil, err
 		}
 
-		if unicode.IsNumber(rune(splitKeyValueFromArg[0][0])) {
-			PrintErrorMessageAndExit("keys of secrets cannot start with a number. Modify the key name(s) and try again")
+		createSecretRequest := api.CreateSecretV3Request{
+			WorkspaceID:             workspaceFile.WorkspaceId,
+			Environment:             environmentName,
+			SecretName:              secret.Key,
+			SecretKeyCiphertext:     encryptedSecret.SecretKeyCiphertext,
+			SecretKeyIV:             encryptedSecret.SecretKeyIV,
+			SecretKeyTag:            encryptedSecret.SecretKeyTag,
+			SecretValueCiphertext:   encryptedSecret.SecretValueCiphertext,
+			SecretValueIV:           encryptedSecret.SecretValueIV,
+			SecretValueTag:          encryptedSecret.SecretValueTag,
+			SecretCommentCiphertext: encryptedSecret.SecretCommentCiphertext,
+			SecretCommentIV:         encryptedSecret.SecretCommentIV,
+			SecretCommentTag:        encryptedSecret.SecretCommentTag,
+			Type:                    secret.Type,
+			SecretPath:              secretsPath,
 		}
 
-		// Key and value from argument
-		key := strings.TrimSpace(splitKeyValueFromArg[0])
-		value := splitKeyValueFromArg[1]
-
-		hashedKey := fmt.Sprintf("%x", sha256.Sum256([]byte(key)))
-		encryptedKey, err := crypto.EncryptSymmetric([]byte(key), []byte(plainTextEncryptionKey))
+		err = api.CallCreateSecretsV3(httpClient, createSecretRequest)
 		if err != nil {
-			return nil, fmt.Errorf("unable to encrypt your secrets [err=%v]", err)
+			return nil, fmt.Errorf("unable to process new secret creations [err=%v]", err)
 		}
+	}
 
-		hashedValue := fmt.Sprintf("%x", sha256.Sum256([]byte(value)))
-		encryptedValue, err := crypto.EncryptSymmetric([]byte(value), []byte(plainTextEncryptionKey))
+	for _, secret := range secretsToModify {
+		encryptedSecret, err := encryptSecretForRequest(secret, plainTextEncryptionKey)
 		if err != nil {
-			return nil, fmt.Errorf("unable to encrypt your secrets [err=%v]", err)
+			return nil, err
 		}
 
-		var existingSecret models.SingleEnvironmentVariable
-		var doesSecretExist bool
+		updateSecretRequest := api.UpdateSecretByNameV3Request{
+			WorkspaceID:             workspaceFile.WorkspaceId,
+			Environment:             environmentName,
+			SecretValueCiphertext:   encryptedSecret.SecretValueCiphertext,
+			SecretValueIV:           encryptedSecret.SecretValueIV,
+			SecretValueTag:          encryptedSecret.SecretValueTag,
+			SecretCommentCiphertext: encryptedSecret.SecretCommentCiphertext,
+			SecretCommentIV:         encryptedSecret.SecretCommentIV,
+			SecretCommentTag:        encryptedSecret.SecretCommentTag,
+			Type:                    secret.Type,
+			SecretPath:              secretsPath,
+		}
 
-		if secretType == SECRET_TYPE_SHARED {
-			existingSecret, doesSecretExist = sharedSecretMapByName[key]
-		} else {
-			existingSecret, doesSecretExist = personalSecretMapByName[key]
+		err = api.CallUpdateSecretsV3(httpClient, updateSecretRequest, secret.Key)
+		if err != nil {
+			return nil, fmt.Errorf("unable to process secret update request [err=%v]", err)
 		}
+	}
 
-		if doesSecretExist {
-			// case: secret exists in project so it needs to be modified
-			encryptedSecretDetails := api.Secret{
-				ID:                    existingSecret.ID,
-				SecretValueCiphertext: base64.StdEncoding.EncodeToString(encryptedValue.CipherText),
-				SecretValueIV:         base64.StdEncoding.EncodeToString(encryptedValue.Nonce),
-				SecretValueTag:        base64.StdEncoding.EncodeToString(encryptedValue.AuthTag),
-				SecretValueHash:       hashedValue,
-				PlainTextKey:          key,
-				Type:                  existingSecret.Type,
-			}
+	return secretOperations, nil
 
-			// Only add to modifications if the value is different
-			if existingSecret.Value != value {
-				secretsToModify = append(secretsToModify, encryptedSecretDetails)
-				secretOperations = append(secretOperations, models.SecretSetOperation{
-					SecretKey:       key,
-					SecretValue:     value,
-					SecretOperation: "SECRET VALUE MODIFIED",
-				})
-			} else {
-				// Current value is same as exisitng so no change
-				secretOperations = append(secretOperations, models.SecretSetOperation{
-					SecretKey:       key,
-					SecretValue:     value,
-					SecretOperation: "SECRET VALUE UNCHANGED",
-				})
-			}
+}
 
-		} else {
-			// case: secret doesn't exist in project so it needs to be created
-			encryptedSecretDetails := api.Secret{
-				SecretKeyCiphertext:   base64.StdEncoding.EncodeToString(encryptedKey.CipherText),
-				SecretKeyIV:           base64.StdEncoding.EncodeToString(encryptedKey.Nonce),
-				SecretKeyTag:          base64.StdEncoding.EncodeToString(encryptedKey.AuthTag),
-				SecretKeyHash:         hashedKey,
-				SecretValueCiphertext: base64.StdEncoding.EncodeToString(encryptedValue.CipherText),
-				SecretValueIV:         base64.StdEncoding.EncodeToString(encryptedValue.Nonce),
-				SecretValueTag:        base64.StdEncoding.EncodeToString(encryptedValue.AuthTag),
-				SecretValueHash:       hashedValue,
-				Type:                  secretType,
-				PlainTextKey:          key,
-			}
-			secretsToCreate = append(secretsToCreate, encryptedSecretDetails)
-			secretOperations = append(secretOperations, models.SecretSetOperation{
-				SecretKey:       key,
-				SecretValue:     value,
-				SecretOperation: "SECRET CREATED",
-			})
-		}
+// encryptSecretForRequest encrypts the key, value and, when set, the comment of a secret with the workspace key
+func encryptSecretForRequest(secret models.SingleEnvironmentVariable, plainTextEncryptionKey []byte) (api.Secret, error) {
+	encryptedKey, err := crypto.EncryptSymmetric([]byte(secret.Key), plainTextEncryptionKey)
+	if err != nil {
+		return api.Secret{}, fmt.Errorf("unable to encrypt your secrets [err=%v]", err)
 	}
 
-	for _, secret := range secretsToCreate {
-		createSecretRequest := api.CreateSecretV3Request{
-			WorkspaceID:           workspaceFile.WorkspaceId,
-			Environment:           environmentName,
-			SecretName:            secret.PlainTextKey,
-			SecretKeyCiphertext:   secret.SecretKeyCiphertext,
-			SecretKeyIV:           secret.SecretKeyIV,
-			SecretKeyTag:          secret.SecretKeyTag,
-			SecretValueCiphertext: secret.SecretValueCiphertext,
-			SecretValueIV:         secret.SecretValueIV,
-			SecretValueTag:        secret.SecretValueTag,
-			Type:                  secret.Type,
-			SecretPath:            secretsPath,
-		}
-
-		err = api.CallCreateSecretsV3(httpClient, createSecretRequest)
-		if err != nil {
-			return nil, fmt.Errorf("unable to process new secret creations [err=%v]", err)
-		}
+	encryptedValue, err := crypto.EncryptSymmetric([]byte(secret.Value), plainTextEncryptionKey)
+	if err != nil {
+		return api.Secret{}, fmt.Errorf("unable to encrypt your secrets [err=%v]", err)
 	}
 
-	for _, secret := range secretsToModify {
-		updateSecretRequest := api.UpdateSecretByNameV3Request{
-			WorkspaceID:           workspaceFile.WorkspaceId,
-			Environment:           environmentName,
-			SecretValueCiphertext: secret.SecretValueCiphertext,
-			SecretValueIV:         secret.SecretValueIV,
-			SecretValueTag:        secret.SecretValueTag,
-			Type:                  secret.Type,
-			SecretPath:            secretsPath,
-		}
+	encryptedSecret := api.Secret{
+		SecretKeyCiphertext:   base64.StdEncoding.EncodeToString(encryptedKey.CipherText),
+		SecretKeyIV:           base64.StdEncoding.EncodeToString(encryptedKey.Nonce),
+		SecretKeyTag:          base64.StdEncoding.EncodeToString(encryptedKey.AuthTag),
+		SecretKeyHash:         fmt.Sprintf("%x", sha256.Sum256([]byte(secret.Key))),
+		SecretValueCiphertext: base64.StdEncoding.EncodeToString(encryptedValue.CipherText),
+		SecretValueIV:         base64.StdEncoding.EncodeToString(encryptedValue.Nonce),
+		SecretValueTag:        base64.StdEncoding.EncodeToString(encryptedValue.AuthTag),
+		SecretValueHash:       fmt.Sprintf("%x", sha256.Sum256([]byte(secret.Value))),
+		Type:                  secret.Type,
+		PlainTextKey:          secret.Key,
+	}
 
-		err = api.CallUpdateSecretsV3(httpClient, updateSecretRequest, secret.PlainTextKey)
+	if secret.Comment != "" {
+		encryptedComment, err := crypto.EncryptSymmetric([]byte(secret.Comment), plainTextEncryptionKey)
 		if err != nil {
-			return nil, fmt.Errorf("unable to process secret update request [err=%v]", err)
+			return api.Secret{}, fmt.Errorf("unable to encrypt your secrets [err=%v]", err)
 		}
-	}
 
-	return secretOperations, nil
+		encryptedSecret.SecretCommentCiphertext = base64.StdEncoding.EncodeToString(encryptedComment.CipherText)
+		encryptedSecret.SecretCommentIV = base64.StdEncoding.EncodeToString(encryptedComment.Nonce)
+		encryptedSecret.SecretCommentTag = base64.StdEncoding.EncodeToString(encryptedComment.AuthTag)
+	}
 
+	return encryptedSecret, nil
 }
 
 func SetRawSecrets(secretArgs []string, secretType string, environmentName string, secretsPath string, projectId string, tokenDetails *models.TokenDetails) ([]models.SecretSetOperation, error) {
+	secrets := ParseSecretArgs(secretArgs)
+	if err := ValidateSecretsToSet(secrets); err != nil {
+		PrintErrorMessageAndExit(err.Error())
+	}
+
+	return SetRawSecretsFromList(secrets, secretType, environmentName, secretsPath, projectId, tokenDetails, nil)
+}
+
+// SetRawSecretsFromList creates or updates the secrets with a service token or machine identity. Comments of existing
+// shared secrets are updated through the batch endpoint, and setting them fails on instances without it; comments of
+// existing personal secrets are left as they are. When a change fails the changes made before it are rolled back and a
+// *SetSecretsError lists the secrets that are still applied.
+// When confirm is given it is called with the planned operations before anything is changed.
+func SetRawSecretsFromList(secretsToSet []models.SingleEnvironmentVariable, secretType string, environmentName string, secretsPath string, projectId string, tokenDetails *models.TokenDetails, confirm ConfirmSecretOperations) ([]models.SecretSetOperation, error) {
 
 	if tokenDetails == nil {
 		return nil, fmt.Errorf("unable to process set secret operations, token details are missing")
@@ -1026,118 +1068,47 @@ func SetRawSecrets(secretArgs []string, secretType string, environmentName strin
 		return nil, fmt.Errorf("unable to retrieve secrets [err=%v]", err)
 	}
 
-	secretsToCreate := []api.RawSecret{}
-	secretsToModify := []api.RawSecret{}
-	secretOperations := []models.SecretSetOperation{}
-
-	sharedSecretMapByName := make(map[string]models.SingleEnvironmentVariable, len(secrets))
-	personalSecretMapByName := make(map[string]models.SingleEnvironmentVariable, len(secrets))
-
-	for _, secret := range secrets {
-		if secret.Type == SECRET_TYPE_PERSONAL {
-			personalSecretMapByName[secret.Key] = secret
-		} else {
-			sharedSecretMapByName[secret.Key] = secret
-		}
+	secretsToCreate, secretsToModify, secretOperations, commentsNotUpdated := classifySecretOperations(secrets, secretsToSet, secretType, secretType == SECRET_TYPE_SHARED)
+	if len(commentsNotUpdated) > 0 {
+		PrintWarning(fmt.Sprintf("the comments of %s are not updated since they already exist, comments of personal secrets can only be updated when logged in", strings.Join(commentsNotUpdated, ", ")))
 	}
 
-	for _, arg := range secretArgs {
-		splitKeyValueFromArg := strings.SplitN(arg, "=", 2)
-		if splitKeyValueFromArg[0] == "" || splitKeyValueFromArg[1] == "" {
-			PrintErrorMessageAndExit("ensure that each secret has a none empty key and value. Modify the input and try again")
-		}
-
-		if unicode.IsNumber(rune(splitKeyValueFromArg[0][0])) {
-			PrintErrorMessageAndExit("keys of secrets cannot start with a number. Modify the key name(s) and try again")
-		}
-
-		// Key and value from argument
-		key := splitKeyValueFromArg[0]
-		value := splitKeyValueFromArg[1]
-
-		var existingSecret models.SingleEnvironmentVariable
-		var doesSecretExist bool
+	if confirm != nil && !confirm(secretOperations) {
+		return secretOperations, nil
+	}
 
-		if secretType == SECRET_TYPE_SHARED {
-			existingSecret, doesSecretExist = sharedSecretMapByName[key]
-		} else {
-			existingSecret, doesSecretExist = personalSecretMapByName[key]
-		}
+	applier := newRawSecretsApplier(httpClient, tokenDetails, projectId, environmentName, secretsPath, secrets)
+	if err := applier.apply(secretsToCreate, secretsToModify); err != nil {
+		return nil, err
+	}
 
-		if doesSecretExist {
-			// case: secret exists in project so it needs to be modified
-			encryptedSecretDetails := api.RawSecret{
-				ID:          existingSecret.ID,
-				SecretValue: value,
-				SecretKey:   key,
-				Type:        existingSecret.Type,
-			}
+	return secretOperations, nil
 
-			// Only add to modifications if the value is different
-			if existingSecret.Value != value {
-				secretsToModify = append(secretsToModify, encryptedSecretDetails)
-				secretOperations = append(secretOperations, models.SecretSetOperation{
-					SecretKey:       key,
-					SecretValue:     value,
-					SecretOperation: "SECRET VALUE MODIFIED",
-				})
-			} else {
-				// Current value is same as existing so no change
-				secretOperations = append(secretOperations, models.SecretSetOperation{
-					SecretKey:       key,
-					SecretValue:     value,
-					SecretOperation: "SECRET VALUE UNCHANGED",
-				})
-			}
+}
 
-		} else {
-			// case: secret doesn't exist in project so it needs to be created
-			encryptedSecretDetails := api.RawSecret{
-				SecretKey:   key,
-				SecretValue: value,
-				Type:        secretType,
-			}
-			secretsToCreate = append(secretsToCreate, encryptedSecretDetails)
-			secretOperations = append(secretOperations, models.SecretSetOperation{
-				SecretKey:       key,
-				SecretValue:     value,
-				SecretOperation: "SECRET CREATED",
-			})
-		}
+// getPlainTextWorkspaceKey decrypts the key of the project that the secrets of the logged in user are encrypted with
+func getPlainTextWorkspaceKey(httpClient *resty.Client, loggedInUserDetails LoggedInUserDetails, workspaceId string) ([]byte, error) {
+	request := api.GetEncryptedWorkspaceKeyRequest{
+		WorkspaceId: workspaceId,
 	}
 
-	for _, secret := range secretsToCreate {
-		createSecretRequest := api.CreateRawSecretV3Request{
-			SecretName:  secret.SecretKey,
-			SecretValue: secret.SecretValue,
-			Type:        secret.Type,
-			SecretPath:  secretsPath,
-			WorkspaceID: projectId,
-			Environment: environmentName,
-		}
-
-		err = api.CallCreateRawSecretsV3(httpClient, createSecretRequest)
-		if err != nil {
-			return nil, fmt.Errorf("unable to process new secret creations [err=%v]", err)
-		}
+	workspaceKeyResponse, err := api.CallGetEncryptedWorkspaceKey(httpClient, request)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get your encrypted workspace key [err=%v]", err)
 	}
 
-	for _, secret := range secretsToModify {
-		updateSecretRequest := api.UpdateRawSecretByNameV3Request{
-			SecretName:  secret.SecretKey,
-			SecretValue: secret.SecretValue,
-			SecretPath:  secretsPath,
-			WorkspaceID: projectId,
-			Environment: environmentName,
-			Type:        secret.Type,
-		}
+	encryptedWorkspaceKey, _ := base64.StdEncoding.DecodeString(workspaceKeyResponse.EncryptedKey)
+	encryptedWorkspaceKeySenderPublicKey, _ := base64.StdEncoding.DecodeString(workspaceKeyResponse.Sender.PublicKey)
+	encryptedWorkspaceKeyNonce, _ := base64.StdEncoding.DecodeString(workspaceKeyResponse.Nonce)
+	currentUsersPrivateKey, _ := base64.StdEncoding.DecodeString(loggedInUserDetails.UserCredentials.PrivateKey)
 
-		err = api.CallUpdateRawSecretsV3(httpClient, updateSecretRequest)
-		if err != nil {
-			return nil, fmt.Errorf("unable to process secret update request [err=%v]", err)
-		}
+	if len(currentUsersPrivateKey) == 0 || len(encryptedWorkspaceKeySenderPublicKey) == 0 {
+		log.Debug().Msgf("Missing credentials for generating plainTextEncryptionKey: [currentUsersPrivateKey=%s] [encryptedWorkspaceKeySenderPublicKey=%s]", currentUsersPrivateKey, encryptedWorkspaceKeySenderPublicKey)
+		PrintErrorMessageAndExit("Some required user credentials are missing to generate your [plainTextEncryptionKey]. Please run [infisical login] then try again")
 	}
 
-	return secretOperations, nil
+	// decrypt workspace key
+	plainTextEncryptionKey := crypto.DecryptAsymmetric(encryptedWorkspaceKey, encryptedWorkspaceKeyNonce, encryptedWorkspaceKeySenderPublicKey, currentUsersPrivateKey)
 
+	return plainTextEncryptionKey, nil
 }
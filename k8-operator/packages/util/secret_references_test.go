@@ -0,0 +1,131 @@
+package util
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/Infisical/infisical/k8-operator/packages/model"
+)
+
+// the cases mirror the ones of the CLI (cli/packages/util/secret_references_test.go), keep them in sync
+
+func testFetchFolder(environment string, secretPath string) (map[string]string, error) {
+	switch environment + ":" + secretPath {
+	case "prod:/db":
+		return map[string]string{"HOST": "db.internal", "URL": "postgres://${HOST}:${PORT}", "PORT": "5432"}, nil
+	case "prod:/":
+		return map[string]string{"REGION": "eu"}, nil
+	case "prod:/v1.2/db":
+		return map[string]string{"HOST": "db-v1"}, nil
+	}
+	return nil, errors.New("folder not found")
+}
+
+func TestExpandSecretReferences(t *testing.T) {
+	tests := []struct {
+		name     string
+		secrets  map[string]string
+		expected map[string]string
+		problems []string
+	}{
+		{
+			name:     "cross folder",
+			secrets:  map[string]string{"DATABASE_URL": "${prod.db.URL}/app", "HOST": "localhost"},
+			expected: map[string]string{"DATABASE_URL": "postgres://db.internal:5432/app", "HOST": "localhost"},
+		},
+		{
+			name:     "chained",
+			secrets:  map[string]string{"NAME": "app-${REGION_NAME}", "REGION_NAME": "${prod.REGION}"},
+			expected: map[string]string{"NAME": "app-eu", "REGION_NAME": "eu"},
+		},
+		{
+			name:     "escaping",
+			secrets:  map[string]string{"SHELL": "echo $${HOME} $$HOME ${NAME"},
+			expected: map[string]string{"SHELL": "echo ${HOME} $$HOME ${NAME"},
+		},
+		{
+			name:     "defaults",
+			secrets:  map[string]string{"PORT": "${HTTP_PORT:-8080}", "EMPTY": "", "FALLBACK": `${EMPTY:-fallback}:${prod."v1.2".db.KEY:-none}`},
+			expected: map[string]string{"PORT": "8080", "EMPTY": "", "FALLBACK": "fallback:none"},
+		},
+		{
+			name:     "quoted folder",
+			secrets:  map[string]string{"HOST": `${prod."v1.2".db.HOST}`, "URL": "http://${HOST}"},
+			expected: map[string]string{"HOST": "db-v1", "URL": "http://db-v1"},
+		},
+		{
+			name:     "missing",
+			secrets:  map[string]string{"MISSING": "[${UNKNOWN}]"},
+			expected: map[string]string{"MISSING": "[]"},
+			problems: []string{"MISSING references ${UNKNOWN}, which does not exist"},
+		},
+		{
+			name:     "folder that can not be fetched",
+			secrets:  map[string]string{"UNFETCHED": "${staging.KEY:-default}"},
+			expected: map[string]string{"UNFETCHED": ""},
+			problems: []string{"UNFETCHED references ${staging.KEY}, whose folder could not be fetched [err=folder not found]"},
+		},
+		{
+			name:     "cycle",
+			secrets:  map[string]string{"A": "${B}", "B": "x${A}"},
+			expected: map[string]string{"A": "x", "B": "x"},
+			problems: []string{"reference cycle: A -> B -> A"},
+		},
+		{
+			name:     "nested default",
+			secrets:  map[string]string{"B": "b", "NESTED": "${A:-${B}}"},
+			expected: map[string]string{"B": "b", "NESTED": "}"},
+			problems: []string{"NESTED references ${A} with a default value that holds a reference, references in default values are not supported"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// sorted so cycles are found from the same secret on each run
+			secrets := []model.SingleEnvironmentVariable{}
+			for _, key := range []string{"A", "B", "DATABASE_URL", "EMPTY", "FALLBACK", "HOST", "MISSING", "NAME", "NESTED", "PORT", "REGION_NAME", "SHELL", "UNFETCHED", "URL"} {
+				if value, ok := test.secrets[key]; ok {
+					secrets = append(secrets, model.SingleEnvironmentVariable{Key: key, Value: value})
+				}
+			}
+
+			expanded, problems := expandSecretReferences(secrets, testFetchFolder)
+
+			values := map[string]string{}
+			for _, secret := range expanded {
+				values[secret.Key] = secret.Value
+			}
+			if !reflect.DeepEqual(values, test.expected) {
+				t.Errorf("unexpected values %v", values)
+			}
+
+			var messages []string
+			for _, problem := range problems {
+				messages = append(messages, problem.Error())
+			}
+			if !reflect.DeepEqual(messages, test.problems) {
+				t.Errorf("unexpected problems %v", messages)
+			}
+		})
+	}
+}
+
+func TestExpandSecretReferencesFetchesEachFolderOnce(t *testing.T) {
+	fetchedFolders := []string{}
+	fetchFolder := func(environment string, secretPath string) (map[string]string, error) {
+		fetchedFolders = append(fetchedFolders, environment+":"+secretPath)
+		return testFetchFolder(environment, secretPath)
+	}
+
+	secrets := []model.SingleEnvironmentVariable{
+		{Key: "HOST", Value: "${prod.db.HOST}"},
+		{Key: "URL", Value: "${prod.db.URL}"},
+		{Key: "MISSING", Value: "${staging.KEY} ${staging.OTHER}"},
+	}
+	expandSecretReferences(secrets, fetchFolder)
+
+	if !reflect.DeepEqual(fetchedFolders, []string{"prod:/db", "staging:/"}) {
+		t.Errorf("expected each folder to be fetched once, got %v", fetchedFolders)
+	}
+}